@@ -0,0 +1,60 @@
+package jsonapi
+
+import (
+	"net/http"
+	"time"
+)
+
+// LogEntry describes a request served by HTTPHandler, see SetLogger
+type LogEntry struct {
+	Time     time.Time // when the request started
+	Method   string
+	URL      string // request uri, like "/api/user?id=1"
+	Route    string // API.Pattern of registered APIs, empty for others
+	RemoteIP string // see HTTP.ClientIP
+	Status   int    // 500 if handler panics before sending response
+	Bytes    int64  // size of response body sent, after compression
+	Duration time.Duration
+}
+
+var logger func(entry LogEntry)
+
+// SetLogger sets fn to be called after every request is served by HTTPHandler,
+// including the ones failed or panicked, for access logs. Nil disables it. It is
+// not safe to call while serving requests.
+//
+//     jsonapi.SetLogger(func(e jsonapi.LogEntry) {
+//         slog.Info("request", "method", e.Method, "url", e.URL, "status", e.Status,
+//             "bytes", e.Bytes, "duration", e.Duration)
+//     })
+func SetLogger(fn func(entry LogEntry)) {
+	logger = fn
+}
+
+// logRequest is deferred by ServeHTTP, panics are passed through after logging
+func (h *HTTP) logRequest(w *statusWriter, start time.Time) {
+	v := recover()
+	e := LogEntry{
+		Time:     start,
+		Status:   w.status,
+		Bytes:    w.written,
+		Duration: DefaultClock.Now().Sub(start),
+	}
+	if r := h.Request; r != nil {
+		e.Method, e.URL = r.Method, r.URL.RequestURI()
+		e.RemoteIP = h.ClientIP()
+	}
+	if h.api != nil {
+		e.Route = h.api.Pattern
+	}
+	switch {
+	case v != nil && e.Status == 0:
+		e.Status = http.StatusInternalServerError
+	case e.Status == 0:
+		e.Status = http.StatusOK
+	}
+	logger(e)
+	if v != nil {
+		panic(v)
+	}
+}
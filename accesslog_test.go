@@ -0,0 +1,79 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSetLogger(t *testing.T) {
+	clock, restore := withFakeClock()
+	defer restore()
+	var entries []LogEntry
+	SetLogger(func(e LogEntry) { entries = append(entries, e) })
+	defer SetLogger(nil)
+
+	m := NewMuxTest([]API{
+		{Pattern: "/api/user/{id}", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			clock.Advance(time.Second)
+			if httpData.Request.PathValue("id") == "0" {
+				return nil, E404.SetData("No such user")
+			}
+			return "ok", nil
+		}},
+	})
+	m.Do(httptest.NewRequest("GET", "/api/user/1?full=1", nil))
+	m.Do(httptest.NewRequest("GET", "/api/user/0", nil))
+	expect := []LogEntry{
+		{
+			Time: clock.Now().Add(-2 * time.Second), Method: "GET", URL: "/api/user/1?full=1", Route: "/api/user/{id}",
+			RemoteIP: "192.0.2.1", Status: http.StatusOK, Bytes: 5, Duration: time.Second,
+		},
+		{
+			Time: clock.Now().Add(-time.Second), Method: "GET", URL: "/api/user/0", Route: "/api/user/{id}",
+			RemoteIP: "192.0.2.1", Status: http.StatusNotFound, Bytes: 48, Duration: time.Second,
+		},
+	}
+	if len(entries) != len(expect) {
+		t.Fatalf("expected %d entries, got %+v", len(expect), entries)
+	}
+	for i, e := range expect {
+		if entries[i] != e {
+			t.Errorf("expected entry %+v, got %+v", e, entries[i])
+		}
+	}
+}
+
+func TestSetLoggerPanic(t *testing.T) {
+	var entries []LogEntry
+	SetLogger(func(e LogEntry) { entries = append(entries, e) })
+	defer SetLogger(nil)
+
+	serve := func(h HTTPHandler) (v interface{}) {
+		defer func() { v = recover() }()
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/panic", nil))
+		return nil
+	}
+	if v := serve(func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		panic("boom")
+	}); v != "boom" {
+		t.Errorf("panic is not passed through: %v", v)
+	}
+	serve(func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		httpData.WriteJSON(http.StatusAccepted, "queued")
+		panic("boom")
+	})
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+	if e := entries[0]; e.Status != http.StatusInternalServerError || e.Bytes != 0 || e.Method != "POST" || e.URL != "/panic" || e.Route != "" {
+		t.Errorf("unexpected entry %+v", e)
+	}
+	// status already sent is kept
+	if e := entries[1]; e.Status != http.StatusAccepted || e.Bytes != 9 {
+		t.Errorf("unexpected entry %+v", e)
+	}
+}
@@ -1,9 +1,16 @@
 package jsonapi
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // these codes are inspired by http://go-talks.appspot.com/github.com/broady/talks/web-frameworks-gophercon.slide#1
@@ -13,6 +20,11 @@ type Error struct {
 	Code    int
 	Message string
 	URL     string // url for 3xx redirect
+	Kind    string // machine readable error type like "response_too_large", optional
+	Cause   error  `json:"-"` // underlying error, never sent to client, see Wrap
+
+	// Details is sent to client along with Message, like a list of invalid fields
+	Details interface{}
 }
 
 // SetData creates a new Error instance and set the Message or URL property according to the error code
@@ -26,6 +38,40 @@ func (h Error) SetData(data string) Error {
 	return h
 }
 
+// Wrap creates a new Error instance carrying cause, so errors.Is and errors.As see
+// through it. The cause is reported to OnError with 5xx responses and shown in
+// DevMode, but never sent to client otherwise.
+//
+//     if errors.Is(err, sql.ErrNoRows) {
+//         return nil, jsonapi.E404.Wrap(err)
+//     }
+func (h Error) Wrap(cause error) Error {
+	h.Cause = cause
+	return h
+}
+
+// Unwrap returns Cause
+func (h Error) Unwrap() error {
+	return h.Cause
+}
+
+// WithDetails creates a new Error instance with Details, which is included in the
+// response body. The Error is sent without Details if they cannot be encoded.
+//
+//     return nil, jsonapi.Error{Code: 422, Message: "Validation failed"}.WithDetails(fieldErrors)
+func (h Error) WithDetails(details interface{}) Error {
+	h.Details = details
+	return h
+}
+
+// WithMessagef creates a new Error instance with Message formatted like fmt.Sprintf
+//
+//     return nil, jsonapi.E404.WithMessagef("User %d not found", id)
+func (h Error) WithMessagef(format string, args ...interface{}) Error {
+	h.Message = fmt.Sprintf(format, args...)
+	return h
+}
+
 func (h Error) Error() string {
 	ret := strconv.Itoa(h.Code)
 	if h.Message != "" {
@@ -35,14 +81,67 @@ func (h Error) Error() string {
 	return ret
 }
 
+// ErrorEncoder converts err into the value sent to client as body of the error
+// response with status code. It may also set response headers.
+type ErrorEncoder func(httpData *HTTP, code int, err error) interface{}
+
+var errorEncoder ErrorEncoder = StructuredErrorEncoder
+
+// SetErrorEncoder changes how errors are sent to clients. Passing nil restores the
+// default StructuredErrorEncoder.
+//
+//     jsonapi.SetErrorEncoder(jsonapi.ProblemEncoder)
+func SetErrorEncoder(f ErrorEncoder) {
+	if f == nil {
+		f = StructuredErrorEncoder
+	}
+	errorEncoder = f
+}
+
+// StringErrorEncoder sends err as a JSON string like "404: Resource not found",
+// which is the format of earlier versions.
+func StringErrorEncoder(httpData *HTTP, code int, err error) interface{} {
+	return err.Error()
+}
+
+// ErrorBody is the body of error responses sent by StructuredErrorEncoder
+type ErrorBody struct {
+	Error ErrorInfo `json:"error"`
+}
+
+// ErrorInfo describes an error in ErrorBody
+type ErrorInfo struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Kind    string      `json:"kind,omitempty"`
+	URL     string      `json:"url,omitempty"`     // destination of 3xx redirect, same as Location header
+	Details interface{} `json:"details,omitempty"` // Error.Details, with stack trace in DevMode
+}
+
+// StructuredErrorEncoder sends err as an ErrorBody, like
+//
+//     {"error": {"code": 404, "message": "Resource not found"}}
+//
+// Message of errors other than Error is err.Error().
+func StructuredErrorEncoder(httpData *HTTP, code int, err error) interface{} {
+	info := ErrorInfo{Code: code, Message: err.Error()}
+	if e, ok := err.(Error); ok {
+		info.Message, info.Kind, info.URL, info.Details = e.Message, e.Kind, e.URL, e.Details
+	}
+	if info.Message == "" {
+		info.Message = http.StatusText(code)
+	}
+	return ErrorBody{info}
+}
+
 // here are predefined error instances, you should call SetData before use it like
 //
 //     return nil, E404.SetData("User not found")
 //
-// You might noticed that here's no 500 error. You should just return a normal error
-// instance instead.
+// Normal errors are sent with 500 status code. E500 is for internal errors whose
+// message should not be sent to client, wrap the actual error with it.
 //
-//     return nil, errors.New("internal server error")
+//     return nil, jsonapi.E500.Wrap(err)
 var (
 	E301 = Error{Code: 301, Message: "Resource has been moved permanently"}
 	E302 = Error{Code: 302, Message: "Resource has bee found at another location"}
@@ -51,10 +150,32 @@ var (
 	E401 = Error{Code: 401, Message: "You have to be authorized before accessing this resource"}
 	E403 = Error{Code: 403, Message: "You have no right to access this resource"}
 	E404 = Error{Code: 404, Message: "Resource not found"}
+	E405 = Error{Code: 405, Message: "Method not allowed"}
+	E409 = Error{Code: 409, Message: "Request conflicts with current state of the resource"}
+	E410 = Error{Code: 410, Message: "Resource is gone"}
+	E412 = Error{Code: 412, Message: "Precondition failed"}
+	E415 = Error{Code: 415, Message: "Unsupported media type"}
 	E418 = Error{Code: 418, Message: "I'm a teapot"}
-	E504 = Error{Code: 504, Message: "Service unavailable"}
+	E422 = Error{Code: 422, Message: "Unprocessable entity"}
+	E429 = Error{Code: 429, Message: "Too many requests"}
+	E500 = Error{Code: 500, Message: "Internal server error"}
+	E501 = Error{Code: 501, Message: "Not implemented"}
+	E502 = Error{Code: 502, Message: "Bad gateway"}
+	E503 = Error{Code: 503, Message: "Service unavailable"}
+	E504 = Error{Code: 504, Message: "Gateway timeout"}
 )
 
+// NewError creates an Error with status code and message
+func NewError(code int, msg string) Error {
+	return Error{Code: code, Message: msg}
+}
+
+// FromStatus creates an Error with status code and its standard text as message,
+// like "Not Found" for 404
+func FromStatus(code int) Error {
+	return Error{Code: code, Message: http.StatusText(code)}
+}
+
 // APIHandler is easy to use entry for API developer.
 //
 // Just return something, and it will be encoded to JSON format and send to client.
@@ -68,51 +189,508 @@ var (
 //         return doSomething(param), nil
 //     }
 //
+// Return json.RawMessage to send JSON encoded already as is, or Raw for other
+// formats like CSV.
+//
 // To redirect clients, return 3xx status code and set Data property
 //
 //     return nil, jsonapi.Error{http.StatusBadRequst, "http://google.com"}
 type APIHandler func(dec *json.Decoder, httpData *HTTP) (interface{}, error)
 
+// responder is implemented by results which write the response on their own
+// instead of being encoded into JSON format.
+type responder interface {
+	respond(httpData *HTTP)
+}
+
 // Handler acts as jsonapi.Handler
 func (h APIHandler) Handler(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+	dec, err := httpData.checkBody(dec)
+	if err != nil {
+		writeError(enc, httpData, err)
+		return
+	}
+
 	res, err := h(dec, httpData)
+	if httpData.replied {
+		return
+	}
+	if _, ok := err.(Error); !ok && err != nil {
+		if e, ok := unknownField(err); ok {
+			err = e
+		}
+	}
+	status := http.StatusOK
+	if s, ok := res.(StatusResult); ok && err == nil {
+		res = s.Body
+		if s.Code != 0 {
+			status = s.Code
+		}
+		if s.Location != "" {
+			httpData.ResponseWriter.Header().Set("Location", redirectLocation(httpData.Request, s.Location))
+		}
+	}
 	if err == nil {
-		if err := enc.Encode(res); err != nil {
-			httpData.WriteHeader(http.StatusInternalServerError)
-			enc.Encode("Cannot encode response into JSON format, please contact the administrator.")
+		if r, ok := res.(responder); ok {
+			r.respond(httpData)
+			return
 		}
+		res, err = httpData.onResponse(res)
+	}
+	if err == nil && bodyless(status) {
+		httpData.ResponseWriter.Header().Del("Content-Type")
+		httpData.WriteHeader(status)
 		return
 	}
+	if err == nil {
+		limit := httpData.maxResponseBytes()
+		var buf []byte
+		var encErr error
+		raw, isRaw := res.(json.RawMessage)
+		rw := rewriterFor(httpData)
+		if !isRaw && limit <= 0 {
+			res = rw.rewrite(res)
+		}
+		switch {
+		case isRaw && !json.Valid(raw):
+			encErr = errors.New("jsonapi: invalid json.RawMessage returned by handler")
+		case isRaw && limit > 0 && int64(len(raw)) > limit:
+			encErr = responseTooLarge(int64(len(raw)), limit)
+		case isRaw:
+			// sent byte for byte
+			buf = raw
+		case limit <= 0:
+			// buffered, so status code can still be changed if encoding fails
+			b := &bytes.Buffer{}
+			encErr = newEncoder(b).Encode(res)
+			buf = b.Bytes()
+		default:
+			// rewritten while encoding, so nothing is copied beyond the limit
+			buf, encErr = encodeLimited(res, rw, limit)
+		}
+		if encErr == nil && !isRaw {
+			buf = httpData.pretty(buf)
+		}
+		if encErr == nil && httpData.notModified(status, buf) {
+			httpData.ResponseWriter.Header().Del("Content-Type")
+			httpData.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if encErr == nil {
+			httpData.ResponseWriter.Header().Set("Content-Length", strconv.Itoa(len(buf)))
+			httpData.WriteHeader(status)
+			httpData.ResponseWriter.Write(buf)
+			return
+		}
+		if e, ok := encErr.(Error); !ok || e.Kind != KindResponseTooLarge {
+			writeInternalError(httpData, errEncodeResponse, encErr)
+			return
+		}
+		err = encErr
+	}
+
+	writeError(enc, httpData, err)
+}
+
+// APIHandlerCtx is APIHandler receiving context of the request, which is canceled
+// when client disconnects, so long-running handlers can abort.
+//
+//     func getUser(ctx context.Context, dec *json.Decoder, httpData *jsonapi.HTTP) (interface{}, error) {
+//         return db.QueryUser(ctx, httpData.Request.PathValue("id"))
+//     }
+type APIHandlerCtx func(ctx context.Context, dec *json.Decoder, httpData *HTTP) (interface{}, error)
+
+// APIHandler converts h into APIHandler
+func (h APIHandlerCtx) APIHandler() APIHandler {
+	return func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return h(httpData.Request.Context(), dec, httpData)
+	}
+}
+
+// Handler acts as jsonapi.Handler
+func (h APIHandlerCtx) Handler(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+	h.APIHandler().Handler(enc, dec, httpData)
+}
+
+// ResponseHook modifies result returned by APIHandler before it is encoded, like
+// adding meta data or filtering fields. Returned error is sent to client instead.
+type ResponseHook func(httpData *HTTP, result interface{}) (interface{}, error)
+
+// OnResponse are hooks run in order on successful results of every APIHandler,
+// followed by API.OnResponse of the route. Results writing response on their own,
+// like Proxy and MultiStatus, are not passed to hooks.
+//
+//     jsonapi.OnResponse = append(jsonapi.OnResponse, func(httpData *jsonapi.HTTP, result interface{}) (interface{}, error) {
+//         return map[string]interface{}{"data": result, "meta": meta(httpData)}, nil
+//     })
+var OnResponse []ResponseHook
+
+// onResponse runs ResponseHooks on res
+func (h *HTTP) onResponse(res interface{}) (interface{}, error) {
+	hooks := OnResponse
+	if h.api != nil && len(h.api.OnResponse) > 0 {
+		hooks = append(hooks[:len(hooks):len(hooks)], h.api.OnResponse...)
+	}
+	for _, hook := range hooks {
+		var err error
+		if res, err = hook(h, res); err != nil {
+			return nil, err
+		}
+	}
+	return res, nil
+}
+
+// StatusClientClosedRequest is sent instead of 500 when handler returns
+// context.Canceled because client has disconnected. It is not defined by RFC,
+// but used by nginx for the same purpose.
+const StatusClientClosedRequest = 499
+
+// errEncodeResponse is sent when result of APIHandler cannot be encoded
+var errEncodeResponse = Error{
+	Code:    http.StatusInternalServerError,
+	Message: "Cannot encode response into JSON format, please contact the administrator.",
+}
 
+// writeInternalError sends public to client, while cause is reported to OnError
+func writeInternalError(httpData *HTTP, public Error, cause error) {
+	httpData.failure = cause
+	traced := reportError(httpData, public.Code, cause)
+	body := rewriterFor(httpData).rewrite(devBody(errorEncoder(httpData, public.Code, public), public.Wrap(cause), traced))
+	httpData.WriteHeader(public.Code)
+	httpData.encoder().Encode(body)
+}
+
+// writeError sends err to client
+func writeError(enc *json.Encoder, httpData *HTTP, err error) {
 	code := http.StatusInternalServerError
+	if errors.Is(err, context.Canceled) && httpData.Request.Context().Err() != nil {
+		// client has gone, it is not a failure of server
+		code = StatusClientClosedRequest
+	}
+	if errors.Is(err, context.DeadlineExceeded) && httpData.Request.Context().Err() == context.DeadlineExceeded {
+		// deadline of the request, like the one asked by client, has passed
+		err, code = errTimeout, errTimeout.Code
+	}
 	if httperr, ok := err.(Error); ok {
 		code = httperr.Code
 		if code >= 300 && code < 400 && httperr.URL != "" {
-			// 3xx redirect
-			http.Redirect(httpData.ResponseWriter, httpData.Request, httperr.URL, code)
-			enc.Encode(err.Error())
+			// 3xx redirect, body is encoded with the url in Location header
+			httperr.URL = redirectLocation(httpData.Request, httperr.URL)
+			if HTMLRedirect {
+				httpData.ResponseWriter.Header().Del("Content-Type")
+				http.Redirect(httpData.ResponseWriter, httpData.Request, httperr.URL, code)
+				return
+			}
+			body := rewriterFor(httpData).rewrite(errorEncoder(httpData, code, httperr))
+			httpData.ResponseWriter.Header().Set("Location", httperr.URL)
+			httpData.WriteHeader(code)
+			httpData.configureEncoder(enc).Encode(body)
 			return
 		}
 	}
 
+	if e := httpData.bodyErr(); e != nil && (code == http.StatusBadRequest || code == http.StatusInternalServerError) {
+		// failed to read request body, like the one cut at MaxBodyBytes
+		err, code = *e, e.Code
+	}
+	httpData.failure = err
+
+	traced := reportError(httpData, code, err)
+	body := rewriterFor(httpData).rewrite(devBody(errorEncoder(httpData, code, err), err, traced))
+	if e, ok := err.(Error); ok && e.Details != nil {
+		if _, mErr := json.Marshal(body); mErr != nil {
+			// falls back to message-only form
+			e.Details = nil
+			body = rewriterFor(httpData).rewrite(devBody(errorEncoder(httpData, code, e), e, traced))
+		}
+	}
 	httpData.WriteHeader(code)
-	enc.Encode(err.Error())
+	httpData.configureEncoder(enc).Encode(body)
+}
+
+// HTMLRedirect makes redirects sent by returning 3xx Error exactly what http.Redirect
+// sends, which has a short HTML body for GET requests. By default, the body is the
+// Error encoded in JSON format.
+var HTMLRedirect bool
+
+// redirectLocation resolves u against the request url, so relative references like
+// "../list" or "?page=2" work like they do in browsers
+func redirectLocation(r *http.Request, u string) string {
+	ref, err := url.Parse(u)
+	if err != nil || ref.IsAbs() || ref.Host != "" {
+		return u
+	}
+	base := &url.URL{Path: r.URL.Path, RawPath: r.URL.RawPath, RawQuery: r.URL.RawQuery}
+	return base.ResolveReference(ref).String()
 }
 
 // API denotes how a json api handler registers to a servemux
 type API struct {
 	Pattern    string
 	APIHandler APIHandler
+
+	// Methods restricts this API to listed methods, others get 405 Method Not
+	// Allowed, and OPTIONS requests get the Allow header. HEAD is allowed with GET.
+	// APIs with same Pattern but different Methods can be registered together.
+	// Empty Methods allows all methods.
+	Methods []string
+
+	// APIHandlerCtx is used if APIHandler is nil
+	APIHandlerCtx APIHandlerCtx
+
+	// Name identifies this route when building URLs to it, see URLFor
+	Name string
+
+	// documentation of this route, see GenerateMarkdown
+	Description string
+	Scopes      []string    // scopes of credential needed to call this route
+	Request     interface{} // sample value of request type
+	Response    interface{} // sample value of response type
+	Examples    []Example
+	Errors      []string // kinds of Error this route may return
+
+	// MaxResponseBytes overrides package-level MaxResponseBytes if > 0
+	MaxResponseBytes int64
+
+	// MaxBodyBytes overrides package-level MaxBodyBytes if not zero, negative
+	// means no limit
+	MaxBodyBytes int64
+
+	// Timeout overrides DefaultTimeout if not zero, negative means no limit
+	Timeout time.Duration
+
+	// StrictBody and AllowTrailingData override package-level StrictBody
+	StrictBody        bool
+	AllowTrailingData bool
+
+	// StrictFields and UseNumber enable options of DefaultDecoderOptions for this route
+	StrictFields bool
+	UseNumber    bool
+
+	// RejectDuplicateKeys and AllowDuplicateKeys override package-level RejectDuplicateKeys
+	RejectDuplicateKeys bool
+	AllowDuplicateKeys  bool
+
+	// FoldDuplicateKeys enables package-level FoldDuplicateKeys for this route
+	FoldDuplicateKeys bool
+
+	// ValidateUTF8 enables package-level ValidateUTF8 for this route
+	ValidateUTF8 bool
+
+	// RequireJSONContentType enables package-level RequireJSONContentType for this
+	// route
+	RequireJSONContentType bool
+
+	// MaxDepth overrides package-level MaxDepth if not zero
+	MaxDepth int
+
+	// Int64AsString enables package-level Int64AsString for this route
+	Int64AsString bool
+
+	// OmitZero overrides package-level OmitZero if not OmitNone
+	OmitZero OmitPolicy
+
+	// Deprecated is the date this API will be removed, like "2025-12-31". Clients
+	// calling a deprecated API get a Warning header, see also OnDeprecated.
+	Deprecated string
+
+	// ETag sends ETag computed from the response body of successful GET requests,
+	// and answers 304 Not Modified if it matches If-None-Match of the request.
+	// Responses in other codecs or compressed get tags with suffixes like "-xml"
+	// and "-gzip", like static files.
+	ETag bool
+
+	// Encodings enables response compression for this route with only listed
+	// encodings, like "gzip", see Compression
+	Encodings []string
+
+	// Gone retires this route, which answers 410 instead of calling APIHandler,
+	// see RegisterGone
+	Gone *GoneInfo
+
+	// CoverageExempt excludes this route from ReportCoverage
+	CoverageExempt bool
+
+	// OnResponse are hooks run after package-level OnResponse
+	OnResponse []ResponseHook
+
+	// RequiredHeaders are checked before calling APIHandler, problems of all
+	// headers are reported at once.
+	RequiredHeaders []HeaderRule
+
+	// Headers are set on every response of this route before calling APIHandler,
+	// including errors, like Cache-Control or SecurityHeaders. Handlers can still
+	// override them.
+	Headers http.Header
+
+	// Middlewares wrap this route, see Chain
+	Middlewares []Middleware
+
+	// CORS allows cross-origin requests to this route, see CORS. It runs before
+	// Middlewares, and answers preflight requests even if Methods has no OPTIONS.
+	// Methods of the options defaults to Methods of the API.
+	CORS *CORSOptions
+
+	instrumentation Instrumentation                 // set by RegisterWith
+	onError         func(httpData *HTTP, err error) // set by RegisterWith
+	tracer          Tracer                          // set by RegisterWith
 }
 
-// Register helps you to register many APIHandlers to a http.ServeMux
+// handler creates HTTPHandler serving api with its own options
+func (api API) handler() HTTPHandler {
+	if api.APIHandler == nil && api.APIHandlerCtx != nil {
+		api.APIHandler = api.APIHandlerCtx.APIHandler()
+	}
+	if api.Gone != nil {
+		info := *api.Gone
+		api.APIHandler = func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return info, nil
+		}
+	}
+	headers := compileHeaderRules(api.RequiredHeaders)
+	h := Chain(func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		if api.Encodings != nil {
+			if err := httpData.checkEncoding(); err != nil {
+				writeError(enc, httpData, err)
+				return
+			}
+		}
+		if err := checkHeaders(httpData.Request, headers); err != nil {
+			writeError(enc, httpData, err)
+			return
+		}
+		if api.RequireJSONContentType && !acceptableBody(httpData.Request) {
+			httpData.RejectEarly(errUnsupportedMediaType)
+			return
+		}
+		if n := httpData.maxBodyBytes(); n > 0 && httpData.Request.ContentLength > n {
+			httpData.RejectEarly(errRequestTooLarge)
+			return
+		}
+		if api.Deprecated != "" {
+			httpData.deprecated("API", api.Pattern, api.Deprecated)
+		}
+		httpData.decoderOptions().apply(dec)
+		api.APIHandler.Handler(enc, dec, httpData)
+	}, api.middlewares()...)
+	withTimeout := func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		if d := httpData.timeout(); d > 0 {
+			httpData.runWithTimeout(d, h, dec)
+			return
+		}
+		h(enc, dec, httpData)
+	}
+	return func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		httpData.api = &api
+		setHeaders(httpData.ResponseWriter, api.Headers)
+		if TrackCoverage {
+			recordCoverage(api.Pattern, httpData.Request.Method)
+		}
+		serve := withTimeout
+		inst := api.instrumentation
+		if inst == nil {
+			inst = DefaultInstrumentation
+		}
+		if inst != nil {
+			serve = instrument(inst, api.Pattern, serve)
+		}
+		tracer := api.tracer
+		if tracer == nil {
+			tracer = DefaultTracer
+		}
+		if tracer != nil && tracer != Tracer(NopTracer{}) {
+			serve = trace(tracer, api.Pattern, serve)
+		}
+		serve(enc, dec, httpData)
+	}
+}
+
+// middlewares returns Middlewares, with CORS in front if enabled
+func (api API) middlewares() []Middleware {
+	if api.CORS == nil {
+		return api.Middlewares
+	}
+	opts := *api.CORS
+	if len(opts.Methods) == 0 && len(api.Methods) > 0 {
+		opts.Methods = api.Methods
+	}
+	return append([]Middleware{CORS(opts)}, api.Middlewares...)
+}
+
+// Register helps you to register many APIHandlers to a http.ServeMux, which is
+// http.DefaultServeMux if mux is nil.
+//
+// Requests matching none of the routes still get plain text 404 and 405 responses
+// of net/http. To send them in JSON format, serve with a Mux, or call
+// RegisterFallback on mux as well. Register does not install the fallback itself,
+// because its catch-all "/" pattern would conflict with a "/" route registered later.
 func Register(apis []API, mux *http.ServeMux) {
 	reg := http.Handle
 	if mux != nil {
 		reg = mux.Handle
 	}
 
+	var patterns []string // keeps the order of registration
+	byMethods := map[string][]API{}
 	for _, api := range apis {
-		reg(api.Pattern, HTTPHandler(api.APIHandler.Handler))
+		addRoute(&api)
+		if len(api.Methods) == 0 {
+			reg(api.Pattern, api.handler())
+			continue
+		}
+		if _, ok := byMethods[api.Pattern]; !ok {
+			patterns = append(patterns, api.Pattern)
+		}
+		byMethods[api.Pattern] = append(byMethods[api.Pattern], api)
+	}
+	for _, p := range patterns {
+		reg(p, methodHandler(byMethods[p]))
+	}
+}
+
+// methodHandler dispatches requests to apis according to API.Methods
+func methodHandler(apis []API) HTTPHandler {
+	handlers := map[string]HTTPHandler{}
+	preflight := map[string]HTTPHandler{} // handlers with CORS, see API.CORS
+	var allowed []string
+	for _, api := range apis {
+		h := api.handler()
+		for _, m := range api.Methods {
+			m = strings.ToUpper(m)
+			if _, ok := handlers[m]; !ok {
+				allowed = append(allowed, m)
+			}
+			handlers[m] = h
+			if api.CORS != nil {
+				preflight[m] = h
+			}
+		}
+	}
+	if _, ok := handlers["GET"]; ok {
+		if _, ok := handlers["HEAD"]; !ok {
+			handlers["HEAD"] = handlers["GET"]
+			allowed = append(allowed, "HEAD")
+		}
+	}
+	if _, ok := handlers["OPTIONS"]; !ok {
+		allowed = append(allowed, "OPTIONS")
+	}
+	allow := strings.Join(allowed, ", ")
+
+	return func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		if h, ok := handlers[httpData.Request.Method]; ok {
+			h(enc, dec, httpData)
+			return
+		}
+		if h, ok := preflight[strings.ToUpper(httpData.Request.Header.Get("Access-Control-Request-Method"))]; ok && isPreflight(httpData.Request) {
+			h(enc, dec, httpData)
+			return
+		}
+		httpData.ResponseWriter.Header().Set("Allow", allow)
+		if httpData.Request.Method == "OPTIONS" {
+			httpData.WriteHeader(http.StatusNoContent)
+			return
+		}
+		APIHandler(DefaultMethodNotAllowed).Handler(enc, dec, httpData)
 	}
 }
@@ -0,0 +1,476 @@
+package jsonapi
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOnResponse(t *testing.T) {
+	var calls []string
+	OnResponse = []ResponseHook{func(httpData *HTTP, result interface{}) (interface{}, error) {
+		calls = append(calls, "global")
+		return map[string]interface{}{"data": result, "meta": map[string]string{"route": httpData.api.Pattern}}, nil
+	}}
+	defer func() { OnResponse = nil }()
+
+	m := NewMuxTest([]API{
+		{Pattern: "/user", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return map[string]string{"role": "ADMIN"}, nil
+		}, OnResponse: []ResponseHook{func(httpData *HTTP, result interface{}) (interface{}, error) {
+			calls = append(calls, "api")
+			// sees result of global hook
+			data := result.(map[string]interface{})["data"].(map[string]string)
+			data["role"] = strings.ToLower(data["role"])
+			return result, nil
+		}}},
+		{Pattern: "/secret", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return map[string]string{"token": "xyz"}, nil
+		}, OnResponse: []ResponseHook{func(httpData *HTTP, result interface{}) (interface{}, error) {
+			calls = append(calls, "deny")
+			return nil, E403.SetData("not visible")
+		}, func(httpData *HTTP, result interface{}) (interface{}, error) {
+			calls = append(calls, "unreachable")
+			return result, nil
+		}}},
+		{Pattern: "/failed", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return nil, E404
+		}},
+		{Pattern: "/bulk", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			var ret MultiStatus
+			ret.Succeed(0, "ok")
+			return &ret, nil
+		}},
+	})
+
+	resp, _ := m.Get("/user", "")
+	if s := strings.TrimSpace(resp.Body.String()); s != `{"data":{"role":"admin"},"meta":{"route":"/user"}}` {
+		t.Errorf("unexpected response: %s", s)
+	}
+	if strings.Join(calls, ",") != "global,api" {
+		t.Errorf("unexpected order of hooks: %v", calls)
+	}
+
+	calls = nil
+	resp, _ = m.Get("/secret", "")
+	var body ErrorBody
+	json.Unmarshal(resp.Body.Bytes(), &body)
+	if resp.Code != http.StatusForbidden || body.Error.Message != "not visible" || strings.Contains(resp.Body.String(), "xyz") {
+		t.Errorf("error of hook is not sent: %d %s", resp.Code, resp.Body)
+	}
+	if strings.Join(calls, ",") != "global,deny" {
+		t.Errorf("unexpected hooks called: %v", calls)
+	}
+
+	calls = nil
+	m.Get("/failed", "")
+	m.Get("/bulk", "")
+	if len(calls) != 0 {
+		t.Errorf("hooks run on errors or responders: %v", calls)
+	}
+}
+
+func TestAPIHandlerCtx(t *testing.T) {
+	started, canceled := make(chan bool), make(chan error, 1)
+	m := NewMuxTest([]API{{Pattern: "/slow", APIHandlerCtx: func(ctx context.Context, dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		close(started)
+		select {
+		case <-ctx.Done():
+			canceled <- ctx.Err()
+			return nil, ctx.Err()
+		case <-time.After(5 * time.Second):
+			return "finished", nil
+		}
+	}}})
+	srv := m.StartServer()
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL+"/slow", nil)
+	go func() {
+		<-started
+		cancel()
+	}()
+	if _, err := srv.Client().Do(req); err == nil {
+		t.Fatal("request is not canceled")
+	}
+	select {
+	case err := <-canceled:
+		if err != context.Canceled {
+			t.Errorf("unexpected error of context: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ctx.Done() is not fired after client disconnected")
+	}
+}
+
+func TestAPIHandlerCtxHandler(t *testing.T) {
+	h := APIHandlerCtx(func(ctx context.Context, dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	resp, _ := HandlerTest(h.Handler).With(CancelAfter(10*time.Millisecond)).Get("/", "")
+	if resp.Code != StatusClientClosedRequest {
+		t.Errorf("expected %d, got %d %s", StatusClientClosedRequest, resp.Code, resp.Body)
+	}
+
+	resp, _ = NewMuxTest([]API{{Pattern: "/", APIHandlerCtx: func(ctx context.Context, dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return ctx == httpData.Request.Context(), nil
+	}}}).Get("/", "")
+	if strings.TrimSpace(resp.Body.String()) != "true" {
+		t.Errorf("ctx is not context of request: %s", resp.Body)
+	}
+}
+
+var errorAPIs = []API{
+	{Pattern: "/missing", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return nil, E404.SetData("User not found")
+	}},
+	{Pattern: "/plain", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return nil, errors.New("database is down")
+	}},
+	{Pattern: "/empty", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return nil, Error{Code: http.StatusConflict}
+	}},
+	{Pattern: "/api/old/", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return nil, E301.SetData("../new/user")
+	}},
+}
+
+func TestStructuredErrors(t *testing.T) {
+	m := NewMuxTest(errorAPIs)
+	cases := []struct {
+		uri    string
+		code   int
+		expect ErrorInfo
+	}{
+		{"/missing", 404, ErrorInfo{Code: 404, Message: "User not found"}},
+		{"/plain", 500, ErrorInfo{Code: 500, Message: "database is down"}},
+		{"/empty", 409, ErrorInfo{Code: 409, Message: "Conflict"}},
+		{"/api/old/user", 301, ErrorInfo{Code: 301, Message: "Resource has been moved permanently", URL: "/api/new/user"}},
+	}
+	for _, c := range cases {
+		resp, _ := m.Get(c.uri, "")
+		var body ErrorBody
+		if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+			t.Errorf("%s: error is not structured: %s", c.uri, resp.Body)
+			continue
+		}
+		if resp.Code != c.code || body.Error != c.expect {
+			t.Errorf("%s: unexpected error %d %+v", c.uri, resp.Code, body.Error)
+		}
+		if loc := resp.Header().Get("Location"); loc != c.expect.URL {
+			t.Errorf("%s: Location %q does not agree with body", c.uri, loc)
+		}
+	}
+}
+
+func TestSetErrorEncoder(t *testing.T) {
+	SetErrorEncoder(StringErrorEncoder)
+	defer SetErrorEncoder(nil)
+
+	m := NewMuxTest(errorAPIs)
+	for uri, expect := range map[string]string{
+		"/missing":      `"404: User not found"`,
+		"/plain":        `"database is down"`,
+		"/api/old/user": `"301: Resource has been moved permanently"`,
+	} {
+		resp, _ := m.Get(uri, "")
+		if s := strings.TrimSpace(resp.Body.String()); s != expect {
+			t.Errorf("%s: expected %s, got %s", uri, expect, s)
+		}
+	}
+
+	SetErrorEncoder(func(httpData *HTTP, code int, err error) interface{} {
+		httpData.ResponseWriter.Header().Set("X-Error-Code", strconv.Itoa(code))
+		return map[string]int{"status": code}
+	})
+	resp, _ := m.Get("/missing", "")
+	if s := strings.TrimSpace(resp.Body.String()); s != `{"status":404}` || resp.Header().Get("X-Error-Code") != "404" {
+		t.Errorf("custom encoder is not used: %s", s)
+	}
+
+	SetErrorEncoder(nil)
+	resp, _ = m.Get("/missing", "")
+	if !strings.HasPrefix(resp.Body.String(), `{"error":`) {
+		t.Errorf("default encoder is not restored: %s", resp.Body)
+	}
+}
+
+func TestMethods(t *testing.T) {
+	handled := func(name string) APIHandler {
+		return func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return name, nil
+		}
+	}
+	m := NewMuxTest([]API{
+		{Pattern: "/api/user", Methods: []string{"GET"}, APIHandler: handled("get")},
+		{Pattern: "/api/user", Methods: []string{"post", "PUT"}, APIHandler: handled("save")},
+		{Pattern: "/api/any", APIHandler: handled("any")},
+	})
+
+	cases := []struct {
+		method, uri string
+		code        int
+		body        string
+	}{
+		{"GET", "/api/user", 200, `"get"`},
+		{"HEAD", "/api/user", 200, `"get"`}, // body is dropped by server, not recorder
+		{"POST", "/api/user", 200, `"save"`},
+		{"PUT", "/api/user", 200, `"save"`},
+		{"DELETE", "/api/user", 405, `{"error":{"code":405,"message":"Method not allowed"}}`},
+		{"OPTIONS", "/api/user", 204, ``},
+		{"DELETE", "/api/any", 200, `"any"`},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest(c.method, c.uri, nil)
+		resp := m.Do(req)
+		if resp.Code != c.code || strings.TrimSpace(resp.Body.String()) != c.body {
+			t.Errorf("%s %s: unexpected response %d: %s", c.method, c.uri, resp.Code, resp.Body)
+		}
+		allow := resp.Header().Get("Allow")
+		if c.code == 200 && allow != "" {
+			t.Errorf("%s %s: unexpected Allow header %q", c.method, c.uri, allow)
+		}
+		if c.code != 200 && allow != "GET, POST, PUT, HEAD, OPTIONS" {
+			t.Errorf("%s %s: unexpected Allow header %q", c.method, c.uri, allow)
+		}
+	}
+}
+
+type failingMarshaler struct{}
+
+func (failingMarshaler) MarshalJSON() ([]byte, error) {
+	return nil, errors.New("cannot marshal")
+}
+
+func TestUnencodableResponse(t *testing.T) {
+	var reported []error
+	OnError = func(httpData *HTTP, err error) { reported = append(reported, err) }
+	defer func() { OnError = nil }()
+
+	result := func(v interface{}) APIHandler {
+		return func(dec *json.Decoder, httpData *HTTP) (interface{}, error) { return v, nil }
+	}
+	m := NewMuxTest([]API{
+		{Pattern: "/chan", APIHandler: result(make(chan int))},
+		{Pattern: "/func", APIHandler: result(func() {})},
+		{Pattern: "/partial", APIHandler: result([]interface{}{"valid", "items", failingMarshaler{}})},
+		{Pattern: "/ok", APIHandler: result(map[string]string{"name": "john"})},
+	})
+
+	for _, uri := range []string{"/chan", "/func", "/partial"} {
+		resp, _ := m.Get(uri, "")
+		var body ErrorBody
+		dec := json.NewDecoder(resp.Body)
+		if err := dec.Decode(&body); err != nil || dec.More() {
+			t.Errorf("%s: body is not a single JSON error: %s", uri, resp.Body)
+			continue
+		}
+		if resp.Code != http.StatusInternalServerError || body.Error.Message != errEncodeResponse.Message {
+			t.Errorf("%s: unexpected response %d: %+v", uri, resp.Code, body)
+		}
+	}
+	if len(reported) != 3 || !strings.Contains(reported[2].Error(), "cannot marshal") {
+		t.Errorf("encoding errors are not reported: %v", reported)
+	}
+
+	resp, _ := m.Get("/ok", "")
+	if cl := resp.Header().Get("Content-Length"); resp.Code != http.StatusOK || cl != strconv.Itoa(resp.Body.Len()) {
+		t.Errorf("unexpected Content-Length %s of %q", cl, resp.Body)
+	}
+}
+
+func TestErrorWrap(t *testing.T) {
+	var reported []error
+	OnError = func(httpData *HTTP, err error) { reported = append(reported, err) }
+	defer func() { OnError = nil }()
+
+	wrapped := E404.Wrap(sql.ErrNoRows)
+	if !errors.Is(wrapped, sql.ErrNoRows) || errors.Unwrap(wrapped) != sql.ErrNoRows {
+		t.Errorf("cause is not found by errors.Is")
+	}
+	var e Error
+	if !errors.As(fmt.Errorf("repository: %w", wrapped), &e) || e.Code != 404 {
+		t.Errorf("Error is not found by errors.As")
+	}
+	if msg := E404.WithMessagef("User %d not found", 42).Message; msg != "User 42 not found" {
+		t.Errorf("unexpected message %q", msg)
+	}
+
+	m := NewMuxTest([]API{
+		{Pattern: "/user", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return nil, E404.WithMessagef("User %d not found", 42).Wrap(sql.ErrNoRows)
+		}},
+		{Pattern: "/db", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return nil, E500.Wrap(errors.New("dial tcp 10.0.0.1:5432: connection refused"))
+		}},
+	})
+	resp, _ := m.Get("/user", "")
+	if s := strings.TrimSpace(resp.Body.String()); s != `{"error":{"code":404,"message":"User 42 not found"}}` {
+		t.Errorf("unexpected response %s", s)
+	}
+	resp, _ = m.Get("/db", "")
+	if strings.Contains(resp.Body.String(), "10.0.0.1") || resp.Code != http.StatusInternalServerError {
+		t.Errorf("cause is leaked to client: %s", resp.Body)
+	}
+	if len(reported) != 1 || !errors.As(reported[0], &e) || e.Cause == nil || !strings.Contains(e.Cause.Error(), "connection refused") {
+		t.Errorf("cause is not reported: %v", reported)
+	}
+
+	DevMode = true
+	defer func() { DevMode = false }()
+	resp, _ = m.Get("/db", "")
+	if !strings.Contains(resp.Body.String(), `"cause":"dial tcp 10.0.0.1:5432: connection refused"`) {
+		t.Errorf("cause is not shown in DevMode: %s", resp.Body)
+	}
+}
+
+type fieldError struct {
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+func TestErrorDetails(t *testing.T) {
+	m := NewMuxTest([]API{
+		{Pattern: "/validate", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return nil, Error{Code: 422, Message: "Validation failed"}.WithDetails([]fieldError{
+				{"name", "required"},
+				{"age", "must be positive"},
+			})
+		}},
+		{Pattern: "/broken", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return nil, Error{Code: 422, Message: "Validation failed"}.WithDetails(map[string]interface{}{"ch": make(chan int)})
+		}},
+	})
+
+	var body struct {
+		Error struct {
+			Code    int          `json:"code"`
+			Message string       `json:"message"`
+			Details []fieldError `json:"details"`
+		} `json:"error"`
+	}
+	resp, _ := m.Get("/validate", "")
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != 422 || body.Error.Message != "Validation failed" || len(body.Error.Details) != 2 ||
+		body.Error.Details[1] != (fieldError{"age", "must be positive"}) {
+		t.Errorf("unexpected response %d: %s", resp.Code, resp.Body)
+	}
+
+	resp, _ = m.Get("/broken", "")
+	if s := strings.TrimSpace(resp.Body.String()); resp.Code != 422 || s != `{"error":{"code":422,"message":"Validation failed"}}` {
+		t.Errorf("expected message-only form, got %d: %s", resp.Code, s)
+	}
+
+	srv := m.StartServer()
+	defer srv.Close()
+	err := NewClient(srv.URL).Call(context.Background(), "/validate", nil, nil)
+	if e, ok := err.(Error); !ok || e.Code != 422 || fmt.Sprint(e.Details) != "[map[field:name reason:required] map[field:age reason:must be positive]]" {
+		t.Errorf("details are not decoded by Client: %#v", err)
+	}
+
+	if e := E404.SetData("gone"); e.Message != "gone" || e.Details != nil {
+		t.Errorf("SetData changes: %+v", e)
+	}
+}
+
+func TestPredefinedErrors(t *testing.T) {
+	errs := []Error{
+		E400, E401, E403, E404, E405, E409, E410, E412, E415, E418, E422, E429,
+		E500, E501, E502, E503, E504,
+		NewError(451, "Blocked by court order"), FromStatus(http.StatusPaymentRequired),
+	}
+	for _, e := range errs {
+		e := e
+		resp, _ := HandlerTest(APIHandler(func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return nil, e
+		}).Handler).Get("/", "")
+		var body ErrorBody
+		json.Unmarshal(resp.Body.Bytes(), &body)
+		if resp.Code != e.Code || body.Error.Code != e.Code || body.Error.Message != e.Message || e.Message == "" {
+			t.Errorf("%d: unexpected response %d: %s", e.Code, resp.Code, resp.Body)
+		}
+	}
+	for _, e := range []Error{E301, E302, E307} {
+		resp, _ := HandlerTest(APIHandler(func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return nil, e.SetData("/elsewhere")
+		}).Handler).Get("/", "")
+		if resp.Code != e.Code || resp.Header().Get("Location") != "/elsewhere" {
+			t.Errorf("%d: unexpected redirect %d %v", e.Code, resp.Code, resp.Header())
+		}
+	}
+
+	if E503.Message != "Service unavailable" || E504.Message != "Gateway timeout" {
+		t.Errorf("unexpected messages %q and %q", E503.Message, E504.Message)
+	}
+	if e := FromStatus(http.StatusPaymentRequired); e.Message != "Payment Required" {
+		t.Errorf("unexpected message %q", e.Message)
+	}
+}
+
+func TestRedirect(t *testing.T) {
+	redirect := func(u string) HandlerTest {
+		return HandlerTest(APIHandler(func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return nil, E302.SetData(u)
+		}).Handler)
+	}
+	cases := []struct {
+		uri, url, location string
+	}{
+		{"/api/list?page=1&sort=name", "?page=2", "/api/list?page=2"},
+		{"/api/old/user?id=1", "../new/user", "/api/new/user"},
+		{"/api/old/user?id=1", "../new/user?id=1", "/api/new/user?id=1"},
+		{"/api/a%2Fb/c?x=1", "d", "/api/a%2Fb/d"},
+		{"/api/list?page=1", "/login", "/login"},
+		{"/api/list", "https://example.com/list", "https://example.com/list"},
+		{"/api/list", "//cdn.example.com/list", "//cdn.example.com/list"},
+	}
+	for _, c := range cases {
+		resp, _ := redirect(c.url).Get(c.uri, "")
+		if resp.Code != http.StatusFound || resp.Header().Get("Location") != c.location {
+			t.Errorf("%s %q: unexpected redirect %d %v", c.uri, c.url, resp.Code, resp.Header())
+			continue
+		}
+		// a single json document
+		dec := json.NewDecoder(resp.Body)
+		var body ErrorBody
+		if err := dec.Decode(&body); err != nil || dec.More() || body.Error.URL != c.location {
+			t.Errorf("%s %q: unexpected body %s", c.uri, c.url, resp.Body)
+		}
+		if ct := resp.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+			t.Errorf("%s %q: unexpected Content-Type %q", c.uri, c.url, ct)
+		}
+	}
+}
+
+func TestHTMLRedirect(t *testing.T) {
+	HTMLRedirect = true
+	defer func() { HTMLRedirect = false }()
+	h := HandlerTest(APIHandler(func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return nil, E301.SetData("?page=2")
+	}).Handler)
+
+	for _, method := range []string{"GET", "POST"} {
+		expect := httptest.NewRecorder()
+		http.Redirect(expect, httptest.NewRequest(method, "/api/list?page=1", nil), "/api/list?page=2", http.StatusMovedPermanently)
+
+		resp := h.With().Do(httptest.NewRequest(method, "/api/list?page=1", nil))
+		if resp.Code != http.StatusMovedPermanently || resp.Header().Get("Location") != "/api/list?page=2" {
+			t.Errorf("%s: unexpected redirect %d %v", method, resp.Code, resp.Header())
+		}
+		if resp.Body.String() != expect.Body.String() || resp.Header().Get("Content-Type") != expect.Header().Get("Content-Type") {
+			t.Errorf("%s: expected %q %v, got %q %v", method, expect.Body, expect.Header(), resp.Body, resp.Header())
+		}
+	}
+}
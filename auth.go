@@ -0,0 +1,86 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+var (
+	errNoAuthorization = E401.SetData("Authorization header is required")
+	errMalformedBearer = E401.SetData(`Authorization header must be in the form of "Bearer <token>"`)
+	errMalformedBasic  = E401.SetData(`Authorization header must be in the form of "Basic <credentials>"`)
+	errInvalidToken    = E401.SetData("Invalid or expired token")
+)
+
+// BearerToken returns the token in "Authorization: Bearer <token>" header, or a
+// 401 Error if the header is missing or malformed.
+func (h *HTTP) BearerToken() (string, error) {
+	auth := h.Request.Header.Get("Authorization")
+	if auth == "" {
+		return "", errNoAuthorization
+	}
+	scheme, token, ok := strings.Cut(auth, " ")
+	if token = strings.TrimSpace(token); !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return "", errMalformedBearer
+	}
+	return token, nil
+}
+
+// BasicCredentials returns user and password in "Authorization: Basic" header, or
+// a 401 Error if the header is missing or malformed. It is Request.BasicAuth with
+// errors in JSON format.
+func (h *HTTP) BasicCredentials() (user, pass string, err error) {
+	if h.Request.Header.Get("Authorization") == "" {
+		return "", "", errNoAuthorization
+	}
+	user, pass, ok := h.Request.BasicAuth()
+	if !ok {
+		return "", "", errMalformedBasic
+	}
+	return user, pass, nil
+}
+
+// Auth creates a Middleware rejecting requests without valid bearer token before
+// calling the handler. verify checks the token, and can pass the user to the
+// handler in context of the request. Error returned by verify is sent as-is, like
+// a 403 for insufficient scopes, others become 401.
+//
+//     auth := jsonapi.Auth(func(token string, httpData *jsonapi.HTTP) error {
+//         user, err := sessions.Lookup(token)
+//         if err != nil {
+//             return err
+//         }
+//         httpData.Request = httpData.Request.WithContext(context.WithValue(httpData.Request.Context(), userKey, user))
+//         return nil
+//     })
+//
+// Requests are rejected with RejectEarly, and 401 responses have the
+// WWW-Authenticate header.
+func Auth(verify func(token string, h *HTTP) error) Middleware {
+	return func(next HTTPHandler) HTTPHandler {
+		return func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+			httpData.Vary("Authorization")
+			token, err := httpData.BearerToken()
+			if err == nil {
+				err = verify(token, httpData)
+			}
+			if err == nil {
+				next(enc, dec, httpData)
+				return
+			}
+
+			e, ok := err.(Error)
+			if !ok {
+				e = errInvalidToken.Wrap(err)
+			}
+			if e.Code == errInvalidToken.Code {
+				challenge := `Bearer realm="api"`
+				if token != "" {
+					challenge += `, error="invalid_token"`
+				}
+				httpData.ResponseWriter.Header().Set("WWW-Authenticate", challenge)
+			}
+			httpData.RejectEarly(e)
+		}
+	}
+}
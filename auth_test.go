@@ -0,0 +1,88 @@
+package jsonapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+type authUserKey struct{}
+
+func TestAuth(t *testing.T) {
+	auth := Auth(func(token string, h *HTTP) error {
+		switch token {
+		case "good":
+			h.Request = h.Request.WithContext(context.WithValue(h.Request.Context(), authUserKey{}, "john"))
+			return nil
+		case "readonly":
+			return E403.SetData("Token cannot write")
+		}
+		return errors.New("no such session")
+	})
+	called := 0
+	h := HandlerTest(auth(APIHandler(func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		called++
+		return httpData.Request.Context().Value(authUserKey{}), nil
+	}).Handler))
+
+	cases := []struct {
+		auth      string
+		code      int
+		message   string
+		challenge string
+	}{
+		{"", 401, "Authorization header is required", `Bearer realm="api"`},
+		{"Bearer", 401, `Authorization header must be in the form of "Bearer <token>"`, `Bearer realm="api"`},
+		{"Bearer   ", 401, `Authorization header must be in the form of "Bearer <token>"`, `Bearer realm="api"`},
+		{"Basic am9objpwYXNz", 401, `Authorization header must be in the form of "Bearer <token>"`, `Bearer realm="api"`},
+		{"Bearer bad", 401, "Invalid or expired token", `Bearer realm="api", error="invalid_token"`},
+		{"Bearer readonly", 403, "Token cannot write", ""},
+		{"bearer good", 200, "", ""},
+	}
+	for _, c := range cases {
+		called = 0
+		resp, _ := h.With(Headers{"Authorization": c.auth}).Post("/", "", `{}`)
+		if resp.Code != c.code || resp.Header().Get("WWW-Authenticate") != c.challenge || resp.Header().Get("Vary") != "Authorization" {
+			t.Errorf("%q: unexpected response %d %v", c.auth, resp.Code, resp.Header())
+			continue
+		}
+		if c.code == 200 {
+			if called != 1 || resp.Body.String() != `"john"`+"\n" {
+				t.Errorf("%q: unexpected body %s", c.auth, resp.Body)
+			}
+			continue
+		}
+		var body ErrorBody
+		json.Unmarshal(resp.Body.Bytes(), &body)
+		if called != 0 || body.Error.Message != c.message {
+			t.Errorf("%q: handler called %d times, body %s", c.auth, called, resp.Body)
+		}
+	}
+}
+
+func TestBasicCredentials(t *testing.T) {
+	cases := []struct {
+		auth, user, pass string
+		err              error
+	}{
+		{"", "", "", errNoAuthorization},
+		{"Basic am9objpzM2NyZXQ6eA==", "john", "s3cret:x", nil},
+		{"Basic not-base64", "", "", errMalformedBasic},
+		{"Bearer am9objpwYXNz", "", "", errMalformedBasic},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest("GET", "/", nil)
+		if c.auth != "" {
+			req.Header.Set("Authorization", c.auth)
+		}
+		user, pass, err := (&HTTP{Request: req}).BasicCredentials()
+		if user != c.user || pass != c.pass || !errors.Is(err, c.err) {
+			t.Errorf("%q: got %q %q %v", c.auth, user, pass, err)
+		}
+		if e, ok := err.(Error); err != nil && (!ok || e.Code != http.StatusUnauthorized) {
+			t.Errorf("%q: expected 401 Error, got %#v", c.auth, err)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+)
+
+// KindBatchTooLarge is the Kind of Error sent when a batch has more than MaxBatchSize calls
+const KindBatchTooLarge = "batch_too_large"
+
+// MaxBatchSize limits number of calls in a request to BatchHandler, zero or negative
+// means no limit. Larger batches are rejected with 413 Error of KindBatchTooLarge.
+var MaxBatchSize = 20
+
+// BatchCall is a call in the request body of BatchHandler
+type BatchCall struct {
+	Method string          `json:"method"` // defaults to POST
+	Path   string          `json:"path"`   // with query string, like "/api/user?id=1"
+	Body   json.RawMessage `json:"body"`
+}
+
+// BatchHandler creates an APIHandler calling apis several times in one round trip.
+// Register it with apis:
+//
+//     apis = append(apis, jsonapi.API{Pattern: "/api/batch", APIHandler: jsonapi.BatchHandler(apis)})
+//     jsonapi.Register(apis, nil)
+//
+// Request body is a list of BatchCall:
+//
+//     [{"path": "/api/user", "body": {"id": 1}}, {"method": "GET", "path": "/api/me"}]
+//
+// Calls are served one by one, with headers of the batch request, by the APIs like
+// they are requested separately. The results are sent in order as a MultiStatus, in
+// which id is the index of the call, and body is what the API would have sent, which
+// is the error document for failed ones. Calls to unknown paths get 404.
+func BatchHandler(apis []API) APIHandler {
+	mux := http.NewServeMux()
+	Register(apis, mux)
+	return func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		var calls []BatchCall
+		if err := dec.Decode(&calls); err != nil {
+			return nil, E400.SetData("Request body must be a list of calls")
+		}
+		if MaxBatchSize > 0 && len(calls) > MaxBatchSize {
+			return nil, Error{
+				Code:    http.StatusRequestEntityTooLarge,
+				Message: fmt.Sprintf("Batch cannot have more than %d calls", MaxBatchSize),
+				Kind:    KindBatchTooLarge,
+			}
+		}
+
+		var ret MultiStatus
+		for idx, c := range calls {
+			req, err := batchRequest(httpData.Request, c)
+			if err != nil {
+				ret.Add(idx, http.StatusBadRequest, errorEncoder(httpData, http.StatusBadRequest, err))
+				continue
+			}
+			if _, pattern := mux.Handler(req); pattern == "" {
+				ret.Add(idx, http.StatusNotFound, errorEncoder(httpData, http.StatusNotFound, E404))
+				continue
+			}
+			rec := httptest.NewRecorder()
+			mux.ServeHTTP(rec, req)
+			ret.Add(idx, rec.Code, batchBody(rec.Body.Bytes()))
+		}
+		return &ret, nil
+	}
+}
+
+// batchRequest creates the request of c, sharing headers and context of r
+func batchRequest(r *http.Request, c BatchCall) (*http.Request, error) {
+	if c.Path == "" || c.Path[0] != '/' {
+		return nil, E400.SetData("Path of the call must be absolute")
+	}
+	method := c.Method
+	if method == "" {
+		method = http.MethodPost
+	}
+	var body []byte
+	if len(c.Body) > 0 && !bytes.Equal(c.Body, []byte("null")) {
+		body = c.Body
+	}
+	req, err := http.NewRequestWithContext(r.Context(), method, c.Path, bytes.NewReader(body))
+	if err != nil {
+		return nil, E400.SetData("Invalid method or path of the call")
+	}
+	req.Header = r.Header.Clone()
+	// the body is not the one of r, and the result is always encoded in plain JSON
+	req.Header.Del("Content-Length")
+	req.Header.Del("Content-Encoding")
+	req.Header.Del("Accept-Encoding")
+	req.Header.Del("Digest")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Host, req.RemoteAddr, req.TLS = r.Host, r.RemoteAddr, r.TLS
+	return req, nil
+}
+
+// batchBody keeps JSON response as is, or sends others as string
+func batchBody(buf []byte) interface{} {
+	buf = bytes.TrimSpace(buf)
+	switch {
+	case len(buf) == 0:
+		return nil
+	case json.Valid(buf):
+		return json.RawMessage(buf)
+	}
+	return string(buf)
+}
@@ -0,0 +1,67 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func batchTest() *MuxTest {
+	apis := []API{
+		{Pattern: "GET /api/user/{id}", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			if id := httpData.Request.PathValue("id"); id != "1" {
+				return nil, E404.SetData("User not found")
+			}
+			return map[string]string{"name": "john", "tenant": httpData.Request.Header.Get("X-Tenant")}, nil
+		}},
+		{Pattern: "POST /api/echo", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			var v interface{}
+			err := dec.Decode(&v)
+			return v, err
+		}},
+	}
+	return NewMuxTest(append(apis, API{Pattern: "/api/batch", APIHandler: BatchHandler(apis)}))
+}
+
+func TestBatchHandler(t *testing.T) {
+	m := batchTest().With(Headers{"X-Tenant": "acme"})
+	resp, _ := m.Post("/api/batch", "", `[
+		{"method": "GET", "path": "/api/user/1"},
+		{"path": "/api/echo", "body": {"a": 1}},
+		{"method": "GET", "path": "/api/user/0"},
+		{"path": "/api/nowhere"},
+		{"path": "api/echo"}
+	]`)
+	expect := `{"status":207,"items":[` +
+		`{"id":0,"status":200,"body":{"name":"john","tenant":"acme"}},` +
+		`{"id":1,"status":200,"body":{"a":1}},` +
+		`{"id":2,"status":404,"body":{"error":{"code":404,"message":"User not found"}}},` +
+		`{"id":3,"status":404,"body":{"error":{"code":404,"message":"Resource not found"}}},` +
+		`{"id":4,"status":400,"body":{"error":{"code":400,"message":"Path of the call must be absolute"}}}]}`
+	if resp.Code != http.StatusMultiStatus || resp.Body.String() != expect+"\n" {
+		t.Errorf("unexpected response %d %s", resp.Code, resp.Body)
+	}
+}
+
+func TestBatchHandlerLimit(t *testing.T) {
+	defer func(n int) { MaxBatchSize = n }(MaxBatchSize)
+	MaxBatchSize = 2
+	m := batchTest()
+
+	call := `{"method": "GET", "path": "/api/user/1"}`
+	resp, _ := m.Post("/api/batch", "", "["+call+","+call+"]")
+	if resp.Code != http.StatusOK {
+		t.Errorf("expected 200, got %d %s", resp.Code, resp.Body)
+	}
+	resp, _ = m.Post("/api/batch", "", "["+call+","+call+","+call+"]")
+	var body ErrorBody
+	json.Unmarshal(resp.Body.Bytes(), &body)
+	if resp.Code != http.StatusRequestEntityTooLarge || body.Error.Kind != KindBatchTooLarge {
+		t.Errorf("expected 413, got %d %s", resp.Code, resp.Body)
+	}
+
+	resp, _ = m.Post("/api/batch", "", `{"path": "/api/user/1"}`)
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a single call, got %d %s", resp.Code, resp.Body)
+	}
+}
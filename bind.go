@@ -0,0 +1,93 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+)
+
+// KindValidation is the Kind of Error sent when request fails validation
+const KindValidation = "validation_failed"
+
+// Validator is implemented by request types checking themselves
+type Validator interface {
+	Validate() error
+}
+
+// HTTPValidator is Validator needing the request, like to check the authenticated user
+type HTTPValidator interface {
+	Validate(httpData *HTTP) error
+}
+
+// RequestValidator is implemented by request types with rules depending on other
+// fields or the request itself, like the authenticated user. It runs after
+// Validate, when all inputs are populated.
+//
+//     func (r *EventArgs) ValidateRequest(httpData *jsonapi.HTTP) error {
+//         if !r.AllDay && !r.End.After(r.Start) {
+//             return jsonapi.E400.SetData("end_date must be after start_date")
+//         }
+//         return nil
+//     }
+type RequestValidator interface {
+	ValidateRequest(httpData *HTTP) error
+}
+
+// Bind decodes request body into v, fills fields from path parameters (see
+// BindPath) and validates it. Empty body leaves v untouched, and malformed JSON
+// is reported by a 400 Error.
+//
+// If v implements Validator (or HTTPValidator) and RequestValidator, they are called
+// in that order. Returned Error is sent as-is, other errors become a 422 Error of
+// KindValidation.
+//
+//     func createEvent(dec *json.Decoder, httpData *jsonapi.HTTP) (interface{}, error) {
+//         var args EventArgs
+//         if err := jsonapi.Bind(dec, httpData, &args); err != nil {
+//             return nil, err
+//         }
+//         ...
+//     }
+func Bind(dec *json.Decoder, httpData *HTTP, v interface{}) error {
+	if err := DecodeOrEmpty(dec, v); err != nil {
+		if e, ok := unknownField(err); ok {
+			return e
+		}
+		return E400.SetData("Cannot decode request body: " + err.Error())
+	}
+
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Ptr && rv.Elem().Kind() == reflect.Struct {
+		if err := bindPath(httpData, rv.Elem()); err != nil {
+			return err
+		}
+	}
+	httpData.WarnDeprecated(v)
+	return validate(httpData, v)
+}
+
+// validate runs validators implemented by v
+func validate(httpData *HTTP, v interface{}) error {
+	if val, ok := v.(Validator); ok {
+		if err := val.Validate(); err != nil {
+			return validationError(err)
+		}
+	}
+	if val, ok := v.(HTTPValidator); ok {
+		if err := val.Validate(httpData); err != nil {
+			return validationError(err)
+		}
+	}
+	if val, ok := v.(RequestValidator); ok {
+		if err := val.ValidateRequest(httpData); err != nil {
+			return validationError(err)
+		}
+	}
+	return nil
+}
+
+func validationError(err error) error {
+	if e, ok := err.(Error); ok {
+		return e
+	}
+	return Error{Code: http.StatusUnprocessableEntity, Message: err.Error(), Kind: KindValidation}
+}
@@ -0,0 +1,157 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type eventArgs struct {
+	Owner  string `json:"-" in:"path" name:"owner"`
+	Title  string `json:"title" query:"title"`
+	Start  int    `json:"start" query:"start"`
+	End    int    `json:"end" query:"end"`
+	AllDay bool   `json:"all_day" query:"all_day"`
+}
+
+func (a *eventArgs) Validate() error {
+	if a.Title == "" {
+		return errors.New("title is required")
+	}
+	return nil
+}
+
+func (a *eventArgs) ValidateRequest(httpData *HTTP) error {
+	if !a.AllDay && a.End <= a.Start {
+		return errors.New("end must be after start unless all_day is set")
+	}
+	if a.Owner != "" && httpData.Request.Header.Get("X-User") != a.Owner {
+		return E403.SetData("Cannot create events for others")
+	}
+	return nil
+}
+
+func TestRequestValidator(t *testing.T) {
+	m := NewMuxTest([]API{
+		{Pattern: "POST /bind/{owner}", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			var args eventArgs
+			if err := Bind(dec, httpData, &args); err != nil {
+				return nil, err
+			}
+			return args.Title, nil
+		}},
+		{Pattern: "POST /typed/{owner}", APIHandler: Typed(func(args eventArgs, httpData *HTTP) (string, error) {
+			return args.Title, nil
+		})},
+		{Pattern: "GET /query/{owner}", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			var args eventArgs
+			args.Owner = httpData.Request.PathValue("owner")
+			if err := httpData.DecodeQuery(&args); err != nil {
+				return nil, err
+			}
+			return args.Title, nil
+		}},
+	})
+
+	cases := []struct {
+		body   string
+		user   string
+		status int
+		kind   string
+	}{
+		{`{"title":"a","start":1,"end":2}`, "john", http.StatusOK, ""},
+		{`{"title":"a","start":2,"end":2,"all_day":true}`, "john", http.StatusOK, ""},
+		{`{"start":1,"end":2}`, "john", http.StatusUnprocessableEntity, KindValidation},
+		{`{"title":"a","start":2,"end":1}`, "john", http.StatusUnprocessableEntity, KindValidation},
+		{`{"title":"a","start":1,"end":2}`, "mary", http.StatusForbidden, ""},
+	}
+	for _, c := range cases {
+		var doc map[string]interface{}
+		json.Unmarshal([]byte(c.body), &doc)
+		var query []string
+		for k, v := range doc {
+			b, _ := json.Marshal(v)
+			query = append(query, k+"="+strings.Trim(string(b), `"`))
+		}
+
+		for _, req := range []*http.Request{
+			newRequest("POST", "/bind/john", c.body),
+			newRequest("POST", "/typed/john", c.body),
+			newRequest("GET", "/query/john?"+strings.Join(query, "&"), ""),
+		} {
+			req.Header.Set("X-User", c.user)
+			resp := m.Do(req)
+			var body ErrorBody
+			json.Unmarshal(resp.Body.Bytes(), &body)
+			if resp.Code != c.status || body.Error.Kind != c.kind {
+				t.Errorf("%s %s as %s: got %d %s", req.URL, c.body, c.user, resp.Code, resp.Body)
+			}
+		}
+	}
+}
+
+func newRequest(method, uri, body string) *http.Request {
+	req, _ := http.NewRequest(method, uri, strings.NewReader(body))
+	return req
+}
+
+type transferArgs struct {
+	From   string `json:"from"`
+	Amount int    `json:"amount"`
+}
+
+func (a *transferArgs) Validate(httpData *HTTP) error {
+	if a.Amount <= 0 {
+		return errors.New("amount must be positive")
+	}
+	if a.From != httpData.Request.Header.Get("X-User") {
+		return E403.SetData("Cannot transfer from others")
+	}
+	return nil
+}
+
+func TestHTTPValidator(t *testing.T) {
+	called := 0
+	m := NewMuxTest([]API{
+		{Pattern: "POST /bind", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			var args transferArgs
+			if err := Bind(dec, httpData, &args); err != nil {
+				return nil, err
+			}
+			called++
+			return args.Amount, nil
+		}},
+		{Pattern: "POST /typed", APIHandler: Typed(func(args transferArgs, httpData *HTTP) (int, error) {
+			called++
+			return args.Amount, nil
+		})},
+	})
+
+	cases := []struct {
+		body   string
+		status int
+		kind   string
+	}{
+		{`{"from":"john","amount":10}`, http.StatusOK, ""},
+		{`{"from":"john","amount":0}`, http.StatusUnprocessableEntity, KindValidation},
+		{`{"from":"mary","amount":10}`, http.StatusForbidden, ""},
+	}
+	for _, c := range cases {
+		for _, uri := range []string{"/bind", "/typed"} {
+			called = 0
+			req := newRequest("POST", uri, c.body)
+			req.Header.Set("X-User", "john")
+			resp := m.Do(req)
+			var body ErrorBody
+			json.Unmarshal(resp.Body.Bytes(), &body)
+			if resp.Code != c.status || body.Error.Kind != c.kind {
+				t.Errorf("%s %s: got %d %s", uri, c.body, resp.Code, resp.Body)
+			}
+			if expect := map[bool]int{true: 1}[c.status == http.StatusOK]; called != expect {
+				t.Errorf("%s %s: handler is called %d times", uri, c.body, called)
+			}
+		}
+	}
+}
@@ -0,0 +1,406 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// KindDuplicateKey is the Kind of Error sent when RejectDuplicateKeys rejects a request
+const KindDuplicateKey = "duplicate_key"
+
+// RejectDuplicateKeys rejects request bodies containing objects with duplicate keys,
+// like `{"amount": 10, "amount": 9999}`, with a 400 Error of KindDuplicateKey. Without
+// it, the last value wins silently. Keys are compared exactly, see FoldDuplicateKeys.
+//
+// API.RejectDuplicateKeys enables it per route, and API.AllowDuplicateKeys skips the
+// check for routes where the cost is not acceptable. Request body is buffered in
+// memory when checking, see HTTP.RawBody.
+var RejectDuplicateKeys bool
+
+// FoldDuplicateKeys makes RejectDuplicateKeys compare keys case-insensitively, the
+// way encoding/json matches struct fields, so `{"amount": 10, "Amount": 9999}` is
+// rejected too. It is for bodies decoded into structs: keys of maps are kept
+// as-is, so both keys of `{"ID": 1, "id": 2}` are valid for a map[string]int.
+// API.FoldDuplicateKeys enables it per route.
+var FoldDuplicateKeys bool
+
+// KindTooDeep is the Kind of Error sent when request body is nested deeper than MaxDepth
+const KindTooDeep = "too_deep"
+
+// MaxDepth limits how deep objects and arrays in request bodies can be nested, so
+// a malicious document cannot exhaust the decoder. API.MaxDepth overrides it per
+// route, a negative value disables the check. Requests exceeding it are rejected
+// with a 400 Error of KindTooDeep.
+//
+// The depth is counted while the handler reads request body, so the body is still
+// streamed to the decoder, unless it is buffered for other checks. Bodies not in
+// JSON format, like uploads, are not checked.
+var MaxDepth = 100
+
+// KindInvalidUTF8 is the Kind of Error sent when ValidateUTF8 rejects a request
+const KindInvalidUTF8 = "invalid_utf8"
+
+// KindUnsupportedCharset is the Kind of Error sent when request body is declared
+// to be encoded in charset other than utf-8.
+const KindUnsupportedCharset = "unsupported_charset"
+
+// ValidateUTF8 rejects request bodies which are not well-formed UTF-8 with a 400 Error
+// of KindInvalidUTF8, reporting offset of the first invalid byte. API.ValidateUTF8
+// enables it per route. Request body is buffered in memory when checking.
+//
+// Regardless of this option, a leading UTF-8 BOM is always stripped from JSON bodies,
+// and requests declaring a charset other than utf-8 in Content-Type are rejected
+// with 415. Other bodies, like uploaded files, are passed to handlers untouched.
+var ValidateUTF8 bool
+
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// bomReader strips leading UTF-8 BOM. It does not read anything until the first Read.
+type bomReader struct {
+	io.ReadCloser
+	checked bool
+	head    []byte
+}
+
+func (r *bomReader) Read(p []byte) (int, error) {
+	if !r.checked {
+		r.checked = true
+		buf := make([]byte, len(utf8BOM))
+		n, err := io.ReadFull(r.ReadCloser, buf)
+		if r.head = buf[:n]; bytes.Equal(r.head, utf8BOM) {
+			r.head = nil
+		}
+		if len(r.head) == 0 && err != nil && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+	}
+
+	if len(r.head) > 0 {
+		n := copy(p, r.head)
+		r.head = r.head[n:]
+		return n, nil
+	}
+	return r.ReadCloser.Read(p)
+}
+
+// RawBody reads whole request body into memory. Request.Body is replaced so it can
+// be read again, and calling RawBody more than once returns the same data.
+func (h *HTTP) RawBody() ([]byte, error) {
+	if h.rawBody == nil {
+		if h.Request.Body == nil {
+			h.rawBody = []byte{}
+		} else if h.rawBody, h.rawErr = ioutil.ReadAll(h.Request.Body); h.rawBody == nil {
+			h.rawBody = []byte{}
+		}
+	}
+
+	h.Request.Body = ioutil.NopCloser(bytes.NewReader(h.rawBody))
+	return h.rawBody, h.rawErr
+}
+
+// bodyState is shared by copies of HTTP, like the one passed to handlers under a
+// timeout, as readers of request body update it even after the deadline.
+type bodyState struct {
+	mu   sync.Mutex
+	err  *Error // request body is broken, replaces errors caused by it
+	read int64  // bytes of request body read, after decompression
+}
+
+func (h *HTTP) bodyErr() *Error {
+	if h.body == nil {
+		return nil
+	}
+	h.body.mu.Lock()
+	defer h.body.mu.Unlock()
+	return h.body.err
+}
+
+func (h *HTTP) setBodyErr(e *Error) {
+	if h.body == nil {
+		return
+	}
+	h.body.mu.Lock()
+	defer h.body.mu.Unlock()
+	h.body.err = e
+}
+
+func (h *HTTP) bodyRead() int64 {
+	if h.body == nil {
+		return 0
+	}
+	h.body.mu.Lock()
+	defer h.body.mu.Unlock()
+	return h.body.read
+}
+
+func (h *HTTP) addBodyRead(n int) {
+	if h.body == nil {
+		return
+	}
+	h.body.mu.Lock()
+	defer h.body.mu.Unlock()
+	h.body.read += int64(n)
+}
+
+func (h *HTTP) rejectDuplicateKeys() bool {
+	if h.api != nil && h.api.AllowDuplicateKeys {
+		return false
+	}
+	return RejectDuplicateKeys || (h.api != nil && h.api.RejectDuplicateKeys)
+}
+
+func (h *HTTP) maxDepth() int {
+	if h.api != nil && h.api.MaxDepth != 0 {
+		return h.api.MaxDepth
+	}
+	return MaxDepth
+}
+
+// checkBody validates request body before it is passed to APIHandler. If the body
+// has been buffered, the returned decoder replaces dec.
+func (h *HTTP) checkBody(dec *json.Decoder) (*json.Decoder, error) {
+	if ct := h.Request.Header.Get("Content-Type"); ct != "" {
+		_, params, _ := mime.ParseMediaType(ct)
+		if cs, ok := params["charset"]; ok && !strings.EqualFold(cs, "utf-8") && !strings.EqualFold(cs, "utf8") {
+			h.rejected = true
+			return dec, Error{
+				Code:    http.StatusUnsupportedMediaType,
+				Message: fmt.Sprintf("Unsupported charset %q, only utf-8 is accepted", cs),
+				Kind:    KindUnsupportedCharset,
+			}
+		}
+	}
+
+	if !jsonBody(h.Request) {
+		// like multipart/form-data, see MultipartFile
+		return dec, nil
+	}
+	opts := scanOpts{duplicateKeys: h.rejectDuplicateKeys()}
+	opts.foldKeys = opts.duplicateKeys && (FoldDuplicateKeys || (h.api != nil && h.api.FoldDuplicateKeys))
+	depth := h.maxDepth()
+	validate := ValidateUTF8 || (h.api != nil && h.api.ValidateUTF8)
+	strict := h.strictBody()
+	if opts == (scanOpts{}) && !validate && !strict {
+		if depth <= 0 {
+			return dec, nil
+		}
+		h.Request.Body = &depthReader{ReadCloser: h.Request.Body, h: h, scanner: depthScanner{max: depth}}
+		return h.newDecoder(h.Request.Body), nil
+	}
+
+	raw, err := h.RawBody()
+	if err != nil {
+		return dec, E400.SetData("Cannot read request body")
+	}
+	if validate && !utf8.Valid(raw) {
+		return dec, Error{
+			Code:    http.StatusBadRequest,
+			Message: fmt.Sprintf("Request body is not valid UTF-8 at byte %d", invalidUTF8(raw)),
+			Kind:    KindInvalidUTF8,
+		}
+	}
+	if depth > 0 {
+		s := depthScanner{max: depth}
+		if s.scan(raw) {
+			return dec, tooDeepError(depth)
+		}
+	}
+	if len(raw) > 0 && opts != (scanOpts{}) {
+		if err := scanBody(raw, opts); err != nil {
+			return dec, err
+		}
+	}
+	if strict && hasTrailingData(raw) {
+		return dec, errTrailingData
+	}
+	return h.newDecoder(h.Request.Body), nil
+}
+
+// invalidUTF8 finds offset of first invalid UTF-8 sequence in buf
+func invalidUTF8(buf []byte) int {
+	for i := 0; i < len(buf); {
+		r, size := utf8.DecodeRune(buf[i:])
+		if r == utf8.RuneError && size == 1 {
+			return i
+		}
+		i += size
+	}
+	return -1
+}
+
+func tooDeepError(depth int) Error {
+	return Error{
+		Code:    http.StatusBadRequest,
+		Message: fmt.Sprintf("JSON document is nested deeper than %d levels", depth),
+		Kind:    KindTooDeep,
+	}
+}
+
+// depthScanner counts nesting of objects and arrays in JSON data, which can be fed
+// in pieces
+type depthScanner struct {
+	max      int
+	depth    int
+	inString bool
+	escaped  bool
+}
+
+// scan reports whether data fed so far is nested deeper than max levels
+func (s *depthScanner) scan(data []byte) bool {
+	for _, b := range data {
+		switch {
+		case s.escaped:
+			s.escaped = false
+		case s.inString && b == '\\':
+			s.escaped = true
+		case b == '"':
+			s.inString = !s.inString
+		case s.inString:
+		case b == '{' || b == '[':
+			if s.depth++; s.depth > s.max {
+				return true
+			}
+		case b == '}' || b == ']':
+			s.depth--
+		}
+	}
+	return false
+}
+
+// depthReader fails once request body read through it is nested deeper than max
+// levels, see MaxDepth
+type depthReader struct {
+	io.ReadCloser
+	h       *HTTP
+	scanner depthScanner
+	err     error
+}
+
+func (r *depthReader) Read(p []byte) (int, error) {
+	if r.err != nil {
+		return 0, r.err
+	}
+	n, err := r.ReadCloser.Read(p)
+	if r.scanner.scan(p[:n]) {
+		e := tooDeepError(r.scanner.max)
+		r.h.setBodyErr(&e)
+		r.err = e
+		return 0, e
+	}
+	return n, err
+}
+
+// scanOpts selects checks done by scanBody
+type scanOpts struct {
+	duplicateKeys bool
+	foldKeys      bool // compare keys like encoding/json matches fields
+}
+
+// foldKey folds key the way encoding/json does to match struct fields, which
+// also folds "K" (Kelvin sign) into "K", and "ſ" into "S".
+func foldKey(key string) string {
+	buf := make([]rune, 0, len(key))
+	for _, r := range key {
+		if r < utf8.RuneSelf {
+			if 'a' <= r && r <= 'z' {
+				r -= 'a' - 'A'
+			}
+		} else {
+			r = unicode.ToUpper(unicode.ToLower(r))
+		}
+		buf = append(buf, r)
+	}
+	return string(buf)
+}
+
+// scanFrame is an object or array being scanned
+type scanFrame struct {
+	keys  map[string]bool // nil for arrays
+	key   string          // current key of object
+	isKey bool            // next string is a key
+	index int             // current index of array
+}
+
+// scanBody walks through tokens of JSON document raw. Syntax errors are left
+// to the decoder used by the handler.
+func scanBody(raw []byte, opts scanOpts) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	var stack []*scanFrame
+	path := func() string {
+		buf := &bytes.Buffer{}
+		buf.WriteString("$")
+		for _, f := range stack {
+			if f.keys == nil {
+				buf.WriteString("[" + strconv.Itoa(f.index) + "]")
+				continue
+			}
+			buf.WriteString("." + f.key)
+		}
+		return buf.String()
+	}
+	// valueDone moves parent to its next key or element
+	valueDone := func() {
+		if len(stack) == 0 {
+			return
+		}
+		if top := stack[len(stack)-1]; top.keys == nil {
+			top.index++
+		} else {
+			top.isKey = true
+		}
+	}
+
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			// io.EOF, or syntax error which is reported by decoder of the handler
+			return nil
+		}
+
+		switch tok {
+		case json.Delim('{'):
+			stack = append(stack, &scanFrame{keys: map[string]bool{}, isKey: true})
+			continue
+		case json.Delim('['):
+			stack = append(stack, &scanFrame{})
+			continue
+		case json.Delim('}'), json.Delim(']'):
+			stack = stack[:len(stack)-1]
+			valueDone()
+			continue
+		}
+
+		if len(stack) > 0 {
+			if top := stack[len(stack)-1]; top.keys != nil && top.isKey {
+				key := tok.(string)
+				top.key = key
+				top.isKey = false
+				folded := key
+				if opts.foldKeys {
+					// "Amount" also sets the field of "amount"
+					folded = foldKey(key)
+				}
+				if opts.duplicateKeys && top.keys[folded] {
+					return Error{
+						Code:    http.StatusBadRequest,
+						Message: fmt.Sprintf("Duplicate key %q at %s", key, path()),
+						Kind:    KindDuplicateKey,
+					}
+				}
+				top.keys[folded] = true
+				continue
+			}
+		}
+		valueDone()
+	}
+}
@@ -0,0 +1,235 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func nested(depth int) string {
+	return strings.Repeat("[", depth) + strings.Repeat("]", depth)
+}
+
+func decodeAny(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, E400.SetData(err.Error())
+	}
+	return "ok", nil
+}
+
+func TestMaxDepth(t *testing.T) {
+	m := NewMuxTest([]API{
+		{Pattern: "/default", APIHandler: decodeAny},
+		{Pattern: "/shallow", APIHandler: decodeAny, MaxDepth: 2},
+		{Pattern: "/unlimited", APIHandler: decodeAny, MaxDepth: -1},
+		{Pattern: "/buffered", APIHandler: decodeAny, MaxDepth: 2, ValidateUTF8: true},
+	})
+	cases := []struct {
+		uri, body string
+		code      int
+	}{
+		{"/default", nested(MaxDepth), http.StatusOK},
+		{"/default", nested(MaxDepth + 1), http.StatusBadRequest},
+		{"/default", nested(100000), http.StatusBadRequest},
+		{"/shallow", `{"a":[1]}`, http.StatusOK},
+		{"/shallow", `{"a":[{}]}`, http.StatusBadRequest},
+		{"/shallow", `{"a":"[[[[["}`, http.StatusOK},
+		{"/shallow", `{"a":"\"[[[[["}`, http.StatusOK},
+		{"/unlimited", nested(MaxDepth + 1), http.StatusOK},
+		{"/buffered", `{"a":[1]}`, http.StatusOK},
+		{"/buffered", `{"a":[{}]}`, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		resp, err := m.Post(c.uri, "", c.body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Code != c.code {
+			t.Errorf("%s %.20s: status = %d, want %d: %s", c.uri, c.body, resp.Code, c.code, resp.Body)
+			continue
+		}
+		if c.code == http.StatusOK {
+			continue
+		}
+		var body ErrorBody
+		if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if body.Error.Kind != KindTooDeep {
+			t.Errorf("%s %.20s: kind = %q, want %q", c.uri, c.body, body.Error.Kind, KindTooDeep)
+		}
+	}
+}
+
+func TestMaxDepthStreamsBody(t *testing.T) {
+	r, w := io.Pipe()
+	got := make(chan json.Token, 1)
+	m := NewMuxTest([]API{{Pattern: "/", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		got <- tok
+		for dec.More() {
+			var v interface{}
+			if err := dec.Decode(&v); err != nil {
+				return nil, err
+			}
+		}
+		return "ok", nil
+	}}})
+
+	done := make(chan int, 1)
+	go func() {
+		req, _ := http.NewRequest("POST", "/", r)
+		done <- m.Do(req).Code
+	}()
+	io.WriteString(w, "[1,")
+	select {
+	case tok := <-got:
+		if tok != json.Delim('[') {
+			t.Errorf("first token = %v", tok)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler does not get the body before it is complete")
+	}
+	io.WriteString(w, "2]")
+	w.Close()
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("status = %d, want 200", code)
+	}
+}
+
+func TestMaxDepthSkipsNonJSON(t *testing.T) {
+	m := NewMuxTest([]API{{Pattern: "/", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		b, err := ioutil.ReadAll(httpData.Request.Body)
+		return len(b), err
+	}}})
+	body := nested(MaxDepth + 1)
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/plain")
+	resp := m.Do(req)
+	if resp.Code != http.StatusOK || strings.TrimSpace(resp.Body.String()) != "202" {
+		t.Errorf("got %d %s", resp.Code, resp.Body)
+	}
+}
+
+func TestRejectDuplicateKeys(t *testing.T) {
+	m := NewMuxTest([]API{
+		{Pattern: "/strict", APIHandler: decodeAny, RejectDuplicateKeys: true},
+		{Pattern: "/fold", APIHandler: decodeAny, RejectDuplicateKeys: true, FoldDuplicateKeys: true},
+		{Pattern: "/loose", APIHandler: decodeAny, FoldDuplicateKeys: true},
+	})
+	cases := []struct {
+		uri, body, message string
+	}{
+		{"/strict", `{"amount":10,"amount":9999}`, `Duplicate key "amount" at $.amount`},
+		{"/strict", `{"user":{"id":1,"id":2}}`, `Duplicate key "id" at $.user.id`},
+		{"/strict", `[{"a":1},{"a":1,"a":2}]`, `Duplicate key "a" at $[1].a`},
+		{"/strict", `{"amount":10,"Amount":9999}`, ""},
+		{"/strict", `{"ID":1,"id":2}`, ""},
+		{"/strict", `{"a":{"b":1},"b":{"b":1}}`, ""},
+		{"/fold", `{"amount":10,"Amount":9999}`, `Duplicate key "Amount" at $.Amount`},
+		{"/fold", `{"user":{"ID":1,"id":2}}`, `Duplicate key "id" at $.user.id`},
+		{"/fold", `{"kind":1,"\u212aind":2}`, "Duplicate key \"\u212aind\" at $.\u212aind"},
+		{"/fold", `{"size":1,"\u017fize":2}`, "Duplicate key \"\u017fize\" at $.\u017fize"},
+		{"/fold", `{"a":{"b":1},"b":{"b":1}}`, ""},
+		{"/loose", `{"amount":10,"Amount":9999}`, ""},
+	}
+	for _, c := range cases {
+		resp, err := m.Post(c.uri, "", c.body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.message == "" {
+			if resp.Code != http.StatusOK {
+				t.Errorf("%s %s: status = %d: %s", c.uri, c.body, resp.Code, resp.Body)
+			}
+			continue
+		}
+		var body ErrorBody
+		if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Code != http.StatusBadRequest || body.Error.Kind != KindDuplicateKey || body.Error.Message != c.message {
+			t.Errorf("%s %s: got %d %+v, want %q", c.uri, c.body, resp.Code, body.Error, c.message)
+		}
+	}
+}
+
+func TestFoldKey(t *testing.T) {
+	// keys folded the same set the same field
+	var v struct{ Kind, Size, ID int }
+	for _, keys := range [][2]string{{"kind", "\u212aind"}, {"size", "\u017fize"}, {"ID", "id"}, {"Size", "SIZE"}} {
+		if foldKey(keys[0]) != foldKey(keys[1]) {
+			t.Errorf("%q and %q are folded differently", keys[0], keys[1])
+		}
+		if err := json.Unmarshal([]byte(`{"`+keys[1]+`":1}`), &v); err != nil || v.Kind+v.Size+v.ID == 0 {
+			t.Errorf("%q is not matched by encoding/json", keys[1])
+		}
+		v.Kind, v.Size, v.ID = 0, 0, 0
+	}
+	if foldKey("id") == foldKey("ib") {
+		t.Errorf("different keys are folded to the same")
+	}
+}
+
+func TestBodyEncoding(t *testing.T) {
+	m := NewMuxTest([]API{
+		{Pattern: "/", APIHandler: decodeAny},
+		{Pattern: "/utf8", APIHandler: decodeAny, ValidateUTF8: true},
+		{Pattern: "/raw", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			b, err := ioutil.ReadAll(httpData.Request.Body)
+			return string(b), err
+		}},
+	})
+	cases := []struct {
+		uri, contentType, body string
+		status                 int
+		kind, message          string
+	}{
+		{"/", "application/json", "\xef\xbb\xbf{\"a\":1}", http.StatusOK, "", ""},
+		{"/raw", "application/json", "\xef\xbb\xbf{}", http.StatusOK, "", ""},
+		{"/raw", "application/octet-stream", "\xef\xbb\xbf\x00\x01", http.StatusOK, "", ""},
+		{"/", "", "\xef\xbb\xbf[]", http.StatusOK, "", ""},
+		{"/", "application/json; charset=UTF-8", `{}`, http.StatusOK, "", ""},
+		{"/utf8", "application/json", `{"name":"caf` + "\xe9" + `"}`, http.StatusBadRequest, KindInvalidUTF8, "Request body is not valid UTF-8 at byte 12"},
+		{"/utf8", "application/json", "\xef\xbb\xbf" + `{"name":"café"}`, http.StatusOK, "", ""},
+		{"/", "application/json; charset=iso-8859-1", `{}`, http.StatusUnsupportedMediaType, KindUnsupportedCharset, `Unsupported charset "iso-8859-1", only utf-8 is accepted`},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest("POST", c.uri, strings.NewReader(c.body))
+		if c.contentType != "" {
+			req.Header.Set("Content-Type", c.contentType)
+		}
+		resp := m.Do(req)
+		if resp.Code != c.status {
+			t.Errorf("%s %q: status = %d: %s", c.uri, c.body, resp.Code, resp.Body)
+			continue
+		}
+		if c.uri == "/raw" {
+			// only JSON bodies are stripped, others are passed byte for byte
+			expect := strings.TrimPrefix(c.body, "\xef\xbb\xbf")
+			if c.contentType != "application/json" {
+				expect = c.body
+			}
+			var got string
+			if json.Unmarshal(resp.Body.Bytes(), &got); got != expect {
+				t.Errorf("%s: expected body %q, got %q", c.contentType, expect, got)
+			}
+		}
+		if c.kind == "" {
+			continue
+		}
+		var body ErrorBody
+		json.Unmarshal(resp.Body.Bytes(), &body)
+		if body.Error.Kind != c.kind || body.Error.Message != c.message {
+			t.Errorf("%s %q: got %+v, want %s %q", c.uri, c.body, body.Error, c.kind, c.message)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+package jsonapi
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxErrorBody limits size of error responses read by Client
+const maxErrorBody = 1 << 20
+
+// Client calls APIs served by this package
+//
+//     c := jsonapi.NewClient("https://example.com/api/")
+//     err := c.Stream(ctx, "GET", "events", nil, func(item json.RawMessage) error {
+//         var ev Event
+//         if err := json.Unmarshal(item, &ev); err != nil {
+//             return err
+//         }
+//         return process(ev)
+//     })
+type Client struct {
+	base    *url.URL
+	client  *http.Client
+	sse     bool
+	header  http.Header   // see WithHeader
+	timeout time.Duration // see WithTimeout
+
+	interceptors []Interceptor // see Use
+}
+
+// ClientOption configures a Client
+type ClientOption func(c *Client)
+
+// WithHTTPClient sends requests with hc instead of http.DefaultClient
+func WithHTTPClient(hc *http.Client) ClientOption {
+	return func(c *Client) { c.client = hc }
+}
+
+// WithHeader adds a header to every request, unless the request has it already
+//
+//     c := jsonapi.NewClient(base, jsonapi.WithHeader("X-API-Key", key))
+func WithHeader(key, value string) ClientOption {
+	return func(c *Client) {
+		if c.header == nil {
+			c.header = http.Header{}
+		}
+		c.header.Add(key, value)
+	}
+}
+
+// WithTimeout limits how long each Call can take, including reading the response.
+// The server is told with X-Request-Timeout header, see MaxRequestTimeout.
+func WithTimeout(d time.Duration) ClientOption {
+	return func(c *Client) { c.timeout = d }
+}
+
+// WithSSE makes Stream read responses as server-sent events, regardless of the
+// content type. Responses of type text/event-stream are always read this way.
+func WithSSE() ClientOption {
+	return func(c *Client) { c.sse = true }
+}
+
+// NewClient creates a Client sending requests to urls relative to baseURL. It
+// panics if baseURL is invalid.
+func NewClient(baseURL string, opts ...ClientOption) *Client {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		panic(err)
+	}
+	c := &Client{base: u, client: http.DefaultClient}
+	for _, o := range opts {
+		o(c)
+	}
+	return c
+}
+
+// request creates a request with req encoded in JSON format as body
+func (c *Client) request(ctx context.Context, method, uri string, req interface{}) (*http.Request, error) {
+	u, err := c.base.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	var body io.Reader
+	if req != nil {
+		buf, err := json.Marshal(req)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(buf)
+	}
+	r, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if req != nil {
+		r.Header.Set("Content-Type", "application/json")
+	}
+	return r, nil
+}
+
+// responseError converts error response into Error
+func responseError(resp *http.Response) error {
+	buf, _ := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	ret := Error{Code: resp.StatusCode, Message: strings.TrimSpace(string(buf))}
+	var body ErrorBody
+	var msg string
+	if json.Unmarshal(buf, &body) == nil && body.Error.Code != 0 {
+		ret.Message, ret.Kind, ret.URL = body.Error.Message, body.Error.Kind, body.Error.URL
+		ret.Details = body.Error.Details
+	} else if json.Unmarshal(buf, &msg) == nil {
+		// "404: Resource not found" sent by StringErrorEncoder
+		ret.Message = strings.TrimPrefix(msg, strconv.Itoa(resp.StatusCode)+": ")
+		if ret.Message == strconv.Itoa(resp.StatusCode) {
+			ret.Message = ""
+		}
+	}
+	if resp.StatusCode >= 300 && resp.StatusCode < 400 && ret.URL == "" {
+		ret.URL = resp.Header.Get("Location")
+	}
+	return ret
+}
+
+// send sends r, and decodes response into out if it is not nil
+func (c *Client) send(r *http.Request, out interface{}) error {
+	resp, err := c.do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return responseError(resp)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("jsonapi: cannot decode response: %w", err)
+	}
+	return nil
+}
+
+// Call POSTs req encoded in JSON format to uri, and decodes the response into resp
+// if it is not nil. Nil req sends no body. Error responses are returned as Error,
+// with the status code and message sent by the server.
+//
+//     var reply HelloReply
+//     err := c.Call(ctx, "hello", HelloArgs{Name: "John"}, &reply)
+//     if e, ok := err.(jsonapi.Error); ok && e.Code == http.StatusNotFound {
+//         ...
+//     }
+func (c *Client) Call(ctx context.Context, uri string, req, resp interface{}) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+	r, err := c.request(ctx, "POST", uri, req)
+	if err != nil {
+		return err
+	}
+	if c.timeout > 0 {
+		r.Header.Set("X-Request-Timeout", strconv.FormatInt(c.timeout.Milliseconds(), 10))
+	}
+	return c.send(r, resp)
+}
+
+// StreamError is returned by Stream when the stream is broken before it ends,
+// like the server disconnects.
+type StreamError struct {
+	Received int // number of items passed to callback
+	Err      error
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("jsonapi: stream broken after %d items: %s", e.Received, e.Err)
+}
+
+func (e *StreamError) Unwrap() error { return e.Err }
+
+// Stream sends req in JSON format, and calls fn with each item of the streaming
+// response: a line of NDJSON, or data of an event if it is server-sent events.
+// Items are passed as is, the next one is not read until fn returns.
+//
+// It stops when the stream ends, ctx is done, or fn returns an error, which is
+// returned. Responses with status code >= 300 are returned as Error.
+func (c *Client) Stream(ctx context.Context, method, uri string, req interface{}, fn func(json.RawMessage) error) error {
+	r, err := c.request(ctx, method, uri, req)
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Accept", "application/x-ndjson, text/event-stream")
+	resp, err := c.do(r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return responseError(resp)
+	}
+
+	next := readNDJSON
+	if c.sse || strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		next = readEvent
+	}
+	br := bufio.NewReader(resp.Body)
+	for n := 0; ; n++ {
+		// buffered items are not passed to fn after ctx is done
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		item, err := next(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return &StreamError{Received: n, Err: err}
+		}
+		if !json.Valid(item) {
+			return &StreamError{Received: n, Err: errors.New("invalid JSON item")}
+		}
+		if err := fn(item); err != nil {
+			return err
+		}
+	}
+}
+
+// readNDJSON reads next non-empty line
+func readNDJSON(br *bufio.Reader) (json.RawMessage, error) {
+	for {
+		line, err := br.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			// last line might not be terminated by newline
+			if err == io.EOF {
+				err = nil
+			}
+			return line, err
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readEvent reads data of next server-sent event
+func readEvent(br *bufio.Reader) (json.RawMessage, error) {
+	var data [][]byte
+	for {
+		line, err := br.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			if err == io.EOF && len(data) > 0 {
+				// stream ended without blank line after the last event
+				return bytes.Join(data, []byte("\n")), nil
+			}
+			return nil, err
+		}
+		line = bytes.TrimRight(line, "\r\n")
+		if len(line) == 0 {
+			if len(data) > 0 {
+				return bytes.Join(data, []byte("\n")), nil
+			}
+			continue
+		}
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		if string(field) == "data" {
+			data = append(data, bytes.TrimPrefix(value, []byte(" ")))
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package jsonapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// streamServer streams n NDJSON lines, and aborts the connection after them if
+// abort is set
+func streamServer(n int, abort bool) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/events" {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(ErrorBody{Error: ErrorInfo{Code: 404, Message: "no such stream"}})
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(w, "{\"seq\":%d}\n", i)
+		}
+		w.(http.Flusher).Flush()
+		if abort {
+			panic(http.ErrAbortHandler)
+		}
+	}))
+}
+
+func collect(items *[]int) func(json.RawMessage) error {
+	return func(item json.RawMessage) error {
+		var v struct{ Seq int }
+		if err := json.Unmarshal(item, &v); err != nil {
+			return err
+		}
+		*items = append(*items, v.Seq)
+		return nil
+	}
+}
+
+func TestClientStream(t *testing.T) {
+	srv := streamServer(300, false)
+	defer srv.Close()
+	c := NewClient(srv.URL + "/api/")
+
+	var items []int
+	if err := c.Stream(context.Background(), "GET", "events", nil, collect(&items)); err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 300 || items[299] != 299 {
+		t.Errorf("unexpected items: %d", len(items))
+	}
+
+	err := c.Stream(context.Background(), "GET", "missing", nil, collect(&items))
+	if e, ok := err.(Error); !ok || e.Code != 404 || e.Message != "no such stream" {
+		t.Errorf("expected Error, got %#v", err)
+	}
+}
+
+func TestClientStreamAbort(t *testing.T) {
+	srv := streamServer(200, true)
+	defer srv.Close()
+
+	var items []int
+	err := NewClient(srv.URL+"/api/").Stream(context.Background(), "GET", "events", nil, collect(&items))
+	var se *StreamError
+	if !errors.As(err, &se) || se.Received != 200 || len(items) != 200 {
+		t.Errorf("expected StreamError after 200 items, got %v with %d items", err, len(items))
+	}
+}
+
+func TestClientStreamStop(t *testing.T) {
+	srv := streamServer(300, false)
+	defer srv.Close()
+	c := NewClient(srv.URL + "/api/")
+
+	stop := errors.New("enough")
+	n := 0
+	err := c.Stream(context.Background(), "GET", "events", nil, func(item json.RawMessage) error {
+		if n++; n == 10 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || n != 10 {
+		t.Errorf("expected error of callback after 10 items, got %v after %d", err, n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	n = 0
+	err = c.Stream(ctx, "GET", "events", nil, func(item json.RawMessage) error {
+		if n++; n == 5 {
+			cancel()
+		}
+		return nil
+	})
+	if err != context.Canceled || n != 5 {
+		t.Errorf("expected context.Canceled after 5 items, got %v after %d", err, n)
+	}
+}
+
+func TestClientStreamSSE(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": comment\n\nevent: user\nid: 1\ndata: {\"name\":\ndata: \"john\"}\n\n")
+		fmt.Fprint(w, "data: [1,2]\r\n\r\ndata: 3")
+	}))
+	defer srv.Close()
+
+	var items []string
+	err := NewClient(srv.URL).Stream(context.Background(), "GET", "/", nil, func(item json.RawMessage) error {
+		items = append(items, string(item))
+		return nil
+	})
+	if err != nil || fmt.Sprint(items) != "[{\"name\":\n\"john\"} [1,2] 3]" {
+		t.Errorf("unexpected events %q: %v", items, err)
+	}
+}
+
+func TestClientCall(t *testing.T) {
+	m := NewMuxTest([]API{
+		{Pattern: "POST /api/hello", APIHandler: Typed(typedHello)},
+		{Pattern: "POST /api/user/{id}", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			e := E404.SetData("User not found").WithDetails(map[string]interface{}{"id": httpData.Request.PathValue("id")})
+			e.Kind = "user_not_found"
+			return nil, e
+		}},
+		{Pattern: "POST /api/whoami", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return []string{
+				httpData.Request.Header.Get("X-API-Key"),
+				httpData.Request.Header.Get("X-Request-Timeout"),
+				httpData.Request.Header.Get("Content-Type"),
+			}, nil
+		}},
+		{Pattern: "POST /api/slow", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			<-httpData.Request.Context().Done()
+			return nil, nil
+		}},
+	})
+	m.Mux.HandleFunc("/api/proxy", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "upstream is down", http.StatusBadGateway)
+	})
+	srv := m.StartServer()
+	defer srv.Close()
+	ctx := context.Background()
+
+	c := NewClient(srv.URL+"/api/", WithHTTPClient(srv.Client()), WithHeader("X-API-Key", "k1"))
+	var reply typedReply
+	if err := c.Call(ctx, "hello", typedArgs{Name: "John", Title: "Mr."}, &reply); err != nil || reply.Message != "Hello, Mr. John" {
+		t.Errorf("unexpected reply %+v, %v", reply, err)
+	}
+
+	cases := []struct {
+		uri    string
+		req    interface{}
+		expect Error
+	}{
+		{"hello", typedArgs{Title: "Dr."}, Error{Code: 422, Message: "name is required", Kind: KindValidation}},
+		{"/api/proxy", nil, Error{Code: 502, Message: "upstream is down"}},
+	}
+	for _, tc := range cases {
+		err := c.Call(ctx, tc.uri, tc.req, nil)
+		if e, ok := err.(Error); !ok || e.Code != tc.expect.Code || e.Message != tc.expect.Message || e.Kind != tc.expect.Kind {
+			t.Errorf("%s: expected %+v, got %#v", tc.uri, tc.expect, err)
+		}
+	}
+	err := c.Call(ctx, "user/42", nil, nil)
+	e, ok := err.(Error)
+	if details, _ := e.Details.(map[string]interface{}); !ok || e.Code != 404 || e.Kind != "user_not_found" || details["id"] != "42" {
+		t.Errorf("unexpected error %#v", err)
+	}
+
+	var who []string
+	if err := c.Call(ctx, "whoami", map[string]int{}, &who); err != nil || fmt.Sprint(who) != "[k1  application/json]" {
+		t.Errorf("unexpected headers %q, %v", who, err)
+	}
+	// headers of the request are kept
+	c = NewClient(srv.URL+"/api/", WithHTTPClient(srv.Client()), WithHeader("Content-Type", "text/plain"), WithTimeout(time.Second))
+	if err := c.Call(ctx, "whoami", nil, &who); err != nil || fmt.Sprint(who) != "[ 1000 text/plain]" {
+		t.Errorf("unexpected headers %q, %v", who, err)
+	}
+	if err := c.Call(ctx, "whoami", map[string]int{}, &who); err != nil || fmt.Sprint(who) != "[ 1000 application/json]" {
+		t.Errorf("unexpected headers %q, %v", who, err)
+	}
+
+	c = NewClient(srv.URL+"/api/", WithHTTPClient(srv.Client()), WithTimeout(50*time.Millisecond))
+	start := time.Now()
+	if err := c.Call(ctx, "slow", nil, nil); !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 5*time.Second {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package jsonapi
+
+import (
+	"fmt"
+	"net"
+	"strings"
+)
+
+var trustedProxies []*net.IPNet
+
+// TrustProxies declares networks of reverse proxies and load balancers in front
+// of the server, like "10.0.0.0/8" or "2001:db8::1", so ClientIP believes headers
+// set by them. It replaces the networks declared before, and is not safe to call
+// while serving requests.
+func TrustProxies(cidrs ...string) error {
+	nets := make([]*net.IPNet, 0, len(cidrs))
+	for _, c := range cidrs {
+		if !strings.Contains(c, "/") {
+			ip := net.ParseIP(c)
+			if ip == nil {
+				return fmt.Errorf("jsonapi: invalid proxy address %q", c)
+			}
+			bits := 8 * net.IPv6len
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, 8*net.IPv4len
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			return fmt.Errorf("jsonapi: invalid proxy network %q: %w", c, err)
+		}
+		nets = append(nets, n)
+	}
+	trustedProxies = nets
+	return nil
+}
+
+func trustedProxy(ip net.IP) bool {
+	for _, n := range trustedProxies {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIP parses addresses like "192.0.2.1", "192.0.2.1:80", "[2001:db8::1]:80"
+// or "2001:db8::1", it returns nil if addr is not an IP address
+func parseIP(addr string) net.IP {
+	addr = strings.TrimSpace(addr)
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]"))
+}
+
+// ClientIP returns IP address of the client, or empty string if it is unknown.
+//
+// X-Forwarded-For and X-Real-IP are used only if the request comes from proxies
+// declared by TrustProxies, as anyone can send them. X-Forwarded-For is read from
+// the nearest hop, the first address not belonging to trusted proxies is the
+// client. Otherwise it is the host part of RemoteAddr.
+func (h *HTTP) ClientIP() string {
+	remote := parseIP(h.Request.RemoteAddr)
+	if remote == nil {
+		return ""
+	}
+	if !trustedProxy(remote) {
+		return remote.String()
+	}
+
+	var hops []string
+	for _, v := range h.Request.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	if len(hops) == 0 {
+		if ip := parseIP(h.Request.Header.Get("X-Real-IP")); ip != nil {
+			return ip.String()
+		}
+		return remote.String()
+	}
+	ret := remote
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := parseIP(hops[i])
+		if ip == nil {
+			// the trusted hop sent a broken header
+			break
+		}
+		ret = ip
+		if !trustedProxy(ip) {
+			break
+		}
+	}
+	return ret.String()
+}
@@ -0,0 +1,66 @@
+package jsonapi
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	if err := TrustProxies("10.0.0.0/8", "2001:db8::1"); err != nil {
+		t.Fatal(err)
+	}
+	defer TrustProxies()
+
+	cases := []struct {
+		remote string
+		xff    []string
+		realIP string
+		expect string
+	}{
+		{"198.51.100.7:1234", nil, "", "198.51.100.7"},
+		{"[2001:db8::2]:443", nil, "", "2001:db8::2"},
+		{"2001:db8::2", nil, "", "2001:db8::2"},
+		{"@", nil, "", ""},
+		// spoofed headers from untrusted sources
+		{"203.0.113.5:1234", []string{"198.51.100.7"}, "198.51.100.8", "203.0.113.5"},
+		{"[2001:db8::2]:443", []string{"198.51.100.7"}, "", "2001:db8::2"},
+
+		{"10.0.0.1:80", nil, "", "10.0.0.1"},
+		{"10.0.0.1:80", nil, "198.51.100.8", "198.51.100.8"},
+		{"10.0.0.1:80", []string{"198.51.100.7"}, "198.51.100.8", "198.51.100.7"},
+		{"10.0.0.1:80", []string{"198.51.100.7, 10.0.0.2"}, "", "198.51.100.7"},
+		{"10.0.0.1:80", []string{"6.6.6.6, 198.51.100.7,10.0.0.2"}, "", "198.51.100.7"},
+		{"10.0.0.1:80", []string{"6.6.6.6", "198.51.100.7"}, "", "198.51.100.7"},
+		{"10.0.0.1:80", []string{"[2001:db8::9]:1234"}, "", "2001:db8::9"},
+		{"10.0.0.1:80", []string{"10.0.0.5"}, "", "10.0.0.5"},
+		{"10.0.0.1:80", []string{"garbage, 10.0.0.3"}, "", "10.0.0.3"},
+		{"[2001:db8::1]:80", []string{"2001:db8::9"}, "", "2001:db8::9"},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.RemoteAddr = c.remote
+		for _, v := range c.xff {
+			req.Header.Add("X-Forwarded-For", v)
+		}
+		if c.realIP != "" {
+			req.Header.Set("X-Real-IP", c.realIP)
+		}
+		if ip := (&HTTP{Request: req}).ClientIP(); ip != c.expect {
+			t.Errorf("%s %q %q: expected %q, got %q", c.remote, c.xff, c.realIP, c.expect, ip)
+		}
+	}
+}
+
+func TestTrustProxies(t *testing.T) {
+	defer TrustProxies()
+	for _, c := range []string{"10.0.0.256", "10.0.0.0/33", "proxy.local"} {
+		if err := TrustProxies("10.0.0.1", c); err == nil {
+			t.Errorf("%s: expected error", c)
+		}
+	}
+	TrustProxies("10.0.0.1")
+	TrustProxies("10.0.0.2")
+	if trustedProxy(parseIP("10.0.0.1")) || !trustedProxy(parseIP("10.0.0.2")) {
+		t.Errorf("declared networks are not replaced: %v", trustedProxies)
+	}
+}
@@ -0,0 +1,204 @@
+package jsonapi
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock tells time to time-dependent features of this package, like RateLimiter,
+// ErrorRateMonitor and Sampler, so tests can control time with a FakeClock
+// instead of sleeping.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	After(d time.Duration) <-chan time.Time
+}
+
+// Timer is a timer created by Clock, see time.Timer
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// DefaultClock is the Clock used by this package, replace it in tests:
+//
+//     clock := jsonapi.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+//     jsonapi.DefaultClock = clock
+//     defer func() { jsonapi.DefaultClock = jsonapi.RealClock{} }()
+//     clock.Advance(time.Minute)
+var DefaultClock Clock = RealClock{}
+
+// RealClock is a Clock using functions of package time
+type RealClock struct{}
+
+// Now implements Clock
+func (RealClock) Now() time.Time { return time.Now() }
+
+// NewTimer implements Clock
+func (RealClock) NewTimer(d time.Duration) Timer { return realTimer{time.NewTimer(d)} }
+
+// After implements Clock
+func (RealClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time { return t.Timer.C }
+
+// FakeClock is a Clock which moves only when Advance is called
+type FakeClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*fakeTimer
+}
+
+// NewFakeClock creates a FakeClock starting at t
+func NewFakeClock(t time.Time) *FakeClock {
+	return &FakeClock{now: t}
+}
+
+// Now implements Clock
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d, and fires timers which expire
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	var fired, pending []*fakeTimer
+	for _, t := range c.timers {
+		if !t.when.After(now) {
+			fired = append(fired, t)
+		} else {
+			pending = append(pending, t)
+		}
+	}
+	c.timers = pending
+	c.mu.Unlock()
+
+	sort.SliceStable(fired, func(i, j int) bool { return fired[i].when.Before(fired[j].when) })
+	for _, t := range fired {
+		select {
+		case t.ch <- now:
+		default:
+		}
+	}
+}
+
+// NewTimer implements Clock
+func (c *FakeClock) NewTimer(d time.Duration) Timer {
+	t := &fakeTimer{clock: c, ch: make(chan time.Time, 1)}
+	t.Reset(d)
+	return t
+}
+
+// After implements Clock
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+type fakeTimer struct {
+	clock *FakeClock
+	when  time.Time
+	ch    chan time.Time
+}
+
+func (t *fakeTimer) C() <-chan time.Time { return t.ch }
+
+// Stop removes t from pending timers, reports whether it was pending
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, x := range c.timers {
+		if x == t {
+			c.timers = append(c.timers[:i], c.timers[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Reset schedules t to fire after d, reports whether it was pending
+func (t *fakeTimer) Reset(d time.Duration) bool {
+	active := t.Stop()
+	c := t.clock
+	c.mu.Lock()
+	t.when = c.now.Add(d)
+	if d <= 0 {
+		c.mu.Unlock()
+		select {
+		case t.ch <- t.when:
+		default:
+		}
+		return active
+	}
+	c.timers = append(c.timers, t)
+	c.mu.Unlock()
+	return active
+}
+
+// withTimeout is context.WithTimeout timed by DefaultClock, so a FakeClock can
+// expire request contexts
+func withTimeout(parent context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := DefaultClock.(RealClock); ok {
+		return context.WithTimeout(parent, d)
+	}
+	deadline := DefaultClock.Now().Add(d)
+	if cur, ok := parent.Deadline(); ok && cur.Before(deadline) {
+		return context.WithCancel(parent)
+	}
+	inner, cancel := context.WithCancel(parent)
+	ctx := &clockContext{Context: inner, cancel: cancel, deadline: deadline}
+	t := DefaultClock.NewTimer(d)
+	go func() {
+		select {
+		case <-t.C():
+			ctx.expire()
+		case <-inner.Done():
+			t.Stop()
+		}
+	}()
+	return ctx, cancel
+}
+
+// clockContext is canceled with context.DeadlineExceeded when timer of
+// DefaultClock fires, see withTimeout
+type clockContext struct {
+	context.Context
+	cancel   context.CancelFunc
+	deadline time.Time
+
+	mu      sync.Mutex
+	expired bool
+}
+
+func (c *clockContext) Deadline() (time.Time, bool) {
+	return c.deadline, true
+}
+
+func (c *clockContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.expired {
+		return context.DeadlineExceeded
+	}
+	return c.Context.Err()
+}
+
+func (c *clockContext) expire() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Context.Err() == nil {
+		c.expired = true
+	}
+	c.cancel()
+}
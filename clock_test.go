@@ -0,0 +1,87 @@
+package jsonapi
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+
+	late, early := clock.NewTimer(2*time.Second), clock.NewTimer(time.Second)
+	after := clock.After(3 * time.Second)
+	stopped := clock.NewTimer(time.Second)
+	if !stopped.Stop() || stopped.Stop() {
+		t.Errorf("Stop should report whether timer was pending")
+	}
+
+	clock.Advance(1500 * time.Millisecond)
+	if now := clock.Now(); !now.Equal(start.Add(1500 * time.Millisecond)) {
+		t.Errorf("Now = %s", now)
+	}
+	select {
+	case at := <-early.C():
+		if !at.Equal(clock.Now()) {
+			t.Errorf("fired at %s", at)
+		}
+	default:
+		t.Errorf("timer did not fire")
+	}
+	select {
+	case <-late.C():
+		t.Errorf("timer fired early")
+	case <-stopped.C():
+		t.Errorf("stopped timer fired")
+	default:
+	}
+
+	if !late.Reset(time.Hour) {
+		t.Errorf("Reset should report pending timer")
+	}
+	clock.Advance(2 * time.Second)
+	select {
+	case <-after:
+	default:
+		t.Errorf("After did not fire")
+	}
+	select {
+	case <-late.C():
+		t.Errorf("reset timer fired at old time")
+	default:
+	}
+}
+
+func TestMemoryRateLimitStoreClock(t *testing.T) {
+	clock, restore := withFakeClock()
+	defer restore()
+	ctx := context.Background()
+	s := NewMemoryRateLimitStore()
+
+	s.Increment(ctx, "k", time.Minute)
+	clock.Advance(30 * time.Second)
+	if n, left, _ := s.Increment(ctx, "k", time.Minute); n != 2 || left != 30*time.Second {
+		t.Errorf("count %d, %s left", n, left)
+	}
+	clock.Advance(30 * time.Second)
+	if n, left, _ := s.Increment(ctx, "k", time.Minute); n != 1 || left != time.Minute {
+		t.Errorf("entry not expired: count %d, %s left", n, left)
+	}
+
+	// 2 tokens per second, up to 4
+	for i := 0; i < 4; i++ {
+		s.TakeToken(ctx, "b", 2, 4)
+	}
+	if ok, _, wait, _ := s.TakeToken(ctx, "b", 2, 4); ok || wait != 500*time.Millisecond {
+		t.Errorf("empty bucket: ok %v, wait %s", ok, wait)
+	}
+	clock.Advance(time.Second)
+	if ok, remaining, _, _ := s.TakeToken(ctx, "b", 2, 4); !ok || remaining != 1 {
+		t.Errorf("refilled bucket: ok %v, remaining %d", ok, remaining)
+	}
+	clock.Advance(time.Hour)
+	if ok, remaining, _, _ := s.TakeToken(ctx, "b", 2, 4); !ok || remaining != 3 {
+		t.Errorf("full bucket: ok %v, remaining %d", ok, remaining)
+	}
+}
@@ -0,0 +1,227 @@
+// Command jsonapi-gen generates skeleton of a new API endpoint: request and
+// response types with a validation stub, the handler, an entry for the []jsonapi.API
+// slice, and a table-driven test using jsonapi.HandlerTest.
+//
+// It is designed for go:generate:
+//
+//     //go:generate go run github.com/Patrolavia/jsonapi/cmd/jsonapi-gen -method POST -route /api/user -request CreateUserArgs -response User -handler createUser
+//
+// which writes create_user.go and create_user_test.go into current directory.
+// Existing files are never overwritten.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// Definition describes the endpoint to generate
+type Definition struct {
+	Package  string
+	Method   string
+	Route    string
+	Handler  string
+	Request  string
+	Response string
+	Types    bool // generate request and response types
+}
+
+func main() {
+	var def Definition
+	flag.StringVar(&def.Package, "package", os.Getenv("GOPACKAGE"), "package name, defaults to $GOPACKAGE set by go generate")
+	flag.StringVar(&def.Method, "method", "GET", "HTTP method")
+	flag.StringVar(&def.Route, "route", "", "path of the route, like /api/user")
+	flag.StringVar(&def.Handler, "handler", "", "name of the handler function")
+	flag.StringVar(&def.Request, "request", "", "name of request type")
+	flag.StringVar(&def.Response, "response", "", "name of response type")
+	flag.BoolVar(&def.Types, "types", true, "generate request and response types")
+	dir := flag.String("dir", ".", "output directory")
+	flag.Parse()
+
+	files, err := Generate(def)
+	if err != nil {
+		log.Fatalf("jsonapi-gen: %s", err)
+	}
+	for name := range files {
+		if _, err := os.Stat(filepath.Join(*dir, name)); err == nil {
+			log.Fatalf("jsonapi-gen: %s already exists", name)
+		}
+	}
+	for name, data := range files {
+		if err := ioutil.WriteFile(filepath.Join(*dir, name), data, 0644); err != nil {
+			log.Fatalf("jsonapi-gen: %s", err)
+		}
+	}
+}
+
+// Generate creates source files of def, keyed by file name
+func Generate(def Definition) (map[string][]byte, error) {
+	def.Method = strings.ToUpper(def.Method)
+	if def.Request == "" {
+		def.Request = exported(def.Handler) + "Request"
+	}
+	if def.Response == "" {
+		def.Response = exported(def.Handler) + "Response"
+	}
+	switch {
+	case def.Package == "":
+		return nil, errors.New("package name is required")
+	case !strings.HasPrefix(def.Route, "/"):
+		return nil, errors.New("route must start with /")
+	case !token.IsIdentifier(def.Handler):
+		return nil, fmt.Errorf("invalid handler name %q", def.Handler)
+	case !token.IsIdentifier(def.Request) || !token.IsIdentifier(def.Response):
+		return nil, fmt.Errorf("invalid type name %q or %q", def.Request, def.Response)
+	case testMethods[def.Method] == "":
+		return nil, fmt.Errorf("unsupported method %q", def.Method)
+	}
+
+	base := snake(def.Handler)
+	ret := map[string][]byte{}
+	for name, tmpl := range map[string]*template.Template{
+		base + ".go":      handlerTemplate,
+		base + "_test.go": testTemplate,
+	} {
+		buf := &bytes.Buffer{}
+		if err := tmpl.Execute(buf, def); err != nil {
+			return nil, err
+		}
+		src, err := format.Source(buf.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("formatting %s: %s", name, err)
+		}
+		ret[name] = src
+	}
+	return ret, nil
+}
+
+// TestName is name of generated test function
+func (def Definition) TestName() string {
+	return "Test" + exported(def.Handler)
+}
+
+// testMethods are methods of jsonapi.HandlerTest sending requests of each method
+var testMethods = map[string]string{
+	"GET":    "Get",
+	"POST":   "PostJSON",
+	"PUT":    "PutJSON",
+	"PATCH":  "PatchJSON",
+	"DELETE": "DeleteJSON",
+}
+
+// TestCall is the call sending request in generated test
+func (def Definition) TestCall() string {
+	if def.Method == "GET" {
+		return fmt.Sprintf("h.Get(%q, \"\")", def.Route)
+	}
+	return fmt.Sprintf("h.%s(%q, \"\", c.req)", testMethods[def.Method], def.Route)
+}
+
+func exported(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// snake converts createUser into create_user
+func snake(s string) string {
+	buf := &bytes.Buffer{}
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				buf.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+var handlerTemplate = template.Must(template.New("handler").Parse(`// Skeleton generated by jsonapi-gen, edit as you need.
+
+package {{.Package}}
+
+import (
+	"encoding/json"
+
+	"github.com/Patrolavia/jsonapi"
+)
+{{if .Types}}
+// {{.Request}} is request of {{.Method}} {{.Route}}
+type {{.Request}} struct {
+}
+
+// Validate checks {{.Request}} before it is passed to {{.Handler}}
+func (r *{{.Request}}) Validate() error {
+	return nil
+}
+
+// {{.Response}} is response of {{.Method}} {{.Route}}
+type {{.Response}} struct {
+}
+{{end}}
+// {{.Handler}}API registers {{.Handler}}, add it to your []jsonapi.API
+var {{.Handler}}API = jsonapi.API{
+	Pattern:    "{{.Method}} {{.Route}}",
+	APIHandler: {{.Handler}},
+}
+
+// {{.Handler}} handles {{.Method}} {{.Route}}
+func {{.Handler}}(dec *json.Decoder, httpData *jsonapi.HTTP) (interface{}, error) {
+	var req {{.Request}}
+	if err := jsonapi.Bind(dec, httpData, &req); err != nil {
+		return nil, err
+	}
+
+	resp := {{.Response}}{}
+	return resp, nil
+}
+`))
+
+var testTemplate = template.Must(template.New("test").Parse(`// Skeleton generated by jsonapi-gen, edit as you need.
+
+package {{.Package}}
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Patrolavia/jsonapi"
+)
+
+func {{.TestName}}(t *testing.T) {
+	cases := []struct {
+		name   string
+		req    {{.Request}}
+		status int
+	}{
+		{name: "ok", req: {{.Request}}{}, status: http.StatusOK},
+	}
+
+	h := jsonapi.HandlerTest(jsonapi.APIHandler({{.Handler}}).Handler)
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			resp, err := {{.TestCall}}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if resp.Code != c.status {
+				t.Errorf("expected status %d, got %d: %s", c.status, resp.Code, resp.Body.String())
+			}
+		})
+	}
+}
+`))
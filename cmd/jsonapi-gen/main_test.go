@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+func TestGenerate(t *testing.T) {
+	for _, method := range []string{"GET", "POST", "put", "PATCH", "DELETE"} {
+		def := Definition{
+			Package: "api",
+			Method:  method,
+			Route:   "/api/user",
+			Handler: strings.ToLower(method) + "User",
+			Types:   true,
+		}
+		files, err := Generate(def)
+		if err != nil {
+			t.Fatalf("%s: %s", method, err)
+		}
+		if len(files) != 2 {
+			t.Errorf("%s: files = %d", method, len(files))
+		}
+		for name, src := range files {
+			if _, err := parser.ParseFile(token.NewFileSet(), name, src, 0); err != nil {
+				t.Errorf("%s: %s", name, err)
+			}
+			golden := filepath.Join("testdata", name+".golden")
+			if *update {
+				if err := ioutil.WriteFile(golden, src, 0644); err != nil {
+					t.Fatal(err)
+				}
+				continue
+			}
+			want, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(src, want) {
+				t.Errorf("%s differs from %s, run with -update if intended:\n%s", name, golden, src)
+			}
+		}
+	}
+}
+
+func TestGenerateInvalid(t *testing.T) {
+	valid := Definition{Package: "api", Method: "POST", Route: "/api/user", Handler: "createUser"}
+	for name, modify := range map[string]func(*Definition){
+		"no package": func(d *Definition) { d.Package = "" },
+		"route":      func(d *Definition) { d.Route = "api/user" },
+		"handler":    func(d *Definition) { d.Handler = "create-user" },
+		"type":       func(d *Definition) { d.Request = "1Args" },
+		"method":     func(d *Definition) { d.Method = "TRACE" },
+	} {
+		def := valid
+		modify(&def)
+		if _, err := Generate(def); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
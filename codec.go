@@ -0,0 +1,228 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Codec encodes and decodes bodies in a media type other than JSON, see
+// RegisterCodec.
+//
+// Handlers still work with JSON: request bodies are converted into JSON before
+// being decoded by the *json.Decoder, and response bodies in JSON are converted
+// after being encoded, so json struct tags apply to all formats. Encoder and
+// Decoder work with values in the form of JSON: map[string]interface{},
+// []interface{}, string, int64, float64, bool and nil.
+type Codec interface {
+	ContentType() string
+	NewEncoder(w io.Writer) Encoder
+
+	// NewDecoder returns nil if requests in this format are not supported
+	NewDecoder(r io.Reader) Decoder
+}
+
+// Encoder is created by Codec to encode values, like *json.Encoder
+type Encoder interface {
+	Encode(v interface{}) error
+}
+
+// Decoder is created by Codec to decode values, like *json.Decoder. It returns
+// io.EOF if there is no more value.
+type Decoder interface {
+	Decode(v interface{}) error
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) ContentType() string            { return "application/json" }
+func (jsonCodec) NewEncoder(w io.Writer) Encoder { return json.NewEncoder(w) }
+func (jsonCodec) NewDecoder(r io.Reader) Decoder { return json.NewDecoder(r) }
+
+// JSONCodec is the default codec, used if client accepts no other registered one
+var JSONCodec Codec = jsonCodec{}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{"application/json": JSONCodec}
+)
+
+// RegisterCodec adds (or replaces) a codec of its media type. Requests with the
+// media type as Content-Type are decoded with it, and responses are encoded with
+// it if the Accept header prefers it. Clients accepting nothing registered get
+// JSON. XMLCodec is built in, and MessagePack is shipped as an optional codec,
+// which is compiled only with build tag jsonapi_msgpack.
+//
+//     jsonapi.RegisterCodec(jsonapi.XMLCodec)
+//
+// Once a codec is registered, requests in other media types are rejected with a
+// 415 Error of KindUnsupportedMediaType, except multipart/form-data, which is left
+// to MultipartFile. Requests without Content-Type are handled as JSON. Responses
+// not in JSON, like Stream or Raw, are not converted.
+func RegisterCodec(c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[strings.ToLower(c.ContentType())] = c
+}
+
+// requestCodec returns codec decoding request body, nil means JSON or not
+// supported
+func requestCodec(r *http.Request) Codec {
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return nil
+	}
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	c, ok := codecs[mt]
+	if !ok || c == JSONCodec {
+		return nil
+	}
+	return c
+}
+
+// unknownBody reports whether r has a body in media type no registered codec
+// decodes, see RegisterCodec
+func unknownBody(r *http.Request) bool {
+	ct := r.Header.Get("Content-Type")
+	if r.ContentLength == 0 || ct == "" || isMultipart(r) {
+		return false
+	}
+	codecsMu.RLock()
+	registered := len(codecs) > 1
+	codecsMu.RUnlock()
+	if !registered {
+		return false
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	return err != nil || !(jsonMediaType(mt) || requestCodec(r) != nil)
+}
+
+// errUnknownMediaType is sent if request body cannot be decoded by any codec
+func errUnknownMediaType(r *http.Request) Error {
+	return Error{
+		Code:    http.StatusUnsupportedMediaType,
+		Message: fmt.Sprintf("Content-Type %q is not supported", r.Header.Get("Content-Type")),
+		Kind:    KindUnsupportedMediaType,
+	}
+}
+
+// negotiateCodec selects codec encoding response by the Accept header, and
+// reports whether other codecs than JSON are registered
+func negotiateCodec(r *http.Request) (c Codec, negotiable bool) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	if len(codecs) == 1 {
+		return JSONCodec, false
+	}
+
+	best, bestQ := JSONCodec, 0.0
+	for _, line := range r.Header.Values("Accept") {
+		for _, item := range strings.Split(line, ",") {
+			mt, params, err := mime.ParseMediaType(strings.TrimSpace(item))
+			if err != nil {
+				continue
+			}
+			q := 1.0
+			if v, ok := params["q"]; ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+			c, ok := codecs[mt]
+			if !ok && (mt == "*/*" || mt == "application/*") {
+				c, ok = JSONCodec, true
+			}
+			if ok && q > bestQ {
+				best, bestQ = c, q
+			}
+		}
+	}
+	return best, true
+}
+
+// codecReader converts request body decoded by Codec into JSON
+type codecReader struct {
+	io.ReadCloser
+	dec Decoder
+	buf bytes.Buffer
+	err error
+}
+
+func (r *codecReader) Read(p []byte) (int, error) {
+	for r.buf.Len() == 0 && r.err == nil {
+		var v interface{}
+		if r.err = r.dec.Decode(&v); r.err != nil {
+			break
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			r.err = err
+			break
+		}
+		r.buf.Write(b)
+		r.buf.WriteByte('\n')
+	}
+	if r.buf.Len() > 0 {
+		return r.buf.Read(p)
+	}
+	return 0, r.err
+}
+
+// transcode converts JSON values in data into format of c, data is returned as-is
+// if it is not JSON
+func transcode(c Codec, data []byte) []byte {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var values []interface{}
+	for {
+		var v interface{}
+		err := dec.Decode(&v)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Printf("jsonapi: cannot convert response into %s: %v", c.ContentType(), err)
+			return data
+		}
+		values = append(values, plainNumbers(v))
+	}
+
+	buf := &bytes.Buffer{}
+	enc := c.NewEncoder(buf)
+	for _, v := range values {
+		if err := enc.Encode(v); err != nil {
+			log.Printf("jsonapi: cannot encode response in %s: %v", c.ContentType(), err)
+			break
+		}
+	}
+	return buf.Bytes()
+}
+
+// plainNumbers replaces json.Number in v with int64 or float64
+func plainNumbers(v interface{}) interface{} {
+	switch x := v.(type) {
+	case json.Number:
+		if i, err := x.Int64(); err == nil {
+			return i
+		}
+		f, _ := x.Float64()
+		return f
+	case map[string]interface{}:
+		for k, e := range x {
+			x[k] = plainNumbers(e)
+		}
+	case []interface{}:
+		for i, e := range x {
+			x[i] = plainNumbers(e)
+		}
+	}
+	return v
+}
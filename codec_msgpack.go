@@ -0,0 +1,31 @@
+//go:build jsonapi_msgpack
+// +build jsonapi_msgpack
+
+package jsonapi
+
+import (
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type msgpackCodec struct {
+	contentType string
+}
+
+func (c msgpackCodec) ContentType() string { return c.contentType }
+
+func (msgpackCodec) NewEncoder(w io.Writer) Encoder {
+	enc := msgpack.NewEncoder(w)
+	enc.SetSortMapKeys(true)
+	return enc
+}
+
+func (msgpackCodec) NewDecoder(r io.Reader) Decoder {
+	return msgpack.NewDecoder(r)
+}
+
+func init() {
+	RegisterCodec(msgpackCodec{"application/msgpack"})
+	RegisterCodec(msgpackCodec{"application/x-msgpack"})
+}
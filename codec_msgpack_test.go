@@ -0,0 +1,35 @@
+//go:build jsonapi_msgpack
+// +build jsonapi_msgpack
+
+package jsonapi
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestMsgpackCodec(t *testing.T) {
+	m := NewMuxTest([]API{echoAPI})
+	body, err := msgpack.Marshal(map[string]interface{}{"a": 1, "b": "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, _ := http.NewRequest("POST", "/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/msgpack")
+	req.Header.Set("Accept", "application/x-msgpack")
+	resp := m.Do(req)
+	if resp.Code != http.StatusOK || resp.Header().Get("Content-Type") != "application/x-msgpack" {
+		t.Fatalf("got %d %v", resp.Code, resp.Header())
+	}
+
+	var got map[string]interface{}
+	if err := msgpack.Unmarshal(resp.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["b"] != "x" {
+		t.Errorf("got %v", got)
+	}
+}
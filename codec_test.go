@@ -0,0 +1,132 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// testCodec is JSON with a prefix, so it is told apart from JSON
+type testCodec struct{}
+
+func (testCodec) ContentType() string { return "application/vnd.test" }
+func (testCodec) NewEncoder(w io.Writer) Encoder {
+	return encoderFunc(func(v interface{}) error {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(w, "TEST:%s\n", b)
+		return err
+	})
+}
+func (testCodec) NewDecoder(r io.Reader) Decoder { return json.NewDecoder(r) }
+
+type encoderFunc func(v interface{}) error
+
+func (f encoderFunc) Encode(v interface{}) error { return f(v) }
+
+// withCodecs registers codecs until the returned function is called
+func withCodecs(cs ...Codec) func() {
+	for _, c := range cs {
+		RegisterCodec(c)
+	}
+	return func() {
+		codecsMu.Lock()
+		defer codecsMu.Unlock()
+		for _, c := range cs {
+			delete(codecs, c.ContentType())
+		}
+	}
+}
+
+var echoAPI = API{Pattern: "/", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+	var v map[string]interface{}
+	if err := DecodeOrEmpty(dec, &v); err != nil {
+		return nil, E400.SetData(err.Error())
+	}
+	return v, nil
+}}
+
+func codecRequest(t *testing.T, m *MuxTest, contentType, accept, body string) (int, http.Header, string) {
+	t.Helper()
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp := m.Do(req)
+	return resp.Code, resp.Header(), resp.Body.String()
+}
+
+func TestCodecNegotiation(t *testing.T) {
+	defer withCodecs(XMLCodec, testCodec{})()
+	m := NewMuxTest([]API{echoAPI})
+
+	cases := []struct {
+		accept, contentType, body string
+	}{
+		{"", "application/json", "{\"a\":1}\n"},
+		{"application/xml", "application/xml", "<response><a>1</a></response>\n"},
+		{"application/vnd.test", "application/vnd.test", "TEST:{\"a\":1}\n"},
+		{"application/xml;q=0.5, application/vnd.test", "application/vnd.test", "TEST:{\"a\":1}\n"},
+		{"application/xml, application/json;q=0.1", "application/xml", "<response><a>1</a></response>\n"},
+		{"text/html, */*;q=0.1", "application/json", "{\"a\":1}\n"},
+		{"image/png", "application/json", "{\"a\":1}\n"},
+	}
+	for _, c := range cases {
+		code, header, body := codecRequest(t, m, "", c.accept, `{"a":1}`)
+		if code != http.StatusOK || header.Get("Content-Type") != c.contentType || body != c.body {
+			t.Errorf("Accept %q: got %d %s %q", c.accept, code, header.Get("Content-Type"), body)
+		}
+		if !strings.Contains(header.Get("Vary"), "Accept") {
+			t.Errorf("Accept %q: Vary = %q", c.accept, header.Get("Vary"))
+		}
+	}
+}
+
+func TestCodecRequestBody(t *testing.T) {
+	defer withCodecs(XMLCodec, testCodec{})()
+	m := NewMuxTest([]API{echoAPI})
+
+	cases := []struct {
+		contentType, body string
+		code              int
+	}{
+		{"", `{"a":1}`, http.StatusOK},
+		{"application/json", `{"a":1}`, http.StatusOK},
+		{"application/merge-patch+json", `{"a":1}`, http.StatusOK},
+		{"application/vnd.test", `{"a":1}`, http.StatusOK},
+		{"application/xml", `<a>1</a>`, http.StatusUnsupportedMediaType},
+		{"text/plain", `{"a":1}`, http.StatusUnsupportedMediaType},
+		{"application/x-www-form-urlencoded", `a=1`, http.StatusUnsupportedMediaType},
+		{"not a media type", `{"a":1}`, http.StatusUnsupportedMediaType},
+		{"text/plain", ``, http.StatusOK},
+	}
+	for _, c := range cases {
+		code, _, body := codecRequest(t, m, c.contentType, "", c.body)
+		if code != c.code {
+			t.Errorf("Content-Type %q: status = %d, want %d: %s", c.contentType, code, c.code, body)
+			continue
+		}
+		if code == http.StatusUnsupportedMediaType {
+			var e ErrorBody
+			if err := json.Unmarshal([]byte(body), &e); err != nil || e.Error.Kind != KindUnsupportedMediaType {
+				t.Errorf("Content-Type %q: body = %s", c.contentType, body)
+			}
+		}
+	}
+}
+
+func TestCodecUnknownContentTypeWithoutCodecs(t *testing.T) {
+	m := NewMuxTest([]API{echoAPI})
+	code, header, body := codecRequest(t, m, "text/plain", "application/xml", `{"a":1}`)
+	if code != http.StatusOK || header.Get("Content-Type") != "application/json" || header.Get("Vary") != "" {
+		t.Errorf("got %d %v %s", code, header, body)
+	}
+}
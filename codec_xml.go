@@ -0,0 +1,115 @@
+package jsonapi
+
+import (
+	"encoding/xml"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type xmlCodec struct{}
+
+// XMLCodec encodes responses in XML, register it with RegisterCodec. Requests in
+// XML are rejected with a 415 Error of KindUnsupportedMediaType.
+//
+// The response is the root element, named "response". Properties of objects are
+// child elements named with the keys, or "entry" with a key attribute if the key
+// is not a valid XML name. Elements of arrays are named "item".
+//
+//     {"id": 1, "tags": ["a", "b"]}
+//
+// is encoded as
+//
+//     <response><id>1</id><tags><item>a</item><item>b</item></tags></response>
+//
+// Null is an empty element.
+var XMLCodec Codec = xmlCodec{}
+
+func (xmlCodec) ContentType() string            { return "application/xml" }
+func (xmlCodec) NewEncoder(w io.Writer) Encoder { return xmlEncoder{w} }
+func (xmlCodec) NewDecoder(r io.Reader) Decoder { return nil }
+
+type xmlEncoder struct {
+	w io.Writer
+}
+
+func (e xmlEncoder) Encode(v interface{}) error {
+	enc := xml.NewEncoder(e.w)
+	if err := writeXML(enc, "response", v); err != nil {
+		return err
+	}
+	if err := enc.Flush(); err != nil {
+		return err
+	}
+	_, err := e.w.Write([]byte{'\n'})
+	return err
+}
+
+func writeXML(enc *xml.Encoder, name string, v interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if !xmlName(name) {
+		start = xml.StartElement{
+			Name: xml.Name{Local: "entry"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: name}},
+		}
+	}
+
+	var text string
+	switch x := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, k := range keys {
+			if err := writeXML(enc, k, x[k]); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	case []interface{}:
+		if err := enc.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, e := range x {
+			if err := writeXML(enc, "item", e); err != nil {
+				return err
+			}
+		}
+		return enc.EncodeToken(start.End())
+	case nil:
+	case string:
+		text = x
+	case int64:
+		text = strconv.FormatInt(x, 10)
+	case float64:
+		text = strconv.FormatFloat(x, 'g', -1, 64)
+	case bool:
+		text = strconv.FormatBool(x)
+	default:
+		return enc.EncodeElement(v, start)
+	}
+	return enc.EncodeElement(text, start)
+}
+
+// xmlName reports whether s can be used as name of XML element
+func xmlName(s string) bool {
+	if s == "" || strings.HasPrefix(strings.ToLower(s), "xml") {
+		return false
+	}
+	for i, r := range s {
+		switch {
+		case unicode.IsLetter(r) || r == '_':
+		case i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'):
+		default:
+			return false
+		}
+	}
+	return true
+}
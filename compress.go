@@ -0,0 +1,219 @@
+package jsonapi
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// KindEncodingNotAcceptable is the Kind of Error sent when client refuses identity
+// encoding, and accepts none of supported encodings.
+const KindEncodingNotAcceptable = "encoding_not_acceptable"
+
+// Compression compresses responses with encodings negotiated against Accept-Encoding
+// header. gzip is built in, and more can be added by RegisterEncoding. Brotli ("br")
+// and zstd are shipped as optional providers, which are compiled only with build tags
+// jsonapi_brotli and jsonapi_zstd, so their dependencies are opt-in.
+//
+// API.Encodings enables compression for a route with only listed encodings allowed.
+// An empty, non-nil list disables it. Requests refusing identity encoding (like
+// "identity;q=0") without any acceptable encoding get a 406 Error of
+// KindEncodingNotAcceptable.
+//
+//     jsonapi.Compression = true
+//     apis := []jsonapi.API{
+//         {Pattern: "/api/export", APIHandler: export, Encodings: []string{"zstd", "gzip"}},
+//     }
+var Compression bool
+
+// Compressor creates a writer compressing data written to w
+type Compressor func(w io.Writer) (io.WriteCloser, error)
+
+type encodingProvider struct {
+	name      string
+	priority  int
+	newWriter Compressor
+}
+
+var (
+	encodingsMu sync.RWMutex
+	encodings   = map[string]encodingProvider{}
+)
+
+func init() {
+	RegisterEncoding("gzip", 10, func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriter(w), nil
+	})
+}
+
+// RegisterEncoding adds (or replaces) a Content-Encoding provider. When client
+// accepts many encodings with same q-value, the one with higher priority is used.
+// Returned writer may also implement Flush() error for streaming responses.
+func RegisterEncoding(name string, priority int, newWriter Compressor) {
+	encodingsMu.Lock()
+	defer encodingsMu.Unlock()
+	name = strings.ToLower(name)
+	encodings[name] = encodingProvider{name: name, priority: priority, newWriter: newWriter}
+}
+
+// allowedEncodings returns encodings set by GzipHandler or API.Encodings, nil
+// means all registered ones
+func (h *HTTP) allowedEncodings() []string {
+	if h.encodings != nil {
+		return h.encodings
+	}
+	if h.api != nil {
+		return h.api.Encodings
+	}
+	return nil
+}
+
+// providers lists registered encodings allowed by api, highest priority first
+func (h *HTTP) providers() []encodingProvider {
+	encodingsMu.RLock()
+	defer encodingsMu.RUnlock()
+	var ret []encodingProvider
+	if allowed := h.allowedEncodings(); allowed != nil {
+		for _, name := range allowed {
+			if p, ok := encodings[strings.ToLower(name)]; ok {
+				ret = append(ret, p)
+			}
+		}
+	} else {
+		for _, p := range encodings {
+			ret = append(ret, p)
+		}
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		if ret[i].priority != ret[j].priority {
+			return ret[i].priority > ret[j].priority
+		}
+		return ret[i].name < ret[j].name
+	})
+	return ret
+}
+
+func (h *HTTP) compression() bool {
+	if h.allowedEncodings() != nil {
+		return true
+	}
+	return Compression
+}
+
+// GzipHandler compresses responses of h with gzip if client accepts it, like
+// Compression does but only for h, regardless of package-level Compression. It
+// overrides API.Encodings.
+//
+//     http.Handle("/api/export", jsonapi.GzipHandler(jsonapi.APIHandler(export).Handler))
+func GzipHandler(h HTTPHandler) HTTPHandler {
+	return func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		httpData.encodings = []string{"gzip"}
+		if err := httpData.checkEncoding(); err != nil {
+			writeError(enc, httpData, err)
+			return
+		}
+		h(enc, dec, httpData)
+	}
+}
+
+// acceptEncoding parses Accept-Encoding header into q-values
+func acceptEncoding(r *http.Request) map[string]float64 {
+	ret := map[string]float64{}
+	for _, line := range r.Header.Values("Accept-Encoding") {
+		for _, item := range strings.Split(line, ",") {
+			name, params, _ := strings.Cut(item, ";")
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			q := 1.0
+			for _, p := range strings.Split(params, ";") {
+				k, v, _ := strings.Cut(strings.TrimSpace(p), "=")
+				if strings.EqualFold(k, "q") {
+					if f, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+						q = f
+					}
+				}
+			}
+			ret[name] = q
+		}
+	}
+	return ret
+}
+
+// negotiateEncoding selects encoding of response. Nil means identity.
+func (h *HTTP) negotiateEncoding() (*encodingProvider, error) {
+	if _, ok := h.Request.Header["Accept-Encoding"]; !ok {
+		return nil, nil
+	}
+	accept := acceptEncoding(h.Request)
+	qOf := func(name string) (float64, bool) {
+		if q, ok := accept[name]; ok {
+			return q, true
+		}
+		q, ok := accept["*"]
+		return q, ok
+	}
+
+	var best *encodingProvider
+	bestQ := 0.0
+	for _, p := range h.providers() {
+		if q, _ := qOf(p.name); q > bestQ {
+			p := p
+			best, bestQ = &p, q
+		}
+	}
+	if best != nil {
+		return best, nil
+	}
+
+	if q, ok := qOf("identity"); !ok || q > 0 {
+		return nil, nil
+	}
+	return nil, Error{
+		Code:    http.StatusNotAcceptable,
+		Message: "None of accepted content encodings is supported",
+		Kind:    KindEncodingNotAcceptable,
+	}
+}
+
+// checkEncoding rejects requests if no acceptable encoding is available
+func (h *HTTP) checkEncoding() error {
+	if !h.compression() {
+		return nil
+	}
+	_, err := h.negotiateEncoding()
+	return err
+}
+
+// compressor starts compressing response written to w if negotiated, and returns
+// the writer
+func (h *HTTP) compressor(w http.ResponseWriter, code int) io.WriteCloser {
+	if !h.compression() {
+		return nil
+	}
+	h.Vary("Accept-Encoding")
+
+	header := w.Header()
+	if code < 200 || code == http.StatusNoContent || code == http.StatusNotModified ||
+		h.Request.Method == http.MethodHead || header.Get("Content-Encoding") != "" {
+		return nil
+	}
+
+	p, err := h.negotiateEncoding()
+	if err != nil || p == nil {
+		return nil
+	}
+	cw, err := p.newWriter(w)
+	if err != nil {
+		return nil
+	}
+	header.Set("Content-Encoding", p.name)
+	header.Del("Content-Length")
+	return cw
+}
@@ -0,0 +1,17 @@
+//go:build jsonapi_brotli
+// +build jsonapi_brotli
+
+package jsonapi
+
+import (
+	"io"
+
+	"github.com/andybalholm/brotli"
+)
+
+// brotli is preferred over gzip, as it compresses JSON better
+func init() {
+	RegisterEncoding("br", 30, func(w io.Writer) (io.WriteCloser, error) {
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression), nil
+	})
+}
@@ -0,0 +1,149 @@
+package jsonapi
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type fakeEncoder struct{ io.Writer }
+
+func (fakeEncoder) Close() error { return nil }
+
+// withFakeEncoding registers "x-fake" encoding, which prefixes data with "fake:",
+// and returns a function removing it
+func withFakeEncoding(priority int) func() {
+	RegisterEncoding("x-fake", priority, func(w io.Writer) (io.WriteCloser, error) {
+		io.WriteString(w, "fake:")
+		return fakeEncoder{w}, nil
+	})
+	return func() {
+		encodingsMu.Lock()
+		defer encodingsMu.Unlock()
+		delete(encodings, "x-fake")
+	}
+}
+
+func TestCompression(t *testing.T) {
+	defer withFakeEncoding(20)()
+	Compression = true
+	defer func() { Compression = false }()
+	hello := func(dec *json.Decoder, httpData *HTTP) (interface{}, error) { return "hello", nil }
+	m := NewMuxTest([]API{
+		{Pattern: "/", APIHandler: hello},
+		{Pattern: "/gzip", APIHandler: hello, Encodings: []string{"gzip"}},
+		{Pattern: "/none", APIHandler: hello, Encodings: []string{}},
+	})
+
+	cases := []struct {
+		uri, accept string
+		status      int
+		encoding    string
+	}{
+		{"/", "", http.StatusOK, ""},
+		{"/", "gzip, x-fake", http.StatusOK, "x-fake"},
+		{"/", "gzip;q=1, x-fake;q=0.5", http.StatusOK, "gzip"},
+		{"/", "*", http.StatusOK, "x-fake"},
+		{"/", "GZIP, x-fake;q=0", http.StatusOK, "gzip"},
+		{"/", "bogus", http.StatusOK, ""},
+		{"/", "bogus, identity;q=0", http.StatusNotAcceptable, ""},
+		{"/", "*;q=0", http.StatusNotAcceptable, ""},
+		{"/gzip", "gzip, x-fake", http.StatusOK, "gzip"},
+		{"/gzip", "x-fake", http.StatusOK, ""},
+		{"/gzip", "x-fake, identity;q=0", http.StatusNotAcceptable, ""},
+		{"/none", "gzip, x-fake", http.StatusOK, ""},
+	}
+	for _, c := range cases {
+		req, _ := http.NewRequest("GET", c.uri, nil)
+		if c.accept != "" {
+			req.Header.Set("Accept-Encoding", c.accept)
+		}
+		resp := m.Do(req)
+		if resp.Code != c.status {
+			t.Errorf("%s %q: status = %d %s", c.uri, c.accept, resp.Code, resp.Body)
+			continue
+		}
+		if ce := resp.Header().Get("Content-Encoding"); ce != c.encoding {
+			t.Errorf("%s %q: expected encoding %q, got %q", c.uri, c.accept, c.encoding, ce)
+			continue
+		}
+		if c.status != http.StatusOK {
+			var body ErrorBody
+			if json.Unmarshal(resp.Body.Bytes(), &body); body.Error.Kind != KindEncodingNotAcceptable {
+				t.Errorf("%s %q: unexpected body %s", c.uri, c.accept, resp.Body)
+			}
+			continue
+		}
+
+		var r io.Reader = resp.Body
+		switch c.encoding {
+		case "gzip":
+			if r, _ = gzip.NewReader(r); r == nil {
+				t.Fatalf("%s %q: invalid gzip data", c.uri, c.accept)
+			}
+		case "x-fake":
+			r = strings.NewReader(strings.TrimPrefix(resp.Body.String(), "fake:"))
+		}
+		if body, _ := ioutil.ReadAll(r); string(body) != `"hello"`+"\n" {
+			t.Errorf("%s %q: unexpected body %q", c.uri, c.accept, body)
+		}
+	}
+}
+
+func gunzip(t *testing.T, data []byte) string {
+	r, err := gzip.NewReader(strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatalf("invalid gzip data: %s", err)
+	}
+	ret, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("broken gzip data: %s", err)
+	}
+	return string(ret)
+}
+
+func TestGzipHandler(t *testing.T) {
+	items := make([]map[string]interface{}, 500)
+	for i := range items {
+		items[i] = map[string]interface{}{"id": i, "name": "user"}
+	}
+	h := HandlerTest(GzipHandler(APIHandler(func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		switch httpData.Request.URL.Path {
+		case "/missing":
+			return nil, E404.SetData("No such list")
+		case "/empty":
+			return StatusResult{Code: http.StatusNoContent}, nil
+		}
+		return items, nil
+	}).Handler))
+
+	plain, _ := h.Get("/list", "")
+	if plain.Header().Get("Content-Encoding") != "" || !strings.HasPrefix(plain.Body.String(), `[{"id":0`) {
+		t.Fatalf("compressed without Accept-Encoding: %v", plain.Header())
+	}
+	gz := h.With(Headers{"Accept-Encoding": "gzip"})
+	resp, _ := gz.Get("/list", "")
+	if resp.Header().Get("Content-Encoding") != "gzip" || resp.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("not compressed: %v", resp.Header())
+	}
+	if resp.Header().Get("Content-Length") != "" || resp.Body.Len() >= plain.Body.Len() {
+		t.Errorf("unexpected compressed response of %d bytes: %v", resp.Body.Len(), resp.Header())
+	}
+	if s := gunzip(t, resp.Body.Bytes()); s != plain.Body.String() {
+		t.Errorf("decompressed body differs from uncompressed run")
+	}
+
+	resp, _ = gz.Get("/missing", "")
+	if resp.Code != http.StatusNotFound || resp.Header().Get("Content-Encoding") != "gzip" ||
+		gunzip(t, resp.Body.Bytes()) != `{"error":{"code":404,"message":"No such list"}}`+"\n" {
+		t.Errorf("unexpected error response %d %v", resp.Code, resp.Header())
+	}
+	resp, _ = gz.Get("/empty", "")
+	if resp.Code != http.StatusNoContent || resp.Header().Get("Content-Encoding") != "" || resp.Body.Len() != 0 {
+		t.Errorf("empty response is compressed: %d %v %q", resp.Code, resp.Header(), resp.Body)
+	}
+}
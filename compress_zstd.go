@@ -0,0 +1,16 @@
+//go:build jsonapi_zstd
+// +build jsonapi_zstd
+
+package jsonapi
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func init() {
+	RegisterEncoding("zstd", 20, func(w io.Writer) (io.WriteCloser, error) {
+		return zstd.NewWriter(w)
+	})
+}
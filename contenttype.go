@@ -0,0 +1,55 @@
+package jsonapi
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// KindUnsupportedMediaType is the Kind of Error sent when RequireJSONContentType
+// rejects a request
+const KindUnsupportedMediaType = "unsupported_media_type"
+
+// RequireJSONContentType rejects requests carrying a body with Content-Type other
+// than application/json or application/*+json, like application/merge-patch+json,
+// with a 415 Error of KindUnsupportedMediaType before calling the handler.
+// Parameters like charset are allowed. Requests without body are not checked.
+// Media types of codecs added by RegisterCodec are accepted too.
+// API.RequireJSONContentType enables it per route.
+var RequireJSONContentType bool
+
+var errUnsupportedMediaType = Error{
+	Code:    http.StatusUnsupportedMediaType,
+	Message: "Content-Type must be application/json",
+	Kind:    KindUnsupportedMediaType,
+}
+
+// acceptableBody reports whether r has no body, or a body in JSON format or a
+// format of registered codec
+func acceptableBody(r *http.Request) bool {
+	if r.ContentLength == 0 {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return jsonMediaType(mt) || requestCodec(r) != nil
+}
+
+// jsonBody reports whether body of r is checked as JSON by options like MaxDepth:
+// it has no Content-Type, or is in JSON format or a format of registered codec.
+// Bodies like multipart/form-data or images are not checked.
+func jsonBody(r *http.Request) bool {
+	ct := r.Header.Get("Content-Type")
+	if ct == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(ct)
+	return err == nil && (jsonMediaType(mt) || requestCodec(r) != nil)
+}
+
+// jsonMediaType reports whether mt is application/json or application/*+json
+func jsonMediaType(mt string) bool {
+	return mt == "application/json" || (strings.HasPrefix(mt, "application/") && strings.HasSuffix(mt, "+json"))
+}
@@ -0,0 +1,80 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRequireJSONContentType(t *testing.T) {
+	called := 0
+	handler := func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		called++
+		return "ok", nil
+	}
+	m := NewMuxTest([]API{
+		{Pattern: "/strict", APIHandler: handler, RequireJSONContentType: true},
+		{Pattern: "/loose", APIHandler: handler},
+	})
+
+	cases := []struct {
+		method, uri, contentType, body string
+		code                           int
+	}{
+		{"POST", "/strict", "application/json", `{}`, 200},
+		{"POST", "/strict", "application/json; charset=utf-8", `{}`, 200},
+		{"PATCH", "/strict", "application/merge-patch+json", `{}`, 200},
+		{"POST", "/strict", "text/plain", `{}`, 415},
+		{"POST", "/strict", "application/x-www-form-urlencoded", `a=1`, 415},
+		{"POST", "/strict", "", `{}`, 415},
+		{"POST", "/strict", "application/json; charset", `{}`, 415},
+		{"GET", "/strict", "", ``, 200},
+		{"HEAD", "/strict", "", ``, 200},
+		{"DELETE", "/strict", "text/plain", ``, 200},
+		{"POST", "/loose", "text/plain", `{}`, 200},
+	}
+	for _, c := range cases {
+		called = 0
+		var r io.Reader
+		if c.body != "" {
+			r = strings.NewReader(c.body)
+		}
+		req, _ := http.NewRequest(c.method, c.uri, r)
+		if c.contentType != "" {
+			req.Header.Set("Content-Type", c.contentType)
+		}
+		resp := m.Do(req)
+		if resp.Code != c.code {
+			t.Errorf("%s %s %q: expected %d, got %d: %s", c.method, c.uri, c.contentType, c.code, resp.Code, resp.Body)
+			continue
+		}
+		if c.code != 415 {
+			continue
+		}
+		var body ErrorBody
+		json.Unmarshal(resp.Body.Bytes(), &body)
+		if body.Error.Kind != KindUnsupportedMediaType || called != 0 {
+			t.Errorf("%s %q: unexpected error %+v, handler called %d times", c.uri, c.contentType, body.Error, called)
+		}
+	}
+}
+
+func TestRequireJSONContentTypeGlobal(t *testing.T) {
+	RequireJSONContentType = true
+	defer func() { RequireJSONContentType = false }()
+
+	h := HandlerTest(func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		enc.Encode("ok")
+	})
+	if resp, _ := h.Post("/", "", "{}"); resp.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("body without Content-Type is accepted: %d", resp.Code)
+	}
+	if resp, _ := h.With(Headers{"Content-Type": "application/json"}).Post("/", "", "{}"); resp.Code != http.StatusOK {
+		t.Errorf("JSON body is rejected: %d %s", resp.Code, resp.Body)
+	}
+	if resp, _ := h.Get("/", ""); resp.Code != http.StatusOK {
+		t.Errorf("request without body is rejected: %d %s", resp.Code, resp.Body)
+	}
+}
@@ -0,0 +1,33 @@
+package jsonapi
+
+import (
+	"net/http"
+	"time"
+)
+
+// controller reaches the ResponseWriter created by server through wrappers
+func (h *HTTP) controller() *http.ResponseController {
+	return http.NewResponseController(h.ResponseWriter)
+}
+
+// Flush sends buffered data to client. It returns an error wrapping
+// http.ErrNotSupported if the ResponseWriter cannot be flushed.
+func (h *HTTP) Flush() error {
+	return h.controller().Flush()
+}
+
+// SetWriteDeadline overrides WriteTimeout of the server for this request, so
+// streaming handlers can run longer. Zero t means no deadline. Like other methods
+// here, it returns an error wrapping http.ErrNotSupported with writers not created
+// by server, like httptest.ResponseRecorder.
+//
+//     httpData.SetWriteDeadline(time.Now().Add(10 * time.Minute))
+func (h *HTTP) SetWriteDeadline(t time.Time) error {
+	return h.controller().SetWriteDeadline(t)
+}
+
+// SetReadDeadline overrides ReadTimeout of the server for this request. Zero t
+// means no deadline.
+func (h *HTTP) SetReadDeadline(t time.Time) error {
+	return h.controller().SetReadDeadline(t)
+}
@@ -0,0 +1,86 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestControllerUnwrap(t *testing.T) {
+	SetLogger(func(e LogEntry) {})
+	defer SetLogger(nil)
+
+	var chain []string
+	var flushErr, deadlineErr error
+	resp, _ := HandlerTest(func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		w := httpData.ResponseWriter
+		for {
+			chain = append(chain, fmt.Sprintf("%T", w))
+			u, ok := w.(unwrapper)
+			if !ok {
+				break
+			}
+			w = u.Unwrap()
+		}
+		flushErr = httpData.Flush()
+		deadlineErr = httpData.SetWriteDeadline(time.Now().Add(time.Minute))
+	}).Get("/", "")
+
+	if fmt.Sprint(chain) != "[*jsonapi.responseWriter *jsonapi.statusWriter *httptest.ResponseRecorder]" {
+		t.Errorf("unexpected chain of writers: %v", chain)
+	}
+	if flushErr != nil || !resp.Flushed {
+		t.Errorf("recorder is not flushed: %v", flushErr)
+	}
+	if !errors.Is(deadlineErr, http.ErrNotSupported) {
+		t.Errorf("expected ErrNotSupported from recorder, got %v", deadlineErr)
+	}
+}
+
+func TestControllerDeadlines(t *testing.T) {
+	SetLogger(func(e LogEntry) {})
+	defer SetLogger(nil)
+
+	m := NewMuxTest([]API{
+		{Pattern: "/extended", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			if err := httpData.SetWriteDeadline(time.Now().Add(5 * time.Second)); err != nil {
+				return nil, err
+			}
+			if err := httpData.SetReadDeadline(time.Time{}); err != nil {
+				return nil, err
+			}
+			time.Sleep(200 * time.Millisecond)
+			return "ok", nil
+		}},
+		{Pattern: "/expired", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			if err := httpData.SetWriteDeadline(time.Now().Add(-time.Second)); err != nil {
+				return nil, err
+			}
+			return "ok", nil
+		}},
+	})
+	srv := httptest.NewUnstartedServer(m.Mux)
+	srv.Config.WriteTimeout = 50 * time.Millisecond
+	srv.Start()
+	defer srv.Close()
+
+	resp, err := srv.Client().Get(srv.URL + "/extended")
+	if err != nil {
+		t.Fatalf("write deadline is not extended: %s", err)
+	}
+	body, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || string(body) != "\"ok\"\n" {
+		t.Errorf("unexpected response %d: %s", resp.StatusCode, body)
+	}
+
+	if resp, err := srv.Client().Get(srv.URL + "/expired"); err == nil {
+		resp.Body.Close()
+		t.Errorf("expired write deadline is not applied: %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,295 @@
+package jsonapi
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// CookieKeys are secrets to sign and encrypt cookies of SetJSONCookie. The first
+// key is used to write cookies, and all are tried when reading, so you can rotate
+// keys by prepending a new one and removing the oldest after cookies expire.
+var CookieKeys [][]byte
+
+// MaxCookieSize is the max length of "name=value" of a cookie written by
+// SetJSONCookie, which is the limit most browsers accept.
+var MaxCookieSize = 4096
+
+var (
+	// ErrNoCookieKey is returned by SetJSONCookie if CookieKeys is empty
+	ErrNoCookieKey = errors.New("jsonapi: CookieKeys is empty")
+	// ErrCookieTooLarge is returned by SetJSONCookie if encoded cookie exceeds MaxCookieSize
+	ErrCookieTooLarge = errors.New("jsonapi: cookie too large")
+	// ErrInvalidCookie is returned by SignedCookie if the signature does not match
+	ErrInvalidCookie = errors.New("jsonapi: invalid cookie signature")
+)
+
+// CookieOptions are attributes of cookies written by SetJSONCookie
+type CookieOptions struct {
+	Path     string
+	Domain   string
+	MaxAge   int
+	Secure   bool
+	HttpOnly bool
+	SameSite http.SameSite
+
+	// Encrypt hides value from client with AES-GCM, otherwise it is only signed
+	// with HMAC-SHA256, which prevents tampering but still readable.
+	Encrypt bool
+}
+
+// deriveKey derives independent keys for signing and encryption from key
+func deriveKey(key []byte, usage string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(usage))
+	return m.Sum(nil)
+}
+
+func cookieMAC(key []byte, name, payload string) []byte {
+	m := hmac.New(sha256.New, deriveKey(key, "jsonapi cookie signing"))
+	m.Write([]byte(name + "=" + payload))
+	return m.Sum(nil)
+}
+
+func cookieAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(deriveKey(key, "jsonapi cookie encryption"))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func sealCookie(name string, data []byte, encrypt bool) (string, error) {
+	if len(CookieKeys) == 0 {
+		return "", ErrNoCookieKey
+	}
+	key := CookieKeys[0]
+	if !encrypt {
+		payload := base64.RawURLEncoding.EncodeToString(data)
+		sig := base64.RawURLEncoding.EncodeToString(cookieMAC(key, name, payload))
+		return payload + "." + sig, nil
+	}
+
+	aead, err := cookieAEAD(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", err
+	}
+	// cookie name is authenticated, so values cannot be swapped between cookies
+	return base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, data, []byte(name))), nil
+}
+
+func openCookie(name, value string, encrypt bool) ([]byte, bool) {
+	if !encrypt {
+		idx := strings.LastIndexByte(value, '.')
+		if idx < 0 {
+			return nil, false
+		}
+		payload := value[:idx]
+		sig, err := base64.RawURLEncoding.DecodeString(value[idx+1:])
+		if err != nil {
+			return nil, false
+		}
+		for _, key := range CookieKeys {
+			if hmac.Equal(sig, cookieMAC(key, name, payload)) {
+				data, err := base64.RawURLEncoding.DecodeString(payload)
+				return data, err == nil
+			}
+		}
+		return nil, false
+	}
+
+	buf, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, false
+	}
+	for _, key := range CookieKeys {
+		aead, err := cookieAEAD(key)
+		if err != nil || len(buf) < aead.NonceSize() {
+			continue
+		}
+		nonce, ciphertext := buf[:aead.NonceSize()], buf[aead.NonceSize():]
+		if data, err := aead.Open(nil, nonce, ciphertext, []byte(name)); err == nil {
+			return data, true
+		}
+	}
+	return nil, false
+}
+
+// SetJSONCookie encodes v in JSON format, signs or encrypts it with CookieKeys
+// according to opts, and sets it as cookie named name.
+//
+//     err := httpData.SetJSONCookie("prefs", prefs, jsonapi.CookieOptions{
+//         Path:     "/",
+//         HttpOnly: true,
+//         Encrypt:  true,
+//     })
+func (h *HTTP) SetJSONCookie(name string, v interface{}, opts CookieOptions) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	value, err := sealCookie(name, data, opts.Encrypt)
+	if err != nil {
+		return err
+	}
+	if err := checkCookieSize(name, value); err != nil {
+		return err
+	}
+
+	http.SetCookie(h.ResponseWriter, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     opts.Path,
+		Domain:   opts.Domain,
+		MaxAge:   opts.MaxAge,
+		Secure:   opts.Secure,
+		HttpOnly: opts.HttpOnly,
+		SameSite: opts.SameSite,
+	})
+	return nil
+}
+
+// JSONCookie verifies cookie named name written by SetJSONCookie with same
+// opts.Encrypt, and decodes it into v.
+//
+// Cookies which are tampered, signed by unknown key or cannot be decrypted are
+// treated as missing: http.ErrNoCookie is returned in both cases.
+func (h *HTTP) JSONCookie(name string, v interface{}, opts CookieOptions) error {
+	c, err := h.Request.Cookie(name)
+	if err != nil {
+		return http.ErrNoCookie
+	}
+	data, ok := openCookie(name, c.Value, opts.Encrypt)
+	if !ok {
+		return http.ErrNoCookie
+	}
+	return json.Unmarshal(data, v)
+}
+
+func checkCookieSize(name, value string) error {
+	if size := len(name) + 1 + len(value); size > MaxCookieSize {
+		return fmt.Errorf("%w: %s is %d bytes after encoding, exceeds %d bytes", ErrCookieTooLarge, name, size, MaxCookieSize)
+	}
+	return nil
+}
+
+// CookieOption changes attributes of cookies written by SetCookie
+type CookieOption func(c *http.Cookie)
+
+// CookieMaxAge sets Max-Age of the cookie in seconds, negative deletes it at once
+func CookieMaxAge(seconds int) CookieOption {
+	return func(c *http.Cookie) { c.MaxAge = seconds }
+}
+
+// CookieSecure sets whether the cookie is sent only over https
+func CookieSecure(secure bool) CookieOption {
+	return func(c *http.Cookie) { c.Secure = secure }
+}
+
+// CookieHttpOnly sets whether the cookie is hidden from scripts
+func CookieHttpOnly(httpOnly bool) CookieOption {
+	return func(c *http.Cookie) { c.HttpOnly = httpOnly }
+}
+
+// CookieSameSite sets SameSite attribute of the cookie
+func CookieSameSite(mode http.SameSite) CookieOption {
+	return func(c *http.Cookie) { c.SameSite = mode }
+}
+
+// CookiePath sets Path attribute of the cookie
+func CookiePath(path string) CookieOption {
+	return func(c *http.Cookie) { c.Path = path }
+}
+
+// CookieDomain sets Domain attribute of the cookie
+func CookieDomain(domain string) CookieOption {
+	return func(c *http.Cookie) { c.Domain = domain }
+}
+
+// newCookie creates a cookie with defaults of SetCookie
+func (h *HTTP) newCookie(name, value string, opts []CookieOption) *http.Cookie {
+	c := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+		Secure:   h.Request.TLS != nil,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// CookieValue returns value of cookie named name, ok is false if it is missing.
+// Unlike Request.Cookie, it does not return the whole http.Cookie.
+func (h *HTTP) CookieValue(name string) (value string, ok bool) {
+	c, err := h.Request.Cookie(name)
+	if err != nil {
+		return "", false
+	}
+	return c.Value, true
+}
+
+// SetCookie sets cookie named name. By default Path is "/", and it is HttpOnly,
+// SameSite=Lax, and Secure if the request comes over TLS, which opts can change.
+//
+//     httpData.SetCookie("lang", "en", jsonapi.CookieMaxAge(86400*365), jsonapi.CookieHttpOnly(false))
+func (h *HTTP) SetCookie(name, value string, opts ...CookieOption) {
+	http.SetCookie(h.ResponseWriter, h.newCookie(name, value, opts))
+}
+
+// DeleteCookie asks client to remove cookie named name. Pass the same CookiePath
+// and CookieDomain as when setting it.
+func (h *HTTP) DeleteCookie(name string, opts ...CookieOption) {
+	c := h.newCookie(name, "", opts)
+	c.MaxAge = -1
+	http.SetCookie(h.ResponseWriter, c)
+}
+
+// SetSignedCookie is SetCookie with value signed by CookieKeys using HMAC-SHA256,
+// so client can read but not modify it. It fails if CookieKeys is empty, or with
+// ErrCookieTooLarge.
+//
+//     if err := httpData.SetSignedCookie("session", token); err != nil {
+//         return nil, jsonapi.E500.Wrap(err)
+//     }
+func (h *HTTP) SetSignedCookie(name, value string, opts ...CookieOption) error {
+	signed, err := sealCookie(name, []byte(value), false)
+	if err != nil {
+		return err
+	}
+	if err := checkCookieSize(name, signed); err != nil {
+		return err
+	}
+	h.SetCookie(name, signed, opts...)
+	return nil
+}
+
+// SignedCookie verifies cookie named name written by SetSignedCookie and returns
+// its value. It returns http.ErrNoCookie if the cookie is missing, or
+// ErrInvalidCookie if it is tampered or signed by unknown key.
+func (h *HTTP) SignedCookie(name string) (string, error) {
+	c, err := h.Request.Cookie(name)
+	if err != nil {
+		return "", http.ErrNoCookie
+	}
+	data, ok := openCookie(name, c.Value, false)
+	if !ok {
+		return "", ErrInvalidCookie
+	}
+	return string(data), nil
+}
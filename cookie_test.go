@@ -0,0 +1,232 @@
+package jsonapi
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type prefs struct {
+	Lang  string `json:"lang"`
+	Theme string `json:"theme"`
+}
+
+// setJSONCookie writes v with SetJSONCookie and returns the cookie
+func setJSONCookie(t *testing.T, name string, v interface{}, opts CookieOptions) *http.Cookie {
+	t.Helper()
+	resp := httptest.NewRecorder()
+	h := &HTTP{ResponseWriter: resp, Request: httptest.NewRequest("GET", "/", nil)}
+	if err := h.SetJSONCookie(name, v, opts); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Result().Cookies()[0]
+}
+
+// readJSONCookie reads cookie c with JSONCookie
+func readJSONCookie(c *http.Cookie, name string, opts CookieOptions) (prefs, error) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(c)
+	h := &HTTP{ResponseWriter: httptest.NewRecorder(), Request: req}
+	var v prefs
+	err := h.JSONCookie(name, &v, opts)
+	return v, err
+}
+
+func withCookieKeys(keys ...string) func() {
+	CookieKeys = nil
+	for _, k := range keys {
+		CookieKeys = append(CookieKeys, []byte(k))
+	}
+	return func() { CookieKeys = nil }
+}
+
+func TestJSONCookie(t *testing.T) {
+	defer withCookieKeys("key1")()
+	v := prefs{Lang: "en", Theme: "secret-dark"}
+	for _, encrypt := range []bool{false, true} {
+		opts := CookieOptions{Path: "/", HttpOnly: true, Encrypt: encrypt}
+		c := setJSONCookie(t, "prefs", v, opts)
+		if c.Path != "/" || !c.HttpOnly {
+			t.Errorf("encrypt %v: attributes not set: %+v", encrypt, c)
+		}
+		if readable := strings.Contains(c.Value, "eyJ"); readable == encrypt {
+			t.Errorf("encrypt %v: value is %q", encrypt, c.Value)
+		}
+		if got, err := readJSONCookie(c, "prefs", opts); err != nil || got != v {
+			t.Errorf("encrypt %v: got %+v, %v", encrypt, got, err)
+		}
+		// decoding with the other mode fails
+		if _, err := readJSONCookie(c, "prefs", CookieOptions{Encrypt: !encrypt}); err != http.ErrNoCookie {
+			t.Errorf("encrypt %v: read in other mode: %v", encrypt, err)
+		}
+	}
+}
+
+func TestJSONCookieRotation(t *testing.T) {
+	defer withCookieKeys("old")()
+	v := prefs{Lang: "fr"}
+	for _, encrypt := range []bool{false, true} {
+		opts := CookieOptions{Encrypt: encrypt}
+		withCookieKeys("old")
+		c := setJSONCookie(t, "prefs", v, opts)
+
+		withCookieKeys("new", "old")
+		if got, err := readJSONCookie(c, "prefs", opts); err != nil || got != v {
+			t.Errorf("encrypt %v: cookie by old key: %+v, %v", encrypt, got, err)
+		}
+		if fresh := setJSONCookie(t, "prefs", v, opts); fresh.Value == c.Value {
+			t.Errorf("encrypt %v: new cookies are not written by the first key", encrypt)
+		}
+
+		withCookieKeys("new")
+		if _, err := readJSONCookie(c, "prefs", opts); err != http.ErrNoCookie {
+			t.Errorf("encrypt %v: cookie by removed key: %v", encrypt, err)
+		}
+	}
+}
+
+func TestJSONCookieTamper(t *testing.T) {
+	defer withCookieKeys("key1")()
+	for _, encrypt := range []bool{false, true} {
+		opts := CookieOptions{Encrypt: encrypt}
+		c := setJSONCookie(t, "prefs", prefs{Lang: "en"}, opts)
+		orig := c.Value
+
+		flipped := []byte(orig)
+		flipped[len(flipped)/2] ^= 1
+		for _, value := range []string{string(flipped), orig[:len(orig)-4], "", "not a cookie."} {
+			c.Value = value
+			if _, err := readJSONCookie(c, "prefs", opts); err != http.ErrNoCookie {
+				t.Errorf("encrypt %v: tampered %q: %v", encrypt, value, err)
+			}
+		}
+
+		// values cannot be moved to cookies of other names
+		c.Name, c.Value = "other", orig
+		if _, err := readJSONCookie(c, "other", opts); err != http.ErrNoCookie {
+			t.Errorf("encrypt %v: renamed cookie: %v", encrypt, err)
+		}
+		if _, err := readJSONCookie(c, "prefs", opts); err != http.ErrNoCookie {
+			t.Errorf("encrypt %v: missing cookie: %v", encrypt, err)
+		}
+	}
+}
+
+func TestJSONCookieErrors(t *testing.T) {
+	h := &HTTP{ResponseWriter: httptest.NewRecorder(), Request: httptest.NewRequest("GET", "/", nil)}
+	defer withCookieKeys()()
+	if err := h.SetJSONCookie("prefs", prefs{}, CookieOptions{}); err != ErrNoCookieKey {
+		t.Errorf("expected ErrNoCookieKey, got %v", err)
+	}
+
+	withCookieKeys("key1")
+	big := prefs{Lang: strings.Repeat("x", MaxCookieSize)}
+	for _, encrypt := range []bool{false, true} {
+		err := h.SetJSONCookie("prefs", big, CookieOptions{Encrypt: encrypt})
+		if !errors.Is(err, ErrCookieTooLarge) || !strings.Contains(err.Error(), "prefs") {
+			t.Errorf("encrypt %v: expected ErrCookieTooLarge, got %v", encrypt, err)
+		}
+	}
+	if cookies := h.ResponseWriter.(*httptest.ResponseRecorder).Result().Cookies(); len(cookies) != 0 {
+		t.Errorf("oversized cookies are set: %v", cookies)
+	}
+}
+
+func TestSignedCookie(t *testing.T) {
+	defer withCookieKeys("key1")()
+	h := HandlerTest(APIHandler(func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		switch httpData.Request.URL.Path {
+		case "/login":
+			return nil, httpData.SetSignedCookie("session", "user=john", CookieMaxAge(3600))
+		case "/logout":
+			httpData.DeleteCookie("session")
+			return nil, nil
+		}
+		v, err := httpData.SignedCookie("session")
+		switch err {
+		case http.ErrNoCookie:
+			return nil, E401
+		case ErrInvalidCookie:
+			return nil, E403
+		}
+		return v, err
+	}).Handler)
+
+	resp, _ := h.Post("/login", "", "")
+	cookies := resp.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Fatalf("expected a cookie, got %v", resp.Header())
+	}
+	c := cookies[0]
+	if c.Name != "session" || c.MaxAge != 3600 || c.Path != "/" || !c.HttpOnly || c.SameSite != http.SameSiteLaxMode || c.Secure {
+		t.Errorf("unexpected cookie %+v", c)
+	}
+
+	resp, _ = h.Get("/me", "session="+c.Value)
+	if resp.Code != http.StatusOK || resp.Body.String() != `"user=john"`+"\n" {
+		t.Errorf("signed cookie is not accepted: %d %s", resp.Code, resp.Body)
+	}
+	tampered := strings.Replace(c.Value, base64.RawURLEncoding.EncodeToString([]byte("user=john")),
+		base64.RawURLEncoding.EncodeToString([]byte("user=root")), 1)
+	for cookie, code := range map[string]int{
+		"":                                    http.StatusUnauthorized,
+		"session=user=root":                   http.StatusForbidden,
+		"session=" + tampered:                 http.StatusForbidden,
+		"session=" + c.Value[:len(c.Value)-1]: http.StatusForbidden,
+		"other=" + c.Value:                    http.StatusUnauthorized,
+	} {
+		if resp, _ := h.Get("/me", cookie); resp.Code != code {
+			t.Errorf("%q: expected %d, got %d", cookie, code, resp.Code)
+		}
+	}
+
+	// cookies signed by removed keys are invalid
+	withCookieKeys("key2")
+	if resp, _ := h.Get("/me", "session="+c.Value); resp.Code != http.StatusForbidden {
+		t.Errorf("old key: expected 403, got %d", resp.Code)
+	}
+
+	resp, _ = h.Post("/logout", "session="+c.Value, "")
+	if cookies := resp.Result().Cookies(); len(cookies) != 1 || cookies[0].MaxAge != -1 || cookies[0].Value != "" {
+		t.Errorf("cookie is not deleted: %v", resp.Header())
+	}
+}
+
+func TestCookieHelpers(t *testing.T) {
+	req := httptest.NewRequest("GET", "https://example.com/", nil)
+	req.AddCookie(&http.Cookie{Name: "lang", Value: "en"})
+	resp := httptest.NewRecorder()
+	h := &HTTP{ResponseWriter: resp, Request: req}
+
+	if v, ok := h.CookieValue("lang"); !ok || v != "en" {
+		t.Errorf("unexpected value %q %v", v, ok)
+	}
+	if _, ok := h.CookieValue("theme"); ok {
+		t.Errorf("missing cookie is found")
+	}
+
+	h.SetCookie("theme", "dark", CookiePath("/app"), CookieHttpOnly(false), CookieSameSite(http.SameSiteStrictMode), CookieDomain("example.com"))
+	h.SetCookie("plain", "1", CookieSecure(false))
+	if err := h.SetSignedCookie("big", strings.Repeat("x", MaxCookieSize)); err != ErrNoCookieKey {
+		t.Errorf("expected ErrNoCookieKey, got %v", err)
+	}
+	cookies := resp.Result().Cookies()
+	if len(cookies) != 2 {
+		t.Fatalf("unexpected cookies %v", cookies)
+	}
+	if c := cookies[0]; c.Path != "/app" || c.HttpOnly || c.SameSite != http.SameSiteStrictMode || c.Domain != "example.com" || !c.Secure {
+		t.Errorf("unexpected cookie %+v", c)
+	}
+	if c := cookies[1]; c.Path != "/" || !c.HttpOnly || c.Secure {
+		t.Errorf("unexpected cookie %+v", c)
+	}
+
+	defer withCookieKeys("key1")()
+	if err := h.SetSignedCookie("big", strings.Repeat("x", MaxCookieSize)); !errors.Is(err, ErrCookieTooLarge) {
+		t.Errorf("expected ErrCookieTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSOptions configures cross-origin requests allowed by CORS
+type CORSOptions struct {
+	// Origins allowed to call the API, like "https://app.example.com", "*" allows
+	// any origin
+	Origins []string
+
+	// Methods allowed in preflight requests, defaults to API.Methods, or GET, HEAD,
+	// POST, PUT, PATCH and DELETE
+	Methods []string
+
+	// Headers clients can send, defaults to Content-Type
+	Headers []string
+
+	// ExposeHeaders are response headers readable by scripts, optional
+	ExposeHeaders []string
+
+	// Credentials allows cookies and Authorization header. The origin is echoed
+	// instead of "*" then, as browsers require.
+	Credentials bool
+
+	// MaxAge is how long the result of preflight requests can be cached, optional
+	MaxAge time.Duration
+}
+
+var defaultCORSMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+// CORS allows cross-origin requests from opts.Origins. Preflight requests are
+// answered with 204 without calling next. Others are served as usual, with
+// Access-Control-Allow-Origin if the origin is allowed. Requests from other
+// origins get no CORS headers, so browsers refuse to share the response.
+//
+//     group := jsonapi.NewGroup("/api", jsonapi.CORS(jsonapi.CORSOptions{
+//         Origins:     []string{"https://app.example.com"},
+//         Credentials: true,
+//     }))
+//
+// OPTIONS requests to APIs with Methods are answered before Middlewares run, set
+// API.CORS for them instead.
+func CORS(opts CORSOptions) Middleware {
+	origins := map[string]bool{}
+	for _, o := range opts.Origins {
+		origins[strings.TrimRight(o, "/")] = true
+	}
+	methods := opts.Methods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	allowMethods := strings.ToUpper(strings.Join(methods, ", "))
+	allowHeaders := "Content-Type"
+	if len(opts.Headers) > 0 {
+		allowHeaders = strings.Join(opts.Headers, ", ")
+	}
+	expose := strings.Join(opts.ExposeHeaders, ", ")
+
+	return func(next HTTPHandler) HTTPHandler {
+		return func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+			r := httpData.Request
+			origin := r.Header.Get("Origin")
+			preflight := isPreflight(r)
+			if !origins["*"] || opts.Credentials {
+				// the response depends on the origin
+				httpData.Vary("Origin")
+			}
+			if origin == "" {
+				next(enc, dec, httpData)
+				return
+			}
+
+			header := httpData.ResponseWriter.Header()
+			if preflight {
+				httpData.Vary("Access-Control-Request-Method", "Access-Control-Request-Headers")
+			}
+			if allowed := origins["*"] || origins[origin]; allowed {
+				if origins["*"] && !opts.Credentials {
+					header.Set("Access-Control-Allow-Origin", "*")
+				} else {
+					header.Set("Access-Control-Allow-Origin", origin)
+				}
+				if opts.Credentials {
+					header.Set("Access-Control-Allow-Credentials", "true")
+				}
+				if preflight {
+					header.Set("Access-Control-Allow-Methods", allowMethods)
+					header.Set("Access-Control-Allow-Headers", allowHeaders)
+					if opts.MaxAge > 0 {
+						header.Set("Access-Control-Max-Age", strconv.Itoa(int(opts.MaxAge/time.Second)))
+					}
+				} else if expose != "" {
+					header.Set("Access-Control-Expose-Headers", expose)
+				}
+			}
+			if preflight {
+				header.Del("Content-Type")
+				httpData.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next(enc, dec, httpData)
+		}
+	}
+}
+
+// isPreflight reports whether r is a CORS preflight request
+func isPreflight(r *http.Request) bool {
+	return r.Method == "OPTIONS" && r.Header.Get("Origin") != "" && r.Header.Get("Access-Control-Request-Method") != ""
+}
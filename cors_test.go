@@ -0,0 +1,128 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func corsTest(opts CORSOptions, called *int) HandlerTest {
+	return HandlerTest(CORS(opts)(func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		*called++
+		httpData.ResponseWriter.Header().Set("X-Request-ID", "1")
+		enc.Encode("ok")
+	}))
+}
+
+// corsHeaders returns Access-Control-* headers of resp
+func corsHeaders(h http.Header) map[string]string {
+	ret := map[string]string{}
+	for k, v := range h {
+		if strings.HasPrefix(k, "Access-Control-") {
+			ret[k] = strings.Join(v, ", ")
+		}
+	}
+	return ret
+}
+
+func TestCORS(t *testing.T) {
+	called := 0
+	h := corsTest(CORSOptions{
+		Origins:       []string{"https://app.example.com/"},
+		Headers:       []string{"Content-Type", "Authorization"},
+		ExposeHeaders: []string{"X-Request-ID"},
+		Credentials:   true,
+		MaxAge:        10 * time.Minute,
+	}, &called)
+	preflight := Headers{"Origin": "https://app.example.com", "Access-Control-Request-Method": "PUT"}
+
+	resp := h.With(preflight).Do(newRequest("OPTIONS", "/api/user", ""))
+	expect := map[string]string{
+		"Access-Control-Allow-Origin":      "https://app.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Methods":     "GET, HEAD, POST, PUT, PATCH, DELETE",
+		"Access-Control-Allow-Headers":     "Content-Type, Authorization",
+		"Access-Control-Max-Age":           "600",
+	}
+	if resp.Code != http.StatusNoContent || called != 0 || resp.Body.Len() != 0 || resp.Header().Get("Content-Type") != "" {
+		t.Errorf("preflight: unexpected response %d %v %q, handler called %d times", resp.Code, resp.Header(), resp.Body, called)
+	}
+	if got := corsHeaders(resp.Header()); !reflect.DeepEqual(got, expect) {
+		t.Errorf("preflight: expected %v, got %v", expect, got)
+	}
+	if vary := strings.Join(resp.Header().Values("Vary"), ", "); vary != "Origin, Access-Control-Request-Method, Access-Control-Request-Headers" {
+		t.Errorf("preflight: unexpected Vary %q", vary)
+	}
+
+	resp, _ = h.With(Headers{"Origin": "https://app.example.com"}).Put("/api/user", "", `{}`)
+	expect = map[string]string{
+		"Access-Control-Allow-Origin":      "https://app.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Expose-Headers":    "X-Request-ID",
+	}
+	if resp.Code != http.StatusOK || called != 1 {
+		t.Errorf("simple: unexpected response %d, handler called %d times", resp.Code, called)
+	}
+	if got := corsHeaders(resp.Header()); !reflect.DeepEqual(got, expect) {
+		t.Errorf("simple: expected %v, got %v", expect, got)
+	}
+
+	// other origins are not an error, but get nothing
+	evil := Headers{"Origin": "https://evil.example.com", "Access-Control-Request-Method": "PUT"}
+	resp = h.With(evil).Do(newRequest("OPTIONS", "/api/user", ""))
+	if resp.Code != http.StatusNoContent || called != 1 || len(corsHeaders(resp.Header())) != 0 {
+		t.Errorf("disallowed preflight: unexpected response %d %v", resp.Code, resp.Header())
+	}
+	resp, _ = h.With(evil).Get("/api/user", "")
+	if resp.Code != http.StatusOK || called != 2 || len(corsHeaders(resp.Header())) != 0 {
+		t.Errorf("disallowed: unexpected response %d %v", resp.Code, resp.Header())
+	}
+	resp, _ = h.Get("/api/user", "")
+	if resp.Code != http.StatusOK || len(corsHeaders(resp.Header())) != 0 || resp.Header().Get("Vary") != "Origin" {
+		t.Errorf("same origin: unexpected response %d %v", resp.Code, resp.Header())
+	}
+}
+
+func TestCORSWildcard(t *testing.T) {
+	called := 0
+	origin := Headers{"Origin": "https://any.example.com"}
+	resp, _ := corsTest(CORSOptions{Origins: []string{"*"}}, &called).With(origin).Get("/", "")
+	if resp.Header().Get("Access-Control-Allow-Origin") != "*" || resp.Header().Get("Vary") != "" {
+		t.Errorf("unexpected headers %v", resp.Header())
+	}
+	resp, _ = corsTest(CORSOptions{Origins: []string{"*"}, Credentials: true}, &called).With(origin).Get("/", "")
+	if resp.Header().Get("Access-Control-Allow-Origin") != "https://any.example.com" || resp.Header().Get("Vary") != "Origin" {
+		t.Errorf("credentials: unexpected headers %v", resp.Header())
+	}
+}
+
+func TestAPICORS(t *testing.T) {
+	called := 0
+	m := NewMuxTest([]API{{
+		Pattern: "/api/user",
+		Methods: []string{"GET", "PUT"},
+		APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			called++
+			return "ok", nil
+		},
+		CORS: &CORSOptions{Origins: []string{"https://app.example.com"}},
+	}})
+
+	preflight := m.With(Headers{"Origin": "https://app.example.com", "Access-Control-Request-Method": "PUT"})
+	resp := preflight.Do(newRequest("OPTIONS", "/api/user", ""))
+	if resp.Code != http.StatusNoContent || called != 0 || resp.Header().Get("Access-Control-Allow-Methods") != "GET, PUT" {
+		t.Errorf("preflight: unexpected response %d %v", resp.Code, resp.Header())
+	}
+	// plain OPTIONS still lists allowed methods
+	resp = m.Do(newRequest("OPTIONS", "/api/user", ""))
+	if resp.Header().Get("Allow") != "GET, PUT, HEAD, OPTIONS" || len(corsHeaders(resp.Header())) != 0 {
+		t.Errorf("OPTIONS: unexpected response %d %v", resp.Code, resp.Header())
+	}
+	resp, _ = m.With(Headers{"Origin": "https://app.example.com"}).Get("/api/user", "")
+	if resp.Code != http.StatusOK || called != 1 || resp.Header().Get("Access-Control-Allow-Origin") != "https://app.example.com" {
+		t.Errorf("GET: unexpected response %d %v", resp.Code, resp.Header())
+	}
+}
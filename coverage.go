@@ -0,0 +1,148 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TrackCoverage records which routes registered by Register are called, so test
+// suites can find untested APIs with ReportCoverage. Turn it on in TestMain:
+//
+//     func TestMain(m *testing.M) {
+//         jsonapi.TrackCoverage = true
+//         os.Exit(m.Run())
+//     }
+var TrackCoverage bool
+
+// CoverageFile is where ReportCoverage writes the report in JSON format for CI,
+// defaults to environment variable JSONAPI_COVERAGE_FILE. Empty disables it.
+var CoverageFile = os.Getenv("JSONAPI_COVERAGE_FILE")
+
+var (
+	coverageMu sync.Mutex
+	coverage   = map[string]map[string]bool{} // pattern => methods called
+)
+
+func recordCoverage(pattern, method string) {
+	coverageMu.Lock()
+	defer coverageMu.Unlock()
+	if coverage[pattern] == nil {
+		coverage[pattern] = map[string]bool{}
+	}
+	coverage[pattern][method] = true
+}
+
+// ResetCoverage forgets recorded calls
+func ResetCoverage() {
+	coverageMu.Lock()
+	defer coverageMu.Unlock()
+	coverage = map[string]map[string]bool{}
+}
+
+// RouteCoverage is coverage of a route
+type RouteCoverage struct {
+	Pattern string   `json:"pattern"`
+	Methods []string `json:"methods,omitempty"` // methods called
+}
+
+// CoverageResult is the report of ReportCoverage
+type CoverageResult struct {
+	Ratio   float64         `json:"ratio"` // covered / (covered + missed)
+	Covered []RouteCoverage `json:"covered"`
+	Missed  []string        `json:"missed"`
+	Exempt  []string        `json:"exempt"` // routes with API.CoverageExempt
+}
+
+// Coverage computes coverage of apis from calls recorded so far
+func Coverage(apis []API) CoverageResult {
+	coverageMu.Lock()
+	defer coverageMu.Unlock()
+	ret := CoverageResult{Covered: []RouteCoverage{}, Missed: []string{}, Exempt: []string{}}
+	for _, api := range apis {
+		if api.CoverageExempt {
+			ret.Exempt = append(ret.Exempt, api.Pattern)
+			continue
+		}
+		c := RouteCoverage{Pattern: api.Pattern}
+		for m := range coverage[api.Pattern] {
+			if api.allows(m) {
+				c.Methods = append(c.Methods, m)
+			}
+		}
+		if len(c.Methods) == 0 {
+			ret.Missed = append(ret.Missed, api.Pattern)
+			continue
+		}
+		sort.Strings(c.Methods)
+		ret.Covered = append(ret.Covered, c)
+	}
+	sort.Slice(ret.Covered, func(i, j int) bool { return ret.Covered[i].Pattern < ret.Covered[j].Pattern })
+	sort.Strings(ret.Missed)
+	sort.Strings(ret.Exempt)
+
+	ret.Ratio = 1
+	if total := len(ret.Covered) + len(ret.Missed); total > 0 {
+		ret.Ratio = float64(len(ret.Covered)) / float64(total)
+	}
+	return ret
+}
+
+// CoverageT is the part of testing.TB used by ReportCoverage
+type CoverageT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+	Logf(format string, args ...interface{})
+}
+
+// ReportCoverage fails t if ratio of apis called in tests is below threshold,
+// listing routes not called. With threshold 0, they are only logged as warnings.
+// The report is also written to CoverageFile if set.
+//
+//     func TestCoverage(t *testing.T) {
+//         jsonapi.ReportCoverage(t, apis, 0.8)
+//     }
+//
+// TrackCoverage must be enabled before calling APIs. Tests run in the order they
+// are defined, and files are sorted by name, so put it in a file like zz_coverage_test.go.
+func ReportCoverage(t CoverageT, apis []API, threshold float64) CoverageResult {
+	t.Helper()
+	res := Coverage(apis)
+	if CoverageFile != "" {
+		data, err := json.MarshalIndent(res, "", "  ")
+		if err == nil {
+			err = ioutil.WriteFile(CoverageFile, data, 0644)
+		}
+		if err != nil {
+			t.Errorf("jsonapi: cannot write coverage report: %s", err)
+		}
+	}
+
+	if len(res.Missed) == 0 {
+		return res
+	}
+	msg := "route coverage %.1f%%, routes not called:\n    %s"
+	args := []interface{}{res.Ratio * 100, strings.Join(res.Missed, "\n    ")}
+	if res.Ratio < threshold {
+		t.Errorf(msg+"\nexpected at least %.1f%%", append(args, threshold*100)...)
+	} else {
+		t.Logf(msg, args...)
+	}
+	return res
+}
+
+// allows reports whether api serves requests of method
+func (api API) allows(method string) bool {
+	if len(api.Methods) == 0 {
+		return true
+	}
+	for _, m := range api.Methods {
+		if strings.EqualFold(m, method) || (method == "HEAD" && strings.EqualFold(m, "GET")) {
+			return true
+		}
+	}
+	return false
+}
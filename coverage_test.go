@@ -0,0 +1,106 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// coverageRecorder is a CoverageT recording messages
+type coverageRecorder struct {
+	errors, logs []string
+}
+
+func (r *coverageRecorder) Helper() {}
+
+func (r *coverageRecorder) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func (r *coverageRecorder) Logf(format string, args ...interface{}) {
+	r.logs = append(r.logs, fmt.Sprintf(format, args...))
+}
+
+func withCoverage() func() {
+	TrackCoverage = true
+	ResetCoverage()
+	return func() {
+		TrackCoverage = false
+		ResetCoverage()
+	}
+}
+
+func TestCoverage(t *testing.T) {
+	defer withCoverage()()
+	apis := []API{
+		{Pattern: "GET /cov/user/{id}", APIHandler: okAPI},
+		{Pattern: "/cov/order", Methods: []string{"POST", "PUT"}, APIHandler: okAPI},
+		{Pattern: "/cov/missed", APIHandler: okAPI},
+		{Pattern: "/cov/health", APIHandler: okAPI, CoverageExempt: true},
+	}
+	m := NewMuxTest(apis)
+	m.Get("/cov/user/1", "")
+	m.Put("/cov/order", "", "{}")
+	m.Get("/cov/order", "") // 405 does not count
+
+	expect := CoverageResult{
+		Ratio: 2.0 / 3,
+		Covered: []RouteCoverage{
+			{Pattern: "/cov/order", Methods: []string{"PUT"}},
+			{Pattern: "GET /cov/user/{id}", Methods: []string{"GET"}},
+		},
+		Missed: []string{"/cov/missed"},
+		Exempt: []string{"/cov/health"},
+	}
+	if got := Coverage(apis); !reflect.DeepEqual(got, expect) {
+		t.Errorf("expected %+v, got %+v", expect, got)
+	}
+
+	r := &coverageRecorder{}
+	ReportCoverage(r, apis, 0.9)
+	if len(r.errors) != 1 || !strings.Contains(r.errors[0], "/cov/missed") || !strings.Contains(r.errors[0], "66.7%") {
+		t.Errorf("expected failure listing missed route, got %q", r.errors)
+	}
+
+	r = &coverageRecorder{}
+	ReportCoverage(r, apis, 0)
+	if len(r.errors) != 0 || len(r.logs) != 1 || !strings.Contains(r.logs[0], "/cov/missed") {
+		t.Errorf("expected warning only, got %q %q", r.errors, r.logs)
+	}
+
+	m.Get("/cov/missed", "")
+	r = &coverageRecorder{}
+	if res := ReportCoverage(r, apis, 1); res.Ratio != 1 || len(r.errors)+len(r.logs) != 0 {
+		t.Errorf("full coverage reported %q %q", r.errors, r.logs)
+	}
+}
+
+func TestCoverageFile(t *testing.T) {
+	defer withCoverage()()
+	CoverageFile = filepath.Join(t.TempDir(), "coverage.json")
+	defer func() { CoverageFile = "" }()
+
+	apis := []API{{Pattern: "/cov/file", APIHandler: okAPI}}
+	ReportCoverage(&coverageRecorder{}, apis, 0)
+	data, err := ioutil.ReadFile(CoverageFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res CoverageResult
+	if err := json.Unmarshal(data, &res); err != nil || res.Ratio != 0 || len(res.Missed) != 1 {
+		t.Errorf("unexpected report %s", data)
+	}
+}
+
+func TestCoverageDisabled(t *testing.T) {
+	ResetCoverage()
+	apis := []API{{Pattern: "/cov/off", APIHandler: okAPI}}
+	NewMuxTest(apis).Get("/cov/off", "")
+	if res := Coverage(apis); len(res.Covered) != 0 {
+		t.Errorf("calls are recorded without TrackCoverage: %+v", res)
+	}
+}
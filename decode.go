@@ -0,0 +1,200 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// KindUnknownField is the Kind of Error sent when request body contains unknown fields
+const KindUnknownField = "unknown_field"
+
+var (
+	unmarshalerType     = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// DecodeOrEmpty decodes next JSON document from dec into v like dec.Decode, but an
+// empty body, or one with only whitespace, means no input is provided and v is
+// left untouched. It is common for GET requests and bodyless POSTs.
+//
+//     var opts ListOptions
+//     if err := jsonapi.DecodeOrEmpty(dec, &opts); err != nil {
+//         return nil, jsonapi.E400.SetData("Cannot decode request body: " + err.Error())
+//     }
+//
+// Malformed JSON still fails, and so does a document cut in the middle, with
+// io.ErrUnexpectedEOF. Bind handles empty bodies the same way.
+func DecodeOrEmpty(dec *json.Decoder, v interface{}) error {
+	if err := dec.Decode(v); err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// DecodeExact decodes next JSON document from dec into v like dec.Decode, but matches
+// object keys against names of struct fields case-sensitively. encoding/json, for
+// example, happily fills field tagged `json:"id"` with {"ID": 1}.
+//
+// Keys matching a field only when case is ignored are treated as unknown fields. If
+// disallowUnknown is set, unknown fields are rejected with a 400 Error of
+// KindUnknownField. Otherwise they are silently ignored.
+func DecodeExact(dec *json.Decoder, v interface{}, disallowUnknown bool) error {
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+	return decodeDoc(raw, v, &docMatcher{exact: true, disallowUnknown: disallowUnknown})
+}
+
+// DecodeInt64Strings decodes next JSON document from dec into v like dec.Decode, but
+// int64 and uint64 values also accept string form like "9007199254740993". It is the
+// counterpart of Int64AsString for decoding.
+func DecodeInt64Strings(dec *json.Decoder, v interface{}) error {
+	var raw json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return err
+	}
+	return decodeDoc(raw, v, &docMatcher{int64Strings: true})
+}
+
+// decodeDoc unmarshals raw into v, adjusted by m
+func decodeDoc(raw json.RawMessage, v interface{}, m *docMatcher) error {
+	t := reflect.TypeOf(v)
+	if t == nil || t.Kind() != reflect.Ptr {
+		return json.Unmarshal(raw, v)
+	}
+
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+	var doc interface{}
+	if err := d.Decode(&doc); err != nil {
+		return err
+	}
+
+	doc, err := m.match(doc, t.Elem(), "$")
+	if err != nil {
+		return err
+	}
+	if m.modified {
+		buf, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		raw = buf
+	}
+	return json.Unmarshal(raw, v)
+}
+
+// docMatcher walks a generic JSON document along with the type it is decoded into
+type docMatcher struct {
+	exact           bool // match keys case-sensitively
+	disallowUnknown bool // reject unknown fields in exact mode
+	int64Strings    bool // accept strings for int64 and uint64
+	modified        bool
+}
+
+// match checks doc against t, and returns adjusted doc
+func (m *docMatcher) match(doc interface{}, t reflect.Type, path string) (interface{}, error) {
+	if reflect.PtrTo(t).Implements(unmarshalerType) || reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return doc, nil
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return m.match(doc, t.Elem(), path)
+	case reflect.Int64, reflect.Uint64:
+		if str, ok := doc.(string); ok && m.int64Strings {
+			var err error
+			if t.Kind() == reflect.Int64 {
+				_, err = strconv.ParseInt(str, 10, 64)
+			} else {
+				_, err = strconv.ParseUint(str, 10, 64)
+			}
+			if err == nil {
+				m.modified = true
+				return json.Number(str), nil
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		arr, ok := doc.([]interface{})
+		if !ok {
+			return doc, nil
+		}
+		for i, e := range arr {
+			v, err := m.match(e, t.Elem(), fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			arr[i] = v
+		}
+	case reflect.Map:
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			return doc, nil
+		}
+		for k, e := range obj {
+			v, err := m.match(e, t.Elem(), path+"."+k)
+			if err != nil {
+				return nil, err
+			}
+			obj[k] = v
+		}
+	case reflect.Struct:
+		if obj, ok := doc.(map[string]interface{}); ok {
+			return obj, m.object(obj, t, path)
+		}
+	}
+	return doc, nil
+}
+
+func (m *docMatcher) object(obj map[string]interface{}, t reflect.Type, path string) error {
+	fields := fieldsOf(t)
+	for k, e := range obj {
+		var exact, folded *field
+		for i := range fields {
+			f := &fields[i]
+			if f.name == k {
+				exact = f
+				break
+			}
+			if folded == nil && strings.EqualFold(f.name, k) {
+				folded = f
+			}
+		}
+
+		if f := exact; f != nil || (!m.exact && folded != nil) {
+			if f == nil {
+				f = folded
+			}
+			v, err := m.match(e, t.FieldByIndex(f.index).Type, path+"."+k)
+			if err != nil {
+				return err
+			}
+			obj[k] = v
+			continue
+		}
+		if !m.exact {
+			continue
+		}
+
+		if m.disallowUnknown {
+			msg := fmt.Sprintf("Unknown field %q at %s", k, path)
+			if folded != nil {
+				msg += fmt.Sprintf(", did you mean %q?", folded.name)
+			}
+			return Error{Code: http.StatusBadRequest, Message: msg, Kind: KindUnknownField}
+		}
+		if folded != nil {
+			delete(obj, k)
+			m.modified = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type exactBase struct {
+	ID int `json:"id"`
+}
+
+type exactArgs struct {
+	exactBase
+	Name  string       `json:"name"`
+	Owner exactOwner   `json:"owner"`
+	Tags  []exactOwner `json:"tags"`
+}
+
+type exactOwner struct {
+	Email string `json:"email"`
+}
+
+func decodeExact(data string, disallowUnknown bool) (exactArgs, error) {
+	var v exactArgs
+	err := DecodeExact(json.NewDecoder(strings.NewReader(data)), &v, disallowUnknown)
+	return v, err
+}
+
+func TestDecodeExact(t *testing.T) {
+	data := `{"id":1,"name":"a","owner":{"email":"a@b"},"tags":[{"email":"c@d"}]}`
+	for _, disallow := range []bool{false, true} {
+		v, err := decodeExact(data, disallow)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if v.ID != 1 || v.Name != "a" || v.Owner.Email != "a@b" || len(v.Tags) != 1 || v.Tags[0].Email != "c@d" {
+			t.Errorf("wrong result %+v", v)
+		}
+	}
+}
+
+func TestDecodeExactIgnoresMismatch(t *testing.T) {
+	v, err := decodeExact(`{"ID":1,"Name":"a","owner":{"Email":"a@b"},"tags":[{"EMAIL":"c@d"}],"extra":true}`, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if v.ID != 0 || v.Name != "" || v.Owner.Email != "" || len(v.Tags) != 1 || v.Tags[0].Email != "" {
+		t.Errorf("case-mismatched keys are not ignored: %+v", v)
+	}
+
+	// an exact key wins over a mismatched one
+	v, _ = decodeExact(`{"Name":"wrong","name":"right"}`, false)
+	if v.Name != "right" {
+		t.Errorf("expected right, got %q", v.Name)
+	}
+}
+
+func TestDecodeExactRejectsMismatch(t *testing.T) {
+	cases := map[string]string{
+		`{"ID":1}`:                      `Unknown field "ID" at $, did you mean "id"?`,
+		`{"owner":{"Email":"a@b"}}`:     `Unknown field "Email" at $.owner, did you mean "email"?`,
+		`{"tags":[{},{"EMAIL":"c@d"}]}`: `Unknown field "EMAIL" at $.tags[1], did you mean "email"?`,
+		`{"extra":true}`:                `Unknown field "extra" at $`,
+	}
+	for data, msg := range cases {
+		_, err := decodeExact(data, true)
+		e, ok := err.(Error)
+		if !ok || e.Code != http.StatusBadRequest || e.Kind != KindUnknownField || e.Message != msg {
+			t.Errorf("%s: expected %q, got %v", data, msg, err)
+		}
+	}
+}
+
+func TestDecodeOrEmpty(t *testing.T) {
+	cases := []struct {
+		body, name string
+		err        error
+	}{
+		{"", "default", nil},
+		{" \r\n\t ", "default", nil},
+		{`{"name":"john"}`, "john", nil},
+		{` {"name":"john"} `, "john", nil},
+		{`{}`, "default", nil},
+		{`{"name":`, "default", io.ErrUnexpectedEOF},
+		{`{"name":"jo`, "default", io.ErrUnexpectedEOF},
+	}
+	for _, c := range cases {
+		v := exactArgs{Name: "default"}
+		err := DecodeOrEmpty(json.NewDecoder(strings.NewReader(c.body)), &v)
+		if err != c.err || v.Name != c.name {
+			t.Errorf("%q: got %q, %v", c.body, v.Name, err)
+		}
+	}
+
+	for _, body := range []string{`nil`, `{"name":1}`, `}`} {
+		var v exactArgs
+		if err := DecodeOrEmpty(json.NewDecoder(strings.NewReader(body)), &v); err == nil || err == io.ErrUnexpectedEOF {
+			t.Errorf("%q: expected malformed JSON, got %v", body, err)
+		}
+	}
+
+	// Bind handles empty bodies the same way
+	h := HandlerTest(Typed(func(args exactArgs, httpData *HTTP) (string, error) {
+		return args.Name, nil
+	}).Handler)
+	for body, code := range map[string]int{"": http.StatusOK, "\n": http.StatusOK, `{"name":`: http.StatusBadRequest} {
+		if resp, _ := h.Post("/", "", body); resp.Code != code {
+			t.Errorf("Bind %q: expected %d, got %d %s", body, code, resp.Code, resp.Body)
+		}
+	}
+}
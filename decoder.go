@@ -0,0 +1,63 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// DecoderOptions configures the json.Decoder passed to handlers
+type DecoderOptions struct {
+	// DisallowUnknownFields rejects fields unknown to the struct decoded into. Bind
+	// and APIHandler report them by a 400 Error of KindUnknownField naming the field.
+	// See also DecodeExact, which also matches names case-sensitively.
+	DisallowUnknownFields bool
+
+	// UseNumber decodes numbers into interface{} as json.Number instead of float64,
+	// so large integers like int64 IDs are kept exactly
+	UseNumber bool
+}
+
+// DefaultDecoderOptions configures decoders created by HTTPHandler. API.StrictFields
+// and API.UseNumber enable the options per route.
+var DefaultDecoderOptions DecoderOptions
+
+func (h *HTTP) decoderOptions() DecoderOptions {
+	ret := DefaultDecoderOptions
+	if h.api != nil {
+		ret.DisallowUnknownFields = ret.DisallowUnknownFields || h.api.StrictFields
+		ret.UseNumber = ret.UseNumber || h.api.UseNumber
+	}
+	return ret
+}
+
+// newDecoder creates the decoder passed to handlers, reading from r
+func (h *HTTP) newDecoder(r io.Reader) *json.Decoder {
+	ret := json.NewDecoder(r)
+	h.decoderOptions().apply(ret)
+	return ret
+}
+
+func (o DecoderOptions) apply(dec *json.Decoder) {
+	if o.DisallowUnknownFields {
+		dec.DisallowUnknownFields()
+	}
+	if o.UseNumber {
+		dec.UseNumber()
+	}
+}
+
+// unknownField converts the error of json.Decoder rejecting unknown field
+func unknownField(err error) (Error, bool) {
+	name, ok := strings.CutPrefix(err.Error(), "json: unknown field ")
+	if !ok {
+		return Error{}, false
+	}
+	return Error{
+		Code:    http.StatusBadRequest,
+		Message: fmt.Sprintf("Unknown field %s", name),
+		Kind:    KindUnknownField,
+	}, true
+}
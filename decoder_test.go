@@ -0,0 +1,75 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type decoderArgs struct {
+	Name string `json:"name"`
+}
+
+func TestDecoderOptions(t *testing.T) {
+	bind := func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		var args decoderArgs
+		if err := Bind(dec, httpData, &args); err != nil {
+			return nil, err
+		}
+		return args.Name, nil
+	}
+	raw := func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		var args decoderArgs
+		if err := dec.Decode(&args); err != nil {
+			return nil, err
+		}
+		return args.Name, nil
+	}
+	number := func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		var args map[string]interface{}
+		if err := dec.Decode(&args); err != nil {
+			return nil, err
+		}
+		_, exact := args["id"].(json.Number)
+		return exact, nil
+	}
+	apis := []API{
+		{Pattern: "/bind", APIHandler: bind},
+		{Pattern: "/bind/strict", APIHandler: bind, StrictFields: true},
+		{Pattern: "/raw/strict", APIHandler: raw, StrictFields: true},
+		{Pattern: "/number", APIHandler: number},
+		{Pattern: "/number/exact", APIHandler: number, UseNumber: true},
+	}
+	m := NewMuxTest(apis)
+
+	extra := `{"name":"john","admin":true}`
+	large := `{"id":9007199254740993}`
+	cases := []struct {
+		uri, body string
+		code      int
+		expect    string
+	}{
+		{"/bind", extra, 200, `"john"`},
+		{"/bind/strict", extra, 400, `{"error":{"code":400,"message":"Unknown field \"admin\"","kind":"unknown_field"}}`},
+		{"/raw/strict", extra, 400, `{"error":{"code":400,"message":"Unknown field \"admin\"","kind":"unknown_field"}}`},
+		{"/bind/strict", `{"name":"john"}`, 200, `"john"`},
+		{"/number", large, 200, `false`},
+		{"/number/exact", large, 200, `true`},
+	}
+	for _, c := range cases {
+		resp, _ := m.Post(c.uri, "", c.body)
+		if s := strings.TrimSpace(resp.Body.String()); resp.Code != c.code || s != c.expect {
+			t.Errorf("%s %s: unexpected response %d: %s", c.uri, c.body, resp.Code, s)
+		}
+	}
+
+	DefaultDecoderOptions = DecoderOptions{DisallowUnknownFields: true, UseNumber: true}
+	defer func() { DefaultDecoderOptions = DecoderOptions{} }()
+	if resp, _ := m.Post("/bind", "", extra); resp.Code != http.StatusBadRequest {
+		t.Errorf("DefaultDecoderOptions is not applied: %d %s", resp.Code, resp.Body)
+	}
+	if resp, _ := m.Post("/number", "", large); strings.TrimSpace(resp.Body.String()) != "true" {
+		t.Errorf("DefaultDecoderOptions is not applied: %s", resp.Body)
+	}
+}
@@ -0,0 +1,76 @@
+package jsonapi
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// KindInvalidContentEncoding is the Kind of Error sent when compressed request body
+// is corrupt
+const KindInvalidContentEncoding = "invalid_content_encoding"
+
+var errCorruptBody = Error{
+	Code:    http.StatusBadRequest,
+	Message: "Request body is not properly compressed",
+	Kind:    KindInvalidContentEncoding,
+}
+
+// decompressBody makes request bodies of Content-Encoding gzip or deflate (zlib
+// format) readable as is. Content-Encoding header is removed and ContentLength is
+// unknown afterwards, like http.Transport does for responses, and MaxBodyBytes limits
+// the decompressed size.
+func decompressBody(h *HTTP, r *http.Request) {
+	enc := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	if enc != "gzip" && enc != "deflate" {
+		return
+	}
+	r.Body = &decompressReader{ReadCloser: r.Body, encoding: enc, h: h}
+	r.Header.Del("Content-Encoding")
+	r.ContentLength = -1
+}
+
+// decompressReader decompresses request body. Decompressor is created at the first
+// Read, so nothing is read until handler does.
+type decompressReader struct {
+	io.ReadCloser // compressed body
+	encoding      string
+	h             *HTTP
+	r             io.Reader
+}
+
+func (d *decompressReader) Read(p []byte) (int, error) {
+	if d.r == nil {
+		var err error
+		if d.encoding == "gzip" {
+			d.r, err = gzip.NewReader(d.ReadCloser)
+		} else {
+			d.r, err = zlib.NewReader(d.ReadCloser)
+		}
+		if err != nil {
+			d.r = errReader{err}
+		}
+	}
+	n, err := d.r.Read(p)
+	if isCorrupt(err) {
+		d.h.setBodyErr(&errCorruptBody)
+	}
+	return n, err
+}
+
+type errReader struct{ err error }
+
+func (r errReader) Read(p []byte) (int, error) { return 0, r.err }
+
+// isCorrupt reports whether err is caused by malformed compressed data
+func isCorrupt(err error) bool {
+	var corrupt flate.CorruptInputError
+	return errors.Is(err, gzip.ErrHeader) || errors.Is(err, gzip.ErrChecksum) ||
+		errors.Is(err, zlib.ErrHeader) || errors.Is(err, zlib.ErrChecksum) ||
+		errors.Is(err, zlib.ErrDictionary) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.As(err, &corrupt)
+}
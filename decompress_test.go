@@ -0,0 +1,74 @@
+package jsonapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func compressed(encoding, s string) []byte {
+	buf := &bytes.Buffer{}
+	var w io.WriteCloser
+	if encoding == "deflate" {
+		w = zlib.NewWriter(buf)
+	} else {
+		w = gzip.NewWriter(buf)
+	}
+	w.Write([]byte(s))
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestCompressedRequestBody(t *testing.T) {
+	m := NewMuxTest([]API{
+		{Pattern: "/echo", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			var args map[string]interface{}
+			if err := Bind(dec, httpData, &args); err != nil {
+				return nil, err
+			}
+			return args, nil
+		}},
+		{Pattern: "/small", APIHandler: decodeAny, MaxBodyBytes: 4 << 10},
+	})
+	send := func(uri, encoding string, body []byte) (int, ErrorBody, string) {
+		req, _ := http.NewRequest("POST", uri, bytes.NewReader(body))
+		req.Header.Set("Content-Encoding", encoding)
+		resp := m.Do(req)
+		var e ErrorBody
+		json.Unmarshal(resp.Body.Bytes(), &e)
+		return resp.Code, e, strings.TrimSpace(resp.Body.String())
+	}
+
+	payload := `{"name":"john","tags":["a","b"]}`
+	for _, enc := range []string{"gzip", "deflate", "GZIP"} {
+		code, _, body := send("/echo", enc, compressed(strings.ToLower(enc), payload))
+		if code != http.StatusOK || body != payload {
+			t.Errorf("%s: unexpected response %d: %s", enc, code, body)
+		}
+	}
+
+	corrupt := compressed("gzip", payload)
+	corrupt[len(corrupt)/2] ^= 0xff
+	for name, body := range map[string][]byte{
+		"not compressed": []byte(payload),
+		"corrupt":        corrupt,
+		"truncated":      compressed("gzip", payload)[:20],
+	} {
+		code, e, s := send("/echo", "gzip", body)
+		if code != http.StatusBadRequest || e.Error.Kind != KindInvalidContentEncoding {
+			t.Errorf("%s: unexpected response %d: %s", name, code, s)
+		}
+	}
+
+	// limit applies to decompressed size
+	bomb := compressed("gzip", `["`+strings.Repeat("x", 1<<20)+`"]`)
+	code, e, s := send("/small", "gzip", bomb)
+	if len(bomb) > 4<<10 || code != http.StatusRequestEntityTooLarge || e.Error.Kind != KindRequestTooLarge {
+		t.Errorf("unexpected response %d for %d bytes: %s", code, len(bomb), s)
+	}
+}
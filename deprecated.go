@@ -0,0 +1,79 @@
+package jsonapi
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// OnDeprecated, if not nil, is called every time a deprecated route or request field
+// is used, so you can track remaining usage before removing it. name is the route
+// pattern, or path of the field like "order.coupon". Fields are reported only for
+// requests checked by WarnDeprecated.
+var OnDeprecated func(httpData *HTTP, name, date string)
+
+// deprecated adds a Warning header describing the deprecation, and calls OnDeprecated
+func (h *HTTP) deprecated(what, name, date string) {
+	h.ResponseWriter.Header().Add(
+		"Warning",
+		fmt.Sprintf(`299 - "%s %s is deprecated and will be removed after %s"`, what, name, date),
+	)
+	if OnDeprecated != nil {
+		OnDeprecated(h, name, date)
+	}
+}
+
+// WarnDeprecated checks decoded request v for populated fields tagged as deprecated,
+// and warns the client about each of them the same way as deprecated routes.
+//
+// Bind, Typed and Wrap call it for you. Handlers decoding with dec.Decode, or
+// helpers like DecodeExact, never warn unless they call it, because the type of
+// request is not known until it is decoded:
+//
+//     type OrderArgs struct {
+//         Item   string `json:"item"`
+//         Coupon string `json:"coupon" deprecated:"2025-12-31"`
+//     }
+//
+//     if err := dec.Decode(&args); err != nil {
+//         return nil, jsonapi.E400
+//     }
+//     httpData.WarnDeprecated(args)
+func (h *HTTP) WarnDeprecated(v interface{}) {
+	h.warnDeprecated(reflect.ValueOf(v), "")
+}
+
+func (h *HTTP) warnDeprecated(v reflect.Value, path string) {
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if !v.IsNil() {
+			h.warnDeprecated(v.Elem(), path)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			h.warnDeprecated(v.Index(i), fmt.Sprintf("%s[%d]", path, i))
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			h.warnDeprecated(iter.Value(), fmt.Sprintf("%s[%v]", path, iter.Key()))
+		}
+	case reflect.Struct:
+		if isMarshaler(v) {
+			return
+		}
+		for _, f := range fieldsOf(v.Type()) {
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok {
+				continue
+			}
+			p := f.name
+			if path != "" {
+				p = path + "." + f.name
+			}
+			if date := f.tag.Get("deprecated"); date != "" && !fv.IsZero() {
+				h.deprecated("Field", p, date)
+			}
+			h.warnDeprecated(fv, p)
+		}
+	}
+}
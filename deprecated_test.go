@@ -0,0 +1,113 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type orderArgs struct {
+	Item   string       `json:"item"`
+	Coupon string       `json:"coupon" deprecated:"2025-12-31"`
+	Lines  []orderLine  `json:"lines"`
+	Note   *orderDetail `json:"note"`
+}
+
+type orderLine struct {
+	SKU string `json:"sku" deprecated:"2026-01-31"`
+}
+
+type orderDetail struct {
+	Text string `json:"text"`
+}
+
+func TestDeprecatedRoute(t *testing.T) {
+	var calls []string
+	OnDeprecated = func(httpData *HTTP, name, date string) {
+		calls = append(calls, name+" "+date)
+	}
+	defer func() { OnDeprecated = nil }()
+
+	m := NewMuxTest([]API{
+		{Pattern: "/old", APIHandler: okAPI, Deprecated: "2025-12-31"},
+		{Pattern: "/new", APIHandler: okAPI},
+	})
+	resp, _ := m.Get("/old", "")
+	expect := `299 - "API /old is deprecated and will be removed after 2025-12-31"`
+	if w := resp.Header().Get("Warning"); w != expect {
+		t.Errorf("expected Warning %s, got %q", expect, w)
+	}
+	resp, _ = m.Get("/new", "")
+	if w := resp.Header().Get("Warning"); w != "" {
+		t.Errorf("unexpected Warning %q", w)
+	}
+	if len(calls) != 1 || calls[0] != "/old 2025-12-31" {
+		t.Errorf("OnDeprecated called with %v", calls)
+	}
+}
+
+func TestDeprecatedField(t *testing.T) {
+	var calls []string
+	OnDeprecated = func(httpData *HTTP, name, date string) {
+		calls = append(calls, name)
+	}
+	defer func() { OnDeprecated = nil }()
+
+	m := NewMuxTest([]API{{Pattern: "/", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		var args orderArgs
+		if err := Bind(dec, httpData, &args); err != nil {
+			return nil, err
+		}
+		return args.Item, nil
+	}}})
+
+	resp, _ := m.Post("/", "", `{"item":"a","coupon":"FREE","lines":[{},{"sku":"x"}]}`)
+	warnings := resp.Header().Values("Warning")
+	expect := []string{
+		`299 - "Field coupon is deprecated and will be removed after 2025-12-31"`,
+		`299 - "Field lines[1].sku is deprecated and will be removed after 2026-01-31"`,
+	}
+	if len(warnings) != len(expect) {
+		t.Fatalf("expected %d warnings, got %q", len(expect), warnings)
+	}
+	for i := range expect {
+		if warnings[i] != expect[i] {
+			t.Errorf("expected %s, got %s", expect[i], warnings[i])
+		}
+	}
+	if len(calls) != 2 || calls[0] != "coupon" || calls[1] != "lines[1].sku" {
+		t.Errorf("OnDeprecated called with %v", calls)
+	}
+
+	resp, _ = m.Post("/", "", `{"item":"a","note":{"text":"hi"}}`)
+	if w := resp.Header().Values("Warning"); len(w) != 0 {
+		t.Errorf("unexpected Warning %q", w)
+	}
+}
+
+func TestDeprecatedFieldDecodingPaths(t *testing.T) {
+	m := NewMuxTest([]API{
+		{Pattern: "/typed", APIHandler: Typed(func(args orderArgs, httpData *HTTP) (string, error) {
+			return args.Item, nil
+		})},
+		{Pattern: "/decode", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			var args orderArgs
+			if err := dec.Decode(&args); err != nil {
+				return nil, E400
+			}
+			httpData.WarnDeprecated(args)
+			return args.Item, nil
+		}},
+		{Pattern: "/unchecked", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			var args orderArgs
+			return args.Item, dec.Decode(&args)
+		}},
+	})
+	warning := `299 - "Field coupon is deprecated and will be removed after 2025-12-31"`
+	for uri, expect := range map[string]int{"/typed": 1, "/decode": 1, "/unchecked": 0} {
+		resp, _ := m.Post(uri, "", `{"item":"a","coupon":"FREE"}`)
+		w := resp.Header().Values("Warning")
+		if resp.Code != 200 || len(w) != expect || (expect > 0 && w[0] != warning) {
+			t.Errorf("%s: got %d %q", uri, resp.Code, w)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package jsonapi
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"hash"
+	"net/http"
+	"strings"
+)
+
+// KindDigestMismatch is the Kind of Error sent when request body does not match its digest
+const KindDigestMismatch = "digest_mismatch"
+
+// KindDigestRequired is the Kind of Error sent by RequireDigest when no supported digest is declared
+const KindDigestRequired = "digest_required"
+
+// digestAlgorithms lists supported algorithms, strongest first
+var digestAlgorithms = []struct {
+	name string
+	hash func() hash.Hash
+}{
+	{"sha-256", sha256.New},
+	{"md5", md5.New},
+}
+
+// VerifyDigest verifies request body against the Digest (RFC 3230) or Content-MD5
+// header before calling next, rejecting mismatches with a 400 Error of
+// KindDigestMismatch. MD5 and SHA-256 are supported, and the strongest one is
+// verified if there are many. Requests without supported digest are passed as-is.
+//
+//     http.Handle("/api/upload", jsonapi.HTTPHandler(jsonapi.VerifyDigest(upload.Handler)))
+//
+// The request body is buffered in memory, see HTTP.RawBody.
+func VerifyDigest(next HTTPHandler) HTTPHandler {
+	return verifyDigest(next, false)
+}
+
+// RequireDigest is like VerifyDigest, but also rejects requests without supported
+// digest with a 400 Error of KindDigestRequired.
+func RequireDigest(next HTTPHandler) HTTPHandler {
+	return verifyDigest(next, true)
+}
+
+func verifyDigest(next HTTPHandler, required bool) HTTPHandler {
+	return func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		algo, want := declaredDigest(httpData.Request.Header)
+		if algo < 0 {
+			if required {
+				writeError(enc, httpData, Error{
+					Code:    http.StatusBadRequest,
+					Message: "Request must carry a SHA-256 or MD5 digest of the body",
+					Kind:    KindDigestRequired,
+				})
+				return
+			}
+			next(enc, dec, httpData)
+			return
+		}
+
+		raw, err := httpData.RawBody()
+		if err != nil {
+			writeError(enc, httpData, E400.SetData("Cannot read request body"))
+			return
+		}
+		h := digestAlgorithms[algo].hash()
+		h.Write(raw)
+		if subtle.ConstantTimeCompare(h.Sum(nil), want) != 1 {
+			writeError(enc, httpData, Error{
+				Code:    http.StatusBadRequest,
+				Message: "Request body does not match " + digestAlgorithms[algo].name + " digest",
+				Kind:    KindDigestMismatch,
+			})
+			return
+		}
+
+		next(enc, httpData.newDecoder(httpData.Request.Body), httpData)
+	}
+}
+
+// declaredDigest finds strongest supported digest in request headers, and returns
+// its index in digestAlgorithms, or -1 if none.
+func declaredDigest(h http.Header) (algo int, sum []byte) {
+	found := map[string][]byte{}
+	for _, line := range h.Values("Digest") {
+		for _, d := range strings.Split(line, ",") {
+			name, value, ok := strings.Cut(strings.TrimSpace(d), "=")
+			if !ok {
+				continue
+			}
+			if sum, err := base64.StdEncoding.DecodeString(value); err == nil {
+				found[strings.ToLower(name)] = sum
+			}
+		}
+	}
+	if _, ok := found["md5"]; !ok {
+		if sum, err := base64.StdEncoding.DecodeString(h.Get("Content-MD5")); err == nil && len(sum) > 0 {
+			found["md5"] = sum
+		}
+	}
+
+	for i, a := range digestAlgorithms {
+		if sum, ok := found[a.name]; ok {
+			return i, sum
+		}
+	}
+	return -1, nil
+}
@@ -0,0 +1,61 @@
+package jsonapi
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestVerifyDigest(t *testing.T) {
+	body := `{"file":"a.txt"}`
+	sha := sha256.Sum256([]byte(body))
+	sum := md5.Sum([]byte(body))
+	shaDigest := "sha-256=" + base64.StdEncoding.EncodeToString(sha[:])
+	md5Digest := base64.StdEncoding.EncodeToString(sum[:])
+	wrong := base64.StdEncoding.EncodeToString(make([]byte, 16))
+
+	handler := APIHandler(decodeAny).Handler
+	verify, require := HandlerTest(VerifyDigest(handler)), HandlerTest(RequireDigest(handler))
+	cases := []struct {
+		name    string
+		h       HandlerTest
+		headers Headers
+		status  int
+		kind    string
+	}{
+		{"sha-256", verify, Headers{"Digest": shaDigest}, http.StatusOK, ""},
+		{"md5", verify, Headers{"Digest": "MD5=" + md5Digest}, http.StatusOK, ""},
+		{"content-md5", verify, Headers{"Content-MD5": md5Digest}, http.StatusOK, ""},
+		{"mismatch", verify, Headers{"Digest": "sha-256=" + wrong}, http.StatusBadRequest, KindDigestMismatch},
+		{"content-md5 mismatch", verify, Headers{"Content-MD5": wrong}, http.StatusBadRequest, KindDigestMismatch},
+		// only sha-256 is verified, so wrong md5 does not matter
+		{"strongest", verify, Headers{"Digest": "md5=" + wrong + ", " + shaDigest}, http.StatusOK, ""},
+		{"strongest mismatch", verify, Headers{"Digest": "md5=" + md5Digest + ",sha-256=" + wrong}, http.StatusBadRequest, KindDigestMismatch},
+		{"unsupported only", verify, Headers{"Digest": "unixsum=30637"}, http.StatusOK, ""},
+		{"none", verify, Headers{}, http.StatusOK, ""},
+		{"required", require, Headers{"Digest": shaDigest}, http.StatusOK, ""},
+		{"required none", require, Headers{}, http.StatusBadRequest, KindDigestRequired},
+		{"required unsupported only", require, Headers{"Digest": "unixsum=30637"}, http.StatusBadRequest, KindDigestRequired},
+	}
+	for _, c := range cases {
+		resp, err := c.h.With(c.headers).Post("/api/upload", "", body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Code != c.status {
+			t.Errorf("%s: status = %d: %s", c.name, resp.Code, resp.Body)
+			continue
+		}
+		if c.kind == "" {
+			continue
+		}
+		var e ErrorBody
+		json.Unmarshal(resp.Body.Bytes(), &e)
+		if e.Error.Kind != c.kind {
+			t.Errorf("%s: expected kind %s, got %+v", c.name, c.kind, e.Error)
+		}
+	}
+}
@@ -0,0 +1,247 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Example is a sample request and response of an API, used in documentation
+type Example struct {
+	Title    string
+	Request  interface{}
+	Response interface{}
+}
+
+// DocOpts configures GenerateMarkdown
+type DocOpts struct {
+	Title       string // title of the document, defaults to "API"
+	Description string // introduction under the title
+}
+
+// GenerateMarkdown renders documentation of apis in Markdown format: one section for
+// each route, sorted by pattern, with API.Description, API.Scopes, API.RequiredHeaders,
+// field tables of API.Request and API.Response, API.Examples and API.Errors.
+//
+// Fields are documented with `doc` tag, and marked required by `validate:"required"`.
+//
+//     type CreateUserArgs struct {
+//         Name  string `json:"name" validate:"required" doc:"display name"`
+//         Email string `json:"email,omitempty" doc:"contact address"`
+//     }
+func GenerateMarkdown(apis []API, opts DocOpts) ([]byte, error) {
+	if opts.Title == "" {
+		opts.Title = "API"
+	}
+	sorted := append([]API(nil), apis...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return patternPath(sorted[i].Pattern)+" "+sorted[i].Pattern < patternPath(sorted[j].Pattern)+" "+sorted[j].Pattern
+	})
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "# %s\n", opts.Title)
+	if opts.Description != "" {
+		fmt.Fprintf(buf, "\n%s\n", strings.TrimSpace(opts.Description))
+	}
+
+	for _, api := range sorted {
+		method, path := "ANY", patternPath(api.Pattern)
+		if i := strings.IndexAny(api.Pattern, " \t"); i >= 0 {
+			method = api.Pattern[:i]
+		}
+		if len(api.Methods) > 0 {
+			method = strings.Join(api.Methods, ", ")
+		}
+		fmt.Fprintf(buf, "\n## %s %s\n", method, path)
+		if api.Name != "" {
+			fmt.Fprintf(buf, "\nName: `%s`\n", api.Name)
+		}
+		if api.Deprecated != "" {
+			fmt.Fprintf(buf, "\n**Deprecated**, will be removed at %s.\n", api.Deprecated)
+		}
+		if api.Gone != nil {
+			fmt.Fprintf(buf, "\n**Removed**: %s\n", api.Gone.Message)
+		}
+		if api.Description != "" {
+			fmt.Fprintf(buf, "\n%s\n", strings.TrimSpace(api.Description))
+		}
+		if len(api.Scopes) > 0 {
+			fmt.Fprintf(buf, "\nScopes: %s\n", codeList(api.Scopes))
+		}
+		if len(api.RequiredHeaders) > 0 {
+			buf.WriteString("\n### Headers\n\n")
+			buf.WriteString("| Name | Format |\n")
+			buf.WriteString("|------|--------|\n")
+			for _, h := range api.RequiredHeaders {
+				format := ""
+				if h.Match != "" {
+					format = "`" + mdEscape(h.Match) + "`"
+				}
+				fmt.Fprintf(buf, "| `%s` | %s |\n", http.CanonicalHeaderKey(h.Name), format)
+			}
+		}
+
+		for _, part := range []struct {
+			title string
+			v     interface{}
+		}{{"Request", api.Request}, {"Response", api.Response}} {
+			if part.v == nil {
+				continue
+			}
+			fmt.Fprintf(buf, "\n### %s\n\n", part.title)
+			rows := docFields(reflect.TypeOf(part.v), "", map[reflect.Type]bool{})
+			if len(rows) == 0 {
+				fmt.Fprintf(buf, "Type: %s\n", jsonTypeName(reflect.TypeOf(part.v)))
+				continue
+			}
+			buf.WriteString("| Name | Type | Required | Description |\n")
+			buf.WriteString("|------|------|----------|-------------|\n")
+			for _, r := range rows {
+				req := ""
+				if r.required {
+					req = "yes"
+				}
+				fmt.Fprintf(buf, "| `%s` | %s | %s | %s |\n", r.name, r.typ, req, mdEscape(r.doc))
+			}
+		}
+
+		for _, ex := range api.Examples {
+			title := ex.Title
+			if title == "" {
+				title = "Example"
+			}
+			fmt.Fprintf(buf, "\n### %s\n", title)
+			for _, part := range []struct {
+				title string
+				v     interface{}
+			}{{"Request", ex.Request}, {"Response", ex.Response}} {
+				if part.v == nil {
+					continue
+				}
+				data, err := json.MarshalIndent(part.v, "", "  ")
+				if err != nil {
+					return nil, fmt.Errorf("jsonapi: example %q of %s: %s", title, api.Pattern, err)
+				}
+				fmt.Fprintf(buf, "\n%s:\n\n```json\n%s\n```\n", part.title, data)
+			}
+		}
+
+		if len(api.Errors) > 0 {
+			errs := append([]string(nil), api.Errors...)
+			sort.Strings(errs)
+			fmt.Fprintf(buf, "\n### Errors\n\n")
+			for _, kind := range errs {
+				fmt.Fprintf(buf, "- `%s`\n", kind)
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+func codeList(items []string) string {
+	ret := make([]string, len(items))
+	for i, s := range items {
+		ret[i] = "`" + s + "`"
+	}
+	return strings.Join(ret, ", ")
+}
+
+func mdEscape(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+type docField struct {
+	name     string
+	typ      string
+	required bool
+	doc      string
+}
+
+// docFields lists fields of t, nested ones are named like "items[].name"
+func docFields(t reflect.Type, prefix string, seen map[reflect.Type]bool) []docField {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case isDocLeaf(t):
+		return nil
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return docFields(t.Elem(), prefix+"[]", seen)
+	case t.Kind() == reflect.Map:
+		return docFields(t.Elem(), prefix+".*", seen)
+	case t.Kind() != reflect.Struct || seen[t]:
+		return nil
+	}
+
+	seen[t] = true
+	defer delete(seen, t)
+	var ret []docField
+	for _, f := range fieldsOf(t) {
+		ft := t.FieldByIndex(f.index).Type
+		name := f.name
+		if prefix != "" {
+			name = prefix + "." + f.name
+		}
+		ret = append(ret, docField{
+			name:     name,
+			typ:      jsonTypeName(ft),
+			required: hasOption(f.tag.Get("validate"), "required"),
+			doc:      f.tag.Get("doc"),
+		})
+		ret = append(ret, docFields(ft, name, seen)...)
+	}
+	return ret
+}
+
+// isDocLeaf reports whether t is encoded as a value without fields
+func isDocLeaf(t reflect.Type) bool {
+	pt := reflect.PtrTo(t)
+	return t == timeType || t.Implements(marshalerType) || pt.Implements(marshalerType) ||
+		t.Implements(textMarshalerType) || pt.Implements(textMarshalerType)
+}
+
+// jsonTypeName is name of JSON type t is encoded into
+func jsonTypeName(t reflect.Type) string {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return "string (date-time)"
+	case t.Implements(textMarshalerType) || reflect.PtrTo(t).Implements(textMarshalerType):
+		return "string"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string (base64)"
+		}
+		return "array of " + jsonTypeName(t.Elem())
+	case reflect.Map, reflect.Struct:
+		return "object"
+	}
+	return "any"
+}
+
+// hasOption checks if comma separated tag contains opt
+func hasOption(tag, opt string) bool {
+	for _, s := range strings.Split(tag, ",") {
+		if strings.TrimSpace(s) == opt {
+			return true
+		}
+	}
+	return false
+}
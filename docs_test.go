@@ -0,0 +1,65 @@
+package jsonapi
+
+import (
+	"testing"
+	"time"
+)
+
+type docUser struct {
+	ID      int64     `json:"id" doc:"unique id"`
+	Name    string    `json:"name" validate:"required" doc:"display name"`
+	Email   string    `json:"email,omitempty" doc:"contact | address"`
+	Created time.Time `json:"created"`
+	Tags    []docTag  `json:"tags"`
+	Extra   map[string]docTag
+	secret  string
+}
+
+type docTag struct {
+	Label string `json:"label"`
+}
+
+func TestGenerateMarkdown(t *testing.T) {
+	apis := []API{
+		{
+			Pattern:     "POST /api/user",
+			Name:        "createUser",
+			Description: "Creates a user.",
+			Scopes:      []string{"user:write", "admin"},
+			Request:     docUser{},
+			Response:    &docUser{},
+			Examples: []Example{{
+				Title:    "Create john",
+				Request:  map[string]string{"name": "john"},
+				Response: map[string]interface{}{"id": 1, "name": "john"},
+			}},
+			Errors:          []string{KindValidation, KindDuplicateKey},
+			RequiredHeaders: []HeaderRule{{Name: "x-request-id", Match: `^[a-z]+|[0-9]+$`}, {Name: "idempotency-key"}},
+		},
+		{Pattern: "/api/old", Gone: &GoneInfo{Message: "Use /api/new"}},
+		{Pattern: "GET /api/user/{id}", Response: docUser{}, Deprecated: "2025-12-31"},
+		{Pattern: "/api/count", Methods: []string{"GET", "HEAD"}, Response: 0},
+	}
+	got, err := GenerateMarkdown(apis, DocOpts{Title: "User service", Description: "  Manages users.\n"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "docs.golden", got)
+
+	// order of apis does not matter
+	reversed := make([]API, len(apis))
+	for i, api := range apis {
+		reversed[len(apis)-1-i] = api
+	}
+	again, _ := GenerateMarkdown(reversed, DocOpts{Title: "User service", Description: "Manages users."})
+	if string(again) != string(got) {
+		t.Errorf("output depends on order of apis")
+	}
+}
+
+func TestGenerateMarkdownBadExample(t *testing.T) {
+	apis := []API{{Pattern: "/", Examples: []Example{{Response: func() {}}}}}
+	if _, err := GenerateMarkdown(apis, DocOpts{}); err == nil {
+		t.Errorf("expected error for example which cannot be encoded")
+	}
+}
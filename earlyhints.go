@@ -0,0 +1,66 @@
+package jsonapi
+
+import (
+	"log"
+	"net/http"
+)
+
+// unwrapper is implemented by ResponseWriter wrappers, see http.ResponseController
+type unwrapper interface {
+	Unwrap() http.ResponseWriter
+}
+
+// bottomWriter unwraps w until the ResponseWriter created by server
+func bottomWriter(w http.ResponseWriter) (http.ResponseWriter, bool) {
+	sent := false
+	for {
+		if rw, ok := w.(*responseWriter); ok && rw.wroteHeader {
+			sent = true
+		}
+		u, ok := w.(unwrapper)
+		if !ok {
+			return w, sent
+		}
+		w = u.Unwrap()
+	}
+}
+
+// EarlyHints sends a 103 Early Hints response with links as Link headers, so
+// browsers can preload resources while handler is still working. It can be called
+// many times before sending the final response, which is not affected.
+//
+//     httpData.EarlyHints([]string{"</style.css>; rel=preload; as=style"})
+//
+// ResponseWriters not created by net/http server, like httptest.ResponseRecorder,
+// treat 103 as final status code, so nothing is sent to them and a warning is logged.
+func (h *HTTP) EarlyHints(links []string) error {
+	if h.replied {
+		return ErrReplied
+	}
+	w, sent := bottomWriter(h.ResponseWriter)
+	if sent {
+		return ErrReplied
+	}
+	// HTTP/1.x server writer is a Hijacker, HTTP/2 one is a Pusher
+	_, h1 := w.(http.Hijacker)
+	_, h2 := w.(http.Pusher)
+	if !h1 && !h2 {
+		log.Printf("jsonapi: %T does not support 103 Early Hints, ignored", w)
+		return nil
+	}
+
+	// interim responses are sent with all headers set so far, keep only the links
+	header := w.Header()
+	saved := header.Clone()
+	for k := range header {
+		delete(header, k)
+	}
+	header["Link"] = links
+	w.WriteHeader(http.StatusEarlyHints)
+
+	delete(header, "Link")
+	for k, v := range saved {
+		header[k] = v
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package jsonapi
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptrace"
+	"net/textproto"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEarlyHints(t *testing.T) {
+	got := make(chan []string, 2)
+	m := NewMuxTest([]API{{Pattern: "/page", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		httpData.ResponseWriter.Header().Set("X-Final", "yes")
+		if err := httpData.EarlyHints([]string{"</style.css>; rel=preload; as=style"}); err != nil {
+			return nil, err
+		}
+		if err := httpData.EarlyHints([]string{"</app.js>; rel=preload; as=script"}); err != nil {
+			return nil, err
+		}
+		// the body is not sent until client has seen both hints
+		for i := 0; i < 2; i++ {
+			select {
+			case <-got:
+			case <-time.After(5 * time.Second):
+				return nil, E500.SetData("hints not received")
+			}
+		}
+		return "page", nil
+	}}})
+	srv := m.StartServer()
+	defer srv.Close()
+
+	var hints [][]string
+	trace := &httptrace.ClientTrace{
+		Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+			if code != http.StatusEarlyHints {
+				t.Errorf("unexpected interim status %d", code)
+			}
+			if header.Get("X-Final") != "" {
+				t.Errorf("final headers leaked into interim response: %v", header)
+			}
+			hints = append(hints, header["Link"])
+			got <- header["Link"]
+			return nil
+		},
+	}
+	req, _ := http.NewRequest("GET", srv.URL+"/page", nil)
+	req = req.WithContext(httptrace.WithClientTrace(context.Background(), trace))
+	resp, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK || strings.TrimSpace(string(body)) != `"page"` {
+		t.Fatalf("unexpected response %d: %s", resp.StatusCode, body)
+	}
+	if resp.Header.Get("X-Final") != "yes" || resp.Header.Get("Link") != "" {
+		t.Errorf("unexpected final headers: %v", resp.Header)
+	}
+	if len(hints) != 2 || hints[0][0] != "</style.css>; rel=preload; as=style" || hints[1][0] != "</app.js>; rel=preload; as=script" {
+		t.Errorf("unexpected hints: %q", hints)
+	}
+}
+
+func TestEarlyHintsUnsupported(t *testing.T) {
+	buf := &strings.Builder{}
+	log.SetOutput(buf)
+	defer log.SetOutput(os.Stderr)
+
+	var errs []error
+	resp, _ := NewMuxTest([]API{{Pattern: "/page", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		errs = append(errs, httpData.EarlyHints([]string{"</style.css>; rel=preload"}))
+		httpData.WriteJSON(http.StatusCreated, "page")
+		errs = append(errs, httpData.EarlyHints([]string{"</style.css>; rel=preload"}))
+		return nil, nil
+	}}}).Get("/page", "")
+
+	if resp.Code != http.StatusCreated || resp.Header().Get("Link") != "" {
+		t.Errorf("unexpected response %d: %v", resp.Code, resp.Header())
+	}
+	if errs[0] != nil || errs[1] != ErrReplied {
+		t.Errorf("unexpected errors: %v", errs)
+	}
+	if !strings.Contains(buf.String(), "does not support 103 Early Hints") {
+		t.Errorf("no warning logged: %q", buf.String())
+	}
+}
@@ -0,0 +1,63 @@
+package jsonapi
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// entityTag computes a strong ETag of data
+func entityTag(data []byte) string {
+	sum := sha256.Sum256(data)
+	return `"` + base64.RawURLEncoding.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches checks If-None-Match of r against etag, ok is false if the header is absent
+func etagMatches(r *http.Request, etag string) (match, ok bool) {
+	inm := r.Header.Get("If-None-Match")
+	if inm == "" {
+		return false, false
+	}
+	for _, tag := range strings.Split(inm, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag || tag == "*" {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// notModified sets ETag of successful response data to GET or HEAD requests if
+// API.ETag is enabled, and reports whether the client has it already.
+func (h *HTTP) notModified(status int, data []byte) bool {
+	if h.api == nil || !h.api.ETag || status != http.StatusOK {
+		return false
+	}
+	if m := h.Request.Method; m != "GET" && m != "HEAD" {
+		return false
+	}
+	etag := h.representationTag(entityTag(data))
+	h.ResponseWriter.Header().Set("ETag", etag)
+	match, _ := etagMatches(h.Request, etag)
+	return match
+}
+
+// representationTag adds the codec and Content-Encoding the response will be sent in
+// to etag computed from JSON data, as each representation has its own entity tag
+func (h *HTTP) representationTag(etag string) string {
+	var suffix string
+	if h.codec != nil {
+		_, sub, _ := strings.Cut(h.codec.ContentType(), "/")
+		suffix += "-" + strings.TrimPrefix(sub, "x-")
+	}
+	if h.compression() {
+		if p, _ := h.negotiateEncoding(); p != nil {
+			suffix += "-" + p.name
+		}
+	}
+	if suffix == "" {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + suffix + `"`
+}
@@ -0,0 +1,94 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestETag(t *testing.T) {
+	m := NewMuxTest([]API{{Pattern: "/", ETag: true, APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return map[string]int{"a": 1}, nil
+	}}})
+
+	resp, err := m.Get("/", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	etag := resp.Header().Get("ETag")
+	if resp.Code != http.StatusOK || etag == "" || etag != entityTag([]byte("{\"a\":1}\n")) {
+		t.Fatalf("got %d, ETag %q", resp.Code, etag)
+	}
+
+	for inm, want := range map[string]int{
+		etag:                  http.StatusNotModified,
+		"W/" + etag:           http.StatusNotModified,
+		`"other", ` + etag:    http.StatusNotModified,
+		"*":                   http.StatusNotModified,
+		`"other"`:             http.StatusOK,
+		strings.ToLower(etag): http.StatusOK,
+	} {
+		resp, err := m.With(Headers{"If-None-Match": inm}).Get("/", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Code != want {
+			t.Errorf("If-None-Match %s: status = %d, want %d", inm, resp.Code, want)
+		}
+		if want == http.StatusNotModified && (resp.Body.Len() != 0 || resp.Header().Get("Content-Type") != "") {
+			t.Errorf("If-None-Match %s: 304 has body or Content-Type", inm)
+		}
+	}
+
+	resp, err = m.With(Headers{"If-None-Match": etag}).Post("/", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != http.StatusOK || resp.Header().Get("ETag") != "" {
+		t.Errorf("POST: got %d, ETag %q", resp.Code, resp.Header().Get("ETag"))
+	}
+}
+
+func TestETagPerRepresentation(t *testing.T) {
+	defer withCodecs(XMLCodec)()
+
+	m := NewMuxTest([]API{{Pattern: "/", ETag: true, Encodings: []string{"gzip"}, APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return map[string]int{"a": 1}, nil
+	}}})
+
+	tags := map[string]string{}
+	for _, h := range []Headers{
+		{},
+		{"Accept-Encoding": "gzip"},
+		{"Accept": "application/xml"},
+		{"Accept": "application/xml", "Accept-Encoding": "gzip"},
+	} {
+		resp, err := m.With(h).Get("/", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		etag := resp.Header().Get("ETag")
+		key := resp.Header().Get("Content-Type") + " " + resp.Header().Get("Content-Encoding")
+		for k, v := range tags {
+			if v == etag {
+				t.Errorf("%s and %s share ETag %s", k, key, etag)
+			}
+		}
+		tags[key] = etag
+
+		resp, err = m.With(h, Headers{"If-None-Match": etag}).Get("/", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Code != http.StatusNotModified {
+			t.Errorf("%s: status = %d with its own ETag", key, resp.Code)
+		}
+	}
+	if len(tags) != 4 {
+		t.Errorf("representations = %v", tags)
+	}
+	if etag := tags["application/xml gzip"]; !strings.HasSuffix(etag, `-xml-gzip"`) {
+		t.Errorf("ETag of compressed XML = %s", etag)
+	}
+}
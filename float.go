@@ -0,0 +1,17 @@
+package jsonapi
+
+// NonFiniteMode selects how NaN and infinite float values in responses are encoded
+type NonFiniteMode int
+
+const (
+	// NonFiniteError fails the encoding like encoding/json does, which leads to a 500 response
+	NonFiniteError NonFiniteMode = iota
+	// NonFiniteNull encodes them as null
+	NonFiniteNull
+	// NonFiniteString encodes them as "NaN", "Inf" and "-Inf"
+	NonFiniteString
+)
+
+// NonFinite selects how NaN and infinite float values are encoded, in both normal
+// and error responses.
+var NonFinite = NonFiniteError
@@ -0,0 +1,78 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type reading struct {
+	Value  float64            `json:"value"`
+	Low    float32            `json:"low"`
+	Series []float64          `json:"series"`
+	ByName map[string]float64 `json:"by_name"`
+}
+
+func TestNonFinite(t *testing.T) {
+	defer func() { NonFinite = NonFiniteError }()
+	v := []reading{{
+		Value:  math.NaN(),
+		Low:    float32(math.Inf(-1)),
+		Series: []float64{1.5, math.Inf(1)},
+		ByName: map[string]float64{"a": math.Inf(-1)},
+	}}
+	m := NewMuxTest([]API{{Pattern: "/", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return v, nil
+	}}})
+
+	cases := []struct {
+		mode   NonFiniteMode
+		status int
+		body   string
+	}{
+		{NonFiniteNull, http.StatusOK, `[{"value":null,"low":null,"series":[1.5,null],"by_name":{"a":null}}]`},
+		{NonFiniteString, http.StatusOK, `[{"value":"NaN","low":"-Inf","series":[1.5,"Inf"],"by_name":{"a":"-Inf"}}]`},
+		{NonFiniteError, http.StatusInternalServerError, ""},
+	}
+	for _, c := range cases {
+		NonFinite = c.mode
+		resp, _ := m.Get("/", "")
+		if resp.Code != c.status {
+			t.Errorf("mode %d: expected %d, got %d %s", c.mode, c.status, resp.Code, resp.Body)
+		}
+		if actual := strings.TrimSpace(resp.Body.String()); c.body != "" && actual != c.body {
+			t.Errorf("mode %d: expected %s, got %s", c.mode, c.body, actual)
+		}
+	}
+}
+
+func TestNonFiniteInError(t *testing.T) {
+	defer func() { NonFinite = NonFiniteError }()
+	m := NewMuxTest([]API{{Pattern: "/", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return nil, E422.WithDetails(map[string][]float64{"score": {math.NaN()}})
+	}}})
+
+	cases := map[NonFiniteMode]string{
+		NonFiniteNull:   `{"score":[null]}`,
+		NonFiniteString: `{"score":["NaN"]}`,
+		NonFiniteError:  ``, // sent without details
+	}
+	for mode, details := range cases {
+		NonFinite = mode
+		resp, _ := m.Get("/", "")
+		var body struct {
+			Error struct {
+				Code    int             `json:"code"`
+				Details json.RawMessage `json:"details"`
+			} `json:"error"`
+		}
+		if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+			t.Fatalf("mode %d: invalid body %s", mode, resp.Body)
+		}
+		if resp.Code != http.StatusUnprocessableEntity || body.Error.Code != http.StatusUnprocessableEntity || string(body.Error.Details) != details {
+			t.Errorf("mode %d: got %d %s", mode, resp.Code, resp.Body)
+		}
+	}
+}
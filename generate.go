@@ -0,0 +1,449 @@
+package jsonapi
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Generator creates random request values for property-based tests, respecting
+// rules in `validate` tags of struct fields:
+//
+//     required     field is not zero
+//     min=N,max=N  bounds of numbers, or lengths of strings and slices
+//     oneof=a b c  allowed values
+//
+// Same seed always generates same values, so failures can be reproduced.
+//
+//     gen := jsonapi.NewGenerator(42)
+//     for i := 0; i < 100; i++ {
+//         var args CreateUserArgs
+//         gen.Valid(&args)
+//         resp, _ := jsonapi.HandlerTest(h).PostJSON("/api/user", "", args)
+//         // expect 2xx
+//     }
+//     for _, c := range gen.Invalid(CreateUserArgs{}) {
+//         resp, _ := jsonapi.HandlerTest(h).PostJSON("/api/user", "", c.Value)
+//         // expect 4xx, c.Name tells which rule is violated
+//     }
+type Generator struct {
+	rand *rand.Rand
+}
+
+// NewGenerator creates a Generator with seed
+func NewGenerator(seed int64) *Generator {
+	return &Generator{rand: rand.New(rand.NewSource(seed))}
+}
+
+// InvalidCase is a value violating exactly one rule
+type InvalidCase struct {
+	Name  string      // like "name: max=5"
+	Value interface{} // pointer to the value
+}
+
+// rules are parsed from validate tag
+type rules struct {
+	required bool
+	min, max *float64
+	oneof    []string
+}
+
+func parseRules(tag string) (ret rules) {
+	for _, r := range strings.Split(tag, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(r), "=")
+		switch k {
+		case "required":
+			ret.required = true
+		case "min", "max":
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			if k == "min" {
+				ret.min = &f
+			} else {
+				ret.max = &f
+			}
+		case "oneof":
+			ret.oneof = strings.Fields(v)
+		}
+	}
+	return
+}
+
+func (r rules) empty() bool {
+	return !r.required && r.min == nil && r.max == nil && r.oneof == nil
+}
+
+// bounds returns range allowed by r, limited within [lo, hi]
+func (r rules) bounds(lo, hi float64) (float64, float64) {
+	if r.min != nil {
+		lo = *r.min
+	}
+	if r.max != nil {
+		hi = *r.max
+	}
+	if r.min != nil && r.max == nil && hi < lo {
+		hi = lo + 100
+	}
+	if r.max != nil && r.min == nil && lo > hi {
+		lo = hi - 100
+	}
+	return lo, hi
+}
+
+// Valid fills the value pointed by v with random data satisfying all rules. It
+// panics if the rules cannot be satisfied by type of the field, like min=200 on
+// int8.
+func (g *Generator) Valid(v interface{}) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		panic(fmt.Sprintf("jsonapi: Generator.Valid needs a pointer, got %T", v))
+	}
+	g.fill(rv.Elem(), rules{}, 0)
+}
+
+// QuickValues creates a function for testing/quick.Config.Values, generating valid
+// values for arguments of same types as prototypes. Randomness comes from
+// quick.Config.Rand, so set it for reproducible runs.
+//
+//     err := quick.Check(func(args CreateUserArgs) bool {
+//         ...
+//     }, &quick.Config{Values: gen.QuickValues(CreateUserArgs{})})
+func (g *Generator) QuickValues(prototypes ...interface{}) func(args []reflect.Value, r *rand.Rand) {
+	return func(args []reflect.Value, r *rand.Rand) {
+		gen := &Generator{rand: r}
+		for i := range args {
+			args[i] = reflect.New(reflect.TypeOf(prototypes[i])).Elem()
+			gen.fill(args[i], rules{}, 0)
+		}
+	}
+}
+
+// Invalid creates values of same type as prototype, each one violates exactly one
+// rule of a field, and satisfies others.
+func (g *Generator) Invalid(prototype interface{}) []InvalidCase {
+	t := reflect.TypeOf(prototype)
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	var ret []InvalidCase
+	g.invalid(t, t, nil, "", &ret)
+	return ret
+}
+
+// invalid collects cases for fields of t, which is at index of root struct
+func (g *Generator) invalid(root, t reflect.Type, index []int, prefix string, ret *[]InvalidCase) {
+	if t.Kind() != reflect.Struct || t == timeType {
+		return
+	}
+	for _, f := range fieldsOf(t) {
+		idx := append(append([]int(nil), index...), f.index...)
+		name := prefix + f.name
+		ft := t.FieldByIndex(f.index).Type
+		r := parseRules(f.tag.Get("validate"))
+
+		for _, c := range violations(r) {
+			v := reflect.New(root)
+			g.fill(v.Elem(), rules{}, 0)
+			fv := fieldAt(v.Elem(), idx)
+			if !c.set(fv) {
+				continue
+			}
+			*ret = append(*ret, InvalidCase{Name: name + ": " + c.rule, Value: v.Interface()})
+		}
+		if ft.Kind() == reflect.Struct {
+			g.invalid(root, ft, idx, name+".", ret)
+		}
+	}
+}
+
+// fieldAt finds field at index, allocating nil pointers on the way
+func fieldAt(v reflect.Value, index []int) reflect.Value {
+	for _, i := range index {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v
+}
+
+type violation struct {
+	rule string
+	set  func(v reflect.Value) bool // returns false if it cannot be violated
+}
+
+// violations lists ways to break each rule in r
+func violations(r rules) []violation {
+	var ret []violation
+	if r.required {
+		ret = append(ret, violation{"required", func(v reflect.Value) bool {
+			v.Set(reflect.Zero(v.Type()))
+			return true
+		}})
+	}
+	if r.min != nil {
+		min := *r.min
+		ret = append(ret, violation{"min=" + strconv.FormatFloat(min, 'g', -1, 64), func(v reflect.Value) bool {
+			return setOutOfRange(v, min, -1)
+		}})
+	}
+	if r.max != nil {
+		max := *r.max
+		ret = append(ret, violation{"max=" + strconv.FormatFloat(max, 'g', -1, 64), func(v reflect.Value) bool {
+			return setOutOfRange(v, max, 1)
+		}})
+	}
+	if r.oneof != nil {
+		choices := r.oneof
+		ret = append(ret, violation{"oneof=" + strings.Join(choices, " "), func(v reflect.Value) bool {
+			v = deref(v)
+			switch v.Kind() {
+			case reflect.String:
+				s := "invalid"
+				for contains(choices, s) {
+					s += "_"
+				}
+				v.SetString(s)
+				return true
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+				n := int64(0)
+				for contains(choices, strconv.FormatInt(n, 10)) {
+					n++
+				}
+				v.SetInt(n)
+				return !v.OverflowInt(n)
+			}
+			return false
+		}})
+	}
+	return ret
+}
+
+func contains(list []string, s string) bool {
+	for _, x := range list {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func deref(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// setOutOfRange sets v (or its length) just below bound if dir < 0, or just above if dir > 0
+func setOutOfRange(v reflect.Value, bound float64, dir int) bool {
+	v = deref(v)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := int64(math.Ceil(bound)) - 1
+		if dir > 0 {
+			n = int64(math.Floor(bound)) + 1
+		}
+		if v.OverflowInt(n) {
+			return false
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n := math.Ceil(bound) - 1
+		if dir > 0 {
+			n = math.Floor(bound) + 1
+		}
+		if n < 0 || v.OverflowUint(uint64(n)) {
+			return false
+		}
+		v.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(bound + float64(dir)*math.Max(1, math.Abs(bound)/1000))
+	case reflect.String:
+		n := int(math.Ceil(bound)) - 1
+		if dir > 0 {
+			n = int(math.Floor(bound)) + 1
+		}
+		if n < 0 {
+			return false
+		}
+		v.SetString(strings.Repeat("x", n))
+	case reflect.Slice:
+		n := int(math.Ceil(bound)) - 1
+		if dir > 0 {
+			n = int(math.Floor(bound)) + 1
+		}
+		if n < 0 {
+			return false
+		}
+		s := reflect.MakeSlice(v.Type(), n, n)
+		for i := 0; i < n && i < v.Len(); i++ {
+			s.Index(i).Set(v.Index(i))
+		}
+		v.Set(s)
+	default:
+		return false
+	}
+	return true
+}
+
+const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+
+// fill sets v to random value satisfying r
+func (g *Generator) fill(v reflect.Value, r rules, depth int) {
+	if depth > 8 || !v.CanSet() {
+		return
+	}
+
+	if v.Type() == timeType {
+		v.Set(reflect.ValueOf(time.Unix(946684800+g.rand.Int63n(30*365*86400), 0).UTC()))
+		return
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !r.required && r.empty() && g.rand.Intn(4) == 0 {
+			return
+		}
+		v.Set(reflect.New(v.Type().Elem()))
+		g.fill(v.Elem(), r, depth+1)
+	case reflect.Struct:
+		for _, f := range fieldsOf(v.Type()) {
+			fr := parseRules(f.tag.Get("validate"))
+			fv := fieldAt(v, f.index)
+			if fr.empty() && fv.Kind() != reflect.Struct && g.rand.Intn(4) == 0 {
+				continue // leave optional field missing
+			}
+			g.fill(fv, fr, depth+1)
+		}
+	case reflect.String:
+		if r.oneof != nil {
+			v.SetString(r.oneof[g.rand.Intn(len(r.oneof))])
+			return
+		}
+		lo, hi := r.bounds(0, 16)
+		if r.required && lo < 1 {
+			lo = 1
+		}
+		n := g.between(int64(math.Ceil(lo)), int64(math.Floor(hi)))
+		buf := make([]byte, n)
+		for i := range buf {
+			buf[i] = letters[g.rand.Intn(len(letters))]
+		}
+		v.SetString(string(buf))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if r.oneof != nil {
+			n, _ := strconv.ParseInt(r.oneof[g.rand.Intn(len(r.oneof))], 10, 64)
+			v.SetInt(n)
+			return
+		}
+		lo, hi := r.bounds(-1000, 1000)
+		// limit is -min and max+1 of the type, exact in float64
+		bits := v.Type().Bits()
+		limit := math.Ldexp(1, bits-1)
+		lo, hi = math.Ceil(lo), math.Floor(hi)
+		if lo >= limit || hi < -limit || lo > hi {
+			panic(fmt.Sprintf("jsonapi: Generator cannot fill %s with value in [%v, %v]", v.Type(), lo, hi))
+		}
+		ilo, ihi := int64(math.Max(lo, -limit)), int64(1)<<uint(bits-1)-1
+		if hi < limit {
+			ihi = int64(hi)
+		}
+		n := g.between(ilo, ihi)
+		if r.required && n == 0 && ihi > 0 {
+			n = ihi
+		} else if r.required && n == 0 && ilo < 0 {
+			n = ilo
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		lo, hi := r.bounds(0, 1000)
+		lo = math.Max(lo, 0)
+		if r.required && lo < 1 {
+			lo = 1
+		}
+		bits := v.Type().Bits()
+		limit := math.Ldexp(1, bits)
+		lo, hi = math.Ceil(lo), math.Floor(hi)
+		if lo >= limit || lo > hi {
+			panic(fmt.Sprintf("jsonapi: Generator cannot fill %s with value in [%v, %v]", v.Type(), lo, hi))
+		}
+		ihi := uint64(math.MaxUint64) >> uint(64-bits)
+		if hi < limit {
+			ihi = uint64(hi)
+		}
+		v.SetUint(g.betweenUint(uint64(lo), ihi))
+	case reflect.Float32, reflect.Float64:
+		lo, hi := r.bounds(-1000, 1000)
+		n := lo + g.rand.Float64()*(hi-lo)
+		if r.required && n == 0 {
+			n = hi
+		}
+		v.SetFloat(n)
+	case reflect.Bool:
+		v.SetBool(r.required || g.rand.Intn(2) == 0)
+	case reflect.Slice:
+		lo, hi := r.bounds(0, 3)
+		if r.required && lo < 1 {
+			lo = 1
+		}
+		n := int(g.between(int64(math.Ceil(lo)), int64(math.Floor(hi))))
+		s := reflect.MakeSlice(v.Type(), n, n)
+		for i := 0; i < n; i++ {
+			g.fill(s.Index(i), rules{}, depth+1)
+		}
+		v.Set(s)
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return
+		}
+		n := g.rand.Intn(3)
+		if r.required && n == 0 {
+			n = 1
+		}
+		m := reflect.MakeMap(v.Type())
+		for i := 0; i < n; i++ {
+			k := reflect.New(v.Type().Key()).Elem()
+			k.SetString("k" + strconv.Itoa(i))
+			e := reflect.New(v.Type().Elem()).Elem()
+			g.fill(e, rules{}, depth+1)
+			m.SetMapIndex(k, e)
+		}
+		v.Set(m)
+	}
+}
+
+// between returns random integer in [lo, hi]
+func (g *Generator) between(lo, hi int64) int64 {
+	if hi <= lo {
+		return lo
+	}
+	return lo + int64(g.betweenUint(0, uint64(hi-lo)))
+}
+
+func (g *Generator) betweenUint(lo, hi uint64) uint64 {
+	if hi <= lo {
+		return lo
+	}
+	span := hi - lo
+	if span < math.MaxInt64 {
+		return lo + uint64(g.rand.Int63n(int64(span)+1))
+	}
+	// wider than Int63n can pick from, reject values out of range
+	for {
+		if n := g.rand.Uint64(); n <= span {
+			return lo + n
+		}
+	}
+}
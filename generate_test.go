@@ -0,0 +1,107 @@
+package jsonapi
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+type generatedArgs struct {
+	Name  string  `json:"name" validate:"required,min=2,max=5"`
+	Role  string  `json:"role" validate:"oneof=admin user"`
+	Age   int     `json:"age" validate:"min=18,max=30"`
+	Small int8    `json:"small" validate:"min=100"`
+	Tiny  uint8   `json:"tiny" validate:"min=250"`
+	Big   int64   `json:"big" validate:"min=-9223372036854775808,max=9223372036854775807"`
+	Huge  uint64  `json:"huge" validate:"min=0,max=18446744073709551615"`
+	Count int     `json:"count" validate:"required"`
+	Score float64 `json:"score" validate:"min=0,max=1"`
+}
+
+func TestGeneratorValid(t *testing.T) {
+	g := NewGenerator(1)
+	for i := 0; i < 200; i++ {
+		var v generatedArgs
+		g.Valid(&v)
+		switch {
+		case len(v.Name) < 2 || len(v.Name) > 5:
+			t.Fatalf("name = %q", v.Name)
+		case v.Role != "" && v.Role != "admin" && v.Role != "user":
+			t.Fatalf("role = %q", v.Role)
+		case v.Age != 0 && (v.Age < 18 || v.Age > 30):
+			t.Fatalf("age = %d", v.Age)
+		case v.Small != 0 && v.Small < 100:
+			t.Fatalf("small = %d", v.Small)
+		case v.Tiny != 0 && v.Tiny < 250:
+			t.Fatalf("tiny = %d", v.Tiny)
+		case v.Count == 0:
+			t.Fatal("required count is zero")
+		case v.Score < 0 || v.Score > 1:
+			t.Fatalf("score = %v", v.Score)
+		}
+	}
+}
+
+func TestGeneratorSameSeed(t *testing.T) {
+	var a, b generatedArgs
+	NewGenerator(7).Valid(&a)
+	NewGenerator(7).Valid(&b)
+	if a != b {
+		t.Errorf("%+v != %+v", a, b)
+	}
+}
+
+func TestGeneratorImpossibleRange(t *testing.T) {
+	cases := map[string]interface{}{
+		"int8": &struct {
+			V int8 `validate:"required,min=200"`
+		}{},
+		"uint8": &struct {
+			V uint8 `validate:"required,min=300"`
+		}{},
+		"uint16": &struct {
+			V uint16 `validate:"required,max=-1"`
+		}{},
+		"int": &struct {
+			V int `validate:"required,min=5,max=4"`
+		}{},
+	}
+	for name, v := range cases {
+		func() {
+			defer func() {
+				msg, _ := recover().(string)
+				if !strings.Contains(msg, "cannot fill "+name) {
+					t.Errorf("%s: panic = %q", name, msg)
+				}
+			}()
+			NewGenerator(1).Valid(v)
+		}()
+	}
+}
+
+func TestGeneratorBetweenFullRange(t *testing.T) {
+	g := NewGenerator(1)
+	for i := 0; i < 100; i++ {
+		g.between(math.MinInt64, math.MaxInt64)
+		g.betweenUint(0, math.MaxUint64)
+	}
+	if n := g.between(3, 3); n != 3 {
+		t.Errorf("between(3, 3) = %d", n)
+	}
+}
+
+func TestGeneratorInvalid(t *testing.T) {
+	type args struct {
+		Name string `json:"name" validate:"required,max=3"`
+	}
+	cases := NewGenerator(1).Invalid(args{})
+	if len(cases) == 0 {
+		t.Fatal("no invalid case")
+	}
+	for _, c := range cases {
+		v := c.Value.(*args)
+		if v.Name != "" && len(v.Name) <= 3 {
+			t.Errorf("%s: %+v is valid", c.Name, v)
+		}
+	}
+}
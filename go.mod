@@ -0,0 +1,3 @@
+module github.com/Patrolavia/jsonapi
+
+go 1.22
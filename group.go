@@ -0,0 +1,82 @@
+package jsonapi
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Group collects APIs sharing a path prefix and middlewares
+//
+//     v1 := jsonapi.NewGroup("/api/v1", jsonapi.RequestLog(log.Printf))
+//     v1.Add("/users", listUsers)
+//     admin := v1.Group("/admin", requireAdmin)
+//     admin.Add("/stats", stats) // GET /api/v1/admin/stats runs RequestLog and requireAdmin
+//     v1.Register(nil)
+type Group struct {
+	prefix      string
+	middlewares []Middleware
+	apis        []API
+	groups      []*Group
+}
+
+// NewGroup creates a Group of APIs under prefix, which are wrapped by mws before
+// Middlewares of each API
+func NewGroup(prefix string, mws ...Middleware) *Group {
+	return &Group{prefix: prefix, middlewares: mws}
+}
+
+// Add adds an API with handler at pattern relative to the prefix of g
+func (g *Group) Add(pattern string, handler APIHandler) *Group {
+	return g.AddAPI(API{Pattern: pattern, APIHandler: handler})
+}
+
+// AddAPI adds apis, whose Patterns are relative to the prefix of g
+func (g *Group) AddAPI(apis ...API) *Group {
+	g.apis = append(g.apis, apis...)
+	return g
+}
+
+// Group creates a nested Group under prefix of g, whose APIs are also wrapped by
+// middlewares of g
+func (g *Group) Group(prefix string, mws ...Middleware) *Group {
+	ret := NewGroup(prefix, mws...)
+	g.groups = append(g.groups, ret)
+	return ret
+}
+
+// APIs returns APIs of g and nested groups, with full patterns and middlewares
+func (g *Group) APIs() []API {
+	ret := append([]API(nil), g.apis...)
+	for _, sub := range g.groups {
+		ret = append(ret, sub.APIs()...)
+	}
+	for i := range ret {
+		ret[i].Pattern = joinPattern(g.prefix, ret[i].Pattern)
+		ret[i].Middlewares = append(append([]Middleware(nil), g.middlewares...), ret[i].Middlewares...)
+	}
+	return ret
+}
+
+// Register registers APIs of g to mux, see Register
+func (g *Group) Register(mux *http.ServeMux) {
+	Register(g.APIs(), mux)
+}
+
+// joinPattern prefixes path of pattern, keeping method like "GET " in front
+func joinPattern(prefix, pattern string) string {
+	method := ""
+	if i := strings.IndexByte(pattern, ' '); i >= 0 {
+		method, pattern = pattern[:i+1], strings.TrimLeft(pattern[i+1:], " ")
+	}
+	prefix = strings.TrimRight(prefix, "/")
+	if prefix == "" {
+		if !strings.HasPrefix(pattern, "/") {
+			pattern = "/" + pattern
+		}
+		return method + pattern
+	}
+	if !strings.HasPrefix(prefix, "/") {
+		prefix = "/" + prefix
+	}
+	return method + prefix + "/" + strings.TrimLeft(pattern, "/")
+}
@@ -0,0 +1,76 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestJoinPattern(t *testing.T) {
+	cases := map[[2]string]string{
+		{"/api/v1", "/users"}:    "/api/v1/users",
+		{"/api/v1/", "users"}:    "/api/v1/users",
+		{"api/v1//", "//users/"}: "/api/v1/users/",
+		{"/api", "/"}:            "/api/",
+		{"", "users"}:            "/users",
+		{"/", "/users"}:          "/users",
+		{"/api", "GET /users"}:   "GET /api/users",
+		{"/api/", "POST  users"}: "POST /api/users",
+	}
+	for in, expect := range cases {
+		if got := joinPattern(in[0], in[1]); got != expect {
+			t.Errorf("joinPattern(%q, %q) = %q, expected %q", in[0], in[1], got, expect)
+		}
+	}
+}
+
+func TestGroup(t *testing.T) {
+	var trace []string
+	name := func(s string) APIHandler {
+		return func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			trace = append(trace, s)
+			return s, nil
+		}
+	}
+	v1 := NewGroup("/grp/v1/", traceMiddleware("v1", &trace))
+	v1.Add("users", name("users"))
+	v1.AddAPI(API{Pattern: "GET /items/{id}", APIHandler: name("item"), Middlewares: []Middleware{traceMiddleware("api", &trace)}})
+	admin := v1.Group("/admin", traceMiddleware("admin", &trace))
+	admin.Add("/stats", name("stats"))
+
+	var patterns []string
+	for _, api := range v1.APIs() {
+		patterns = append(patterns, api.Pattern)
+	}
+	if strings.Join(patterns, ",") != "/grp/v1/users,GET /grp/v1/items/{id},/grp/v1/admin/stats" {
+		t.Errorf("unexpected patterns %v", patterns)
+	}
+
+	mux := http.NewServeMux()
+	v1.Register(mux)
+	for uri, expect := range map[string]string{
+		"/grp/v1/users":       "/grp/v1/users",
+		"/grp/v1/items/7":     "GET /grp/v1/items/{id}",
+		"/grp/v1/admin/stats": "/grp/v1/admin/stats",
+		"/grp/v1/admin":       "",
+	} {
+		req, _ := http.NewRequest("GET", uri, nil)
+		if _, p := mux.Handler(req); p != expect {
+			t.Errorf("%s is routed to %q, expected %q", uri, p, expect)
+		}
+	}
+
+	m := &MuxTest{TestRequest: &TestRequest{h: mux}, Mux: mux}
+	for uri, expect := range map[string]string{
+		"/grp/v1/users":       "->v1 users <-v1",
+		"/grp/v1/items/7":     "->v1 ->api item <-api <-v1",
+		"/grp/v1/admin/stats": "->v1 ->admin stats <-admin <-v1",
+	} {
+		trace = nil
+		m.Get(uri, "")
+		if s := strings.Join(trace, " "); s != expect {
+			t.Errorf("%s: unexpected trace %s", uri, s)
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package jsonapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TestOption modifies requests sent by TestRequest
+type TestOption interface {
+	apply(r *http.Request) *http.Request
+}
+
+type testOptionFunc func(r *http.Request) *http.Request
+
+func (f testOptionFunc) apply(r *http.Request) *http.Request {
+	return f(r)
+}
+
+// FailBodyAfter makes reading request body fail with err after n bytes, like a
+// client disconnecting mid-upload. Nil err means io.ErrUnexpectedEOF.
+func FailBodyAfter(n int, err error) TestOption {
+	if err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+	return testOptionFunc(func(r *http.Request) *http.Request {
+		body := r.Body
+		if body == nil {
+			body = http.NoBody
+		}
+		r.Body = &failingReader{ReadCloser: body, left: n, err: err}
+		return r
+	})
+}
+
+// Headers is a TestOption setting request headers, replacing the cookie argument
+// of TestRequest methods if Cookie header is also set.
+//
+//     resp, err := jsonapi.HandlerTest(me).With(jsonapi.Headers{
+//         "Authorization": "Bearer " + token,
+//         "X-Request-ID":  "test-1",
+//     }).Get("/api/me", "")
+type Headers map[string]string
+
+func (h Headers) apply(r *http.Request) *http.Request {
+	for k, v := range h {
+		r.Header.Set(k, v)
+	}
+	return r
+}
+
+// CancelAfter cancels context of the request after d, like a client disconnecting
+// while handler is running. d is measured by DefaultClock.
+func CancelAfter(d time.Duration) TestOption {
+	return testOptionFunc(func(r *http.Request) *http.Request {
+		ctx, cancel := context.WithCancel(r.Context())
+		t := DefaultClock.NewTimer(d)
+		go func() {
+			<-t.C()
+			cancel()
+		}()
+		return r.WithContext(ctx)
+	})
+}
+
+// failingReader fails with err after left bytes are read
+type failingReader struct {
+	io.ReadCloser
+	left int
+	err  error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.left <= 0 {
+		return 0, r.err
+	}
+	if len(p) > r.left {
+		p = p[:r.left]
+	}
+	n, err := r.ReadCloser.Read(p)
+	r.left -= n
+	if err == io.EOF {
+		// body is shorter than expected, the failure still happens
+		if n > 0 {
+			return n, nil
+		}
+		return 0, r.err
+	}
+	return n, err
+}
+
+// TestRequest is a HandlerTest with options applied to every request
+//
+//     resp, err := jsonapi.HandlerTest(upload).With(jsonapi.FailBodyAfter(1024, nil)).Post("/api/upload", "", data)
+type TestRequest struct {
+	h       http.Handler
+	opts    []TestOption
+	session *Session // see TestSession
+}
+
+// With creates a TestRequest sending requests modified by opts
+func (f HandlerTest) With(opts ...TestOption) *TestRequest {
+	return &TestRequest{h: HTTPHandler(f), opts: opts}
+}
+
+// With adds more options
+func (t *TestRequest) With(opts ...TestOption) *TestRequest {
+	return &TestRequest{h: t.h, opts: append(append([]TestOption(nil), t.opts...), opts...), session: t.session}
+}
+
+// Do sends req to the handler (or the ServeMux of MuxTest) after applying options
+func (t *TestRequest) Do(req *http.Request) *httptest.ResponseRecorder {
+	for _, o := range t.opts {
+		req = o.apply(req)
+	}
+	if t.session != nil {
+		return t.session.Do(req)
+	}
+	ret := httptest.NewRecorder()
+	t.h.ServeHTTP(ret, req)
+	return ret
+}
+
+// TestSession is a TestRequest keeping cookies set by responses and sending them
+// with later requests, like a browser. Cookies passed to methods like Get are sent
+// in addition.
+//
+//     s := jsonapi.NewTestSession(handler)
+//     s.PostJSON("/api/login", "", credentials)
+//     resp, err := s.Get("/api/me", "") // with the cookie set by login
+type TestSession struct {
+	*TestRequest
+
+	// Session keeps cookies of the session in its Jar, requests without host are
+	// for "example.com", see Cookies and SetCookies
+	Session *Session
+}
+
+// NewTestSession creates a TestSession sending requests modified by opts to f
+func NewTestSession(f HandlerTest, opts ...TestOption) *TestSession {
+	return newTestSession(f.With(opts...))
+}
+
+func newTestSession(t *TestRequest) *TestSession {
+	t = t.With()
+	t.session = NewSession(t.h)
+	return &TestSession{TestRequest: t, Session: t.session}
+}
+
+// Cookies returns cookies the session sends to uri
+func (s *TestSession) Cookies(uri string) ([]*http.Cookie, error) {
+	u, err := resolve(uri)
+	if err != nil {
+		return nil, err
+	}
+	return s.Session.Jar.Cookies(u), nil
+}
+
+// SetCookies seeds the session with cookies as if they are set by response of uri
+func (s *TestSession) SetCookies(uri string, cookies ...*http.Cookie) error {
+	u, err := resolve(uri)
+	if err != nil {
+		return err
+	}
+	s.Session.Jar.SetCookies(u, cookies)
+	return nil
+}
+
+func (t *TestRequest) send(method, uri, cookie string, body io.Reader) (*httptest.ResponseRecorder, error) {
+	req, err := http.NewRequest(method, uri, body)
+	if err != nil {
+		return nil, err
+	}
+	if cookie != "" {
+		req.Header.Add("Cookie", cookie)
+	}
+	return t.Do(req), nil
+}
+
+// Get helps you to test with HTTP GET request
+func (t *TestRequest) Get(uri, cookie string) (*httptest.ResponseRecorder, error) {
+	return t.send("GET", uri, cookie, nil)
+}
+
+// Post helps you to test with post data
+func (t *TestRequest) Post(uri, cookie, data string) (*httptest.ResponseRecorder, error) {
+	return t.send("POST", uri, cookie, strings.NewReader(data))
+}
+
+// PostJSON helps you to test with json encoded post data
+func (t *TestRequest) PostJSON(uri, cookie string, data interface{}) (*httptest.ResponseRecorder, error) {
+	return t.sendJSON("POST", uri, cookie, data)
+}
+
+func (t *TestRequest) sendJSON(method, uri, cookie string, data interface{}) (*httptest.ResponseRecorder, error) {
+	buf, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	return t.send(method, uri, cookie, strings.NewReader(string(buf)))
+}
+
+// Put helps you to test with HTTP PUT request
+func (t *TestRequest) Put(uri, cookie, data string) (*httptest.ResponseRecorder, error) {
+	return t.send("PUT", uri, cookie, strings.NewReader(data))
+}
+
+// PutJSON helps you to test with json encoded put data
+func (t *TestRequest) PutJSON(uri, cookie string, data interface{}) (*httptest.ResponseRecorder, error) {
+	return t.sendJSON("PUT", uri, cookie, data)
+}
+
+// Patch helps you to test with HTTP PATCH request
+func (t *TestRequest) Patch(uri, cookie, data string) (*httptest.ResponseRecorder, error) {
+	return t.send("PATCH", uri, cookie, strings.NewReader(data))
+}
+
+// PatchJSON helps you to test with json encoded patch data
+func (t *TestRequest) PatchJSON(uri, cookie string, data interface{}) (*httptest.ResponseRecorder, error) {
+	return t.sendJSON("PATCH", uri, cookie, data)
+}
+
+// Delete helps you to test with HTTP DELETE request. Empty data sends no body.
+func (t *TestRequest) Delete(uri, cookie, data string) (*httptest.ResponseRecorder, error) {
+	var body io.Reader
+	if data != "" {
+		body = strings.NewReader(data)
+	}
+	return t.send("DELETE", uri, cookie, body)
+}
+
+// DeleteJSON helps you to test with HTTP DELETE request with json encoded body
+func (t *TestRequest) DeleteJSON(uri, cookie string, data interface{}) (*httptest.ResponseRecorder, error) {
+	return t.sendJSON("DELETE", uri, cookie, data)
+}
+
+// GetInto sends HTTP GET request like Get, and decodes the response into out, see
+// PostJSONInto.
+func (t *TestRequest) GetInto(uri, cookie string, out interface{}) (*httptest.ResponseRecorder, error) {
+	resp, err := t.Get(uri, cookie)
+	if err != nil {
+		return nil, err
+	}
+	return resp, decodeInto(resp, out)
+}
+
+// PostJSONInto sends data like PostJSON, and decodes the response into out. The
+// returned error contains the raw body if status code >= 300 or the body cannot be
+// decoded into out. Use PostJSON to check the error responses.
+//
+//     var reply HelloReply
+//     if _, err := jsonapi.HandlerTest(hello).PostJSONInto("/api/hello", "", args, &reply); err != nil {
+//         t.Fatal(err)
+//     }
+func (t *TestRequest) PostJSONInto(uri, cookie string, data, out interface{}) (*httptest.ResponseRecorder, error) {
+	resp, err := t.PostJSON(uri, cookie, data)
+	if err != nil {
+		return nil, err
+	}
+	return resp, decodeInto(resp, out)
+}
+
+// decodeInto decodes body of resp into out
+func decodeInto(resp *httptest.ResponseRecorder, out interface{}) error {
+	body := bytes.TrimSpace(resp.Body.Bytes())
+	if resp.Code >= 300 {
+		return fmt.Errorf("jsonapi: unexpected status %d, body: %s", resp.Code, body)
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("jsonapi: cannot decode response into %T: %s, body: %s", out, err, body)
+	}
+	return nil
+}
+
+// PostMultipart helps you to test with multipart/form-data post data. files are
+// uploaded with field name as file name.
+func (t *TestRequest) PostMultipart(uri, cookie string, fields map[string]string, files map[string][]byte) (*httptest.ResponseRecorder, error) {
+	names := make([]string, 0, len(files))
+	for k := range files {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	uploads := make([]UploadFile, len(names))
+	for i, k := range names {
+		uploads[i] = UploadFile{Field: k, Filename: k, Reader: bytes.NewReader(files[k])}
+	}
+
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+	if err := writeMultipart(mw, fields, uploads); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", uri, buf)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	if cookie != "" {
+		req.Header.Add("Cookie", cookie)
+	}
+	return t.Do(req), nil
+}
+
+// PostForm helps you to test with form encoded post data
+func (t *TestRequest) PostForm(uri, cookie string, data url.Values) (*httptest.ResponseRecorder, error) {
+	return t.Post(uri, cookie, data.Encode())
+}
+
+// MuxTest tests APIs routed by a ServeMux they are registered to, so mistakes in
+// patterns are found. Requests to unregistered paths get 404 of the ServeMux.
+//
+//     m := jsonapi.NewMuxTest(apis)
+//     resp, err := m.Get("/api/users/42", "")
+type MuxTest struct {
+	*TestRequest
+	Mux *http.ServeMux
+}
+
+// NewMuxTest registers apis to a new ServeMux, and sends requests modified by opts
+// to it
+func NewMuxTest(apis []API, opts ...TestOption) *MuxTest {
+	mux := http.NewServeMux()
+	Register(apis, mux)
+	return &MuxTest{TestRequest: &TestRequest{h: mux, opts: opts}, Mux: mux}
+}
+
+// Session creates a TestSession sending requests to the ServeMux
+func (m *MuxTest) Session() *TestSession {
+	return newTestSession(m.TestRequest)
+}
+
+// StartServer starts an httptest.Server serving the ServeMux, for clients like
+// Client. Close it after testing.
+//
+//     srv := m.StartServer()
+//     defer srv.Close()
+//     c := jsonapi.NewClient(srv.URL + "/api/")
+func (m *MuxTest) StartServer() *httptest.Server {
+	return httptest.NewServer(m.Mux)
+}
@@ -0,0 +1,342 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sessionAPIs logs in by setting a cookie, and reports the cookie it gets
+var sessionAPIs = []API{
+	{Pattern: "/login", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		http.SetCookie(httpData.ResponseWriter, &http.Cookie{Name: "sid", Value: "42", Path: "/"})
+		http.SetCookie(httpData.ResponseWriter, &http.Cookie{Name: "admin", Value: "1", Path: "/admin"})
+		return nil, nil
+	}},
+	{Pattern: "/logout", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		http.SetCookie(httpData.ResponseWriter, &http.Cookie{Name: "sid", Path: "/", MaxAge: -1})
+		return nil, nil
+	}},
+	{Pattern: "/", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		var names []string
+		for _, c := range httpData.Request.Cookies() {
+			names = append(names, c.Name+"="+c.Value)
+		}
+		return strings.Join(names, ";"), nil
+	}},
+}
+
+func sessionGet(t *testing.T, s *TestSession, uri string) string {
+	t.Helper()
+	resp, err := s.Get(uri, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ret string
+	if err := json.Unmarshal(resp.Body.Bytes(), &ret); err != nil {
+		t.Fatalf("%s: %s", uri, resp.Body)
+	}
+	return ret
+}
+
+func TestTestSession(t *testing.T) {
+	s := NewMuxTest(sessionAPIs).Session()
+	if got := sessionGet(t, s, "/me"); got != "" {
+		t.Errorf("cookies before login: %q", got)
+	}
+	if _, err := s.PostJSON("/login", "", nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := sessionGet(t, s, "/me"); got != "sid=42" {
+		t.Errorf("cookies after login: %q", got)
+	}
+	if got := sessionGet(t, s, "/admin/users"); !strings.Contains(got, "admin=1") {
+		t.Errorf("cookies of /admin: %q", got)
+	}
+	if _, err := s.Get("/logout", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := sessionGet(t, s, "/me"); got != "" {
+		t.Errorf("cookies after logout: %q", got)
+	}
+}
+
+func TestTestSessionJar(t *testing.T) {
+	s := NewMuxTest(sessionAPIs).Session()
+	if err := s.SetCookies("/", &http.Cookie{Name: "seed", Value: "x", Path: "/"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := sessionGet(t, s, "/"); got != "seed=x" {
+		t.Errorf("seeded cookies: %q", got)
+	}
+
+	// the jar is the one of Session, so both see the same cookies
+	s.Session.SetCookie("/", &http.Cookie{Name: "more", Value: "y", Path: "/"})
+	cookies, err := s.Cookies("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cookies) != 2 {
+		t.Errorf("cookies = %v", cookies)
+	}
+	s.Session.ClearCookies()
+	if got := sessionGet(t, s, "/"); got != "" {
+		t.Errorf("cookies after ClearCookies: %q", got)
+	}
+
+	// options and explicit cookies are applied along with the jar
+	s.SetCookies("/", &http.Cookie{Name: "seed", Value: "x", Path: "/"})
+	resp, err := s.With(Headers{"X-Test": "1"}).Get("/", "extra=z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := resp.Body.String(); !strings.Contains(got, "extra=z") || !strings.Contains(got, "seed=x") {
+		t.Errorf("cookies sent: %s", got)
+	}
+}
+
+func TestFailBodyAfter(t *testing.T) {
+	var reported []error
+	OnError = func(httpData *HTTP, err error) { reported = append(reported, err) }
+	defer func() { OnError = nil }()
+
+	var read int
+	var readErr error
+	m := NewMuxTest([]API{
+		{Pattern: "/upload", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			b, err := ioutil.ReadAll(httpData.Request.Body)
+			read, readErr = len(b), err
+			if err != nil {
+				return nil, E400.SetData("Upload interrupted")
+			}
+			return len(b), nil
+		}},
+		{Pattern: "/decode", APIHandler: decodeAny},
+		{Pattern: "/ignore", APIHandler: okAPI},
+	})
+	body := `{"data":"` + strings.Repeat("x", 100) + `"}`
+
+	resp, _ := m.With(FailBodyAfter(10, nil)).Post("/upload", "", body)
+	if resp.Code != http.StatusBadRequest || read != 10 || readErr != io.ErrUnexpectedEOF {
+		t.Errorf("got %d after reading %d bytes with %v", resp.Code, read, readErr)
+	}
+	broken := errors.New("connection reset")
+	m.With(FailBodyAfter(0, broken)).Post("/upload", "", body)
+	if read != 0 || readErr != broken {
+		t.Errorf("read %d bytes with %v", read, readErr)
+	}
+	if resp, _ := m.With(FailBodyAfter(20, nil)).Post("/decode", "", body); resp.Code != http.StatusBadRequest {
+		t.Errorf("decode: expected 400, got %d %s", resp.Code, resp.Body)
+	}
+	// draining body not read by the handler tolerates the failure
+	if resp, _ := m.With(FailBodyAfter(5, nil)).Post("/ignore", "", body); resp.Code != http.StatusOK {
+		t.Errorf("ignore: expected 200, got %d %s", resp.Code, resp.Body)
+	}
+	if len(reported) != 0 {
+		t.Errorf("failing body reported as server errors: %v", reported)
+	}
+}
+
+func TestCancelAfter(t *testing.T) {
+	var reported []error
+	OnError = func(httpData *HTTP, err error) { reported = append(reported, err) }
+	defer func() { OnError = nil }()
+
+	m := NewMuxTest([]API{{Pattern: "/", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		select {
+		case <-httpData.Request.Context().Done():
+			return nil, httpData.Request.Context().Err()
+		case <-time.After(5 * time.Second):
+			return "finished", nil
+		}
+	}}})
+
+	start := time.Now()
+	resp, _ := m.With(CancelAfter(10*time.Millisecond)).Get("/", "")
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("handler did not return promptly: %s", elapsed)
+	}
+	if resp.Code != StatusClientClosedRequest {
+		t.Errorf("expected %d, got %d %s", StatusClientClosedRequest, resp.Code, resp.Body)
+	}
+	if len(reported) != 0 {
+		t.Errorf("disconnect reported as server error: %v", reported)
+	}
+}
+
+func TestCancelAfterFakeClock(t *testing.T) {
+	clock, restore := withFakeClock()
+	defer restore()
+
+	started := make(chan bool)
+	m := NewMuxTest([]API{{Pattern: "/", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		close(started)
+		<-httpData.Request.Context().Done()
+		return nil, httpData.Request.Context().Err()
+	}}})
+
+	done := make(chan int)
+	go func() {
+		resp, _ := m.With(CancelAfter(time.Minute)).Get("/", "")
+		done <- resp.Code
+	}()
+	<-started
+	clock.Advance(time.Minute)
+	select {
+	case code := <-done:
+		if code != StatusClientClosedRequest {
+			t.Errorf("expected %d, got %d", StatusClientClosedRequest, code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request is not canceled by the fake clock")
+	}
+}
+
+// echoRequest reports method, cookie and body of the request
+func echoRequest(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+	body, _ := ioutil.ReadAll(httpData.Request.Body)
+	enc.Encode(map[string]interface{}{
+		"method":  httpData.Request.Method,
+		"cookie":  httpData.Request.Header.Get("Cookie"),
+		"body":    string(body),
+		"hasBody": httpData.Request.ContentLength != 0,
+	})
+}
+
+func TestHandlerTestMethods(t *testing.T) {
+	h := HandlerTest(echoRequest)
+	data := map[string]int{"id": 1}
+	cases := []struct {
+		name   string
+		send   func() (*httptest.ResponseRecorder, error)
+		method string
+		body   string
+	}{
+		{"Get", func() (*httptest.ResponseRecorder, error) { return h.Get("/", "sid=1") }, "GET", ""},
+		{"Post", func() (*httptest.ResponseRecorder, error) { return h.Post("/", "sid=1", "plain") }, "POST", "plain"},
+		{"PostJSON", func() (*httptest.ResponseRecorder, error) { return h.PostJSON("/", "sid=1", data) }, "POST", `{"id":1}`},
+		{"Put", func() (*httptest.ResponseRecorder, error) { return h.Put("/", "sid=1", "plain") }, "PUT", "plain"},
+		{"PutJSON", func() (*httptest.ResponseRecorder, error) { return h.PutJSON("/", "sid=1", data) }, "PUT", `{"id":1}`},
+		{"Patch", func() (*httptest.ResponseRecorder, error) { return h.Patch("/", "sid=1", "plain") }, "PATCH", "plain"},
+		{"PatchJSON", func() (*httptest.ResponseRecorder, error) { return h.PatchJSON("/", "sid=1", data) }, "PATCH", `{"id":1}`},
+		{"Delete", func() (*httptest.ResponseRecorder, error) { return h.Delete("/", "sid=1", "") }, "DELETE", ""},
+		{"Delete with body", func() (*httptest.ResponseRecorder, error) { return h.Delete("/", "sid=1", "plain") }, "DELETE", "plain"},
+		{"DeleteJSON", func() (*httptest.ResponseRecorder, error) { return h.DeleteJSON("/", "sid=1", data) }, "DELETE", `{"id":1}`},
+	}
+	for _, c := range cases {
+		resp, err := c.send()
+		if err != nil {
+			t.Fatalf("%s: %s", c.name, err)
+		}
+		var got struct {
+			Method, Cookie, Body string
+			HasBody              bool
+		}
+		json.Unmarshal(resp.Body.Bytes(), &got)
+		if got.Method != c.method || got.Cookie != "sid=1" || got.Body != c.body || got.HasBody != (c.body != "") {
+			t.Errorf("%s: handler got %+v", c.name, got)
+		}
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	h := HandlerTest(func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		r := httpData.Request
+		enc.Encode([]string{r.Header.Get("Authorization"), r.Header.Get("X-Request-ID"), r.Header.Get("Cookie")})
+	})
+	with := h.With(Headers{"Authorization": "Bearer token", "x-request-id": "test-1"})
+
+	resp, _ := with.PostJSON("/", "sid=1", nil)
+	if s := strings.TrimSpace(resp.Body.String()); s != `["Bearer token","test-1","sid=1"]` {
+		t.Errorf("headers are not sent: %s", s)
+	}
+	resp, _ = with.With(Headers{"Cookie": "sid=2"}).Get("/", "sid=1")
+	if s := strings.TrimSpace(resp.Body.String()); s != `["Bearer token","test-1","sid=2"]` {
+		t.Errorf("Cookie header does not replace cookie argument: %s", s)
+	}
+	resp, _ = h.Get("/", "sid=1")
+	if s := strings.TrimSpace(resp.Body.String()); s != `["","","sid=1"]` {
+		t.Errorf("headers leak into other requests: %s", s)
+	}
+}
+
+func TestDecodeInto(t *testing.T) {
+	h := HandlerTest(APIHandler(func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		var args struct{ Name string }
+		dec.Decode(&args)
+		if args.Name == "" && httpData.Request.Method == "POST" {
+			return nil, E400.SetData("Name is required")
+		}
+		return map[string]string{"greeting": "Hello, " + args.Name}, nil
+	}).Handler)
+
+	var reply struct{ Greeting string }
+	resp, err := h.PostJSONInto("/", "", map[string]string{"name": "John"}, &reply)
+	if err != nil || resp.Code != 200 || reply.Greeting != "Hello, John" {
+		t.Errorf("unexpected result %+v: %v", reply, err)
+	}
+	if _, err := h.GetInto("/", "", &reply); err != nil || reply.Greeting != "Hello, " {
+		t.Errorf("unexpected result %+v: %v", reply, err)
+	}
+
+	resp, err = h.PostJSONInto("/", "", map[string]string{}, &reply)
+	if err == nil || resp.Code != 400 || !strings.Contains(err.Error(), "unexpected status 400") ||
+		!strings.Contains(err.Error(), `"message":"Name is required"`) {
+		t.Errorf("expected error with raw body, got %v", err)
+	}
+
+	var mismatch []int
+	_, err = h.GetInto("/", "", &mismatch)
+	if err == nil || !strings.Contains(err.Error(), "cannot decode response into *[]int") ||
+		!strings.Contains(err.Error(), `body: {"greeting":"Hello, "}`) {
+		t.Errorf("expected error with raw body, got %v", err)
+	}
+}
+
+func TestMuxTest(t *testing.T) {
+	route := func(name string) APIHandler {
+		return func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return name + " " + httpData.Request.Header.Get("X-Tenant"), nil
+		}
+	}
+	m := NewMuxTest([]API{
+		{Pattern: "/api/user/", APIHandler: route("prefix")},
+		{Pattern: "/api/user/{id}", APIHandler: route("id")},
+		{Pattern: "/api/user/me", APIHandler: route("me")},
+	}, Headers{"X-Tenant": "acme"})
+
+	cases := []struct {
+		uri    string
+		code   int
+		expect string
+	}{
+		{"/api/user/me", 200, `"me acme"`},
+		{"/api/user/42", 200, `"id acme"`},
+		{"/api/user/42/posts", 200, `"prefix acme"`},
+		{"/api/users", 404, "404 page not found"},
+	}
+	for _, c := range cases {
+		resp, _ := m.PostJSON(c.uri, "", nil)
+		if resp.Code != c.code || strings.TrimSpace(resp.Body.String()) != c.expect {
+			t.Errorf("%s: unexpected response %d %s", c.uri, resp.Code, resp.Body)
+		}
+	}
+
+	srv := m.StartServer()
+	defer srv.Close()
+	resp, err := http.Get(srv.URL + "/api/user/me")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	// options are not applied to requests to the server
+	if body, _ := ioutil.ReadAll(resp.Body); resp.StatusCode != 200 || string(body) != `"me "`+"\n" {
+		t.Errorf("unexpected response %d %s", resp.StatusCode, body)
+	}
+}
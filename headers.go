@@ -0,0 +1,101 @@
+package jsonapi
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// KindInvalidHeader is the Kind of Error returned when request headers declared
+// by API.RequiredHeaders are missing or malformed
+const KindInvalidHeader = "invalid_header"
+
+// HeaderRule declares a request header required by an API
+type HeaderRule struct {
+	Name string `json:"name"`
+
+	// Match is a regular expression the whole value must match, optional
+	Match string `json:"match,omitempty"`
+
+	// Kind overrides KindInvalidHeader when the header is invalid
+	Kind string `json:"kind,omitempty"`
+
+	// Precondition sends 428 instead of 400 when the header is missing, for
+	// headers like If-Match
+	Precondition bool `json:"precondition,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// compileHeaderRules compiles Match of rules, it panics if any is invalid
+func compileHeaderRules(rules []HeaderRule) []HeaderRule {
+	ret := make([]HeaderRule, len(rules))
+	for i, rule := range rules {
+		rule.Name = http.CanonicalHeaderKey(rule.Name)
+		if rule.Match != "" {
+			rule.re = regexp.MustCompile("^(?:" + rule.Match + ")$")
+		}
+		ret[i] = rule
+	}
+	return ret
+}
+
+// checkHeaders validates request headers against rules, reporting all problems
+// in one Error.
+func checkHeaders(r *http.Request, rules []HeaderRule) error {
+	var problems []string
+	kind := ""
+	code := http.StatusPreconditionRequired
+	for _, rule := range rules {
+		vals := r.Header.Values(rule.Name)
+		switch {
+		case len(vals) == 0 || vals[0] == "":
+			problems = append(problems, "missing header "+rule.Name)
+			if !rule.Precondition {
+				code = http.StatusBadRequest
+			}
+		case rule.re != nil && !rule.re.MatchString(vals[0]):
+			problems = append(problems, "malformed header "+rule.Name)
+			code = http.StatusBadRequest
+		default:
+			continue
+		}
+		if kind == "" {
+			kind = rule.Kind
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	if kind == "" {
+		kind = KindInvalidHeader
+	}
+	return Error{
+		Code:    code,
+		Message: "Invalid request headers: " + strings.Join(problems, ", "),
+		Kind:    kind,
+	}
+}
+
+// SecurityHeaders returns response headers hardening APIs against being sniffed,
+// framed or cached by shared caches, for API.Headers
+//
+//     jsonapi.API{Pattern: "/api/me", APIHandler: me, Headers: jsonapi.SecurityHeaders()}
+func SecurityHeaders() http.Header {
+	return http.Header{
+		"X-Content-Type-Options":  {"nosniff"},
+		"X-Frame-Options":         {"DENY"},
+		"Content-Security-Policy": {"default-src 'none'; frame-ancestors 'none'"},
+		"Referrer-Policy":         {"no-referrer"},
+		"Cache-Control":           {"no-store"},
+	}
+}
+
+// setHeaders sets headers of the response, replacing existing values
+func setHeaders(w http.ResponseWriter, headers http.Header) {
+	header := w.Header()
+	for k, vals := range headers {
+		k = http.CanonicalHeaderKey(k)
+		header[k] = append([]string(nil), vals...)
+	}
+}
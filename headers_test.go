@@ -0,0 +1,136 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+var headerAPIs = []API{
+	{Pattern: "/api/orders", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return "created", nil
+	}, RequiredHeaders: []HeaderRule{
+		{Name: "x-tenant-id", Match: "[a-z]+"},
+		{Name: "Idempotency-Key", Match: "[0-9a-f]{8}", Kind: "bad_idempotency_key"},
+	}},
+	{Pattern: "/api/orders/1", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return "updated", nil
+	}, RequiredHeaders: []HeaderRule{{Name: "If-Match", Precondition: true}}},
+}
+
+func TestRequiredHeaders(t *testing.T) {
+	m := NewMuxTest(headerAPIs)
+	cases := []struct {
+		name    string
+		uri     string
+		headers Headers
+		code    int
+		kind    string
+		message string
+	}{
+		{"passing", "/api/orders", Headers{"X-Tenant-ID": "acme", "Idempotency-Key": "0123abcd"}, 200, "", ""},
+		{"missing", "/api/orders", Headers{"X-Tenant-ID": "acme"}, 400, "bad_idempotency_key",
+			"Invalid request headers: missing header Idempotency-Key"},
+		{"malformed", "/api/orders", Headers{"X-Tenant-ID": "ACME", "Idempotency-Key": "0123abcd"}, 400, KindInvalidHeader,
+			"Invalid request headers: malformed header X-Tenant-Id"},
+		{"all together", "/api/orders", Headers{"Idempotency-Key": "xyz"}, 400, "bad_idempotency_key",
+			"Invalid request headers: missing header X-Tenant-Id, malformed header Idempotency-Key"},
+		{"precondition", "/api/orders/1", Headers{}, http.StatusPreconditionRequired, KindInvalidHeader,
+			"Invalid request headers: missing header If-Match"},
+		{"precondition met", "/api/orders/1", Headers{"If-Match": `"v1"`}, 200, "", ""},
+	}
+	for _, c := range cases {
+		resp, _ := m.With(c.headers).Get(c.uri, "")
+		if resp.Code != c.code {
+			t.Errorf("%s: expected %d, got %d: %s", c.name, c.code, resp.Code, resp.Body)
+			continue
+		}
+		if c.code == 200 {
+			continue
+		}
+		var body ErrorBody
+		json.Unmarshal(resp.Body.Bytes(), &body)
+		if body.Error.Kind != c.kind || body.Error.Message != c.message {
+			t.Errorf("%s: unexpected error %+v", c.name, body.Error)
+		}
+	}
+}
+
+func TestRequiredHeadersDocs(t *testing.T) {
+	NewMuxTest(headerAPIs)
+	found := false
+	for _, r := range Routes() {
+		if r.Pattern == "/api/orders" {
+			found = len(r.RequiredHeaders) == 2 && r.RequiredHeaders[1].Kind == "bad_idempotency_key"
+		}
+	}
+	if !found {
+		t.Errorf("required headers are not listed in routes: %+v", Routes())
+	}
+
+	md, err := GenerateMarkdown(headerAPIs, DocOpts{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"### Headers", "| `X-Tenant-Id` | `[a-z]+` |", "| `If-Match` |  |"} {
+		if !strings.Contains(string(md), s) {
+			t.Errorf("%q not found in docs:\n%s", s, md)
+		}
+	}
+}
+
+func TestAPIHeaders(t *testing.T) {
+	headers := SecurityHeaders()
+	headers.Set("cache-control", "private, max-age=60")
+	m := NewMuxTest([]API{
+		{Pattern: "/api/user/{id}", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			switch httpData.Request.PathValue("id") {
+			case "0":
+				return nil, E404.SetData("User not found")
+			case "me":
+				httpData.ResponseWriter.Header().Set("Cache-Control", "no-store")
+				httpData.ResponseWriter.Header().Add("X-Frame-Options", "SAMEORIGIN")
+			}
+			return "john", nil
+		}, Headers: headers, RequiredHeaders: []HeaderRule{{Name: "X-Tenant-ID"}}},
+	})
+	tenant := m.With(Headers{"X-Tenant-ID": "acme"})
+
+	expect := map[string]string{
+		"X-Content-Type-Options":  "nosniff",
+		"X-Frame-Options":         "DENY",
+		"Content-Security-Policy": "default-src 'none'; frame-ancestors 'none'",
+		"Referrer-Policy":         "no-referrer",
+		"Cache-Control":           "private, max-age=60",
+	}
+	for _, c := range []struct {
+		req  *TestRequest
+		uri  string
+		code int
+	}{
+		{tenant, "/api/user/1", http.StatusOK},
+		{tenant, "/api/user/0", http.StatusNotFound},
+		{m.TestRequest, "/api/user/1", http.StatusBadRequest},
+	} {
+		resp, _ := c.req.Get(c.uri, "")
+		if resp.Code != c.code {
+			t.Errorf("%s: expected %d, got %d %s", c.uri, c.code, resp.Code, resp.Body)
+		}
+		for k, v := range expect {
+			if vals := resp.Header().Values(k); len(vals) != 1 || vals[0] != v {
+				t.Errorf("%s %d: expected %s %q, got %q", c.uri, resp.Code, k, v, vals)
+			}
+		}
+	}
+
+	// handlers can override them, without changing later responses
+	resp, _ := tenant.Get("/api/user/me", "")
+	if resp.Header().Get("Cache-Control") != "no-store" || len(resp.Header().Values("X-Frame-Options")) != 2 {
+		t.Errorf("cannot override headers: %v", resp.Header())
+	}
+	resp, _ = tenant.Get("/api/user/1", "")
+	if resp.Header().Get("Cache-Control") != "private, max-age=60" || len(resp.Header().Values("X-Frame-Options")) != 1 {
+		t.Errorf("headers of API are modified: %v", resp.Header())
+	}
+}
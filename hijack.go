@@ -0,0 +1,43 @@
+package jsonapi
+
+// Hijacked tells that the handler takes over the connection, like upgrading to
+// WebSocket. The JSON Content-Type header is removed, the result returned by
+// APIHandler is ignored like after WriteJSON, and the request body is not drained
+// after handler returns.
+//
+// Requests are marked as hijacked automatically when the connection is hijacked
+// through http.Hijacker or http.ResponseController, and nothing is written to the
+// ResponseWriter after that. Call it before upgrading anyway, as WebSocket libraries
+// send their own response if the handshake fails. With gorilla/websocket:
+//
+//     func chat(dec *json.Decoder, httpData *jsonapi.HTTP) (interface{}, error) {
+//         httpData.Hijacked()
+//         conn, err := upgrader.Upgrade(httpData.ResponseWriter, httpData.Request, nil)
+//         if err != nil {
+//             // Upgrade has sent the error response
+//             return nil, nil
+//         }
+//         defer conn.Close()
+//         serveChat(conn)
+//         return nil, nil
+//     }
+//
+// or nhooyr.io/websocket:
+//
+//     func chat(dec *json.Decoder, httpData *jsonapi.HTTP) (interface{}, error) {
+//         httpData.Hijacked()
+//         conn, err := websocket.Accept(httpData.ResponseWriter, httpData.Request, nil)
+//         if err != nil {
+//             return nil, nil
+//         }
+//         defer conn.CloseNow()
+//         serveChat(httpData.Request.Context(), conn)
+//         return nil, nil
+//     }
+//
+// Hijacking is not supported by APIs with Timeout, as the response is buffered.
+func (h *HTTP) Hijacked() {
+	h.hijacked = true
+	h.replied = true
+	h.ResponseWriter.Header().Del("Content-Type")
+}
@@ -0,0 +1,66 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHijack(t *testing.T) {
+	var writeErr, replyErr error
+	m := NewMuxTest([]API{{Pattern: "/ws", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		conn, rw, err := http.NewResponseController(httpData.ResponseWriter).Hijack()
+		if err != nil {
+			return nil, err
+		}
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\n\r\n")
+		rw.Flush()
+		conn.Close()
+
+		_, writeErr = httpData.ResponseWriter.Write([]byte("late"))
+		httpData.ResponseWriter.WriteHeader(http.StatusInternalServerError)
+		replyErr = httpData.WriteJSON(http.StatusOK, "late")
+		return "ignored", nil
+	}}})
+
+	w := newHijackRecorder()
+	received := make(chan string)
+	go func() {
+		buf, _ := ioutil.ReadAll(w.client)
+		received <- string(buf)
+	}()
+	body := &countingReader{n: 1000}
+	req := httptest.NewRequest("GET", "/ws", ioutil.NopCloser(body))
+	m.Mux.ServeHTTP(w, req)
+
+	if s := <-received; s != "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\n\r\n" {
+		t.Errorf("unexpected data on the connection %q", s)
+	}
+	if writeErr != http.ErrHijacked || replyErr != ErrReplied {
+		t.Errorf("writes after hijacking: %v and %v", writeErr, replyErr)
+	}
+	if w.Body.Len() != 0 || w.Header().Get("Content-Type") != "" {
+		t.Errorf("written to ResponseWriter after hijacking: %v %q", w.Header(), w.Body)
+	}
+	if body.read != 0 {
+		t.Errorf("%d bytes of request body are drained", body.read)
+	}
+}
+
+func TestHijackedHandlerTest(t *testing.T) {
+	h := HandlerTest(APIHandler(func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		httpData.Hijacked()
+		// the upgrade fails without a Hijacker, like the library sends its own error
+		http.Error(httpData.ResponseWriter, "upgrade required", http.StatusUpgradeRequired)
+		return "ignored", nil
+	}).Handler)
+	resp, _ := h.Get("/ws", "")
+	if resp.Code != http.StatusUpgradeRequired || resp.Body.String() != "upgrade required\n" {
+		t.Errorf("unexpected response %d %q", resp.Code, resp.Body)
+	}
+	if ct := resp.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+}
@@ -0,0 +1,176 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Instrumentation receives events of requests to registered APIs, for metrics like
+// request counters and latency histograms. route is API.Pattern, never the request
+// path, so the number of labels is bounded.
+//
+// It can be bridged to a metrics library, like Prometheus:
+//
+//     type promInstrumentation struct {
+//         inflight *prometheus.GaugeVec
+//         latency  *prometheus.HistogramVec
+//     }
+//
+//     func (p promInstrumentation) RequestStarted(route string) {
+//         p.inflight.WithLabelValues(route).Inc()
+//     }
+//
+//     func (p promInstrumentation) RequestFinished(route string, status int, d time.Duration, reqBytes, respBytes int64) {
+//         p.inflight.WithLabelValues(route).Dec()
+//         p.latency.WithLabelValues(route, strconv.Itoa(status)).Observe(d.Seconds())
+//     }
+//
+//     jsonapi.DefaultInstrumentation = promInstrumentation{inflight, latency}
+type Instrumentation interface {
+	RequestStarted(route string)
+
+	// RequestFinished is called after the handler returns or panics. Status is 500
+	// if it panics before sending response. reqBytes is the size of request body
+	// read by handler, and respBytes is the size of response body before compression.
+	RequestFinished(route string, status int, duration time.Duration, reqBytes, respBytes int64)
+}
+
+// DefaultInstrumentation is used by APIs not registered with
+// RegisterOpts.Instrumentation. Nil disables it.
+var DefaultInstrumentation Instrumentation
+
+// RegisterOpts configures APIs registered by RegisterWith
+type RegisterOpts struct {
+	// Instrumentation overrides DefaultInstrumentation for these APIs
+	Instrumentation Instrumentation
+
+	// OnError overrides package-level OnError for these APIs
+	OnError func(httpData *HTTP, err error)
+
+	// Tracer overrides DefaultTracer for these APIs
+	Tracer Tracer
+}
+
+// RegisterWith registers apis like Register, with options applied to all of them
+//
+//     metrics := jsonapi.NewMemoryInstrumentation()
+//     jsonapi.RegisterWith(apis, mux, jsonapi.RegisterOpts{Instrumentation: metrics})
+func RegisterWith(apis []API, mux *http.ServeMux, opts RegisterOpts) {
+	apis = append([]API(nil), apis...)
+	for i := range apis {
+		apis[i].instrumentation = opts.Instrumentation
+		apis[i].onError = opts.OnError
+		apis[i].tracer = opts.Tracer
+	}
+	Register(apis, mux)
+}
+
+// instrument reports request served by next to inst
+func instrument(inst Instrumentation, route string, next HTTPHandler) HTTPHandler {
+	return func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		inst.RequestStarted(route)
+		start := DefaultClock.Now()
+		read := httpData.bodyRead()
+		w := &statusWriter{ResponseWriter: httpData.ResponseWriter}
+		httpData.ResponseWriter = w
+		defer func() {
+			httpData.ResponseWriter = w.ResponseWriter
+			status := w.status
+			v := recover()
+			switch {
+			case v != nil && status == 0:
+				status = http.StatusInternalServerError
+			case status == 0:
+				status = http.StatusOK
+			}
+			inst.RequestFinished(route, status, DefaultClock.Now().Sub(start), httpData.bodyRead()-read, w.written)
+			if v != nil {
+				panic(v)
+			}
+		}()
+		next(httpData.configureEncoder(json.NewEncoder(w)), dec, httpData)
+	}
+}
+
+// RouteMetrics is collected by MemoryInstrumentation for a route
+type RouteMetrics struct {
+	Route     string        `json:"route"`
+	InFlight  int64         `json:"in_flight"`
+	Requests  int64         `json:"requests"` // finished requests
+	Statuses  map[int]int64 `json:"statuses"` // finished requests by status code
+	Duration  time.Duration `json:"duration"` // sum of durations
+	ReqBytes  int64         `json:"req_bytes"`
+	RespBytes int64         `json:"resp_bytes"`
+}
+
+// MemoryInstrumentation is an Instrumentation keeping metrics in memory, for tests
+// or simple status pages
+type MemoryInstrumentation struct {
+	mu     sync.Mutex
+	routes map[string]*RouteMetrics
+}
+
+// NewMemoryInstrumentation creates a MemoryInstrumentation
+func NewMemoryInstrumentation() *MemoryInstrumentation {
+	return &MemoryInstrumentation{routes: map[string]*RouteMetrics{}}
+}
+
+func (m *MemoryInstrumentation) route(route string) *RouteMetrics {
+	r, ok := m.routes[route]
+	if !ok {
+		r = &RouteMetrics{Route: route, Statuses: map[int]int64{}}
+		m.routes[route] = r
+	}
+	return r
+}
+
+// RequestStarted implements Instrumentation
+func (m *MemoryInstrumentation) RequestStarted(route string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.route(route).InFlight++
+}
+
+// RequestFinished implements Instrumentation
+func (m *MemoryInstrumentation) RequestFinished(route string, status int, duration time.Duration, reqBytes, respBytes int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	r := m.route(route)
+	r.InFlight--
+	r.Requests++
+	r.Statuses[status]++
+	r.Duration += duration
+	r.ReqBytes += reqBytes
+	r.RespBytes += respBytes
+}
+
+// Metrics returns a copy of metrics of route
+func (m *MemoryInstrumentation) Metrics(route string) RouteMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.route(route).copy()
+}
+
+// All returns copies of metrics of all routes, sorted by route
+func (m *MemoryInstrumentation) All() []RouteMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ret := make([]RouteMetrics, 0, len(m.routes))
+	for _, r := range m.routes {
+		ret = append(ret, r.copy())
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Route < ret[j].Route })
+	return ret
+}
+
+func (r *RouteMetrics) copy() RouteMetrics {
+	ret := *r
+	ret.Statuses = make(map[int]int64, len(r.Statuses))
+	for k, v := range r.Statuses {
+		ret.Statuses[k] = v
+	}
+	return ret
+}
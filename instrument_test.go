@@ -0,0 +1,93 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInstrumentation(t *testing.T) {
+	clock, restore := withFakeClock()
+	defer restore()
+	metrics := NewMemoryInstrumentation()
+	var inflight int64
+	mux := http.NewServeMux()
+	RegisterWith([]API{
+		{Pattern: "/api/user/{id}", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			inflight = metrics.Metrics("/api/user/{id}").InFlight
+			clock.Advance(time.Second)
+			if httpData.Request.PathValue("id") == "0" {
+				return nil, E404
+			}
+			var v interface{}
+			dec.Decode(&v)
+			return v, nil
+		}},
+	}, mux, RegisterOpts{Instrumentation: metrics})
+	h := &TestRequest{h: mux}
+
+	if resp, _ := h.Post("/api/user/1", "", `{"a":1}`); resp.Code != http.StatusOK {
+		t.Fatalf("unexpected response %d %s", resp.Code, resp.Body)
+	}
+	if inflight != 1 {
+		t.Errorf("expected 1 request in flight, got %d", inflight)
+	}
+	h.Get("/api/user/0", "")
+	h.Get("/api/user/2", "")
+
+	expect := []RouteMetrics{{
+		Route:     "/api/user/{id}",
+		Requests:  3,
+		Statuses:  map[int]int64{http.StatusOK: 2, http.StatusNotFound: 1},
+		Duration:  3 * time.Second,
+		ReqBytes:  7,
+		RespBytes: int64(len(`{"a":1}`+"\n") + len(`{"error":{"code":404,"message":"Resource not found"}}`+"\n") + len("null\n")),
+	}}
+	if all := metrics.All(); !reflect.DeepEqual(all, expect) {
+		t.Errorf("expected %+v, got %+v", expect, all)
+	}
+}
+
+func TestDefaultInstrumentation(t *testing.T) {
+	metrics := NewMemoryInstrumentation()
+	DefaultInstrumentation = metrics
+	defer func() { DefaultInstrumentation = nil }()
+	own := NewMemoryInstrumentation()
+	mux := http.NewServeMux()
+	Register([]API{{Pattern: "GET /a", APIHandler: okAPI}}, mux)
+	RegisterWith([]API{{Pattern: "GET /b", APIHandler: okAPI}}, mux, RegisterOpts{Instrumentation: own})
+	h := &TestRequest{h: mux}
+
+	h.Get("/a", "")
+	h.Get("/b", "")
+	h.Get("/b", "")
+	if m := metrics.All(); len(m) != 1 || m[0].Route != "GET /a" || m[0].Requests != 1 {
+		t.Errorf("unexpected default metrics %+v", m)
+	}
+	if m := own.All(); len(m) != 1 || m[0].Route != "GET /b" || m[0].Requests != 2 {
+		t.Errorf("unexpected metrics %+v", m)
+	}
+}
+
+func TestInstrumentationPanic(t *testing.T) {
+	metrics := NewMemoryInstrumentation()
+	mux := http.NewServeMux()
+	RegisterWith([]API{{Pattern: "/panic", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		panic("boom")
+	}}}, mux, RegisterOpts{Instrumentation: metrics})
+
+	func() {
+		defer func() {
+			if v := recover(); v != "boom" {
+				t.Errorf("panic is not passed through: %v", v)
+			}
+		}()
+		(&TestRequest{h: mux}).Get("/panic", "")
+	}()
+	m := metrics.Metrics("/panic")
+	if m.InFlight != 0 || m.Requests != 1 || m.Statuses[http.StatusInternalServerError] != 1 {
+		t.Errorf("unexpected metrics %+v", m)
+	}
+}
@@ -0,0 +1,13 @@
+package jsonapi
+
+// Int64AsString encodes int64 and uint64 values in responses as JSON strings, so
+// JavaScript clients do not round values above 2^53 silently. API.Int64AsString
+// enables it per route, and fields tagged `jsstring:"true"` are always encoded this way:
+//
+//     type User struct {
+//         ID   int64  `json:"id" jsstring:"true"` // {"id": "9007199254740993"}
+//         Name string `json:"name"`
+//     }
+//
+// Use DecodeInt64Strings to accept both forms in request bodies.
+var Int64AsString bool
@@ -0,0 +1,85 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const big = int64(1<<53 + 1) // 9007199254740993
+
+type snowflake struct {
+	ID     int64            `json:"id" jsstring:"true"`
+	Parent uint64           `json:"parent"`
+	Count  int              `json:"count"`
+	Refs   []int64          `json:"refs"`
+	ByName map[string]int64 `json:"by_name"`
+}
+
+func TestInt64AsString(t *testing.T) {
+	v := snowflake{ID: big, Parent: uint64(big), Count: 3, Refs: []int64{big}, ByName: map[string]int64{"a": big}}
+	apis := []API{
+		{Pattern: "/tag", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) { return v, nil }},
+		{Pattern: "/api", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) { return v, nil }, Int64AsString: true},
+	}
+	cases := map[string]string{
+		"/tag": `{"id":"9007199254740993","parent":9007199254740993,"count":3,"refs":[9007199254740993],"by_name":{"a":9007199254740993}}`,
+		"/api": `{"id":"9007199254740993","parent":"9007199254740993","count":3,"refs":["9007199254740993"],"by_name":{"a":"9007199254740993"}}`,
+	}
+
+	m := NewMuxTest(apis)
+	for uri, expect := range cases {
+		resp, _ := m.Get(uri, "")
+		if actual := strings.TrimSpace(resp.Body.String()); actual != expect {
+			t.Errorf("%s: expected %s, got %s", uri, expect, actual)
+		}
+	}
+
+	Int64AsString = true
+	defer func() { Int64AsString = false }()
+	resp, _ := m.Get("/tag", "")
+	if actual := strings.TrimSpace(resp.Body.String()); actual != cases["/api"] {
+		t.Errorf("global: expected %s, got %s", cases["/api"], actual)
+	}
+}
+
+func TestDecodeInt64Strings(t *testing.T) {
+	expect := snowflake{ID: big, Parent: uint64(big), Count: 3, Refs: []int64{big, big}, ByName: map[string]int64{"a": big, "b": big}}
+	for _, data := range []string{
+		`{"id":"9007199254740993","parent":"9007199254740993","count":3,"refs":["9007199254740993",9007199254740993],"by_name":{"a":"9007199254740993","b":9007199254740993}}`,
+		`{"id":9007199254740993,"parent":9007199254740993,"count":3,"refs":[9007199254740993,"9007199254740993"],"by_name":{"a":9007199254740993,"b":"9007199254740993"}}`,
+	} {
+		var v snowflake
+		if err := DecodeInt64Strings(json.NewDecoder(strings.NewReader(data)), &v); err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !reflect.DeepEqual(v, expect) {
+			t.Errorf("%s: expected %+v, got %+v", data, expect, v)
+		}
+	}
+
+	var v snowflake
+	if err := DecodeInt64Strings(json.NewDecoder(strings.NewReader(`{"count":"3"}`)), &v); err == nil {
+		t.Errorf("string accepted for int field")
+	}
+	if err := DecodeInt64Strings(json.NewDecoder(strings.NewReader(`{"id":"x"}`)), &v); err == nil {
+		t.Errorf("invalid string accepted for int64 field")
+	}
+}
+
+func TestInt64RoundTrip(t *testing.T) {
+	m := NewMuxTest([]API{{Pattern: "/", Int64AsString: true, APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		var v snowflake
+		if err := DecodeInt64Strings(dec, &v); err != nil {
+			return nil, E400
+		}
+		return v, nil
+	}}})
+
+	first, _ := m.Post("/", "", `{"id":9007199254740993,"refs":[9007199254740995]}`)
+	second, _ := m.Post("/", "", first.Body.String())
+	if first.Body.String() != second.Body.String() || !strings.Contains(second.Body.String(), `"refs":["9007199254740995"]`) {
+		t.Errorf("values changed in round trip: %s then %s", first.Body, second.Body)
+	}
+}
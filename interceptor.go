@@ -0,0 +1,124 @@
+package jsonapi
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// RoundTripFunc sends a request of Client
+type RoundTripFunc func(r *http.Request) (*http.Response, error)
+
+// Interceptor wraps a RoundTripFunc, see Client.Use
+type Interceptor func(next RoundTripFunc) RoundTripFunc
+
+// Use adds interceptors to c. Interceptors added earlier wrap later ones: for
+//
+//     c.Use(a, b)
+//     c.Use(d)
+//
+// requests pass a, b, d in order before being sent, and responses pass d, b, a.
+// An interceptor can modify the request before calling next, retry by calling
+// next again, or return without calling next to replace the response or abort
+// with an error, which is then returned by the method of Client. Error responses
+// are passed as is, use DecodeError to inspect them.
+//
+// Use is not safe to call while c is sending requests.
+func (c *Client) Use(interceptors ...Interceptor) {
+	c.interceptors = append(c.interceptors, interceptors...)
+}
+
+// do sends r through interceptors
+func (c *Client) do(r *http.Request) (*http.Response, error) {
+	for k, v := range c.header {
+		if _, ok := r.Header[k]; !ok {
+			r.Header[k] = v
+		}
+	}
+	rt := RoundTripFunc(c.client.Do)
+	for i := len(c.interceptors) - 1; i >= 0; i-- {
+		rt = c.interceptors[i](rt)
+	}
+	return rt(r)
+}
+
+// DecodeError returns the Error sent by server if status code of resp >= 300, or
+// nil otherwise. Body of resp is kept, so it can be read again.
+func DecodeError(resp *http.Response) error {
+	if resp.StatusCode < 300 {
+		return nil
+	}
+	buf, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(buf))
+	if err != nil {
+		return err
+	}
+	copied := *resp
+	copied.Body = ioutil.NopCloser(bytes.NewReader(buf))
+	return responseError(&copied)
+}
+
+// BearerToken sets Authorization header of requests to the token returned by
+// token, unless it is set already.
+//
+//     c.Use(jsonapi.BearerToken(func(ctx context.Context) (string, error) {
+//         return tokens.Get(ctx)
+//     }))
+func BearerToken(token func(ctx context.Context) (string, error)) Interceptor {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			if r.Header.Get("Authorization") != "" {
+				return next(r)
+			}
+			t, err := token(r.Context())
+			if err != nil {
+				return nil, err
+			}
+			r = r.Clone(r.Context())
+			r.Header.Set("Authorization", "Bearer "+t)
+			return next(r)
+		}
+	}
+}
+
+// LogRequests logs method, url, status code and duration of every request with
+// logf, like log.Printf.
+func LogRequests(logf func(format string, args ...interface{})) Interceptor {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			start := DefaultClock.Now()
+			resp, err := next(r)
+			d := DefaultClock.Now().Sub(start)
+			if err != nil {
+				logf("jsonapi: %s %s failed after %s: %s", r.Method, r.URL, d, err)
+				return resp, err
+			}
+			logf("jsonapi: %s %s %d %s", r.Method, r.URL, resp.StatusCode, d)
+			return resp, err
+		}
+	}
+}
+
+// Latency calls observe with duration of every request, which is until response
+// header is received. status is 0 if request failed.
+//
+//     c.Use(jsonapi.Latency(func(r *http.Request, status int, d time.Duration) {
+//         histogram.WithLabelValues(r.URL.Path, strconv.Itoa(status)).Observe(d.Seconds())
+//     }))
+func Latency(observe func(r *http.Request, status int, d time.Duration)) Interceptor {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			start := DefaultClock.Now()
+			resp, err := next(r)
+			status := 0
+			if err == nil {
+				status = resp.StatusCode
+			}
+			observe(r, status, DefaultClock.Now().Sub(start))
+			return resp, err
+		}
+	}
+}
@@ -0,0 +1,190 @@
+package jsonapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// interceptorServer echoes Authorization header, and fails with 503 for the first
+// `unavailable` requests
+func interceptorServer(unavailable int) (*httptest.Server, *int) {
+	hits := 0
+	srv := NewMuxTest([]API{
+		{Pattern: "/api/whoami", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			if hits++; hits <= unavailable {
+				return nil, E503
+			}
+			var args map[string]string
+			dec.Decode(&args)
+			return map[string]string{"auth": httpData.Request.Header.Get("Authorization"), "name": args["name"]}, nil
+		}},
+	}).StartServer()
+	return srv, &hits
+}
+
+func recordInterceptor(name string, log *[]string) Interceptor {
+	return func(next RoundTripFunc) RoundTripFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			*log = append(*log, "->"+name)
+			resp, err := next(r)
+			*log = append(*log, "<-"+name)
+			return resp, err
+		}
+	}
+}
+
+func TestClientUseOrder(t *testing.T) {
+	srv, _ := interceptorServer(0)
+	defer srv.Close()
+
+	var log []string
+	c := NewClient(srv.URL + "/api/")
+	c.Use(recordInterceptor("a", &log), recordInterceptor("b", &log))
+	c.Use(recordInterceptor("d", &log))
+	if err := c.Call(context.Background(), "whoami", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	if s := strings.Join(log, " "); s != "->a ->b ->d <-d <-b <-a" {
+		t.Errorf("unexpected order: %s", s)
+	}
+}
+
+func TestClientInterceptorRetry(t *testing.T) {
+	srv, hits := interceptorServer(2)
+	defer srv.Close()
+
+	var seen []error
+	c := NewClient(srv.URL + "/api/")
+	c.Use(func(next RoundTripFunc) RoundTripFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			for {
+				resp, err := next(r)
+				if err != nil {
+					return resp, err
+				}
+				e := DecodeError(resp)
+				seen = append(seen, e)
+				if ee, ok := e.(Error); !ok || ee.Code != http.StatusServiceUnavailable || len(seen) > 3 {
+					return resp, nil
+				}
+				resp.Body.Close()
+				r.Body, _ = r.GetBody()
+			}
+		}
+	})
+
+	var reply map[string]string
+	if err := c.Call(context.Background(), "whoami", map[string]string{"name": "john"}, &reply); err != nil {
+		t.Fatal(err)
+	}
+	if *hits != 3 || len(seen) != 3 || seen[2] != nil || reply["name"] != "john" {
+		t.Errorf("unexpected retries: %d hits, errors %v, reply %v", *hits, seen, reply)
+	}
+}
+
+func TestClientInterceptorShortCircuit(t *testing.T) {
+	srv, hits := interceptorServer(0)
+	defer srv.Close()
+
+	aborted := errors.New("offline")
+	c := NewClient(srv.URL + "/api/")
+	c.Use(func(next RoundTripFunc) RoundTripFunc {
+		return func(r *http.Request) (*http.Response, error) {
+			switch r.URL.Query().Get("mode") {
+			case "abort":
+				return nil, aborted
+			case "cached":
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": {"application/json"}},
+					Body:       ioutil.NopCloser(strings.NewReader(`{"name":"cached"}`)),
+					Request:    r,
+				}, nil
+			case "teapot":
+				return &http.Response{
+					StatusCode: http.StatusTeapot,
+					Body:       ioutil.NopCloser(strings.NewReader(`{"error":{"code":418,"message":"no coffee"}}`)),
+					Request:    r,
+				}, nil
+			}
+			return next(r)
+		}
+	})
+
+	if err := c.Call(context.Background(), "whoami?mode=abort", nil, nil); !errors.Is(err, aborted) {
+		t.Errorf("expected error of interceptor, got %v", err)
+	}
+	var reply map[string]string
+	if err := c.Call(context.Background(), "whoami?mode=cached", nil, &reply); err != nil || reply["name"] != "cached" {
+		t.Errorf("response is not replaced: %v %v", reply, err)
+	}
+	err := c.Call(context.Background(), "whoami?mode=teapot", nil, nil)
+	if e, ok := err.(Error); !ok || e.Code != http.StatusTeapot || e.Message != "no coffee" {
+		t.Errorf("expected Error of replaced response, got %#v", err)
+	}
+	if *hits != 0 {
+		t.Errorf("%d requests reached server", *hits)
+	}
+}
+
+func TestClientBuiltinInterceptors(t *testing.T) {
+	clock, restore := withFakeClock()
+	defer restore()
+	srv, _ := interceptorServer(0)
+	defer srv.Close()
+
+	var logs []string
+	type observed struct {
+		path   string
+		status int
+		d      time.Duration
+	}
+	var latencies []observed
+	tokenErr := errors.New("no token")
+	token := "secret"
+	c := NewClient(srv.URL + "/api/")
+	c.Use(
+		LogRequests(func(format string, args ...interface{}) { logs = append(logs, fmt.Sprintf(format, args...)) }),
+		Latency(func(r *http.Request, status int, d time.Duration) {
+			latencies = append(latencies, observed{r.URL.Path, status, d})
+		}),
+		BearerToken(func(ctx context.Context) (string, error) {
+			if token == "" {
+				return "", tokenErr
+			}
+			return token, nil
+		}),
+		func(next RoundTripFunc) RoundTripFunc {
+			return func(r *http.Request) (*http.Response, error) {
+				clock.Advance(time.Second)
+				return next(r)
+			}
+		},
+	)
+
+	var reply map[string]string
+	if err := c.Call(context.Background(), "whoami", nil, &reply); err != nil || reply["auth"] != "Bearer secret" {
+		t.Errorf("token is not sent: %v %v", reply, err)
+	}
+	token = ""
+	if err := c.Call(context.Background(), "whoami", nil, nil); err != tokenErr {
+		t.Errorf("expected error of token, got %v", err)
+	}
+
+	expect := []observed{{"/api/whoami", 200, time.Second}, {"/api/whoami", 0, 0}}
+	if fmt.Sprint(latencies) != fmt.Sprint(expect) {
+		t.Errorf("unexpected latencies %v", latencies)
+	}
+	if len(logs) != 2 || logs[0] != "jsonapi: POST "+srv.URL+"/api/whoami 200 1s" ||
+		logs[1] != "jsonapi: POST "+srv.URL+"/api/whoami failed after 0s: no token" {
+		t.Errorf("unexpected logs %q", logs)
+	}
+}
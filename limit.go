@@ -0,0 +1,342 @@
+package jsonapi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"reflect"
+	"sort"
+)
+
+// KindResponseTooLarge is the Kind of Error sent when response exceeds MaxResponseBytes
+const KindResponseTooLarge = "response_too_large"
+
+// MaxResponseBytes limits size of encoded APIHandler results. Zero means no limit.
+// API.MaxResponseBytes overrides it per route.
+//
+// Responses are buffered when limited, and a 500 Error of KindResponseTooLarge is
+// sent instead if the limit is exceeded. Arrays, maps and structs are encoded
+// member by member, so encoding stops shortly after the limit is reached.
+//
+// Streaming responses, like Stream, Raw, Proxy and SSE, are cut once the limit is
+// reached instead, and the error is logged and reported to OnError.
+var MaxResponseBytes int64
+
+var errResponseTooLarge = errors.New("jsonapi: response too large")
+
+// responseTooLarge is sent when a response of at least size bytes exceeds limit
+func responseTooLarge(size, limit int64) Error {
+	return Error{
+		Code:    http.StatusInternalServerError,
+		Message: fmt.Sprintf("Response of at least %d bytes exceeds the limit of %d bytes", size, limit),
+		Kind:    KindResponseTooLarge,
+	}
+}
+
+func (h *HTTP) maxResponseBytes() int64 {
+	if h.api != nil && h.api.MaxResponseBytes > 0 {
+		return h.api.MaxResponseBytes
+	}
+	return MaxResponseBytes
+}
+
+// limitedBuffer rejects writes beyond limit
+type limitedBuffer struct {
+	bytes.Buffer
+	limit     int64
+	attempted int64 // size of the rejected write, including buffered data
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if int64(b.Len()+len(p)) > b.limit {
+		b.attempted = int64(b.Len() + len(p))
+		return 0, errResponseTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+// streamLimiter applies maxResponseBytes to streaming responses. Once a write
+// would exceed the limit, data up to the limit is written, then it and all later
+// writes fail with err, a 500 Error of KindResponseTooLarge. Writers of framed data,
+// like Stream, reserve space first so no partial frame is written.
+type streamLimiter struct {
+	w        io.Writer
+	limit    int64 // zero or negative means no limit
+	written  int64
+	err      *Error
+	reported bool
+}
+
+func (h *HTTP) limitStream(w io.Writer) *streamLimiter {
+	return &streamLimiter{w: w, limit: h.maxResponseBytes()}
+}
+
+// reserve fails if n more bytes cannot be written
+func (l *streamLimiter) reserve(n int) error {
+	if l.err == nil && l.limit > 0 && l.written+int64(n) > l.limit {
+		e := responseTooLarge(l.written+int64(n), l.limit)
+		l.err = &e
+	}
+	if l.err != nil {
+		return *l.err
+	}
+	return nil
+}
+
+func (l *streamLimiter) Write(p []byte) (int, error) {
+	if l.err != nil {
+		return 0, *l.err
+	}
+	err := l.reserve(len(p))
+	if err != nil {
+		p = p[:l.limit-l.written]
+	}
+	n, wErr := l.w.Write(p)
+	l.written += int64(n)
+	if wErr != nil {
+		err = wErr
+	}
+	return n, err
+}
+
+// reportCut logs and reports the error to OnError once if the stream is cut, and
+// returns whether it is.
+func (l *streamLimiter) reportCut(h *HTTP) bool {
+	if l.err == nil {
+		return false
+	}
+	if !l.reported {
+		l.reported = true
+		log.Printf("jsonapi: response of %s cut: %s", h.Request.URL.Path, l.err.Message)
+		reportError(h, http.StatusInternalServerError, *l.err)
+	}
+	return true
+}
+
+// encodeLimited encodes v into JSON format like rewriter w does, failing with an
+// Error of KindResponseTooLarge once the result grows beyond limit bytes. Arrays, maps and
+// structs are encoded member by member into the buffer, so nothing is copied or
+// encoded after the limit is reached.
+func encodeLimited(v interface{}, w rewriter, limit int64) ([]byte, error) {
+	buf := &limitedBuffer{limit: limit}
+	// like rewriter.rewrite, jsstring tags behind interface values are ignored if
+	// nothing else is rewritten
+	e := pieceEncoder{buf: buf, tags: v != nil && (w != rewriter{} || hasRewriteTags(reflect.TypeOf(v)))}
+	w.visiting = &visitSet{}
+	if err := e.encode(reflect.ValueOf(v), w); err != nil {
+		return nil, limitError(unwrapLimit(err), buf)
+	}
+	if _, err := buf.Write([]byte{'\n'}); err != nil {
+		return nil, limitError(err, buf)
+	}
+	return buf.Bytes(), nil
+}
+
+// limitError converts errResponseTooLarge into the Error sent to client
+func limitError(err error, buf *limitedBuffer) error {
+	if err == errResponseTooLarge {
+		return responseTooLarge(buf.attempted, buf.limit)
+	}
+	return err
+}
+
+// pieceEncoder writes values piece by piece, see encodeLimited
+type pieceEncoder struct {
+	buf  *limitedBuffer
+	tags bool // apply rewriteTags
+}
+
+// encode writes v rewritten by w
+func (e pieceEncoder) encode(v reflect.Value, w rewriter) error {
+	buf := e.buf
+	leaf := func(x interface{}) error {
+		b, err := marshal(x)
+		if err != nil {
+			return err
+		}
+		_, err = buf.Write(b)
+		return err
+	}
+	// list writes n elements between open and close
+	list := func(open, close byte, n int, elem func(i int) error) error {
+		if _, err := buf.Write([]byte{open}); err != nil {
+			return err
+		}
+		for i := 0; i < n; i++ {
+			if i > 0 {
+				if _, err := buf.Write([]byte{','}); err != nil {
+					return err
+				}
+			}
+			if err := elem(i); err != nil {
+				return err
+			}
+		}
+		_, err := buf.Write([]byte{close})
+		return err
+	}
+	key := func(name string) error {
+		if err := leaf(name); err != nil {
+			return err
+		}
+		_, err := buf.Write([]byte{':'})
+		return err
+	}
+
+	if !v.IsValid() || isMarshaler(v) {
+		return leaf(w.value(v))
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			break
+		}
+		leave, ok := w.visiting.enter(v)
+		if !ok {
+			return cycleError(v.Type())
+		}
+		defer leave()
+	}
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return leaf(nil)
+		}
+		return e.encode(v.Elem(), w)
+	case reflect.Struct:
+		type entry struct {
+			f field
+			v reflect.Value
+		}
+		var entries []entry
+		for _, f := range fieldsOf(v.Type()) {
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok || (f.omitEmpty && isEmptyValue(fv)) || (f.omitZero && fv.IsZero()) || w.omits(f, fv) {
+				continue
+			}
+			entries = append(entries, entry{f, fv})
+		}
+		return list('{', '}', len(entries), func(i int) error {
+			f, fv := entries[i].f, entries[i].v
+			if err := key(f.name); err != nil {
+				return err
+			}
+			if f.quoted && fv.Kind() == reflect.Ptr && fv.IsNil() {
+				return leaf(nil)
+			}
+			if f.quoted {
+				b, err := marshal(fv.Interface())
+				if err != nil {
+					return err
+				}
+				return leaf(string(b))
+			}
+			sub := w
+			if e.tags && f.tag.Get("jsstring") == "true" {
+				sub.int64String = true
+			}
+			return e.encode(fv, sub)
+		})
+	case reflect.Map:
+		if v.IsNil() {
+			return leaf(nil)
+		}
+		keys := make([]string, 0, v.Len())
+		values := make(map[string]reflect.Value, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			k, ok := mapKey(iter.Key())
+			if !ok {
+				return leaf(v.Interface())
+			}
+			keys = append(keys, k)
+			values[k] = iter.Value()
+		}
+		sort.Strings(keys)
+		return list('{', '}', len(keys), func(i int) error {
+			if err := key(keys[i]); err != nil {
+				return err
+			}
+			return e.encode(values[keys[i]], w)
+		})
+	case reflect.Slice:
+		if v.IsNil() || v.Type().Elem().Kind() == reflect.Uint8 {
+			return leaf(w.value(v))
+		}
+		fallthrough
+	case reflect.Array:
+		iface := v.Type().Elem().Kind() == reflect.Interface
+		return list('[', ']', v.Len(), func(i int) error {
+			x := v.Index(i)
+			if iface && w.variants && !x.IsNil() {
+				return leaf(w.variant(x.Elem()))
+			}
+			return e.encode(x, w)
+		})
+	}
+	return leaf(w.value(v))
+}
+
+// unwrapLimit recovers errResponseTooLarge from errors returned by json.Encoder
+func unwrapLimit(err error) error {
+	if errors.Is(err, errResponseTooLarge) {
+		return errResponseTooLarge
+	}
+	return err
+}
+
+// KindRequestTooLarge is the Kind of Error sent when request body exceeds MaxBodyBytes
+const KindRequestTooLarge = "request_too_large"
+
+// MaxBodyBytes limits size of request bodies, 10MB by default. Zero or negative
+// means no limit. API.MaxBodyBytes overrides it per route.
+//
+// Registered APIs reject requests with larger Content-Length early, without reading
+// the body. Otherwise reading stops at the limit, and errors returned by the handler
+// for the broken body, like the one of Bind, are sent as 413 Error of
+// KindRequestTooLarge.
+var MaxBodyBytes int64 = 10 << 20
+
+// maxDrain limits bytes of request body discarded after handler returns
+const maxDrain = 256 << 10
+
+var errRequestTooLarge = Error{
+	Code:    http.StatusRequestEntityTooLarge,
+	Message: "Request body is too large",
+	Kind:    KindRequestTooLarge,
+}
+
+func (h *HTTP) maxBodyBytes() int64 {
+	if h.api != nil && h.api.MaxBodyBytes != 0 {
+		return h.api.MaxBodyBytes
+	}
+	return MaxBodyBytes
+}
+
+// bodyLimiter applies maxBodyBytes with http.MaxBytesReader at the first Read, as
+// the API is not known yet when request body is wrapped.
+type bodyLimiter struct {
+	io.ReadCloser
+	w http.ResponseWriter // from net/http, which closes the connection once the limit is hit
+	h *HTTP
+	r io.Reader
+}
+
+func (b *bodyLimiter) Read(p []byte) (int, error) {
+	if b.r == nil {
+		b.r = b.ReadCloser
+		if n := b.h.maxBodyBytes(); n > 0 {
+			b.r = http.MaxBytesReader(b.w, b.ReadCloser, n)
+		}
+	}
+	n, err := b.r.Read(p)
+	b.h.addBodyRead(n)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		b.h.setBodyErr(&errRequestTooLarge)
+	}
+	return n, err
+}
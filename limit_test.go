@@ -0,0 +1,350 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+type limitInner struct {
+	Name  string `json:"name,omitempty"`
+	Count int64  `json:"count,string"`
+}
+
+type limitSample struct {
+	ID      int64                  `json:"id"`
+	Big     int64                  `json:"big" jsstring:"true"`
+	Skip    string                 `json:"-"`
+	Tags    []string               `json:"tags"`
+	Nil     []int                  `json:"nil"`
+	Raw     []byte                 `json:"raw"`
+	When    time.Time              `json:"when"`
+	Inner   *limitInner            `json:"inner"`
+	Empty   *limitInner            `json:"empty"`
+	Props   map[string]interface{} `json:"props"`
+	ByInt   map[int]string         `json:"by_int"`
+	Message json.RawMessage        `json:"message"`
+	limitInner
+}
+
+func TestEncodeLimitedMatchesRewriter(t *testing.T) {
+	v := []interface{}{
+		limitSample{
+			ID: 1, Big: 1 << 60, Skip: "x", Tags: []string{"a", "<b>"}, Raw: []byte("hi"),
+			When:       time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Inner:      &limitInner{Name: "in", Count: 3},
+			Props:      map[string]interface{}{"z": 1, "a": []int{1, 2}, "m": nil},
+			ByInt:      map[int]string{10: "ten", 2: "two"},
+			Message:    json.RawMessage(`{"ok":true}`),
+			limitInner: limitInner{Count: 7},
+		},
+		"text", 1.5, nil, [2]bool{true, false},
+	}
+	for _, rw := range []rewriter{{}, {int64String: true}, {omit: OmitAllZero}, {omit: OmitEmptyContainers}} {
+		for _, x := range []interface{}{v, v[0]} {
+			want := &bytes.Buffer{}
+			if err := newEncoder(want).Encode(rw.rewrite(x)); err != nil {
+				t.Fatal(err)
+			}
+			got, err := encodeLimited(x, rw, 1<<20)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != want.String() {
+				t.Errorf("%+v:\ngot  %s\nwant %s", rw, got, want)
+			}
+		}
+	}
+}
+
+func TestEncodeLimitedQuotedNilPointer(t *testing.T) {
+	id := int64(7)
+	for _, v := range []interface{}{quotedPointers{}, quotedPointers{ID: &id}, []quotedPointers{{}}} {
+		for _, rw := range []rewriter{{}, {int64String: true}, {omit: OmitAllZero}} {
+			want := &bytes.Buffer{}
+			if err := newEncoder(want).Encode(rw.rewrite(v)); err != nil {
+				t.Fatal(err)
+			}
+			got, err := encodeLimited(v, rw, 1<<20)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != want.String() {
+				t.Errorf("%+v:\ngot  %s\nwant %s", rw, got, want)
+			}
+		}
+		plain, _ := json.Marshal(v)
+		if got, _ := encodeLimited(v, rewriter{}, 1<<20); string(got) != string(plain)+"\n" {
+			t.Errorf("got %s, encoding/json sends %s", got, plain)
+		}
+	}
+}
+
+func TestEncodeLimitedStopsAtLimit(t *testing.T) {
+	huge := struct {
+		Data map[string][]int `json:"data"`
+	}{map[string][]int{"items": make([]int, 4<<20)}}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	_, err := encodeLimited(huge, rewriter{}, 4096)
+	runtime.ReadMemStats(&after)
+	if e, ok := err.(Error); !ok || e.Kind != KindResponseTooLarge {
+		t.Fatalf("err = %v, want Error of KindResponseTooLarge", err)
+	}
+	if alloc := after.TotalAlloc - before.TotalAlloc; alloc > 1<<20 {
+		t.Errorf("allocated %d bytes encoding past the limit", alloc)
+	}
+}
+
+// withReported records errors reported to OnError by path
+func withReported() (map[string][]error, func()) {
+	reported := map[string][]error{}
+	OnError = func(httpData *HTTP, err error) {
+		reported[httpData.Request.URL.Path] = append(reported[httpData.Request.URL.Path], err)
+	}
+	return reported, func() { OnError = nil }
+}
+
+// tooLarge reports whether err is an Error of KindResponseTooLarge
+func tooLarge(err error) bool {
+	var e Error
+	return errors.As(err, &e) && e.Kind == KindResponseTooLarge
+}
+
+func TestMaxResponseBytes(t *testing.T) {
+	reported, restore := withReported()
+	defer restore()
+	m := NewMuxTest([]API{
+		{Pattern: "/big", MaxResponseBytes: 100, APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return map[string]interface{}{"items": make([]int, 1000)}, nil
+		}},
+		{Pattern: "/raw", MaxResponseBytes: 100, APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return json.RawMessage(`"` + strings.Repeat("x", 998) + `"`), nil
+		}},
+		{Pattern: "/small", MaxResponseBytes: 100, APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return map[string]interface{}{"items": []int{1, 2}}, nil
+		}},
+	})
+
+	for uri, message := range map[string]string{
+		"/big": "Response of at least 101 bytes exceeds the limit of 100 bytes",
+		"/raw": "Response of at least 1000 bytes exceeds the limit of 100 bytes",
+	} {
+		resp, err := m.Get(uri, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body ErrorBody
+		if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Code != http.StatusInternalServerError || body.Error.Kind != KindResponseTooLarge {
+			t.Errorf("%s: got %d %s", uri, resp.Code, resp.Body)
+		}
+		if body.Error.Message != message {
+			t.Errorf("%s: message = %q", uri, body.Error.Message)
+		}
+		if errs := reported[uri]; len(errs) != 1 || !tooLarge(errs[0]) || !strings.Contains(errs[0].Error(), message) {
+			t.Errorf("%s: reported %v", uri, errs)
+		}
+	}
+
+	resp, err := m.Get("/small", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != http.StatusOK || strings.TrimSpace(resp.Body.String()) != `{"items":[1,2]}` || len(reported["/small"]) != 0 {
+		t.Errorf("got %d %s", resp.Code, resp.Body)
+	}
+}
+
+func TestMaxResponseBytesStreams(t *testing.T) {
+	reported, restore := withReported()
+	defer restore()
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+	big := strings.Repeat("x", 1000)
+	var sendErr error
+	m := NewMuxTest([]API{
+		{Pattern: "/stream", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return Stream{Produce: produce(1000, nil)}, nil
+		}},
+		{Pattern: "/stream/first", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return Stream{Produce: func(emit func(v interface{}) error) error { return emit(big) }}, nil
+		}},
+		{Pattern: "/raw", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return Raw{ContentType: "text/plain", Body: strings.NewReader(big)}, nil
+		}},
+		{Pattern: "/sse", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			w, err := httpData.SSE()
+			if err != nil {
+				return nil, err
+			}
+			for i := 0; i < 100 && sendErr == nil; i++ {
+				sendErr = w.SendJSON("progress", i)
+			}
+			w.SendJSON("done", nil)
+			return nil, nil
+		}},
+		{Pattern: "/json", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return nil, httpData.WriteJSON(http.StatusOK, big)
+		}},
+	})
+	defer func(n int64) { MaxResponseBytes = n }(MaxResponseBytes)
+	MaxResponseBytes = 100
+
+	// cut after headers are sent
+	for _, uri := range []string{"/stream", "/raw", "/sse"} {
+		resp, _ := m.Get(uri, "")
+		if resp.Code != http.StatusOK || resp.Body.Len() > 100 || resp.Body.Len() == 0 {
+			t.Errorf("%s: got %d with %d bytes", uri, resp.Code, resp.Body.Len())
+		}
+		if errs := reported[uri]; len(errs) != 1 || !tooLarge(errs[0]) {
+			t.Errorf("%s: reported %v", uri, errs)
+		}
+	}
+	if !tooLarge(sendErr) {
+		t.Errorf("SendJSON beyond the limit: err = %v", sendErr)
+	}
+
+	// rejected before anything is sent
+	for _, uri := range []string{"/stream/first", "/json"} {
+		resp, _ := m.Get(uri, "")
+		var body ErrorBody
+		json.Unmarshal(resp.Body.Bytes(), &body)
+		if resp.Code != http.StatusInternalServerError || body.Error.Kind != KindResponseTooLarge {
+			t.Errorf("%s: got %d %s", uri, resp.Code, resp.Body)
+		}
+		if errs := reported[uri]; len(errs) != 1 || !tooLarge(errs[0]) {
+			t.Errorf("%s: reported %v", uri, errs)
+		}
+	}
+}
+
+func TestMaxResponseBytesProxy(t *testing.T) {
+	reported, restore := withReported()
+	defer restore()
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(os.Stderr)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunked" {
+			// unknown length
+			w.(http.Flusher).Flush()
+		}
+		io.WriteString(w, strings.Repeat("x", 1000))
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	m := NewMuxTest([]API{{Pattern: "/", APIHandler: Proxy(u, ProxyOpts{}), MaxResponseBytes: 100}})
+
+	resp, _ := m.Get("/sized", "")
+	var body ErrorBody
+	json.Unmarshal(resp.Body.Bytes(), &body)
+	if resp.Code != http.StatusInternalServerError || body.Error.Message != "Response of at least 1000 bytes exceeds the limit of 100 bytes" {
+		t.Errorf("sized: got %d %s", resp.Code, resp.Body)
+	}
+	resp, _ = m.Get("/chunked", "")
+	if resp.Code != http.StatusOK || resp.Body.Len() > 100 {
+		t.Errorf("chunked: got %d with %d bytes", resp.Code, resp.Body.Len())
+	}
+	for _, uri := range []string{"/sized", "/chunked"} {
+		if errs := reported[uri]; len(errs) != 1 || !tooLarge(errs[0]) {
+			t.Errorf("%s: reported %v", uri, errs)
+		}
+	}
+}
+
+// countingReader serves n bytes of spaces, counting bytes read
+type countingReader struct {
+	n, read int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	if r.read >= r.n {
+		return 0, io.EOF
+	}
+	if len(p) > r.n-r.read {
+		p = p[:r.n-r.read]
+	}
+	for i := range p {
+		p[i] = ' '
+	}
+	r.read += len(p)
+	return len(p), nil
+}
+
+func TestMaxBodyBytes(t *testing.T) {
+	var decoded []interface{}
+	handler := func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		var v interface{}
+		if err := Bind(dec, httpData, &v); err != nil {
+			return nil, err
+		}
+		decoded = append(decoded, v)
+		return "ok", nil
+	}
+	m := NewMuxTest([]API{
+		{Pattern: "/small", APIHandler: handler, MaxBodyBytes: 16},
+		{Pattern: "/unlimited", APIHandler: handler, MaxBodyBytes: -1},
+		{Pattern: "/default", APIHandler: handler},
+	})
+	defer func(n int64) { MaxBodyBytes = n }(MaxBodyBytes)
+	MaxBodyBytes = 64
+
+	big := `["` + strings.Repeat("x", 1000) + `"]`
+	for _, c := range []struct {
+		uri    string
+		length bool // send Content-Length
+	}{{"/small", true}, {"/small", false}, {"/default", true}, {"/default", false}} {
+		req, _ := http.NewRequest("POST", c.uri, strings.NewReader(big))
+		if !c.length {
+			req.ContentLength = -1
+		}
+		resp := m.Do(req)
+		var body ErrorBody
+		json.Unmarshal(resp.Body.Bytes(), &body)
+		if resp.Code != http.StatusRequestEntityTooLarge || body.Error.Kind != KindRequestTooLarge {
+			t.Errorf("%s (content length %v): unexpected response %d: %s", c.uri, c.length, resp.Code, resp.Body)
+		}
+	}
+	if len(decoded) != 0 {
+		t.Errorf("handler decoded oversized bodies: %v", decoded)
+	}
+
+	for _, uri := range []string{"/small", "/unlimited", "/default"} {
+		if resp, _ := m.Post(uri, "", `["small"]`); resp.Code != http.StatusOK {
+			t.Errorf("%s: small body is rejected: %d %s", uri, resp.Code, resp.Body)
+		}
+	}
+	if resp, _ := m.Post("/unlimited", "", big); resp.Code != http.StatusOK {
+		t.Errorf("unlimited body is rejected: %d %s", resp.Code, resp.Body)
+	}
+}
+
+func TestDrainIsCapped(t *testing.T) {
+	body := &countingReader{n: 100 << 20}
+	h := HandlerTest(func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		enc.Encode("ignored the body")
+	})
+	defer func(n int64) { MaxBodyBytes = n }(MaxBodyBytes)
+	MaxBodyBytes = 0
+
+	req, _ := http.NewRequest("POST", "/", body)
+	h.With().Do(req)
+	if body.read > maxDrain+64<<10 {
+		t.Errorf("%d bytes are drained", body.read)
+	}
+}
@@ -54,11 +54,12 @@ package jsonapi
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
-	"strings"
 )
 
 // HTTP holds original data from http request handler.
@@ -66,19 +67,146 @@ import (
 type HTTP struct {
 	http.ResponseWriter
 	*http.Request
+	api *API // the API being served, set by Register
+
+	replied bool // response has been sent by WriteJSON or Fail
+
+	rawBody []byte // buffered request body, see RawBody
+	rawErr  error
+
+	rejected bool // request is rejected before reading body, see RejectEarly
+
+	body *bodyState // updated by readers of request body, see bodyState
+
+	vary []string // request headers the response varies on, see Vary
+
+	encodings []string // overrides API.Encodings, see GzipHandler
+
+	failure error // error sent to client, see Tracer
+
+	hijacked bool // connection is taken over by handler, see Hijacked
+
+	codec Codec // negotiated if not JSON, see RegisterCodec
+}
+
+// ErrReplied is returned by WriteJSON and Fail if the response has been sent
+var ErrReplied = errors.New("jsonapi: response has already been sent")
+
+// encoder creates an encoder writing to the response
+func (h *HTTP) encoder() *json.Encoder {
+	return h.configureEncoder(json.NewEncoder(h.ResponseWriter))
+}
+
+// WriteJSON sends v to client with status code. It can be called only once, and
+// the result returned by APIHandler is ignored after calling it. Like results of
+// APIHandler, v is limited by MaxResponseBytes.
+//
+//     if err := httpData.WriteJSON(http.StatusCreated, user); err != nil {
+//         log.Print(err)
+//     }
+func (h *HTTP) WriteJSON(status int, v interface{}) error {
+	if h.replied {
+		return ErrReplied
+	}
+	h.replied = true
+
+	var buf []byte
+	var err error
+	if limit := h.maxResponseBytes(); limit > 0 {
+		buf, err = encodeLimited(v, rewriterFor(h), limit)
+	} else if buf, err = marshal(rewriterFor(h).rewrite(v)); err == nil {
+		buf = append(buf, '\n')
+	}
+	if err != nil {
+		writeError(h.encoder(), h, err)
+		return err
+	}
+	h.WriteHeader(status)
+	_, err = h.ResponseWriter.Write(h.pretty(buf))
+	return err
+}
+
+// RejectEarly refuses the request with err without reading the request body, and the
+// body is not drained after handler returns either. Middlewares checking headers
+// should use it.
+//
+// Clients sending "Expect: 100-continue" wait for the server before uploading the body.
+// net/http sends "100 Continue" when the body is read for the first time, so clients
+// rejected early never upload the body. Built-in checks which need the body, like
+// RejectDuplicateKeys and VerifyDigest, buffer it with RawBody, which lets the client
+// continue.
+func (h *HTTP) RejectEarly(err Error) {
+	h.rejected = true
+	h.Fail(err)
+}
+
+// Fail sends err to client the same way as errors returned by APIHandler. Like
+// WriteJSON, it can be called only once.
+func (h *HTTP) Fail(err Error) error {
+	if h.replied {
+		return ErrReplied
+	}
+	h.replied = true
+
+	writeError(h.encoder(), h, err)
+	return nil
 }
 
 // HTTPHandler converts our json api handler to be used with net/http package.
 type HTTPHandler func(*json.Encoder, *json.Decoder, *HTTP)
 
 func (f HTTPHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	h := &HTTP{w, r}
-	e := json.NewEncoder(w)
-	d := json.NewDecoder(r.Body)
-	w.Header().Add("Content-Type", "application/json")
-	f(e, d, h)
-	if r.Body != nil {
-		ioutil.ReadAll(r.Body) // drain data to enable socket reuse
+	if r.Body == nil {
+		r.Body = http.NoBody
+	}
+	h := &HTTP{body: &bodyState{}}
+	decompressBody(h, r)
+	r.Body = &bodyLimiter{ReadCloser: r.Body, w: w, h: h}
+	if jsonBody(r) {
+		r.Body = &bomReader{ReadCloser: r.Body}
+	}
+	body := r.Body
+	undecodable := false // in format of codec not supporting requests
+	if c := requestCodec(r); c != nil {
+		if dec := c.NewDecoder(body); dec != nil {
+			r.Body = &codecReader{ReadCloser: body, dec: dec}
+		} else {
+			undecodable = r.ContentLength != 0
+		}
+	}
+	if logger != nil {
+		sw := &statusWriter{ResponseWriter: w}
+		w = sw
+		defer h.logRequest(sw, DefaultClock.Now())
+	}
+	r, cancel := withClientTimeout(w, r)
+	defer cancel()
+
+	h.Request = r
+	rw := &responseWriter{ResponseWriter: w, h: h}
+	h.ResponseWriter = rw
+	e := h.encoder()
+	d := h.newDecoder(r.Body)
+	codec, negotiable := negotiateCodec(r)
+	if negotiable {
+		h.Vary("Accept")
+	}
+	if codec != JSONCodec {
+		rw.codec, h.codec = codec, codec
+	}
+	w.Header().Add("Content-Type", codec.ContentType())
+	if err := h.checkEncoding(); err != nil {
+		writeError(e, h, err)
+	} else if undecodable || unknownBody(r) {
+		h.RejectEarly(errUnknownMediaType(r))
+	} else if RequireJSONContentType && !acceptableBody(r) {
+		h.RejectEarly(errUnsupportedMediaType)
+	} else {
+		f(e, d, h)
+	}
+	rw.finish()
+	if !h.rejected && !h.hijacked && h.bodyErr() == nil {
+		io.CopyN(ioutil.Discard, body, maxDrain) // drain data to enable socket reuse
 	}
 }
 
@@ -92,43 +220,66 @@ type HandlerTest func(*json.Encoder, *json.Decoder, *HTTP)
 
 // Get helps you to test with HTTP GET request
 func (f HandlerTest) Get(uri, cookie string) (*httptest.ResponseRecorder, error) {
-	ret := httptest.NewRecorder()
-	req, err := http.NewRequest("GET", uri, nil)
-	if err != nil {
-		return nil, err
-	}
-	if cookie != "" {
-		req.Header.Add("Cookie", cookie)
-	}
-	HTTPHandler(f).ServeHTTP(ret, req)
-	return ret, err
+	return f.With().Get(uri, cookie)
 }
 
 // Post helps you to test with post data
 func (f HandlerTest) Post(uri, cookie, data string) (*httptest.ResponseRecorder, error) {
-	ret := httptest.NewRecorder()
-	req, err := http.NewRequest("POST", uri, strings.NewReader(data))
-	if err != nil {
-		return nil, err
-	}
-	if cookie != "" {
-		req.Header.Add("Cookie", cookie)
-	}
-	HTTPHandler(f).ServeHTTP(ret, req)
-	return ret, err
+	return f.With().Post(uri, cookie, data)
 }
 
 // PostJSON helps you to test with json encoded post data
 func (f HandlerTest) PostJSON(uri, cookie string, data interface{}) (ret *httptest.ResponseRecorder, err error) {
-	buf, err := json.Marshal(data)
-	if err != nil {
-		return
-	}
+	return f.With().PostJSON(uri, cookie, data)
+}
+
+// Put helps you to test with HTTP PUT request
+func (f HandlerTest) Put(uri, cookie, data string) (*httptest.ResponseRecorder, error) {
+	return f.With().Put(uri, cookie, data)
+}
+
+// PutJSON helps you to test with json encoded put data
+func (f HandlerTest) PutJSON(uri, cookie string, data interface{}) (*httptest.ResponseRecorder, error) {
+	return f.With().PutJSON(uri, cookie, data)
+}
+
+// Patch helps you to test with HTTP PATCH request
+func (f HandlerTest) Patch(uri, cookie, data string) (*httptest.ResponseRecorder, error) {
+	return f.With().Patch(uri, cookie, data)
+}
+
+// PatchJSON helps you to test with json encoded patch data
+func (f HandlerTest) PatchJSON(uri, cookie string, data interface{}) (*httptest.ResponseRecorder, error) {
+	return f.With().PatchJSON(uri, cookie, data)
+}
+
+// Delete helps you to test with HTTP DELETE request. Empty data sends no body.
+func (f HandlerTest) Delete(uri, cookie, data string) (*httptest.ResponseRecorder, error) {
+	return f.With().Delete(uri, cookie, data)
+}
+
+// DeleteJSON helps you to test with HTTP DELETE request with json encoded body
+func (f HandlerTest) DeleteJSON(uri, cookie string, data interface{}) (*httptest.ResponseRecorder, error) {
+	return f.With().DeleteJSON(uri, cookie, data)
+}
+
+// GetInto helps you to test with HTTP GET request, decoding the response into out
+func (f HandlerTest) GetInto(uri, cookie string, out interface{}) (*httptest.ResponseRecorder, error) {
+	return f.With().GetInto(uri, cookie, out)
+}
+
+// PostJSONInto helps you to test with json encoded post data, decoding the response
+// into out
+func (f HandlerTest) PostJSONInto(uri, cookie string, data, out interface{}) (*httptest.ResponseRecorder, error) {
+	return f.With().PostJSONInto(uri, cookie, data, out)
+}
 
-	return f.Post(uri, cookie, string(buf))
+// PostMultipart helps you to test with multipart/form-data post data
+func (f HandlerTest) PostMultipart(uri, cookie string, fields map[string]string, files map[string][]byte) (*httptest.ResponseRecorder, error) {
+	return f.With().PostMultipart(uri, cookie, fields, files)
 }
 
 // PostForm helps you to test with form encoded post data
 func (f HandlerTest) PostForm(uri, cookie string, data url.Values) (*httptest.ResponseRecorder, error) {
-	return f.Post(uri, cookie, data.Encode())
+	return f.With().PostForm(uri, cookie, data)
 }
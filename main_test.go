@@ -0,0 +1,135 @@
+package jsonapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWriteJSON(t *testing.T) {
+	var second error
+	h := HandlerTest(func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		if err := httpData.WriteJSON(http.StatusCreated, map[string]int{"id": 1}); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+		second = httpData.WriteJSON(http.StatusOK, "again")
+	})
+
+	resp, _ := h.Get("/", "")
+	if resp.Code != http.StatusCreated || resp.Body.String() != `{"id":1}`+"\n" {
+		t.Errorf("got %d %s", resp.Code, resp.Body)
+	}
+	if second != ErrReplied {
+		t.Errorf("expected ErrReplied for second call, got %v", second)
+	}
+}
+
+func TestFail(t *testing.T) {
+	var second, third error
+	h := HandlerTest(func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		if err := httpData.Fail(E403.SetData("Nope")); err != nil {
+			t.Errorf("unexpected error: %s", err)
+		}
+		second = httpData.Fail(E500)
+		third = httpData.WriteJSON(http.StatusOK, "ok")
+	})
+
+	resp, _ := h.Get("/", "")
+	var body ErrorBody
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatalf("body is not a single error envelope: %s", resp.Body)
+	}
+	if resp.Code != http.StatusForbidden || body.Error.Code != http.StatusForbidden || body.Error.Message != "Nope" {
+		t.Errorf("got %d %s", resp.Code, resp.Body)
+	}
+	if second != ErrReplied || third != ErrReplied {
+		t.Errorf("expected ErrReplied for later calls, got %v and %v", second, third)
+	}
+}
+
+func TestWriteJSONInAPIHandler(t *testing.T) {
+	m := NewMuxTest([]API{{Pattern: "/", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		httpData.WriteJSON(http.StatusAccepted, "queued")
+		return "ignored", nil
+	}}})
+	resp, _ := m.Get("/", "")
+	if resp.Code != http.StatusAccepted || resp.Body.String() != `"queued"`+"\n" {
+		t.Errorf("got %d %s", resp.Code, resp.Body)
+	}
+}
+
+func TestWriteJSONPretty(t *testing.T) {
+	PrettyQuery = true
+	defer func() { PrettyQuery = false }()
+	h := HandlerTest(func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		httpData.WriteJSON(http.StatusOK, map[string]int{"a": 1})
+	})
+	resp, _ := h.Get("/?pretty", "")
+	if resp.Body.String() != "{\n  \"a\": 1\n}\n" {
+		t.Errorf("not pretty printed: %q", resp.Body)
+	}
+}
+
+// expectContinue sends headers of a request with "Expect: 100-continue" to srv,
+// and returns status of the first response, which is 100 if the server asks for
+// the body
+func expectContinue(t *testing.T, srv *httptest.Server, uri string, headers map[string]string) int {
+	t.Helper()
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	fmt.Fprintf(conn, "POST %s HTTP/1.1\r\nHost: example.com\r\nContent-Length: 1000\r\nExpect: 100-continue\r\n", uri)
+	for k, v := range headers {
+		fmt.Fprintf(conn, "%s: %s\r\n", k, v)
+	}
+	fmt.Fprint(conn, "\r\n")
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode
+}
+
+func TestRejectEarly(t *testing.T) {
+	mux := http.NewServeMux()
+	Register([]API{
+		{Pattern: "/json", APIHandler: decodeAny, RequireJSONContentType: true},
+		{Pattern: "/small", APIHandler: decodeAny, MaxBodyBytes: 10},
+	}, mux)
+	mux.Handle("/auth", HTTPHandler(Auth(func(token string, h *HTTP) error {
+		if token != "good" {
+			return E401
+		}
+		return nil
+	})(APIHandler(decodeAny).Handler)))
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	jsonType := map[string]string{"Content-Type": "application/json"}
+	cases := []struct {
+		uri     string
+		headers map[string]string
+		status  int
+	}{
+		{"/json", map[string]string{"Content-Type": "text/plain"}, http.StatusUnsupportedMediaType},
+		{"/json", jsonType, http.StatusContinue},
+		{"/small", jsonType, http.StatusRequestEntityTooLarge},
+		{"/auth", jsonType, http.StatusUnauthorized},
+		{"/auth", map[string]string{"Content-Type": "application/json", "Authorization": "Bearer good"}, http.StatusContinue},
+	}
+	for _, c := range cases {
+		if status := expectContinue(t, srv, c.uri, c.headers); status != c.status {
+			t.Errorf("%s %v: expected %d, got %d", c.uri, c.headers, c.status, status)
+		}
+	}
+}
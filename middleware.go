@@ -0,0 +1,109 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+)
+
+// Middleware wraps an HTTPHandler to add features like authentication or logging.
+// It can stop the request by not calling next.
+//
+//     func auth(next jsonapi.HTTPHandler) jsonapi.HTTPHandler {
+//         return func(enc *json.Encoder, dec *json.Decoder, httpData *jsonapi.HTTP) {
+//             if !valid(httpData.Request) {
+//                 httpData.Fail(jsonapi.E401)
+//                 return
+//             }
+//             next(enc, dec, httpData)
+//         }
+//     }
+type Middleware func(next HTTPHandler) HTTPHandler
+
+// Chain wraps h with mws, the first one is the outermost, which runs first.
+//
+//     h := jsonapi.Chain(jsonapi.APIHandler(myHandler).Handler, jsonapi.RequestLog(log.Printf), auth)
+func Chain(h HTTPHandler, mws ...Middleware) HTTPHandler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// RequestLog logs method, path, status code and duration of every request with
+// logf, like log.Printf.
+func RequestLog(logf func(format string, args ...interface{})) Middleware {
+	return func(next HTTPHandler) HTTPHandler {
+		return func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+			start := DefaultClock.Now()
+			w := &statusWriter{ResponseWriter: httpData.ResponseWriter}
+			httpData.ResponseWriter = w
+			defer func() {
+				httpData.ResponseWriter = w.ResponseWriter
+				status := w.status
+				v := recover()
+				switch {
+				case v != nil && status == 0:
+					status = http.StatusInternalServerError
+				case status == 0:
+					status = http.StatusOK
+				}
+				logf("%s %s %d %s", httpData.Request.Method, httpData.Request.URL.RequestURI(),
+					status, DefaultClock.Now().Sub(start).Round(time.Microsecond))
+				if v != nil {
+					panic(v)
+				}
+			}()
+			next(httpData.configureEncoder(json.NewEncoder(w)), dec, httpData)
+		}
+	}
+}
+
+// errPanic is sent to client by Recover, details of the panic are not leaked
+var errPanic = E500
+
+// Recover sends a 500 error in JSON format if the handler panics, instead of
+// dropping the connection. The panic and stack trace are logged with logf if it
+// is not nil, and the panic is reported to OnError as an error.
+//
+// If the handler has sent the response header before panicking, nothing more
+// is written to client. Panics with http.ErrAbortHandler are not recovered.
+func Recover(logf func(format string, args ...interface{})) Middleware {
+	return func(next HTTPHandler) HTTPHandler {
+		return func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+			w := &statusWriter{ResponseWriter: httpData.ResponseWriter}
+			httpData.ResponseWriter = w
+			defer func() {
+				httpData.ResponseWriter = w.ResponseWriter
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				if logf != nil {
+					logf("jsonapi: panic serving %s %s: %v\n%s", httpData.Request.Method, httpData.Request.URL, v, debug.Stack())
+				}
+
+				var err error
+				if e, ok := v.(error); ok {
+					err = fmt.Errorf("panic: %w", e)
+				} else {
+					err = fmt.Errorf("panic: %v", v)
+				}
+				err = withStack(err)
+				if w.status != 0 || httpData.replied {
+					// too late to change the response
+					reportError(httpData, http.StatusInternalServerError, err)
+					return
+				}
+				httpData.replied = true
+				writeInternalError(httpData, errPanic, err)
+			}()
+			next(httpData.configureEncoder(json.NewEncoder(w)), dec, httpData)
+		}
+	}
+}
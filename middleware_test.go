@@ -0,0 +1,186 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func traceMiddleware(name string, trace *[]string) Middleware {
+	return func(next HTTPHandler) HTTPHandler {
+		return func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+			*trace = append(*trace, "->"+name)
+			next(enc, dec, httpData)
+			*trace = append(*trace, "<-"+name)
+		}
+	}
+}
+
+func denyMiddleware(next HTTPHandler) HTTPHandler {
+	return func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		if httpData.Request.Header.Get("Authorization") == "" {
+			httpData.Fail(E401)
+			return
+		}
+		next(enc, dec, httpData)
+	}
+}
+
+func TestChain(t *testing.T) {
+	var trace []string
+	h := Chain(func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		trace = append(trace, "handler")
+		enc.Encode("ok")
+	}, traceMiddleware("a", &trace), traceMiddleware("b", &trace), denyMiddleware)
+
+	resp, _ := HandlerTest(h).Get("/", "")
+	if resp.Code != http.StatusUnauthorized || strings.Join(trace, " ") != "->a ->b <-b <-a" {
+		t.Errorf("not short-circuited: %d %v", resp.Code, trace)
+	}
+
+	trace = nil
+	resp, _ = HandlerTest(h).With(Headers{"Authorization": "Bearer x"}).Get("/", "")
+	if resp.Code != http.StatusOK || strings.Join(trace, " ") != "->a ->b handler <-b <-a" {
+		t.Errorf("unexpected order: %d %v", resp.Code, trace)
+	}
+}
+
+func TestAPIMiddlewares(t *testing.T) {
+	var trace []string
+	m := NewMuxTest([]API{
+		{Pattern: "/private", APIHandler: okAPI, Middlewares: []Middleware{traceMiddleware("private", &trace), denyMiddleware}},
+		{Pattern: "/public", APIHandler: okAPI},
+	})
+	if resp, _ := m.Get("/private", ""); resp.Code != http.StatusUnauthorized {
+		t.Errorf("middleware is not applied: %d", resp.Code)
+	}
+	if resp, _ := m.Get("/public", ""); resp.Code != http.StatusOK {
+		t.Errorf("middleware of other route is applied: %d", resp.Code)
+	}
+	if strings.Join(trace, " ") != "->private <-private" {
+		t.Errorf("unexpected trace %v", trace)
+	}
+}
+
+func TestRequestLog(t *testing.T) {
+	var logs []string
+	logf := func(format string, args ...interface{}) { logs = append(logs, fmt.Sprintf(format, args...)) }
+	m := NewMuxTest([]API{
+		{Pattern: "/ok", APIHandler: okAPI, Middlewares: []Middleware{RequestLog(logf)}},
+		{Pattern: "/missing", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return nil, E404
+		}, Middlewares: []Middleware{RequestLog(logf)}},
+	})
+	m.Get("/ok?x=1", "")
+	m.Post("/missing", "", "{}")
+	if len(logs) != 2 || !strings.HasPrefix(logs[0], "GET /ok?x=1 200 ") || !strings.HasPrefix(logs[1], "POST /missing 404 ") {
+		t.Errorf("unexpected logs %q", logs)
+	}
+}
+
+func TestRecover(t *testing.T) {
+	var reported []error
+	OnError = func(httpData *HTTP, err error) { reported = append(reported, err) }
+	defer func() { OnError = nil }()
+	var logs []string
+	logf := func(format string, args ...interface{}) { logs = append(logs, fmt.Sprintf(format, args...)) }
+
+	boom := errors.New("boom")
+	recovered := func(h APIHandler) API {
+		return API{APIHandler: h, Middlewares: []Middleware{Recover(logf)}}
+	}
+	apis := []API{
+		recovered(func(dec *json.Decoder, httpData *HTTP) (interface{}, error) { panic(boom) }),
+		recovered(func(dec *json.Decoder, httpData *HTTP) (interface{}, error) { panic("bad index") }),
+		recovered(func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			httpData.WriteJSON(http.StatusAccepted, "sent")
+			panic("too late")
+		}),
+		recovered(func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			httpData.ResponseWriter.Write([]byte(`{"partial":`))
+			panic("half way")
+		}),
+	}
+	for i, p := range []string{"/error", "/string", "/replied", "/partial"} {
+		apis[i].Pattern = p
+	}
+	m := NewMuxTest(apis)
+
+	for _, uri := range []string{"/error", "/string"} {
+		resp, _ := m.Get(uri, "")
+		var body ErrorBody
+		json.Unmarshal(resp.Body.Bytes(), &body)
+		if resp.Code != http.StatusInternalServerError || body.Error != (ErrorInfo{Code: 500, Message: E500.Message}) {
+			t.Errorf("%s: unexpected response %d: %s", uri, resp.Code, resp.Body)
+		}
+	}
+	resp, _ := m.Get("/replied", "")
+	if resp.Code != http.StatusAccepted || strings.TrimSpace(resp.Body.String()) != `"sent"` {
+		t.Errorf("response is changed after panic: %d %s", resp.Code, resp.Body)
+	}
+	resp, _ = m.Get("/partial", "")
+	if resp.Code != http.StatusOK || resp.Body.String() != `{"partial":` {
+		t.Errorf("partial response is changed after panic: %d %s", resp.Code, resp.Body)
+	}
+
+	if len(logs) != 4 || !strings.Contains(logs[0], "panic serving GET /error: boom") ||
+		!strings.Contains(logs[1], "panic serving GET /string: bad index") || !strings.Contains(logs[0], "goroutine ") {
+		t.Errorf("unexpected logs %q", logs)
+	}
+	if len(reported) != 4 || !errors.Is(reported[0], boom) || reported[1].Error() != "panic: bad index" ||
+		reported[3].Error() != "panic: half way" {
+		t.Errorf("panics are not reported: %v", reported)
+	}
+	if len(StackTrace(reported[1])) == 0 {
+		t.Errorf("no stack trace of panic")
+	}
+}
+
+func TestRecoverAbort(t *testing.T) {
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Errorf("expected ErrAbortHandler, got %v", v)
+		}
+	}()
+	HandlerTest(Chain(func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		panic(http.ErrAbortHandler)
+	}, Recover(nil))).Get("/", "")
+}
+
+func TestMiddlewaresConfigureEncoder(t *testing.T) {
+	handler := func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		enc.Encode("/search?q=a&b")
+	}
+	middlewares := map[string]Middleware{
+		"Recover":    Recover(nil),
+		"RequestLog": RequestLog(func(string, ...interface{}) {}),
+		"Sampler":    NewSampler(SamplerOpts{}).Middleware,
+	}
+	for name, m := range middlewares {
+		h := HandlerTest(Chain(handler, m))
+		if resp, _ := h.Get("/", ""); resp.Body.String() != `"/search?q=a\u0026b"`+"\n" {
+			t.Errorf("%s: HTML is not escaped by default: %q", name, resp.Body)
+		}
+	}
+
+	SetEscapeHTML(false)
+	defer SetEscapeHTML(true)
+	for name, m := range middlewares {
+		h := HandlerTest(Chain(handler, m))
+		if resp, _ := h.Get("/", ""); resp.Body.String() != `"/search?q=a&b"`+"\n" {
+			t.Errorf("%s: HTML is escaped: %q", name, resp.Body)
+		}
+	}
+
+	SetIndent("", "  ")
+	defer SetIndent("", "")
+	h := HandlerTest(Chain(func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		enc.Encode(map[string]int{"a": 1})
+	}, Recover(nil)))
+	if resp, _ := h.Get("/", ""); resp.Body.String() != "{\n  \"a\": 1\n}\n" {
+		t.Errorf("not indented behind Recover: %q", resp.Body)
+	}
+}
@@ -0,0 +1,201 @@
+package jsonapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+)
+
+// MirrorResponse is a response captured by Mirror
+type MirrorResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	Err    error // error sending shadow request to Upstream, or panic of Shadow
+}
+
+// MirrorOpts configures a Mirror. Zero values are replaced by defaults.
+type MirrorOpts struct {
+	// Shadow is the new implementation receiving copy of requests. If it is nil,
+	// requests are sent to Upstream with Client instead, which defaults to
+	// http.DefaultClient.
+	Shadow   HTTPHandler
+	Upstream *url.URL
+	Client   *http.Client
+
+	Rate     float64 // fraction of requests to mirror, defaults to 1
+	Mutating bool    // also mirror requests other than GET, HEAD and OPTIONS
+	Workers  int     // max concurrent shadow requests, defaults to 4
+	Queue    int     // shadow requests waiting for workers, defaults to 100, extra are dropped
+
+	// Compare is called in worker goroutine with both responses, where body of
+	// primary one is truncated at MaxBody bytes, which defaults to 1MB.
+	Compare func(r *http.Request, primary, shadow MirrorResponse)
+	MaxBody int
+}
+
+// Mirror sends copy of sampled requests to a shadow implementation, so you can
+// compare it with current one before cutting over. Clients get responses from
+// the primary handler only.
+//
+//     mirror := jsonapi.NewMirror(jsonapi.MirrorOpts{
+//         Shadow: jsonapi.APIHandler(newListUsers).Handler,
+//         Rate:   0.1,
+//         Compare: func(r *http.Request, primary, shadow jsonapi.MirrorResponse) {
+//             if !bytes.Equal(primary.Body, shadow.Body) {
+//                 log.Printf("mismatch: %s", r.URL)
+//             }
+//         },
+//     })
+//     http.Handle("/api/users", jsonapi.HTTPHandler(mirror.Middleware(jsonapi.APIHandler(listUsers).Handler)))
+type Mirror struct {
+	opts  MirrorOpts
+	queue chan mirrorJob
+}
+
+type mirrorJob struct {
+	r       *http.Request
+	body    []byte
+	primary MirrorResponse
+}
+
+// NewMirror creates a Mirror and starts its workers
+func NewMirror(opts MirrorOpts) *Mirror {
+	if opts.Rate <= 0 {
+		opts.Rate = 1
+	}
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if opts.Queue <= 0 {
+		opts.Queue = 100
+	}
+	if opts.MaxBody <= 0 {
+		opts.MaxBody = 1 << 20
+	}
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+
+	m := &Mirror{opts: opts, queue: make(chan mirrorJob, opts.Queue)}
+	for i := 0; i < opts.Workers; i++ {
+		go m.work()
+	}
+	return m
+}
+
+// Close stops workers after pending shadow requests are done. Middleware must
+// not be used after Close.
+func (m *Mirror) Close() {
+	close(m.queue)
+}
+
+func (m *Mirror) sampled(r *http.Request) bool {
+	switch r.Method {
+	case "GET", "HEAD", "OPTIONS":
+	default:
+		if !m.opts.Mutating {
+			return false
+		}
+	}
+	return m.opts.Rate >= 1 || rand.Float64() < m.opts.Rate
+}
+
+// Middleware mirrors requests handled by next
+func (m *Mirror) Middleware(next HTTPHandler) HTTPHandler {
+	return func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		if !m.sampled(httpData.Request) {
+			next(enc, dec, httpData)
+			return
+		}
+
+		body, err := httpData.RawBody()
+		if err != nil {
+			// let primary handler deal with it
+			next(enc, dec, httpData)
+			return
+		}
+		dec = httpData.newDecoder(httpData.Request.Body)
+		w := &statusWriter{ResponseWriter: httpData.ResponseWriter}
+		buf := &headBuffer{max: m.opts.MaxBody}
+		httpData.ResponseWriter = &teeWriter{w, buf}
+		next(httpData.configureEncoder(json.NewEncoder(httpData.ResponseWriter)), dec, httpData)
+		httpData.ResponseWriter = w.ResponseWriter
+
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		job := mirrorJob{
+			r:       httpData.Request.Clone(context.WithoutCancel(httpData.Request.Context())),
+			body:    body,
+			primary: MirrorResponse{Status: w.status, Header: w.Header().Clone(), Body: buf.buf},
+		}
+		select {
+		case m.queue <- job:
+		default:
+			// workers are busy, shadow traffic must not slow down clients
+		}
+	}
+}
+
+func (m *Mirror) work() {
+	for job := range m.queue {
+		shadow := m.shadow(job.r, job.body)
+		if m.opts.Compare != nil {
+			job.r.Body = ioutil.NopCloser(bytes.NewReader(job.body))
+			m.opts.Compare(job.r, job.primary, shadow)
+		}
+	}
+}
+
+// shadow sends r to the shadow implementation
+func (m *Mirror) shadow(r *http.Request, body []byte) (ret MirrorResponse) {
+	if m.opts.Shadow != nil {
+		// a buggy shadow must not crash the server
+		defer func() {
+			if v := recover(); v != nil {
+				ret = MirrorResponse{Err: fmt.Errorf("jsonapi: shadow handler panicked: %v", v)}
+			}
+		}()
+		req := r.Clone(r.Context())
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+		rec := httptest.NewRecorder()
+		m.opts.Shadow.ServeHTTP(rec, req)
+		return MirrorResponse{Status: rec.Code, Header: rec.Header(), Body: rec.Body.Bytes()}
+	}
+
+	var headers []string
+	for k := range r.Header {
+		headers = append(headers, k)
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	req := proxyRequest(r.Context(), m.opts.Upstream, ProxyOpts{}, headers, r, body)
+	for _, k := range hopHeaders {
+		req.Header.Del(k)
+	}
+	resp, err := m.opts.Client.Do(req)
+	if err != nil {
+		return MirrorResponse{Err: err}
+	}
+	defer resp.Body.Close()
+	buf, err := ioutil.ReadAll(resp.Body)
+	return MirrorResponse{Status: resp.StatusCode, Header: resp.Header, Body: buf, Err: err}
+}
+
+// teeWriter copies response body into buf
+type teeWriter struct {
+	*statusWriter
+	buf *headBuffer
+}
+
+func (w *teeWriter) Write(p []byte) (int, error) {
+	n, err := w.statusWriter.Write(p)
+	w.buf.Write(p[:n])
+	return n, err
+}
@@ -0,0 +1,116 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+type mirrorResult struct {
+	r               *http.Request
+	primary, shadow MirrorResponse
+}
+
+// mirrorTest creates a Mirror with opts, sending results of Compare to returned
+// channel
+func mirrorTest(opts MirrorOpts) (*Mirror, chan mirrorResult) {
+	ch := make(chan mirrorResult, 10)
+	opts.Compare = func(r *http.Request, primary, shadow MirrorResponse) {
+		ch <- mirrorResult{r, primary, shadow}
+	}
+	return NewMirror(opts), ch
+}
+
+func waitMirror(t *testing.T, ch chan mirrorResult) mirrorResult {
+	select {
+	case ret := <-ch:
+		return ret
+	case <-time.After(5 * time.Second):
+		t.Fatal("Compare is not called")
+	}
+	return mirrorResult{}
+}
+
+func echoHandler(version string, status int) HTTPHandler {
+	return APIHandler(func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		var args map[string]interface{}
+		dec.Decode(&args)
+		httpData.ResponseWriter.Header().Set("X-Version", version)
+		return nil, httpData.WriteJSON(status, map[string]interface{}{"version": version, "args": args})
+	}).Handler
+}
+
+func TestMirrorShadowHandler(t *testing.T) {
+	m, ch := mirrorTest(MirrorOpts{Shadow: echoHandler("new", http.StatusCreated), Mutating: true})
+	defer m.Close()
+
+	h := HandlerTest(m.Middleware(echoHandler("old", http.StatusOK)))
+	resp, _ := h.Post("/api/users?page=2", "", `{"name":"john"}`)
+	if resp.Code != http.StatusOK || resp.Header().Get("X-Version") != "old" ||
+		!strings.Contains(resp.Body.String(), `"version":"old"`) || !strings.Contains(resp.Body.String(), `"name":"john"`) {
+		t.Fatalf("client does not get primary response: %d %s", resp.Code, resp.Body)
+	}
+
+	ret := waitMirror(t, ch)
+	if ret.r.URL.RequestURI() != "/api/users?page=2" {
+		t.Errorf("unexpected request %s", ret.r.URL)
+	}
+	if ret.primary.Status != http.StatusOK || ret.primary.Header.Get("X-Version") != "old" || string(ret.primary.Body) != resp.Body.String() {
+		t.Errorf("unexpected primary response: %+v", ret.primary)
+	}
+	if ret.shadow.Err != nil || ret.shadow.Status != http.StatusCreated ||
+		!strings.Contains(string(ret.shadow.Body), `"version":"new"`) || !strings.Contains(string(ret.shadow.Body), `"name":"john"`) {
+		t.Errorf("unexpected shadow response: %+v %s", ret.shadow, ret.shadow.Body)
+	}
+}
+
+func TestMirrorSkipsMutating(t *testing.T) {
+	m, ch := mirrorTest(MirrorOpts{Shadow: echoHandler("new", http.StatusOK)})
+	defer m.Close()
+
+	h := HandlerTest(m.Middleware(echoHandler("old", http.StatusOK)))
+	if resp, _ := h.Post("/api/users", "", `{"name":"john"}`); !strings.Contains(resp.Body.String(), "john") {
+		t.Errorf("unexpected response: %s", resp.Body)
+	}
+	h.Get("/api/users", "")
+	if ret := waitMirror(t, ch); ret.r.Method != "GET" {
+		t.Errorf("mutating request %s is mirrored", ret.r.Method)
+	}
+}
+
+func TestMirrorShadowPanic(t *testing.T) {
+	m, ch := mirrorTest(MirrorOpts{Shadow: func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		panic("bug in new implementation")
+	}})
+	defer m.Close()
+
+	resp, _ := HandlerTest(m.Middleware(echoHandler("old", http.StatusOK))).Get("/api/users", "")
+	if resp.Code != http.StatusOK {
+		t.Errorf("unexpected status %d", resp.Code)
+	}
+	if ret := waitMirror(t, ch); ret.shadow.Err == nil || !strings.Contains(ret.shadow.Err.Error(), "panicked") {
+		t.Errorf("expected error of panic, got %+v", ret.shadow)
+	}
+}
+
+func TestMirrorUpstream(t *testing.T) {
+	srv := httptest.NewServer(echoHandler("remote", http.StatusAccepted))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	m, ch := mirrorTest(MirrorOpts{Upstream: u, Mutating: true})
+	defer m.Close()
+
+	resp, _ := HandlerTest(m.Middleware(echoHandler("old", http.StatusOK))).Put("/api/users/1", "", `{"name":"mary"}`)
+	if resp.Header().Get("X-Version") != "old" {
+		t.Errorf("client does not get primary response: %s", resp.Body)
+	}
+	ret := waitMirror(t, ch)
+	if ret.shadow.Err != nil || ret.shadow.Status != http.StatusAccepted || ret.shadow.Header.Get("X-Version") != "remote" ||
+		!strings.Contains(string(ret.shadow.Body), `"name":"mary"`) {
+		t.Errorf("unexpected shadow response: %+v %s", ret.shadow, ret.shadow.Body)
+	}
+}
@@ -0,0 +1,205 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WindowStats is statistics of a route in the rolling window of ErrorRateMonitor
+type WindowStats struct {
+	Pattern         string        `json:"pattern"`
+	Window          time.Duration `json:"window"`
+	Requests        int64         `json:"requests"`
+	ClientErrors    int64         `json:"client_errors"` // responses with 4xx status
+	ServerErrors    int64         `json:"server_errors"` // responses with 5xx status
+	ClientErrorRate float64       `json:"client_error_rate"`
+	ServerErrorRate float64       `json:"server_error_rate"`
+}
+
+// MonitorOpts configures an ErrorRateMonitor. Zero values are replaced by defaults.
+type MonitorOpts struct {
+	Window  time.Duration // length of the rolling window, defaults to 1 minute
+	Buckets int           // granularity of the window, defaults to 12
+
+	// thresholds of error rates, between 0 and 1, zero disables
+	ClientErrorRate float64
+	ServerErrorRate float64
+
+	// MinRequests is number of requests in window needed to check thresholds,
+	// so few failures on a quiet route do not trigger alerts. Defaults to 10.
+	MinRequests int64
+
+	// OnErrorRateExceeded is called when an error rate of a route exceeds its
+	// threshold, at most once per Cooldown for each route, which defaults to Window.
+	OnErrorRateExceeded func(pattern string, stats WindowStats)
+	Cooldown            time.Duration
+
+	Now func() time.Time // defaults to DefaultClock.Now
+}
+
+// ErrorRateMonitor tracks requests and errors of each route in a rolling window.
+// Routes are identified by API.Pattern, or path of the request for handlers not
+// registered by Register.
+//
+//     monitor := jsonapi.NewErrorRateMonitor(jsonapi.MonitorOpts{
+//         ServerErrorRate: 0.05,
+//         OnErrorRateExceeded: func(pattern string, stats jsonapi.WindowStats) {
+//             alert("%s is failing: %.1f%% 5xx", pattern, stats.ServerErrorRate*100)
+//         },
+//     })
+//     http.Handle("/api/order", jsonapi.HTTPHandler(monitor.Middleware(order)))
+//     http.Handle("/admin/routes", jsonapi.HTTPHandler(jsonapi.APIHandler(monitor.Handler).Handler))
+type ErrorRateMonitor struct {
+	opts   MonitorOpts
+	mu     sync.Mutex
+	routes map[string]*routeStats
+}
+
+type routeStats struct {
+	buckets []statsBucket
+	alerted time.Time
+}
+
+// statsBucket counts requests in slot-th period of Window/Buckets since epoch
+type statsBucket struct {
+	slot     int64
+	requests int64
+	client   int64
+	server   int64
+}
+
+// NewErrorRateMonitor creates an ErrorRateMonitor
+func NewErrorRateMonitor(opts MonitorOpts) *ErrorRateMonitor {
+	if opts.Window <= 0 {
+		opts.Window = time.Minute
+	}
+	if opts.Buckets <= 0 {
+		opts.Buckets = 12
+	}
+	if opts.MinRequests <= 0 {
+		opts.MinRequests = 10
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = opts.Window
+	}
+	if opts.Now == nil {
+		opts.Now = func() time.Time { return DefaultClock.Now() }
+	}
+	return &ErrorRateMonitor{opts: opts, routes: map[string]*routeStats{}}
+}
+
+// Middleware records status code of responses from next
+func (m *ErrorRateMonitor) Middleware(next HTTPHandler) HTTPHandler {
+	return func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		w := &statusWriter{ResponseWriter: httpData.ResponseWriter}
+		httpData.ResponseWriter = w
+
+		defer func() {
+			status := w.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			v := recover()
+			if v != nil && w.status == 0 {
+				status = http.StatusInternalServerError
+			}
+
+			pattern := httpData.Request.URL.Path
+			if httpData.api != nil {
+				pattern = httpData.api.Pattern
+			}
+			m.Record(pattern, status)
+			if v != nil {
+				panic(v)
+			}
+		}()
+		next(httpData.configureEncoder(json.NewEncoder(w)), dec, httpData)
+	}
+}
+
+// Record adds a response with status to stats of route pattern. It is used by
+// Middleware, and is exported for responses not served by this package.
+func (m *ErrorRateMonitor) Record(pattern string, status int) {
+	now := m.opts.Now()
+	slot := m.slot(now)
+
+	m.mu.Lock()
+	r, ok := m.routes[pattern]
+	if !ok {
+		r = &routeStats{buckets: make([]statsBucket, m.opts.Buckets)}
+		m.routes[pattern] = r
+	}
+	b := &r.buckets[int(slot%int64(m.opts.Buckets))]
+	if b.slot != slot {
+		*b = statsBucket{slot: slot}
+	}
+	b.requests++
+	switch {
+	case status >= 500:
+		b.server++
+	case status >= 400:
+		b.client++
+	}
+
+	stats := m.stats(pattern, r, slot)
+	fire := m.opts.OnErrorRateExceeded != nil && m.exceeded(stats) &&
+		(r.alerted.IsZero() || now.Sub(r.alerted) >= m.opts.Cooldown)
+	if fire {
+		r.alerted = now
+	}
+	m.mu.Unlock()
+
+	if fire {
+		m.opts.OnErrorRateExceeded(pattern, stats)
+	}
+}
+
+func (m *ErrorRateMonitor) slot(t time.Time) int64 {
+	return t.UnixNano() / int64(m.opts.Window/time.Duration(m.opts.Buckets))
+}
+
+func (m *ErrorRateMonitor) exceeded(s WindowStats) bool {
+	if s.Requests < m.opts.MinRequests {
+		return false
+	}
+	return (m.opts.ClientErrorRate > 0 && s.ClientErrorRate > m.opts.ClientErrorRate) ||
+		(m.opts.ServerErrorRate > 0 && s.ServerErrorRate > m.opts.ServerErrorRate)
+}
+
+// stats sums buckets of r in the window ending at slot
+func (m *ErrorRateMonitor) stats(pattern string, r *routeStats, slot int64) WindowStats {
+	ret := WindowStats{Pattern: pattern, Window: m.opts.Window}
+	for _, b := range r.buckets {
+		if b.slot > slot-int64(m.opts.Buckets) {
+			ret.Requests += b.requests
+			ret.ClientErrors += b.client
+			ret.ServerErrors += b.server
+		}
+	}
+	if ret.Requests > 0 {
+		ret.ClientErrorRate = float64(ret.ClientErrors) / float64(ret.Requests)
+		ret.ServerErrorRate = float64(ret.ServerErrors) / float64(ret.Requests)
+	}
+	return ret
+}
+
+// Stats returns current stats of routes, sorted by pattern
+func (m *ErrorRateMonitor) Stats() []WindowStats {
+	slot := m.slot(m.opts.Now())
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ret := make([]WindowStats, 0, len(m.routes))
+	for pattern, r := range m.routes {
+		ret = append(ret, m.stats(pattern, r, slot))
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Pattern < ret[j].Pattern })
+	return ret
+}
+
+// Handler is an APIHandler listing current stats of routes
+func (m *ErrorRateMonitor) Handler(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+	return m.Stats(), nil
+}
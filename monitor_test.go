@@ -0,0 +1,126 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func monitorTest(m *ErrorRateMonitor) *MuxTest {
+	return NewMuxTest([]API{{Pattern: "/api/order/{id}", Middlewares: []Middleware{m.Middleware}, APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		switch httpData.Request.PathValue("id") {
+		case "broken":
+			return nil, E500
+		case "missing":
+			return nil, E404
+		}
+		return "ok", nil
+	}}})
+}
+
+func TestErrorRateMonitor(t *testing.T) {
+	clock, restore := withFakeClock()
+	defer restore()
+	var alerts []WindowStats
+	monitor := NewErrorRateMonitor(MonitorOpts{
+		Window:          time.Minute,
+		ServerErrorRate: 0.5,
+		MinRequests:     4,
+		Cooldown:        5 * time.Minute,
+		OnErrorRateExceeded: func(pattern string, stats WindowStats) {
+			alerts = append(alerts, stats)
+		},
+	})
+	m := monitorTest(monitor)
+
+	// 4xx does not count towards ServerErrorRate
+	for i := 0; i < 4; i++ {
+		m.Get("/api/order/missing", "")
+	}
+	if len(alerts) != 0 {
+		t.Fatalf("alerted by client errors: %+v", alerts)
+	}
+
+	for i := 0; i < 6; i++ {
+		m.Get("/api/order/broken", "")
+	}
+	if len(alerts) != 1 {
+		t.Fatalf("expected 1 alert, got %+v", alerts)
+	}
+	a := alerts[0]
+	if a.Pattern != "/api/order/{id}" || a.Requests != 9 || a.ServerErrors != 5 || a.ClientErrors != 4 {
+		t.Errorf("unexpected stats %+v", a)
+	}
+
+	// still failing, but in cooldown even after the window is renewed
+	clock.Advance(2 * time.Minute)
+	for i := 0; i < 6; i++ {
+		m.Get("/api/order/broken", "")
+	}
+	if len(alerts) != 1 {
+		t.Errorf("alerted in cooldown: %+v", alerts)
+	}
+
+	clock.Advance(3 * time.Minute)
+	for i := 0; i < 4; i++ {
+		m.Get("/api/order/broken", "")
+	}
+	if len(alerts) != 2 || alerts[1].Requests != 4 {
+		t.Errorf("expected second alert after cooldown, got %+v", alerts)
+	}
+}
+
+func TestErrorRateMonitorClientErrors(t *testing.T) {
+	_, restore := withFakeClock()
+	defer restore()
+	var alerts []WindowStats
+	monitor := NewErrorRateMonitor(MonitorOpts{
+		ClientErrorRate: 0.2,
+		MinRequests:     5,
+		OnErrorRateExceeded: func(pattern string, stats WindowStats) {
+			alerts = append(alerts, stats)
+		},
+	})
+	m := monitorTest(monitor)
+	for i := 0; i < 4; i++ {
+		m.Get("/api/order/1", "")
+	}
+	m.Get("/api/order/missing", "")
+	if len(alerts) != 0 {
+		t.Errorf("rate at threshold alerted: %+v", alerts)
+	}
+	m.Get("/api/order/broken", "")
+	m.Get("/api/order/missing", "")
+	if len(alerts) != 1 || alerts[0].ClientErrors != 2 || alerts[0].ServerErrors != 1 {
+		t.Errorf("unexpected alerts %+v", alerts)
+	}
+}
+
+func TestErrorRateMonitorWindow(t *testing.T) {
+	clock, restore := withFakeClock()
+	defer restore()
+	monitor := NewErrorRateMonitor(MonitorOpts{Window: time.Minute, Buckets: 6})
+	m := monitorTest(monitor)
+
+	m.Get("/api/order/broken", "")
+	clock.Advance(30 * time.Second)
+	m.Get("/api/order/1", "")
+	monitor.Record("/legacy", 503)
+
+	var stats []WindowStats
+	if _, err := HandlerTest(APIHandler(monitor.Handler).Handler).GetInto("/admin/routes", "", &stats); err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 2 || stats[0].Pattern != "/api/order/{id}" || stats[1].Pattern != "/legacy" {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if s := stats[0]; s.Requests != 2 || s.ServerErrors != 1 || s.ServerErrorRate != 0.5 || s.Window != time.Minute {
+		t.Errorf("unexpected stats %+v", s)
+	}
+
+	// the failure slides out of the window
+	clock.Advance(40 * time.Second)
+	if s := monitor.Stats()[0]; s.Requests != 1 || s.ServerErrors != 0 {
+		t.Errorf("expired requests are counted: %+v", s)
+	}
+}
@@ -0,0 +1,108 @@
+package jsonapi
+
+import (
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// defaultMultipartMemory is used by DecodeFormJSON if the form is not parsed yet,
+// same as the one of http.Request.FormFile
+const defaultMultipartMemory = 32 << 20
+
+var errNotMultipart = Error{
+	Code:    http.StatusUnsupportedMediaType,
+	Message: "Request body must be in multipart/form-data format",
+	Kind:    KindUnsupportedMediaType,
+}
+
+// isMultipart reports whether r has a multipart/form-data body
+func isMultipart(r *http.Request) bool {
+	typ, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && typ == "multipart/form-data"
+}
+
+// parseMultipart parses multipart/form-data body once, keeping up to maxMemory
+// bytes of files in memory and the rest in temporary files
+func (h *HTTP) parseMultipart(maxMemory int64) error {
+	if h.Request.MultipartForm != nil {
+		return nil
+	}
+	if !isMultipart(h.Request) {
+		return errNotMultipart
+	}
+	if err := h.Request.ParseMultipartForm(maxMemory); err != nil {
+		return E400.SetData("Cannot parse multipart body: " + err.Error())
+	}
+	return nil
+}
+
+// MultipartFile returns the file uploaded in field of multipart/form-data body,
+// which is parsed with maxMemory, see http.Request.ParseMultipartForm. It returns a
+// 415 Error if the body is not multipart/form-data, or a 400 Error if it is broken
+// or has no such file. Request.FormFile still works, but errors are not in JSON
+// format.
+//
+//     func upload(dec *json.Decoder, httpData *jsonapi.HTTP) (interface{}, error) {
+//         var meta PhotoMeta
+//         if err := httpData.DecodeFormJSON("meta", &meta); err != nil {
+//             return nil, err
+//         }
+//         f, hdr, err := httpData.MultipartFile("photo", 8<<20)
+//         if err != nil {
+//             return nil, err
+//         }
+//         defer f.Close()
+//         return photos.Save(meta, hdr.Filename, f)
+//     }
+//
+// The JSON checks of request body, like MaxDepth, are skipped for multipart
+// bodies. Do not enable RequireJSONContentType for such APIs.
+func (h *HTTP) MultipartFile(field string, maxMemory int64) (multipart.File, *multipart.FileHeader, error) {
+	if err := h.parseMultipart(maxMemory); err != nil {
+		return nil, nil, err
+	}
+	files := h.Request.MultipartForm.File[field]
+	if len(files) == 0 {
+		return nil, nil, E400.SetData(fmt.Sprintf("Missing file %q", field))
+	}
+	f, err := files[0].Open()
+	if err != nil {
+		return nil, nil, E500.Wrap(err)
+	}
+	return f, files[0], nil
+}
+
+// DecodeFormJSON decodes field of multipart/form-data body into v and validates it
+// like Bind. The field can be a value or a file part. Errors are the same as
+// MultipartFile, and malformed JSON is reported by a 400 Error.
+func (h *HTTP) DecodeFormJSON(field string, v interface{}) error {
+	if err := h.parseMultipart(defaultMultipartMemory); err != nil {
+		return err
+	}
+	form := h.Request.MultipartForm
+	var r io.Reader
+	if vals := form.Value[field]; len(vals) > 0 {
+		r = strings.NewReader(vals[0])
+	} else if files := form.File[field]; len(files) > 0 {
+		f, err := files[0].Open()
+		if err != nil {
+			return E500.Wrap(err)
+		}
+		defer f.Close()
+		r = f
+	} else {
+		return E400.SetData(fmt.Sprintf("Missing form field %q", field))
+	}
+
+	if err := h.newDecoder(r).Decode(v); err != nil {
+		if e, ok := unknownField(err); ok {
+			return e
+		}
+		return E400.SetData(fmt.Sprintf("Cannot decode form field %q: %s", field, err))
+	}
+	return validate(h, v)
+}
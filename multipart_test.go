@@ -0,0 +1,77 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type photoMeta struct {
+	Album string `json:"album"`
+}
+
+func (m photoMeta) Validate() error {
+	if m.Album == "" {
+		return E422.SetData("album is required")
+	}
+	return nil
+}
+
+func TestMultipart(t *testing.T) {
+	m := NewMuxTest([]API{{Pattern: "/api/photo", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		var meta photoMeta
+		if err := httpData.DecodeFormJSON("meta", &meta); err != nil {
+			return nil, err
+		}
+		f, hdr, err := httpData.MultipartFile("photo", 1<<10)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		data, _ := ioutil.ReadAll(f)
+		return []string{meta.Album, hdr.Filename, string(data)}, nil
+	}}})
+
+	photo := map[string][]byte{"photo": []byte("photo data")}
+	cases := []struct {
+		name    string
+		fields  map[string]string
+		files   map[string][]byte
+		code    int
+		message string
+	}{
+		{"meta in value", map[string]string{"meta": `{"album":"trip"}`}, photo, 200, ""},
+		{"meta in file", nil, map[string][]byte{"meta": []byte(`{"album":"trip"}`), "photo": []byte("photo data")}, 200, ""},
+		{"missing meta", nil, photo, 400, `Missing form field "meta"`},
+		{"malformed meta", map[string]string{"meta": `{"album":`}, photo, 400, `Cannot decode form field "meta": unexpected EOF`},
+		{"invalid meta", map[string]string{"meta": `{}`}, photo, 422, "album is required"},
+		{"missing file", map[string]string{"meta": `{"album":"trip"}`}, nil, 400, `Missing file "photo"`},
+	}
+	for _, c := range cases {
+		resp, _ := m.PostMultipart("/api/photo", "", c.fields, c.files)
+		if resp.Code != c.code {
+			t.Errorf("%s: expected %d, got %d %s", c.name, c.code, resp.Code, resp.Body)
+			continue
+		}
+		if c.code == 200 {
+			if s := strings.TrimSpace(resp.Body.String()); s != `["trip","photo","photo data"]` {
+				t.Errorf("%s: unexpected body %s", c.name, s)
+			}
+			continue
+		}
+		var body ErrorBody
+		json.Unmarshal(resp.Body.Bytes(), &body)
+		if body.Error.Message != c.message {
+			t.Errorf("%s: unexpected error %s", c.name, resp.Body)
+		}
+	}
+
+	resp, _ := m.Post("/api/photo", "", `{"album":"trip"}`)
+	var body ErrorBody
+	json.Unmarshal(resp.Body.Bytes(), &body)
+	if resp.Code != http.StatusUnsupportedMediaType || body.Error.Kind != KindUnsupportedMediaType {
+		t.Errorf("not multipart: unexpected response %d %s", resp.Code, resp.Body)
+	}
+}
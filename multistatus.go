@@ -0,0 +1,122 @@
+package jsonapi
+
+import (
+	"net/http"
+)
+
+// MultiStatusItem is result of an item in MultiStatus
+type MultiStatusItem struct {
+	ID     interface{} // id or index of the item
+	Status int
+	Body   interface{} // result if succeeded
+	Err    error       // error if failed
+}
+
+// MultiStatus is the result of batch or bulk operations, in which some items might
+// succeed while others fail. Return it from APIHandler:
+//
+//     var ret jsonapi.MultiStatus
+//     for idx, u := range users {
+//         if err := save(u); err != nil {
+//             ret.Fail(idx, err)
+//             continue
+//         }
+//         ret.Succeed(idx, u)
+//     }
+//     return &ret, nil
+//
+// It is sent with status code computed by Status, in the form of
+//
+//     {"status": 207, "items": [
+//         {"id": 0, "status": 200, "body": {"name": "john"}},
+//         {"id": 1, "status": 404, "error": {"code": 404, "message": "User not found"}}
+//     ]}
+//
+// where errors are encoded by the ErrorEncoder.
+type MultiStatus struct {
+	Items []MultiStatusItem
+}
+
+// Add appends result of an item with status code
+func (m *MultiStatus) Add(id interface{}, status int, body interface{}) {
+	m.Items = append(m.Items, MultiStatusItem{ID: id, Status: status, Body: body})
+}
+
+// Succeed appends a successful item with status code 200
+func (m *MultiStatus) Succeed(id interface{}, body interface{}) {
+	m.Add(id, http.StatusOK, body)
+}
+
+// Fail appends a failed item. Status code is taken from Error, or 500 for other errors.
+func (m *MultiStatus) Fail(id interface{}, err error) {
+	code := http.StatusInternalServerError
+	if e, ok := err.(Error); ok {
+		code = e.Code
+	}
+	m.Items = append(m.Items, MultiStatusItem{ID: id, Status: code, Err: err})
+}
+
+// Status computes the overall status code: 200 if all items succeeded (or there
+// is no item), the common status code if all items failed with the same one, or
+// 207 otherwise.
+func (m *MultiStatus) Status() int {
+	if len(m.Items) == 0 {
+		return http.StatusOK
+	}
+	failed := 0
+	for _, item := range m.Items {
+		if item.Status >= 400 {
+			failed++
+		}
+	}
+	switch failed {
+	case 0:
+		return http.StatusOK
+	case len(m.Items):
+		code := m.Items[0].Status
+		for _, item := range m.Items[1:] {
+			if item.Status != code {
+				return http.StatusMultiStatus
+			}
+		}
+		return code
+	}
+	return http.StatusMultiStatus
+}
+
+// multiStatusItem is how MultiStatusItem is encoded
+type multiStatusItem struct {
+	ID     interface{} `json:"id"`
+	Status int         `json:"status"`
+	Body   interface{} `json:"body,omitempty"`
+	Error  interface{} `json:"error,omitempty"`
+}
+
+func (m MultiStatus) respond(httpData *HTTP) {
+	rewriter := rewriterFor(httpData)
+	items := make([]multiStatusItem, len(m.Items))
+	for idx, item := range m.Items {
+		items[idx] = multiStatusItem{ID: item.ID, Status: item.Status}
+		if item.Err == nil {
+			items[idx].Body = rewriter.rewrite(item.Body)
+			continue
+		}
+		traced := reportError(httpData, item.Status, item.Err)
+		body := devBody(errorEncoder(httpData, item.Status, item.Err), item.Err, traced)
+		if b, ok := body.(ErrorBody); ok {
+			// status is already in the item
+			body = b.Error
+		}
+		items[idx].Error = rewriter.rewrite(body)
+	}
+
+	code := m.Status()
+	// ErrorEncoder might change content type for error documents, which is not
+	// the case of the envelope
+	httpData.ResponseWriter.Header().Set("Content-Type", "application/json")
+	httpData.WriteHeader(code)
+	httpData.encoder().Encode(struct {
+		Status int               `json:"status"`
+		Items  []multiStatusItem `json:"items"`
+	}{code, items})
+}
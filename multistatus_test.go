@@ -0,0 +1,103 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestMultiStatusStatus(t *testing.T) {
+	cases := []struct {
+		name   string
+		build  func(m *MultiStatus)
+		expect int
+	}{
+		{"empty", func(m *MultiStatus) {}, http.StatusOK},
+		{"all success", func(m *MultiStatus) {
+			m.Succeed(0, "a")
+			m.Add(1, http.StatusCreated, "b")
+		}, http.StatusOK},
+		{"mixed", func(m *MultiStatus) {
+			m.Succeed(0, "a")
+			m.Fail(1, E404)
+		}, http.StatusMultiStatus},
+		{"all failed identically", func(m *MultiStatus) {
+			m.Fail(0, E404)
+			m.Fail(1, E404.SetData("User not found"))
+		}, http.StatusNotFound},
+		{"all failed differently", func(m *MultiStatus) {
+			m.Fail(0, E404)
+			m.Fail(1, errors.New("boom"))
+		}, http.StatusMultiStatus},
+	}
+	for _, c := range cases {
+		var m MultiStatus
+		c.build(&m)
+		if s := m.Status(); s != c.expect {
+			t.Errorf("%s: expected %d, got %d", c.name, c.expect, s)
+		}
+	}
+}
+
+func TestMultiStatusResponse(t *testing.T) {
+	m := NewMuxTest([]API{{Pattern: "/bulk", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		var ret MultiStatus
+		ret.Succeed(0, map[string]string{"name": "john"})
+		ret.Fail(1, E404.SetData("User not found"))
+		ret.Fail(2, errors.New("database is down"))
+		return &ret, nil
+	}}})
+	resp, _ := m.Get("/bulk", "")
+	if resp.Code != http.StatusMultiStatus {
+		t.Fatalf("expected 207, got %d", resp.Code)
+	}
+	if ct := resp.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("unexpected content type %s", ct)
+	}
+
+	var body struct {
+		Status int `json:"status"`
+		Items  []struct {
+			ID     int               `json:"id"`
+			Status int               `json:"status"`
+			Body   map[string]string `json:"body"`
+			Error  *ErrorInfo        `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatalf("cannot decode %s: %s", resp.Body, err)
+	}
+	if body.Status != http.StatusMultiStatus || len(body.Items) != 3 {
+		t.Fatalf("unexpected envelope: %s", resp.Body)
+	}
+	if i := body.Items[0]; i.Status != 200 || i.Body["name"] != "john" || i.Error != nil {
+		t.Errorf("unexpected success item: %+v", i)
+	}
+	if i := body.Items[1]; i.ID != 1 || i.Status != 404 || i.Error == nil || i.Error.Message != "User not found" {
+		t.Errorf("unexpected failed item: %+v", i)
+	}
+	if i := body.Items[2]; i.Status != 500 || i.Error == nil || i.Error.Code != 500 {
+		t.Errorf("unexpected failed item: %+v", i)
+	}
+}
+
+func TestMultiStatusAllFailed(t *testing.T) {
+	m := NewMuxTest([]API{{Pattern: "/bulk", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		var ret MultiStatus
+		ret.Fail("a", E403)
+		ret.Fail("b", E403)
+		return ret, nil
+	}}})
+	resp, _ := m.Get("/bulk", "")
+	var body struct {
+		Status int `json:"status"`
+		Items  []struct {
+			ID string `json:"id"`
+		} `json:"items"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &body)
+	if resp.Code != http.StatusForbidden || body.Status != http.StatusForbidden || len(body.Items) != 2 || body.Items[1].ID != "b" {
+		t.Errorf("unexpected response %d: %s", resp.Code, resp.Body)
+	}
+}
@@ -0,0 +1,115 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// probeMethods are tried against the routing table to find out which methods
+// an unmatched request could have used instead.
+var probeMethods = []string{
+	http.MethodGet,
+	http.MethodHead,
+	http.MethodPost,
+	http.MethodPut,
+	http.MethodPatch,
+	http.MethodDelete,
+	http.MethodOptions,
+}
+
+// DefaultNotFound is used when no route matches the request path.
+func DefaultNotFound(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+	return nil, E404
+}
+
+// DefaultMethodNotAllowed is used when the path matches but the method does not.
+// The Allow header has already been set when it runs.
+func DefaultMethodNotAllowed(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+	return nil, E405
+}
+
+// Mux is a http.ServeMux which answers unmatched requests in JSON format
+// instead of plain text.
+//
+//     mux := jsonapi.NewMux()
+//     jsonapi.Register(apis, mux.ServeMux)
+//     http.ListenAndServe(":8000", mux)
+type Mux struct {
+	*http.ServeMux
+	NotFound         APIHandler // defaults to DefaultNotFound
+	MethodNotAllowed APIHandler // defaults to DefaultMethodNotAllowed
+}
+
+// NewMux creates a Mux with default handlers
+func NewMux() *Mux {
+	return &Mux{
+		ServeMux:         http.NewServeMux(),
+		NotFound:         DefaultNotFound,
+		MethodNotAllowed: DefaultMethodNotAllowed,
+	}
+}
+
+func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := m.ServeMux.Handler(r); pattern != "" {
+		m.ServeMux.ServeHTTP(w, r)
+		return
+	}
+
+	fallback(m.ServeMux, "", m.NotFound, m.MethodNotAllowed).ServeHTTP(w, r)
+}
+
+// RegisterFallback installs JSON NotFound and MethodNotAllowed handlers on a plain
+// http.ServeMux by registering a catch-all "/" pattern. Since "/" has the lowest
+// precedence, it does not shadow real routes. Nil handlers are replaced by defaults.
+//
+// It does nothing if "/" is already registered.
+func RegisterFallback(mux *http.ServeMux, notFound, methodNotAllowed APIHandler) {
+	if mux == nil {
+		mux = http.DefaultServeMux
+	}
+
+	if _, pattern := mux.Handler(&http.Request{Method: "GET", URL: &url.URL{Path: "/"}}); pattern != "" {
+		return
+	}
+
+	mux.Handle("/", fallback(mux, "/", notFound, methodNotAllowed))
+}
+
+// fallback creates handler for unmatched requests. catchAll is the pattern the
+// fallback itself is registered with, which must be ignored when probing.
+func fallback(mux *http.ServeMux, catchAll string, notFound, methodNotAllowed APIHandler) HTTPHandler {
+	if notFound == nil {
+		notFound = DefaultNotFound
+	}
+	if methodNotAllowed == nil {
+		methodNotAllowed = DefaultMethodNotAllowed
+	}
+
+	return func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		allowed := allowedMethods(mux, catchAll, httpData.Request)
+		if len(allowed) == 0 {
+			notFound.Handler(enc, dec, httpData)
+			return
+		}
+
+		httpData.ResponseWriter.Header().Set("Allow", strings.Join(allowed, ", "))
+		methodNotAllowed.Handler(enc, dec, httpData)
+	}
+}
+
+// allowedMethods lists methods which would match a route for the path of r
+func allowedMethods(mux *http.ServeMux, catchAll string, r *http.Request) (ret []string) {
+	for _, m := range probeMethods {
+		if m == r.Method {
+			continue
+		}
+		probe := r.Clone(r.Context())
+		probe.Method = m
+		if _, pattern := mux.Handler(probe); pattern != "" && pattern != catchAll {
+			ret = append(ret, m)
+		}
+	}
+	return
+}
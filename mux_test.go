@@ -0,0 +1,92 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func okAPI(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+	return "ok", nil
+}
+
+func serveMux(t *testing.T, h http.Handler, method, uri string) (*httptest.ResponseRecorder, ErrorBody) {
+	t.Helper()
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest(method, uri, nil))
+	var body ErrorBody
+	if resp.Code >= 400 {
+		if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s %s: body is not JSON: %q", method, uri, resp.Body)
+		}
+	}
+	return resp, body
+}
+
+func TestMux(t *testing.T) {
+	mux := NewMux()
+	Register([]API{{Pattern: "GET /api/user/{id}", APIHandler: okAPI}, {Pattern: "POST /api/user/{id}", APIHandler: okAPI}}, mux.ServeMux)
+
+	resp, _ := serveMux(t, mux, "GET", "/api/user/1")
+	if resp.Code != http.StatusOK {
+		t.Errorf("GET: %d %s", resp.Code, resp.Body)
+	}
+
+	resp, body := serveMux(t, mux, "GET", "/nowhere")
+	if resp.Code != http.StatusNotFound || body.Error.Code != http.StatusNotFound {
+		t.Errorf("unknown path: %d %s", resp.Code, resp.Body)
+	}
+	if ct := resp.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+
+	resp, body = serveMux(t, mux, "DELETE", "/api/user/1")
+	if resp.Code != http.StatusMethodNotAllowed || body.Error.Code != http.StatusMethodNotAllowed {
+		t.Errorf("wrong method: %d %s", resp.Code, resp.Body)
+	}
+	if allow := resp.Header().Get("Allow"); allow != "GET, HEAD, POST" {
+		t.Errorf("Allow = %q", allow)
+	}
+}
+
+func TestMuxCustomHandlers(t *testing.T) {
+	mux := NewMux()
+	mux.NotFound = func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return nil, E404.SetData("no such thing")
+	}
+	resp, body := serveMux(t, mux, "GET", "/nowhere")
+	if resp.Code != http.StatusNotFound || body.Error.Message != "no such thing" {
+		t.Errorf("got %d %s", resp.Code, resp.Body)
+	}
+}
+
+func TestRegisterWithoutFallback(t *testing.T) {
+	mux := http.NewServeMux()
+	Register([]API{{Pattern: "/api/item", APIHandler: okAPI}}, mux)
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest("GET", "/nowhere", nil))
+	if resp.Code != http.StatusNotFound || resp.Header().Get("Content-Type") == "application/json" {
+		t.Errorf("got %d %v", resp.Code, resp.Header())
+	}
+	// "/" is still free for the application
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {})
+}
+
+func TestRegisterFallback(t *testing.T) {
+	mux := http.NewServeMux()
+	Register([]API{{Pattern: "PUT /api/item", APIHandler: okAPI}, {Pattern: "/api/any", APIHandler: okAPI}}, mux)
+	RegisterFallback(mux, nil, nil)
+	// registering again does nothing instead of panicking on duplicate "/"
+	RegisterFallback(mux, nil, nil)
+
+	for uri, want := range map[string]int{"/api/any": http.StatusOK, "/api/any/deeper": http.StatusNotFound, "/": http.StatusNotFound} {
+		if resp, _ := serveMux(t, mux, "GET", uri); resp.Code != want {
+			t.Errorf("GET %s: status = %d, want %d", uri, resp.Code, want)
+		}
+	}
+	resp, _ := serveMux(t, mux, "GET", "/api/item")
+	if resp.Code != http.StatusMethodNotAllowed || resp.Header().Get("Allow") != "PUT" {
+		t.Errorf("wrong method: %d, Allow %q", resp.Code, resp.Header().Get("Allow"))
+	}
+}
@@ -0,0 +1,33 @@
+package jsonapi
+
+// OmitPolicy selects which struct fields are dropped from responses by OmitZero
+type OmitPolicy int
+
+const (
+	// OmitNone keeps all fields, which is the default
+	OmitNone OmitPolicy = iota
+	// OmitEmptyContainers drops nil pointers, interfaces, and empty slices and maps
+	OmitEmptyContainers
+	// OmitAllZero drops every zero value, like "", 0 and false, in addition to
+	// OmitEmptyContainers
+	OmitAllZero
+)
+
+// OmitZero drops fields from objects in responses according to the policy, as if
+// they were tagged omitempty. API.OmitZero overrides it per route if set. Fields
+// tagged `keep:"true"` are always kept, for the cases zero is meaningful:
+//
+//     type Stock struct {
+//         Name  string `json:"name"`
+//         Count int    `json:"count" keep:"true"`
+//     }
+//
+// Only struct fields are dropped, entries of maps are left untouched.
+var OmitZero = OmitNone
+
+func (h *HTTP) omitZero() OmitPolicy {
+	if h.api != nil && h.api.OmitZero != OmitNone {
+		return h.api.OmitZero
+	}
+	return OmitZero
+}
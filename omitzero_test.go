@@ -0,0 +1,52 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type stock struct {
+	Name    string                 `json:"name"`
+	Count   int                    `json:"count" keep:"true"`
+	Price   float64                `json:"price"`
+	OnSale  bool                   `json:"on_sale"`
+	Tags    []string               `json:"tags"`
+	Owner   *stockOwner            `json:"owner"`
+	Extra   map[string]interface{} `json:"extra"`
+	Nested  stockOwner             `json:"nested"`
+	Comment interface{}            `json:"comment"`
+}
+
+type stockOwner struct {
+	Name string `json:"name"`
+	ID   int    `json:"id"`
+}
+
+func TestOmitZero(t *testing.T) {
+	v := stock{
+		Extra:  map[string]interface{}{"empty": "", "zero": 0, "none": nil},
+		Nested: stockOwner{ID: 1},
+	}
+	cases := []struct {
+		global, api OmitPolicy
+		expect      string
+	}{
+		{OmitNone, OmitNone, `{"name":"","count":0,"price":0,"on_sale":false,"tags":null,"owner":null,"extra":{"empty":"","none":null,"zero":0},"nested":{"name":"","id":1},"comment":null}`},
+		{OmitEmptyContainers, OmitNone, `{"name":"","count":0,"price":0,"on_sale":false,"extra":{"empty":"","none":null,"zero":0},"nested":{"name":"","id":1}}`},
+		{OmitAllZero, OmitNone, `{"count":0,"extra":{"empty":"","none":null,"zero":0},"nested":{"id":1}}`},
+		{OmitNone, OmitAllZero, `{"count":0,"extra":{"empty":"","none":null,"zero":0},"nested":{"id":1}}`},
+		{OmitAllZero, OmitEmptyContainers, `{"name":"","count":0,"price":0,"on_sale":false,"extra":{"empty":"","none":null,"zero":0},"nested":{"name":"","id":1}}`},
+	}
+	defer func() { OmitZero = OmitNone }()
+	for _, c := range cases {
+		OmitZero = c.global
+		m := NewMuxTest([]API{{Pattern: "/", OmitZero: c.api, APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return []stock{v}, nil
+		}}})
+		resp, _ := m.Get("/", "")
+		if actual := strings.TrimSpace(resp.Body.String()); actual != "["+c.expect+"]" {
+			t.Errorf("policy %d/%d: expected %s, got %s", c.global, c.api, c.expect, actual)
+		}
+	}
+}
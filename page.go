@@ -0,0 +1,146 @@
+package jsonapi
+
+import (
+	"strconv"
+)
+
+// PageDefaults configures ParsePage
+type PageDefaults struct {
+	Limit    int // items per page if not requested, defaults to 20
+	MaxLimit int // upper bound of items per page, defaults to 100
+}
+
+// PageRequest is the page requested by client, see ParsePage
+type PageRequest struct {
+	Limit  int
+	Offset int
+}
+
+// ParsePage reads the requested page from query parameters limit and offset, or
+// page (starting from 1) and per_page if neither is present. Values out of range
+// are clamped: limit to 1..MaxLimit, and offset to 0 or more. Non-numeric values
+// are rejected with a 400 Error of KindInvalidQueryParam.
+//
+//     func listUsers(dec *json.Decoder, httpData *jsonapi.HTTP) (interface{}, error) {
+//         page, err := jsonapi.ParsePage(httpData, jsonapi.PageDefaults{})
+//         if err != nil {
+//             return nil, err
+//         }
+//         users, total, err := db.ListUsers(page.Limit, page.Offset)
+//         if err != nil {
+//             return nil, err
+//         }
+//         return jsonapi.NewPagedResponse(httpData, page, users, total), nil
+//     }
+func ParsePage(h *HTTP, defaults PageDefaults) (PageRequest, error) {
+	if defaults.Limit <= 0 {
+		defaults.Limit = 20
+	}
+	if defaults.MaxLimit <= 0 {
+		defaults.MaxLimit = 100
+	}
+	if defaults.Limit > defaults.MaxLimit {
+		defaults.Limit = defaults.MaxLimit
+	}
+
+	q := h.Request.URL.Query()
+	num := func(name string, def int) (int, error) {
+		v := q.Get(name)
+		if v == "" {
+			return def, nil
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, invalidQueryParam(name, v, "an integer")
+		}
+		return n, nil
+	}
+
+	ret := PageRequest{Limit: defaults.Limit}
+	var err error
+	if q.Get("limit") != "" || q.Get("offset") != "" || (q.Get("page") == "" && q.Get("per_page") == "") {
+		if ret.Limit, err = num("limit", defaults.Limit); err != nil {
+			return PageRequest{}, err
+		}
+		if ret.Offset, err = num("offset", 0); err != nil {
+			return PageRequest{}, err
+		}
+	} else {
+		if ret.Limit, err = num("per_page", defaults.Limit); err != nil {
+			return PageRequest{}, err
+		}
+		page, err := num("page", 1)
+		if err != nil {
+			return PageRequest{}, err
+		}
+		if page < 1 {
+			page = 1
+		}
+		ret.Limit = clampLimit(ret.Limit, defaults.MaxLimit)
+		ret.Offset = (page - 1) * ret.Limit
+	}
+
+	ret.Limit = clampLimit(ret.Limit, defaults.MaxLimit)
+	if ret.Offset < 0 {
+		ret.Offset = 0
+	}
+	return ret, nil
+}
+
+func clampLimit(n, max int) int {
+	switch {
+	case n < 1:
+		return 1
+	case n > max:
+		return max
+	}
+	return n
+}
+
+// PagedResponse is a page of items, encoded like
+//
+//     {"items": [...], "total": 42, "limit": 20, "offset": 20,
+//         "next": "/api/users?limit=20&offset=40", "prev": "/api/users?limit=20&offset=0"}
+//
+// Next and Prev are omitted on the last and first page.
+type PagedResponse[T any] struct {
+	Items  []T    `json:"items"`
+	Total  int64  `json:"total"`
+	Limit  int    `json:"limit"`
+	Offset int    `json:"offset"`
+	Next   string `json:"next,omitempty"`
+	Prev   string `json:"prev,omitempty"`
+}
+
+// NewPagedResponse creates a PagedResponse of items in page out of total, with
+// links to next and previous page built from the request url. Other query
+// parameters like filters are kept in links.
+func NewPagedResponse[T any](h *HTTP, page PageRequest, items []T, total int64) PagedResponse[T] {
+	if items == nil {
+		items = []T{}
+	}
+	ret := PagedResponse[T]{Items: items, Total: total, Limit: page.Limit, Offset: page.Offset}
+	if int64(page.Offset+page.Limit) < total {
+		ret.Next = pageLink(h, page.Limit, page.Offset+page.Limit)
+	}
+	if page.Offset > 0 {
+		prev := page.Offset - page.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		ret.Prev = pageLink(h, page.Limit, prev)
+	}
+	return ret
+}
+
+// pageLink builds url of the request with limit and offset replaced
+func pageLink(h *HTTP, limit, offset int) string {
+	u := *h.Request.URL
+	q := u.Query()
+	q.Del("page")
+	q.Del("per_page")
+	q.Set("limit", strconv.Itoa(limit))
+	q.Set("offset", strconv.Itoa(offset))
+	u.RawQuery = q.Encode()
+	return u.RequestURI()
+}
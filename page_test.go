@@ -0,0 +1,71 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParsePage(t *testing.T) {
+	cases := []struct {
+		uri      string
+		defaults PageDefaults
+		expect   PageRequest
+	}{
+		{"/users", PageDefaults{}, PageRequest{Limit: 20}},
+		{"/users", PageDefaults{Limit: 50, MaxLimit: 10}, PageRequest{Limit: 10}},
+		{"/users?limit=5&offset=10", PageDefaults{}, PageRequest{Limit: 5, Offset: 10}},
+		{"/users?offset=10", PageDefaults{Limit: 30}, PageRequest{Limit: 30, Offset: 10}},
+		{"/users?limit=1000&offset=-5", PageDefaults{}, PageRequest{Limit: 100}},
+		{"/users?limit=0", PageDefaults{}, PageRequest{Limit: 1}},
+		{"/users?limit=-3", PageDefaults{}, PageRequest{Limit: 1}},
+		{"/users?page=3&per_page=10", PageDefaults{}, PageRequest{Limit: 10, Offset: 20}},
+		{"/users?page=2", PageDefaults{Limit: 15}, PageRequest{Limit: 15, Offset: 15}},
+		{"/users?page=0&per_page=500", PageDefaults{MaxLimit: 50}, PageRequest{Limit: 50}},
+		{"/users?page=2&per_page=500", PageDefaults{MaxLimit: 50}, PageRequest{Limit: 50, Offset: 50}},
+		// limit and offset win
+		{"/users?page=3&limit=5", PageDefaults{}, PageRequest{Limit: 5}},
+	}
+	for _, c := range cases {
+		page, err := ParsePage(&HTTP{Request: httptest.NewRequest("GET", c.uri, nil)}, c.defaults)
+		if err != nil || page != c.expect {
+			t.Errorf("%s %+v: expected %+v, got %+v %v", c.uri, c.defaults, c.expect, page, err)
+		}
+	}
+
+	for _, uri := range []string{"/users?limit=ten", "/users?offset=1.5", "/users?page=x", "/users?per_page=2&page=%20"} {
+		_, err := ParsePage(&HTTP{Request: httptest.NewRequest("GET", uri, nil)}, PageDefaults{})
+		if e, ok := err.(Error); !ok || e.Code != 400 || e.Kind != KindInvalidQueryParam {
+			t.Errorf("%s: expected 400 Error, got %#v", uri, err)
+		}
+	}
+}
+
+func TestNewPagedResponse(t *testing.T) {
+	cases := []struct {
+		uri        string
+		total      int64
+		next, prev string
+	}{
+		{"/api/users?role=admin", 45, "/api/users?limit=20&offset=20&role=admin", ""},
+		{"/api/users?role=admin&page=2", 45, "/api/users?limit=20&offset=40&role=admin", "/api/users?limit=20&offset=0&role=admin"},
+		{"/api/users?limit=20&offset=40", 45, "", "/api/users?limit=20&offset=20"},
+		{"/api/users?limit=20&offset=10", 45, "/api/users?limit=20&offset=30", "/api/users?limit=20&offset=0"},
+		{"/api/users?limit=20&offset=20", 40, "", "/api/users?limit=20&offset=0"},
+		{"/api/users", 0, "", ""},
+	}
+	for _, c := range cases {
+		h := &HTTP{Request: httptest.NewRequest("GET", c.uri, nil)}
+		page, _ := ParsePage(h, PageDefaults{})
+		resp := NewPagedResponse(h, page, []string{"john"}, c.total)
+		if resp.Next != c.next || resp.Prev != c.prev || resp.Total != c.total || resp.Limit != page.Limit || resp.Offset != page.Offset {
+			t.Errorf("%s: unexpected response %+v", c.uri, resp)
+		}
+	}
+
+	h := &HTTP{Request: httptest.NewRequest("GET", "/api/users", nil)}
+	buf, _ := json.Marshal(NewPagedResponse[int](h, PageRequest{Limit: 20}, nil, 0))
+	if string(buf) != `{"items":[],"total":0,"limit":20,"offset":0}` {
+		t.Errorf("unexpected encoding %s", buf)
+	}
+}
@@ -0,0 +1,201 @@
+package jsonapi
+
+import (
+	"encoding"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// KindInvalidPathParam is the Kind of Error sent when a path parameter cannot be
+// converted to expected type
+const KindInvalidPathParam = "invalid_path_param"
+
+// invalidPathParam creates the 400 Error naming parameter, received value and expected type
+func invalidPathParam(name, value, expected string) Error {
+	return Error{
+		Code:    http.StatusBadRequest,
+		Message: fmt.Sprintf("Path parameter %q must be %s, got %q", name, expected, value),
+		Kind:    KindInvalidPathParam,
+	}
+}
+
+// PathInt64 returns the path parameter matched by wildcard name as an int64
+//
+//     // GET /api/user/{id}
+//     id, err := httpData.PathInt64("id")
+//     if err != nil {
+//         return nil, err
+//     }
+func (h *HTTP) PathInt64(name string) (int64, error) {
+	v := h.Request.PathValue(name)
+	ret, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, invalidPathParam(name, v, "an integer of 64 bits")
+	}
+	return ret, nil
+}
+
+// PathUUID returns the path parameter matched by wildcard name if it is a UUID in
+// canonical form like "123e4567-e89b-12d3-a456-426614174000". It is lowercased.
+func (h *HTTP) PathUUID(name string) (string, error) {
+	v := h.Request.PathValue(name)
+	if !isUUID(v) {
+		return "", invalidPathParam(name, v, "a UUID")
+	}
+	return strings.ToLower(v), nil
+}
+
+// PathTime returns the path parameter matched by wildcard name parsed with layout
+func (h *HTTP) PathTime(name, layout string) (time.Time, error) {
+	v := h.Request.PathValue(name)
+	ret, err := time.Parse(layout, v)
+	if err != nil {
+		return time.Time{}, invalidPathParam(name, v, "a time like "+layout)
+	}
+	return ret, nil
+}
+
+// isUUID checks if s is a UUID in canonical 8-4-4-4-12 form
+func isUUID(s string) bool {
+	if len(s) != 36 {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch i {
+		case 8, 13, 18, 23:
+			if c != '-' {
+				return false
+			}
+		default:
+			if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// BindPath fills fields of struct pointed by v tagged with `in:"path"` from path
+// parameters. Wildcard name is taken from `name` tag, json tag or the field name,
+// in that order. Strings, numbers, booleans, encoding.TextUnmarshaler and
+// time.Time (`layout` tag, defaults to time.RFC3339) are supported, and
+// `type:"uuid"` validates strings as UUIDs.
+//
+//     // GET /api/user/{id}/log/{date}
+//     type logParam struct {
+//         UserID string    `json:"-" in:"path" name:"id" type:"uuid"`
+//         Date   time.Time `json:"-" in:"path" name:"date" layout:"2006-01-02"`
+//     }
+//
+// Failures are reported by a 400 Error of KindInvalidPathParam.
+func BindPath(httpData *HTTP, v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("jsonapi: BindPath needs pointer to struct, got %T", v)
+	}
+	return bindPath(httpData, rv.Elem())
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+func bindPath(httpData *HTTP, rv reflect.Value) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		fv := rv.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct && sf.Tag.Get("in") == "" {
+			if err := bindPath(httpData, fv); err != nil {
+				return err
+			}
+			continue
+		}
+		if sf.PkgPath != "" || sf.Tag.Get("in") != "path" {
+			continue
+		}
+
+		name := sf.Tag.Get("name")
+		if name == "" {
+			name, _, _ = strings.Cut(sf.Tag.Get("json"), ",")
+		}
+		if name == "" || name == "-" {
+			name = sf.Name
+		}
+		if err := setParam(fv, sf.Tag, name, httpData.Request.PathValue(name), invalidPathParam); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// setParam converts parameter str and stores it in fv, failures are reported by
+// the Error created by invalid
+func setParam(fv reflect.Value, tag reflect.StructTag, name, str string, invalid func(name, value, expected string) Error) error {
+	if fv.Kind() == reflect.Ptr {
+		if fv.IsNil() {
+			fv.Set(reflect.New(fv.Type().Elem()))
+		}
+		fv = fv.Elem()
+	}
+
+	if fv.Type() == timeType {
+		layout := tag.Get("layout")
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.Parse(layout, str)
+		if err != nil {
+			return invalid(name, str, "a time like "+layout)
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	}
+	if u, ok := fv.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		if err := u.UnmarshalText([]byte(str)); err != nil {
+			return invalid(name, str, "a valid "+fv.Type().Name())
+		}
+		return nil
+	}
+
+	switch fv.Kind() {
+	case reflect.String:
+		if tag.Get("type") == "uuid" {
+			if !isUUID(str) {
+				return invalid(name, str, "a UUID")
+			}
+			str = strings.ToLower(str)
+		}
+		fv.SetString(str)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(str, 10, fv.Type().Bits())
+		if err != nil {
+			return invalid(name, str, fmt.Sprintf("an integer of %d bits", fv.Type().Bits()))
+		}
+		fv.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(str, 10, fv.Type().Bits())
+		if err != nil {
+			return invalid(name, str, fmt.Sprintf("an unsigned integer of %d bits", fv.Type().Bits()))
+		}
+		fv.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(str, fv.Type().Bits())
+		if err != nil {
+			return invalid(name, str, "a number")
+		}
+		fv.SetFloat(n)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(str)
+		if err != nil {
+			return invalid(name, str, "a boolean")
+		}
+		fv.SetBool(b)
+	default:
+		return fmt.Errorf("jsonapi: cannot bind parameter %q to %s", name, fv.Type())
+	}
+	return nil
+}
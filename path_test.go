@@ -0,0 +1,138 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// pathTest serves GET /item/{v} with f, and returns the response to uri
+func pathTest(t *testing.T, uri string, f func(httpData *HTTP) (interface{}, error)) (int, ErrorInfo, string) {
+	t.Helper()
+	m := NewMuxTest([]API{{Pattern: "GET /item/{v}", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return f(httpData)
+	}}})
+	resp, _ := m.Get(uri, "")
+	var body ErrorBody
+	if resp.Code != http.StatusOK {
+		json.Unmarshal(resp.Body.Bytes(), &body)
+	}
+	return resp.Code, body.Error, resp.Body.String()
+}
+
+func TestPathAccessors(t *testing.T) {
+	int64Of := func(h *HTTP) (interface{}, error) { return h.PathInt64("v") }
+	uuidOf := func(h *HTTP) (interface{}, error) { return h.PathUUID("v") }
+	dateOf := func(h *HTTP) (interface{}, error) {
+		d, err := h.PathTime("v", "2006-01-02")
+		return d.Format("Jan 2 2006"), err
+	}
+	cases := []struct {
+		uri     string
+		f       func(*HTTP) (interface{}, error)
+		result  string
+		message string
+	}{
+		{"/item/-42", int64Of, "-42", ""},
+		{"/item/9223372036854775807", int64Of, "9223372036854775807", ""},
+		{"/item/9223372036854775808", int64Of, "", `Path parameter "v" must be an integer of 64 bits, got "9223372036854775808"`},
+		{"/item/4x", int64Of, "", `Path parameter "v" must be an integer of 64 bits, got "4x"`},
+		{"/item/123E4567-e89b-12d3-a456-426614174000", uuidOf, `"123e4567-e89b-12d3-a456-426614174000"`, ""},
+		{"/item/123e4567e89b12d3a456426614174000", uuidOf, "", `Path parameter "v" must be a UUID, got "123e4567e89b12d3a456426614174000"`},
+		{"/item/123e4567-e89b-12d3-a456-42661417400g", uuidOf, "", `Path parameter "v" must be a UUID, got "123e4567-e89b-12d3-a456-42661417400g"`},
+		{"/item/123e4567-e89b-12d3-a456-4266141740000", uuidOf, "", `Path parameter "v" must be a UUID, got "123e4567-e89b-12d3-a456-4266141740000"`},
+		{"/item/2024-02-29", dateOf, `"Feb 29 2024"`, ""},
+		{"/item/2023-02-29", dateOf, "", `Path parameter "v" must be a time like 2006-01-02, got "2023-02-29"`},
+	}
+	for _, c := range cases {
+		code, e, body := pathTest(t, c.uri, c.f)
+		if c.message == "" {
+			if code != http.StatusOK || body != c.result+"\n" {
+				t.Errorf("%s: expected %s, got %d %s", c.uri, c.result, code, body)
+			}
+			continue
+		}
+		if code != http.StatusBadRequest || e.Kind != KindInvalidPathParam || e.Message != c.message {
+			t.Errorf("%s: expected %q, got %d %+v", c.uri, c.message, code, e)
+		}
+	}
+}
+
+func TestBindPath(t *testing.T) {
+	bind := func(field string) func(*HTTP) (interface{}, error) {
+		return func(h *HTTP) (interface{}, error) {
+			switch field {
+			case "uuid":
+				var v struct {
+					ID string `in:"path" name:"v" type:"uuid"`
+				}
+				err := BindPath(h, &v)
+				return v.ID, err
+			case "int8":
+				var v struct {
+					Small int8 `json:"v" in:"path"`
+				}
+				err := BindPath(h, &v)
+				return v.Small, err
+			default:
+				var v struct {
+					When *time.Time `in:"path" name:"v" layout:"2006-01-02"`
+				}
+				if err := BindPath(h, &v); err != nil {
+					return nil, err
+				}
+				return v.When.Format("Jan 2 2006"), nil
+			}
+		}
+	}
+	cases := []struct {
+		uri, field, result, message string
+	}{
+		{"/item/123E4567-E89B-12D3-A456-426614174000", "uuid", `"123e4567-e89b-12d3-a456-426614174000"`, ""},
+		{"/item/not-a-uuid", "uuid", "", `Path parameter "v" must be a UUID, got "not-a-uuid"`},
+		{"/item/-128", "int8", `-128`, ""},
+		{"/item/128", "int8", "", `Path parameter "v" must be an integer of 8 bits, got "128"`},
+		{"/item/2024-01-31", "time", `"Jan 31 2024"`, ""},
+		{"/item/tomorrow", "time", "", `Path parameter "v" must be a time like 2006-01-02, got "tomorrow"`},
+	}
+	for _, c := range cases {
+		code, e, body := pathTest(t, c.uri, bind(c.field))
+		if c.message == "" {
+			if code != http.StatusOK || body != c.result+"\n" {
+				t.Errorf("%s: expected %s, got %d %s", c.uri, c.result, code, body)
+			}
+			continue
+		}
+		if code != http.StatusBadRequest || e.Kind != KindInvalidPathParam || e.Message != c.message {
+			t.Errorf("%s: expected %q, got %d %+v", c.uri, c.message, code, e)
+		}
+	}
+}
+
+func TestBindPathEmbedded(t *testing.T) {
+	type base struct {
+		ID int64 `json:"v" in:"path"`
+	}
+	var got int64
+	code, _, body := pathTest(t, "/item/7", func(h *HTTP) (interface{}, error) {
+		var v struct {
+			base
+			Name string `json:"name"`
+		}
+		err := BindPath(h, &v)
+		got = v.ID
+		return nil, err
+	})
+	if code != http.StatusOK || got != 7 {
+		t.Errorf("embedded field not bound: %d %d %s", code, got, body)
+	}
+
+	var notStruct int
+	pathTest(t, "/item/7", func(h *HTTP) (interface{}, error) {
+		if err := BindPath(h, &notStruct); err == nil {
+			t.Errorf("BindPath accepts pointer to int")
+		}
+		return nil, nil
+	})
+}
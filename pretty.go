@@ -0,0 +1,105 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"strconv"
+)
+
+var indent struct {
+	prefix, indent string
+}
+
+var escapeHTML = true
+
+// SetIndent makes responses indented like json.MarshalIndent, including errors.
+// SetIndent("", "") restores compact responses. It is not safe to call while
+// serving requests.
+//
+//     if devMode {
+//         jsonapi.SetIndent("", "  ")
+//     }
+//
+// Stream and SSE still send one line per value, and json.RawMessage returned by
+// handlers is sent byte for byte.
+func SetIndent(prefix, ind string) {
+	indent.prefix, indent.indent = prefix, ind
+}
+
+// PrettyQuery indents responses of requests with query parameter "pretty", like
+// ?pretty=1, with two spaces unless SetIndent is called. ?pretty=0 or false asks
+// for compact ones. It is for reading responses during development without piping
+// them through jq, so it is disabled by default.
+var PrettyQuery bool
+
+// indentation returns prefix and indent of the response, ok is false if it is
+// compact
+func (h *HTTP) indentation() (prefix, ind string, ok bool) {
+	prefix, ind = indent.prefix, indent.indent
+	ok = prefix != "" || ind != ""
+	if !PrettyQuery || h.Request == nil {
+		return
+	}
+	v, set := h.Request.URL.Query()["pretty"]
+	if !set {
+		return
+	}
+	if on, err := strconv.ParseBool(v[0]); err == nil && !on {
+		return "", "", false
+	}
+	if !ok {
+		ind = "  "
+	}
+	return prefix, ind, true
+}
+
+// SetEscapeHTML(false) stops escaping <, > and & in strings of responses as
+// \u003c, \u003e and \u0026, so URLs like "/search?q=go&page=2" are sent as
+// they are. They are escaped by default like json.Marshal does, which is safer if
+// responses can be embedded in HTML. It applies to errors, Stream and SSE too, and
+// is not safe to call while serving requests.
+func SetEscapeHTML(on bool) {
+	escapeHTML = on
+}
+
+// newEncoder creates an encoder writing to w, escaping HTML as SetEscapeHTML sets
+func newEncoder(w io.Writer) *json.Encoder {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(escapeHTML)
+	return enc
+}
+
+// marshal is json.Marshal escaping HTML as SetEscapeHTML sets
+func marshal(v interface{}) ([]byte, error) {
+	if escapeHTML {
+		return json.Marshal(v)
+	}
+	buf := &bytes.Buffer{}
+	if err := newEncoder(buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
+
+// configureEncoder applies SetEscapeHTML and indentation of the response to enc
+func (h *HTTP) configureEncoder(enc *json.Encoder) *json.Encoder {
+	enc.SetEscapeHTML(escapeHTML)
+	if prefix, ind, ok := h.indentation(); ok {
+		enc.SetIndent(prefix, ind)
+	}
+	return enc
+}
+
+// pretty indents encoded JSON in buf if needed
+func (h *HTTP) pretty(buf []byte) []byte {
+	prefix, ind, ok := h.indentation()
+	if !ok {
+		return buf
+	}
+	out := &bytes.Buffer{}
+	if err := json.Indent(out, buf, prefix, ind); err != nil {
+		return buf
+	}
+	return out.Bytes()
+}
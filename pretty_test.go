@@ -0,0 +1,144 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type htmlLink struct {
+	URL   string `json:"url"`
+	Label string `json:"label,string"`
+	Hits  int64  `json:"hits"`
+	Note  string `json:"note"`
+}
+
+func TestSetEscapeHTMLWithRewriter(t *testing.T) {
+	SetEscapeHTML(false)
+	defer SetEscapeHTML(true)
+	RegisterVariant("link", htmlLink{})
+	defer func() {
+		variants.Lock()
+		delete(variants.names, reflect.TypeOf(htmlLink{}))
+		delete(variants.names, reflect.TypeOf(&htmlLink{}))
+		delete(variants.types, "link")
+		variants.Unlock()
+	}()
+
+	link := htmlLink{URL: "/search?q=go&page=2", Label: "<b>", Hits: 1}
+	m := NewMuxTest([]API{
+		{Pattern: "/plain", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return link, nil
+		}},
+		{Pattern: "/int64", Int64AsString: true, APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return link, nil
+		}},
+		{Pattern: "/omit", OmitZero: OmitAllZero, APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return link, nil
+		}},
+		{Pattern: "/variant", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return []interface{}{link}, nil
+		}},
+		{Pattern: "/limited", MaxResponseBytes: 1024, APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return link, nil
+		}},
+	})
+	for _, uri := range []string{"/plain", "/int64", "/omit", "/variant", "/limited"} {
+		resp, err := m.Get(uri, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body := resp.Body.String()
+		if !strings.Contains(body, `"/search?q=go&page=2"`) || !strings.Contains(body, `"\"<b>\""`) || strings.Contains(body, `\u00`) {
+			t.Errorf("%s: HTML is escaped: %s", uri, body)
+		}
+	}
+}
+
+func TestEscapeHTMLByDefault(t *testing.T) {
+	m := NewMuxTest([]API{{Pattern: "/", Int64AsString: true, APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return htmlLink{URL: "a&b"}, nil
+	}}})
+	resp, err := m.Get("/", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := resp.Body.String(); !strings.Contains(body, `"a\u0026b"`) {
+		t.Errorf("HTML is not escaped: %s", body)
+	}
+}
+
+var prettyAPIs = []API{
+	{Pattern: "/user", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return map[string]interface{}{"name": "john", "tags": []string{"a&b"}}, nil
+	}},
+	{Pattern: "/limited", MaxResponseBytes: 1024, APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return map[string]interface{}{"name": "john", "tags": []string{"a&b"}}, nil
+	}},
+	{Pattern: "/missing", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return nil, E404.SetData("User not found")
+	}},
+	{Pattern: "/raw", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return json.RawMessage(`{"a":1}`), nil
+	}},
+}
+
+func TestSetIndent(t *testing.T) {
+	SetIndent("", "\t")
+	defer SetIndent("", "")
+	m := NewMuxTest(prettyAPIs)
+	user := "{\n\t\"name\": \"john\",\n\t\"tags\": [\n\t\t\"a\\u0026b\"\n\t]\n}\n"
+	for uri, expect := range map[string]string{
+		"/user":     user,
+		"/limited":  user,
+		"/missing":  "{\n\t\"error\": {\n\t\t\"code\": 404,\n\t\t\"message\": \"User not found\"\n\t}\n}\n",
+		"/raw":      `{"a":1}`,
+		"/user?x=1": user,
+	} {
+		if resp, _ := m.Get(uri, ""); resp.Body.String() != expect {
+			t.Errorf("%s: expected %q, got %q", uri, expect, resp.Body)
+		}
+	}
+
+	SetEscapeHTML(false)
+	defer SetEscapeHTML(true)
+	if resp, _ := m.Get("/user", ""); resp.Body.String() != strings.Replace(user, `\u0026`, "&", 1) {
+		t.Errorf("HTML is escaped: %q", resp.Body)
+	}
+}
+
+func TestPrettyQuery(t *testing.T) {
+	m := NewMuxTest(prettyAPIs)
+	compact := `{"name":"john","tags":["a\u0026b"]}` + "\n"
+	if resp, _ := m.Get("/user?pretty", ""); resp.Body.String() != compact {
+		t.Errorf("pretty without PrettyQuery: %q", resp.Body)
+	}
+
+	PrettyQuery = true
+	defer func() { PrettyQuery = false }()
+	pretty := "{\n  \"name\": \"john\",\n  \"tags\": [\n    \"a\\u0026b\"\n  ]\n}\n"
+	for uri, expect := range map[string]string{
+		"/user":              compact,
+		"/user?pretty":       pretty,
+		"/user?pretty=1":     pretty,
+		"/limited?pretty":    pretty,
+		"/user?pretty=0":     compact,
+		"/user?pretty=false": compact,
+		"/missing?pretty":    "{\n  \"error\": {\n    \"code\": 404,\n    \"message\": \"User not found\"\n  }\n}\n",
+	} {
+		if resp, _ := m.Get(uri, ""); resp.Body.String() != expect {
+			t.Errorf("%s: expected %q, got %q", uri, expect, resp.Body)
+		}
+	}
+
+	// ?pretty=0 asks for compact responses even with SetIndent
+	SetIndent("", "\t")
+	defer SetIndent("", "")
+	if resp, _ := m.Get("/user?pretty=0", ""); resp.Body.String() != compact {
+		t.Errorf("pretty=0 with SetIndent: %q", resp.Body)
+	}
+	if resp, _ := m.Get("/user?pretty", ""); !strings.HasPrefix(resp.Body.String(), "{\n\t\"name\"") {
+		t.Errorf("indent of SetIndent is not used: %q", resp.Body)
+	}
+}
@@ -0,0 +1,50 @@
+package jsonapi
+
+import (
+	"net/http"
+	"strings"
+)
+
+// ProblemTypeBase is prefixed to error kind to build the type of problem documents,
+// like "https://example.com/problems/". Problems have type "about:blank" if it is empty.
+var ProblemTypeBase string
+
+// Problem is an RFC 7807 problem document
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	Details interface{} `json:"details,omitempty"` // extension member from Error.Details
+}
+
+// ProblemEncoder is an ErrorEncoder which sends errors as application/problem+json
+// documents defined in RFC 7807.
+//
+// Type is ProblemTypeBase followed by Error.Kind, or by a name derived from the status
+// code if Kind is empty. Instance is the path of the request.
+func ProblemEncoder(httpData *HTTP, code int, err error) interface{} {
+	p := Problem{
+		Type:     "about:blank",
+		Title:    http.StatusText(code),
+		Status:   code,
+		Detail:   err.Error(),
+		Instance: httpData.Request.URL.Path,
+	}
+
+	kind := strings.ReplaceAll(strings.ToLower(p.Title), " ", "-")
+	if e, ok := err.(Error); ok {
+		p.Detail, p.Details = e.Message, e.Details
+		if e.Kind != "" {
+			kind = e.Kind
+		}
+	}
+	if ProblemTypeBase != "" && kind != "" {
+		p.Type = ProblemTypeBase + kind
+	}
+
+	httpData.ResponseWriter.Header().Set("Content-Type", "application/problem+json")
+	return p
+}
@@ -0,0 +1,84 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestProblemEncoder(t *testing.T) {
+	SetErrorEncoder(ProblemEncoder)
+	ProblemTypeBase = "https://example.com/problems/"
+	defer func() {
+		SetErrorEncoder(nil)
+		ProblemTypeBase = ""
+	}()
+
+	fields := map[string]string{"email": "required"}
+	m := NewMuxTest([]API{
+		{Pattern: "/api/user", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			e := E422.SetData("Invalid user").WithDetails(fields)
+			e.Kind = "validation"
+			return nil, e
+		}},
+		{Pattern: "/api/ok", APIHandler: okAPI},
+	})
+	RegisterFallback(m.Mux, nil, nil)
+
+	cases := []struct {
+		uri    string
+		expect Problem
+	}{
+		{"/api/nowhere", Problem{
+			Type:     "https://example.com/problems/not-found",
+			Title:    "Not Found",
+			Status:   http.StatusNotFound,
+			Detail:   E404.Message,
+			Instance: "/api/nowhere",
+		}},
+		{"/api/user", Problem{
+			Type:     "https://example.com/problems/validation",
+			Title:    "Unprocessable Entity",
+			Status:   http.StatusUnprocessableEntity,
+			Detail:   "Invalid user",
+			Instance: "/api/user",
+			Details:  map[string]interface{}{"email": "required"},
+		}},
+	}
+	for _, c := range cases {
+		resp, _ := m.Get(c.uri, "")
+		if resp.Code != c.expect.Status {
+			t.Errorf("%s: expected %d, got %d", c.uri, c.expect.Status, resp.Code)
+		}
+		if ct := resp.Header().Get("Content-Type"); ct != "application/problem+json" {
+			t.Errorf("%s: Content-Type = %q", c.uri, ct)
+		}
+		var p Problem
+		if err := json.Unmarshal(resp.Body.Bytes(), &p); err != nil {
+			t.Fatalf("%s: %s", c.uri, err)
+		}
+		if !reflect.DeepEqual(p, c.expect) {
+			t.Errorf("%s: expected %+v, got %+v", c.uri, c.expect, p)
+		}
+	}
+
+	resp, _ := m.Get("/api/ok", "")
+	if ct := resp.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("successful response has Content-Type %q", ct)
+	}
+}
+
+func TestProblemEncoderBlankType(t *testing.T) {
+	SetErrorEncoder(ProblemEncoder)
+	defer SetErrorEncoder(nil)
+
+	resp, _ := NewMuxTest([]API{{Pattern: "/", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return nil, E404
+	}}}).Get("/nowhere", "")
+	var p Problem
+	json.Unmarshal(resp.Body.Bytes(), &p)
+	if p.Type != "about:blank" {
+		t.Errorf("expected about:blank, got %q", p.Type)
+	}
+}
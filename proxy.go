@@ -0,0 +1,222 @@
+package jsonapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// hopHeaders are meaningful only for a single connection, and must not be forwarded by proxies.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// maxTranslateBody limits size of upstream error body passed to ProxyOpts.TranslateError
+const maxTranslateBody = 1 << 20
+
+// ProxyOpts controls how Proxy forwards requests
+type ProxyOpts struct {
+	// StripPrefix is removed from request path, and AddPrefix is prepended to it
+	// before joining with the path of upstream url.
+	StripPrefix string
+	AddPrefix   string
+
+	// Headers lists request headers to forward. Nil forwards Accept, Content-Type,
+	// Authorization and Cookie.
+	Headers []string
+
+	// Timeout limits whole upstream round-trip, including streaming the response.
+	// Zero means no limit.
+	Timeout time.Duration
+
+	// Retries is how many more times to try when failed to connect to upstream.
+	// The request body is buffered in memory if Retries > 0.
+	Retries int
+
+	// Client sends the requests, defaults to http.DefaultClient.
+	Client *http.Client
+
+	// TranslateError, if not nil, is called with status code and body of upstream
+	// responses with status >= 400. A non-nil return value is sent to client the same
+	// way as errors returned by APIHandler. Returning nil passes the response through.
+	TranslateError func(code int, body []byte) error
+}
+
+var defaultProxyHeaders = []string{"Accept", "Content-Type", "Authorization", "Cookie"}
+
+// Proxy creates an APIHandler which forwards requests to upstream.
+//
+//     legacy, _ := url.Parse("http://legacy.local/api")
+//     jsonapi.API{
+//         Pattern: "/api/v1/orders/",
+//         APIHandler: jsonapi.Proxy(legacy, jsonapi.ProxyOpts{
+//             StripPrefix: "/api/v1",
+//             Timeout:     5 * time.Second,
+//         }),
+//     }
+//
+// Method, path, query string, selected headers and body are forwarded, with
+// X-Forwarded-For, X-Forwarded-Host and X-Forwarded-Proto added. Upstream response
+// is streamed back to client as-is, unless converted by opts.TranslateError. It is
+// limited by MaxResponseBytes like Raw, and rejected before sending anything if
+// its Content-Length is beyond the limit.
+func Proxy(upstream *url.URL, opts ProxyOpts) APIHandler {
+	client := opts.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	headers := opts.Headers
+	if headers == nil {
+		headers = defaultProxyHeaders
+	}
+
+	return func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		r := httpData.Request
+		ctx, cancel := r.Context(), context.CancelFunc(func() {})
+		if opts.Timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		}
+
+		var body []byte
+		if opts.Retries > 0 && r.Body != nil {
+			var err error
+			if body, err = ioutil.ReadAll(r.Body); err != nil {
+				cancel()
+				return nil, E400.SetData("Cannot read request body")
+			}
+		}
+
+		var resp *http.Response
+		var err error
+		for i := 0; i <= opts.Retries; i++ {
+			req := proxyRequest(ctx, upstream, opts, headers, r, body)
+			if resp, err = client.Do(req); err == nil || !isDialError(err) {
+				break
+			}
+		}
+		if err != nil {
+			cancel()
+			if errors.Is(err, context.DeadlineExceeded) {
+				return nil, E504.SetData("Upstream timeout")
+			}
+			return nil, E502
+		}
+
+		if opts.TranslateError != nil && resp.StatusCode >= 400 {
+			buf, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxTranslateBody))
+			resp.Body.Close()
+			if err != nil {
+				cancel()
+				return nil, E502
+			}
+			if err := opts.TranslateError(resp.StatusCode, buf); err != nil {
+				cancel()
+				return nil, err
+			}
+			resp.Body = ioutil.NopCloser(bytes.NewReader(buf))
+		}
+
+		return proxyResponse{resp, cancel}, nil
+	}
+}
+
+func proxyRequest(ctx context.Context, upstream *url.URL, opts ProxyOpts, headers []string, r *http.Request, body []byte) *http.Request {
+	u := *upstream
+	p := strings.TrimPrefix(r.URL.Path, opts.StripPrefix)
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + strings.TrimPrefix(opts.AddPrefix+p, "/")
+	u.RawPath = ""
+	switch {
+	case u.RawQuery == "":
+		u.RawQuery = r.URL.RawQuery
+	case r.URL.RawQuery != "":
+		u.RawQuery += "&" + r.URL.RawQuery
+	}
+
+	var reader io.Reader = r.Body
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		reader = nil
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, r.Method, u.String(), reader)
+	if reader != nil && body == nil {
+		// r.Body might be decompressed, transcoded or stripped of BOM, so its length
+		// is unknown until read
+		req.ContentLength = -1
+	}
+	for _, k := range headers {
+		for _, v := range r.Header.Values(k) {
+			req.Header.Add(k, v)
+		}
+	}
+
+	host := r.RemoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if prior := r.Header.Get("X-Forwarded-For"); prior != "" && host != "" {
+		host = prior + ", " + host
+	} else if prior != "" {
+		host = prior
+	}
+	if host != "" {
+		req.Header.Set("X-Forwarded-For", host)
+	}
+	req.Header.Set("X-Forwarded-Host", r.Host)
+	proto := "http"
+	if r.TLS != nil {
+		proto = "https"
+	}
+	req.Header.Set("X-Forwarded-Proto", proto)
+
+	return req
+}
+
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// proxyResponse streams upstream response to client
+type proxyResponse struct {
+	resp   *http.Response
+	cancel context.CancelFunc
+}
+
+func (p proxyResponse) respond(httpData *HTTP) {
+	defer p.cancel()
+	defer p.resp.Body.Close()
+
+	lw := httpData.limitStream(httpData.ResponseWriter)
+	if lw.limit > 0 && p.resp.ContentLength > lw.limit {
+		writeError(httpData.encoder(), httpData, responseTooLarge(p.resp.ContentLength, lw.limit))
+		return
+	}
+	h := httpData.ResponseWriter.Header()
+	for k, v := range p.resp.Header {
+		h[k] = v
+	}
+	for _, k := range hopHeaders {
+		h.Del(k)
+	}
+	httpData.WriteHeader(p.resp.StatusCode)
+	io.Copy(lw, p.resp.Body)
+	lw.reportCut(httpData)
+}
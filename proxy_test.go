@@ -0,0 +1,173 @@
+package jsonapi
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func proxyTest(t *testing.T, upstream http.HandlerFunc, opts ProxyOpts) *MuxTest {
+	t.Helper()
+	srv := httptest.NewServer(upstream)
+	t.Cleanup(srv.Close)
+	u, _ := url.Parse(srv.URL + "/legacy")
+	return NewMuxTest([]API{{Pattern: "/api/v1/", APIHandler: Proxy(u, opts)}})
+}
+
+func TestProxy(t *testing.T) {
+	var got *http.Request
+	var gotBody string
+	m := proxyTest(t, func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		buf, _ := ioutil.ReadAll(r.Body)
+		gotBody = string(buf)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Upstream", "1")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":7}`))
+	}, ProxyOpts{StripPrefix: "/api/v1", AddPrefix: "/v0"})
+
+	resp, err := m.With(Headers{"Authorization": "Bearer x", "X-Secret": "s", "X-Forwarded-For": "10.0.0.1"}).Post("/api/v1/orders?page=2", "", `{"item":"a"}`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if resp.Code != http.StatusCreated || resp.Body.String() != `{"id":7}` || resp.Header().Get("X-Upstream") != "1" {
+		t.Errorf("response not passed through: %d %v %s", resp.Code, resp.Header(), resp.Body)
+	}
+	if got.Method != "POST" || got.URL.Path != "/legacy/v0/orders" || got.URL.RawQuery != "page=2" {
+		t.Errorf("forwarded to %s %s", got.Method, got.URL)
+	}
+	if gotBody != `{"item":"a"}` {
+		t.Errorf("forwarded body %q", gotBody)
+	}
+	if got.Header.Get("Authorization") != "Bearer x" || got.Header.Get("X-Secret") != "" {
+		t.Errorf("forwarded headers %v", got.Header)
+	}
+	if !strings.HasPrefix(got.Header.Get("X-Forwarded-For"), "10.0.0.1") || got.Header.Get("X-Forwarded-Proto") != "http" {
+		t.Errorf("X-Forwarded-* missing: %v", got.Header)
+	}
+}
+
+func TestProxyTranslateError(t *testing.T) {
+	m := proxyTest(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/legacy/gone" {
+			http.Error(w, "gone", http.StatusGone)
+			return
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(`{"errmsg":"db down"}`))
+	}, ProxyOpts{StripPrefix: "/api/v1", TranslateError: func(code int, body []byte) error {
+		if code != http.StatusInternalServerError {
+			return nil
+		}
+		var legacy struct{ Errmsg string }
+		if err := json.Unmarshal(body, &legacy); err != nil {
+			return err
+		}
+		return E502.SetData(legacy.Errmsg)
+	}})
+
+	resp, _ := m.Get("/api/v1/orders", "")
+	var body ErrorBody
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatalf("not an error envelope: %s", resp.Body)
+	}
+	if resp.Code != http.StatusBadGateway || body.Error.Message != "db down" {
+		t.Errorf("got %d %s", resp.Code, resp.Body)
+	}
+
+	resp, _ = m.Get("/api/v1/gone", "")
+	if resp.Code != http.StatusGone || resp.Body.String() != "gone\n" {
+		t.Errorf("untranslated error: got %d %q", resp.Code, resp.Body)
+	}
+}
+
+func TestProxyTimeout(t *testing.T) {
+	m := proxyTest(t, func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}, ProxyOpts{Timeout: 20 * time.Millisecond})
+
+	resp, _ := m.Get("/api/v1/slow", "")
+	if resp.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected 504, got %d %s", resp.Code, resp.Body)
+	}
+}
+
+type failingTransport struct {
+	calls int
+	err   error
+}
+
+func (f *failingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	f.calls++
+	if f.err != nil {
+		return nil, f.err
+	}
+	return nil, errors.New("unexpected")
+}
+
+func TestProxyRetry(t *testing.T) {
+	// nothing listens on a closed server
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	u, _ := url.Parse(srv.URL)
+	m := NewMuxTest([]API{{Pattern: "/", APIHandler: Proxy(u, ProxyOpts{Retries: 2})}})
+	if resp, _ := m.Post("/", "", `{}`); resp.Code != http.StatusBadGateway {
+		t.Errorf("expected 502, got %d %s", resp.Code, resp.Body)
+	}
+
+	tr := &failingTransport{err: &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}}
+	m = NewMuxTest([]API{{Pattern: "/", APIHandler: Proxy(u, ProxyOpts{Retries: 2, Client: &http.Client{Transport: tr}})}})
+	if resp, _ := m.Post("/", "", `{}`); resp.Code != http.StatusBadGateway || tr.calls != 3 {
+		t.Errorf("dial failures: got %d after %d attempts, expected 502 after 3", resp.Code, tr.calls)
+	}
+
+	// only connect failures are retried
+	tr = &failingTransport{}
+	m = NewMuxTest([]API{{Pattern: "/", APIHandler: Proxy(u, ProxyOpts{Retries: 2, Client: &http.Client{Transport: tr}})}})
+	m.Get("/", "")
+	if tr.calls != 1 {
+		t.Errorf("sent %d times, expected 1", tr.calls)
+	}
+}
+
+func TestProxyRewrittenBody(t *testing.T) {
+	var gotBody string
+	m := proxyTest(t, func(w http.ResponseWriter, r *http.Request) {
+		buf, _ := ioutil.ReadAll(r.Body)
+		gotBody = string(buf)
+	}, ProxyOpts{})
+
+	var gz strings.Builder
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(`{"item":"a"}`))
+	zw.Close()
+	cases := []struct {
+		name, encoding, body string
+	}{
+		{"BOM", "", "\xef\xbb\xbf" + `{"item":"a"}`},
+		{"gzip", "gzip", gz.String()},
+	}
+	for _, c := range cases {
+		gotBody = ""
+		req := newRequest("POST", "/api/v1/orders", c.body)
+		req.Header.Set("Content-Type", "application/json")
+		if c.encoding != "" {
+			req.Header.Set("Content-Encoding", c.encoding)
+		}
+		if resp := m.Do(req); resp.Code != http.StatusOK || gotBody != `{"item":"a"}` {
+			t.Errorf("%s: got %d %s, forwarded %q", c.name, resp.Code, resp.Body, gotBody)
+		}
+	}
+}
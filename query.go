@@ -0,0 +1,103 @@
+package jsonapi
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// KindInvalidQueryParam is the Kind of Error sent when a query parameter cannot be
+// converted to expected type
+const KindInvalidQueryParam = "invalid_query_param"
+
+// invalidQueryParam creates the 400 Error naming parameter, received value and expected type
+func invalidQueryParam(name, value, expected string) Error {
+	return Error{
+		Code:    http.StatusBadRequest,
+		Message: fmt.Sprintf("Query parameter %q must be %s, got %q", name, expected, value),
+		Kind:    KindInvalidQueryParam,
+	}
+}
+
+// DecodeQuery fills fields of struct pointed by v from query parameters, and runs
+// validators of v like Bind. Parameter name is taken from `query` tag, json tag or
+// the field name, in that order, and `query:"-"` or `json:"-"` skips the field.
+// Types supported by BindPath are supported, and slices of them are filled from
+// repeated parameters. Fields of missing parameters are kept untouched, so defaults
+// can be set before calling it.
+//
+//     // GET /api/users?role=admin&role=owner&limit=10
+//     type listParam struct {
+//         Roles []string `query:"role"`
+//         Limit int      `query:"limit"`
+//     }
+//
+//     p := listParam{Limit: 20}
+//     if err := httpData.DecodeQuery(&p); err != nil {
+//         return nil, err
+//     }
+//
+// Conversion failures are reported by a 400 Error of KindInvalidQueryParam.
+func (h *HTTP) DecodeQuery(v interface{}) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("jsonapi: DecodeQuery needs pointer to struct, got %T", v)
+	}
+	if err := bindQuery(h.Request.URL.Query(), rv.Elem()); err != nil {
+		return err
+	}
+	return validate(h, v)
+}
+
+func bindQuery(q map[string][]string, rv reflect.Value) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		fv := rv.Field(i)
+		if sf.Anonymous && sf.Type.Kind() == reflect.Struct && sf.Tag.Get("query") == "" {
+			if err := bindQuery(q, fv); err != nil {
+				return err
+			}
+			continue
+		}
+		if sf.PkgPath != "" || sf.Tag.Get("in") == "path" {
+			continue
+		}
+
+		name := sf.Tag.Get("query")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			tag := sf.Tag.Get("json")
+			if tag == "-" {
+				// hidden from request bodies, so it is not bound from query either
+				continue
+			}
+			name, _, _ = strings.Cut(tag, ",")
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		values, ok := q[name]
+		if !ok || len(values) == 0 {
+			continue
+		}
+
+		if fv.Kind() == reflect.Slice && !reflect.PtrTo(fv.Type()).Implements(textUnmarshalerType) {
+			list := reflect.MakeSlice(fv.Type(), len(values), len(values))
+			for j, str := range values {
+				if err := setParam(list.Index(j), sf.Tag, name, str, invalidQueryParam); err != nil {
+					return err
+				}
+			}
+			fv.Set(list)
+			continue
+		}
+		if err := setParam(fv, sf.Tag, name, values[0], invalidQueryParam); err != nil {
+			return err
+		}
+	}
+	return nil
+}
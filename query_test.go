@@ -0,0 +1,66 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type queryParam struct {
+	Roles   []string `query:"role"`
+	Limit   int      `json:"limit,omitempty"`
+	Page    int
+	Admin   bool   `json:"-"`
+	Owner   string `json:"-" query:"owner"`
+	Dash    string `json:"-,"`
+	Ignored string `query:"-"`
+	private string
+}
+
+func TestDecodeQuery(t *testing.T) {
+	var got queryParam
+	m := NewMuxTest([]API{{Pattern: "/", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		got = queryParam{Limit: 20}
+		return nil, httpData.DecodeQuery(&got)
+	}}})
+
+	resp, err := m.Get("/?role=a&role=b&limit=5&Page=2&Admin=true&admin=true&owner=me&-=x&Ignored=1&private=1", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != http.StatusOK {
+		t.Fatalf("got %d %s", resp.Code, resp.Body)
+	}
+	want := queryParam{Roles: []string{"a", "b"}, Limit: 5, Page: 2, Owner: "me", Dash: "x"}
+	if strings.Join(got.Roles, ",") != "a,b" || got.Limit != want.Limit || got.Page != want.Page ||
+		got.Admin || got.Owner != want.Owner || got.Dash != want.Dash || got.Ignored != "" || got.private != "" {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+
+	resp, err = m.Get("/", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != http.StatusOK || got.Limit != 20 {
+		t.Errorf("default is not kept: %d %+v", resp.Code, got)
+	}
+}
+
+func TestDecodeQueryInvalid(t *testing.T) {
+	m := NewMuxTest([]API{{Pattern: "/", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		var p queryParam
+		return nil, httpData.DecodeQuery(&p)
+	}}})
+	resp, err := m.Get("/?limit=ten", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body ErrorBody
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != http.StatusBadRequest || body.Error.Kind != KindInvalidQueryParam {
+		t.Errorf("got %d %s", resp.Code, resp.Body)
+	}
+}
@@ -0,0 +1,238 @@
+package jsonapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// KindRateLimited is the Kind of Error sent when client exceeds the rate limit
+const KindRateLimited = "rate_limited"
+
+// RateLimitStore keeps states of RateLimiter. Operations must be atomic, so many
+// servers can share one store.
+type RateLimitStore interface {
+	// Increment adds one to counter of key, which is created with ttl if not
+	// exists. It returns new count and time left before the counter expires.
+	Increment(ctx context.Context, key string, ttl time.Duration) (count int64, left time.Duration, err error)
+
+	// TakeToken takes a token from bucket of key, which is refilled with rate
+	// tokens per second and holds at most burst tokens. It returns whether a token
+	// is taken, tokens remaining, and time to wait for next token.
+	TakeToken(ctx context.Context, key string, rate float64, burst int64) (ok bool, remaining int64, wait time.Duration, err error)
+}
+
+// RateLimitOpts configures a RateLimiter
+type RateLimitOpts struct {
+	// Limit is number of requests allowed in Window
+	Limit  int64
+	Window time.Duration
+
+	// Burst selects token bucket algorithm if > 0: Limit/Window tokens are added
+	// per second, up to Burst. Otherwise requests are counted in fixed windows.
+	Burst int64
+
+	// Key identifies clients, defaults to IP address of client, see ClientIP
+	Key func(httpData *HTTP) string
+
+	// Store keeps states, defaults to an in-memory store
+	Store RateLimitStore
+
+	// FailClosed rejects requests with 503 when Store fails. By default they are
+	// allowed (fail-open), so an outage of the store does not take down the API,
+	// with the risk of not limiting at all during the outage.
+	FailClosed bool
+
+	// OnStoreError is called with errors from Store, optional
+	OnStoreError func(httpData *HTTP, err error)
+}
+
+// RateLimiter limits how many requests a client can send. Responses carry
+// X-RateLimit-Limit, X-RateLimit-Remaining and X-RateLimit-Reset (in seconds)
+// headers, and requests over the limit are rejected by a 429 Error of
+// KindRateLimited with a Retry-After header.
+//
+//     limiter := jsonapi.NewRateLimiter(jsonapi.RateLimitOpts{Limit: 100, Window: time.Minute})
+//     http.Handle("/api/", jsonapi.HTTPHandler(limiter.Middleware(myHandler)))
+//
+// The default store lives in memory of one process. Use a shared store like
+// package redisstore when running many replicas, so they see the same counters.
+// Counters may be a bit inaccurate as clocks of replicas differ, and requests
+// in flight when the store fails are governed by FailClosed.
+type RateLimiter struct {
+	opts RateLimitOpts
+}
+
+// NewRateLimiter creates a RateLimiter. It panics if opts.Limit is not positive,
+// which would refill no token, or reject every request.
+func NewRateLimiter(opts RateLimitOpts) *RateLimiter {
+	if opts.Limit <= 0 {
+		panic(fmt.Sprintf("jsonapi: RateLimitOpts.Limit must be positive, got %d", opts.Limit))
+	}
+	if opts.Window <= 0 {
+		opts.Window = time.Minute
+	}
+	if opts.Key == nil {
+		opts.Key = remoteIP
+	}
+	if opts.Store == nil {
+		opts.Store = NewMemoryRateLimitStore()
+	}
+	return &RateLimiter{opts: opts}
+}
+
+// RateLimit creates a Middleware limiting requests with a new RateLimiter, for
+// API.Middlewares or Group. Options are validated by NewRateLimiter when RateLimit
+// is called, so invalid ones panic while building routes, not at the first request.
+//
+//     api := jsonapi.API{Pattern: "/api/login", APIHandler: login, Middlewares: []jsonapi.Middleware{
+//         jsonapi.RateLimit(jsonapi.RateLimitOpts{Limit: 5, Window: time.Minute, Burst: 5}),
+//     }}
+func RateLimit(opts RateLimitOpts) Middleware {
+	return NewRateLimiter(opts).Middleware
+}
+
+// remoteIP is the default key of RateLimiter
+func remoteIP(httpData *HTTP) string {
+	if ip := httpData.ClientIP(); ip != "" {
+		return ip
+	}
+	return httpData.Request.RemoteAddr
+}
+
+// Middleware rejects requests to next if the client exceeds the limit
+func (l *RateLimiter) Middleware(next HTTPHandler) HTTPHandler {
+	return func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		if err := l.allow(httpData); err != nil {
+			writeError(enc, httpData, err)
+			return
+		}
+		next(enc, dec, httpData)
+	}
+}
+
+// allow takes a quota for the request and sets headers
+func (l *RateLimiter) allow(httpData *HTTP) error {
+	var (
+		ok        bool
+		remaining int64
+		reset     time.Duration
+		err       error
+	)
+	ctx := httpData.Request.Context()
+	key := l.opts.Key(httpData)
+	limit := l.opts.Limit
+	if l.opts.Burst > 0 {
+		limit = l.opts.Burst
+		rate := float64(l.opts.Limit) / l.opts.Window.Seconds()
+		ok, remaining, reset, err = l.opts.Store.TakeToken(ctx, key, rate, l.opts.Burst)
+	} else {
+		var count int64
+		count, reset, err = l.opts.Store.Increment(ctx, key, l.opts.Window)
+		ok, remaining = count <= limit, limit-count
+	}
+
+	if err != nil {
+		if l.opts.OnStoreError != nil {
+			l.opts.OnStoreError(httpData, err)
+		}
+		if l.opts.FailClosed {
+			return Error{Code: http.StatusServiceUnavailable, Message: "Rate limiter is unavailable"}
+		}
+		return nil
+	}
+
+	if remaining < 0 {
+		remaining = 0
+	}
+	secs := strconv.FormatInt(int64(math.Ceil(reset.Seconds())), 10)
+	header := httpData.ResponseWriter.Header()
+	header.Set("X-RateLimit-Limit", strconv.FormatInt(limit, 10))
+	header.Set("X-RateLimit-Remaining", strconv.FormatInt(remaining, 10))
+	header.Set("X-RateLimit-Reset", secs)
+	if !ok {
+		header.Set("Retry-After", secs)
+		return Error{Code: http.StatusTooManyRequests, Message: "Too many requests", Kind: KindRateLimited}
+	}
+	return nil
+}
+
+// MemoryRateLimitStore is a RateLimitStore in memory of current process
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	entries map[string]*rateEntry
+	ops     int
+}
+
+type rateEntry struct {
+	count   int64
+	tokens  float64
+	updated time.Time
+	expires time.Time
+}
+
+// NewMemoryRateLimitStore creates a MemoryRateLimitStore
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{entries: map[string]*rateEntry{}}
+}
+
+// entry finds live entry of key, expired entries are swept now and then
+func (s *MemoryRateLimitStore) entry(key string, now time.Time) *rateEntry {
+	if s.ops++; s.ops >= 1024 {
+		s.ops = 0
+		for k, e := range s.entries {
+			if !now.Before(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+	}
+	e, ok := s.entries[key]
+	if !ok || !now.Before(e.expires) {
+		return nil
+	}
+	return e
+}
+
+// Increment implements RateLimitStore
+func (s *MemoryRateLimitStore) Increment(ctx context.Context, key string, ttl time.Duration) (int64, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := DefaultClock.Now()
+	e := s.entry(key, now)
+	if e == nil {
+		e = &rateEntry{expires: now.Add(ttl)}
+		s.entries[key] = e
+	}
+	e.count++
+	return e.count, e.expires.Sub(now), nil
+}
+
+// TakeToken implements RateLimitStore
+func (s *MemoryRateLimitStore) TakeToken(ctx context.Context, key string, rate float64, burst int64) (bool, int64, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := DefaultClock.Now()
+	e := s.entry(key, now)
+	if e == nil {
+		e = &rateEntry{tokens: float64(burst), updated: now}
+		s.entries[key] = e
+	}
+	e.tokens = math.Min(float64(burst), e.tokens+now.Sub(e.updated).Seconds()*rate)
+	e.updated = now
+	e.expires = now.Add(time.Duration(float64(burst) / rate * float64(time.Second)))
+
+	ok := e.tokens >= 1
+	if ok {
+		e.tokens--
+	}
+	var wait time.Duration
+	if e.tokens < 1 {
+		wait = time.Duration((1 - e.tokens) / rate * float64(time.Second))
+	}
+	return ok, int64(e.tokens), wait, nil
+}
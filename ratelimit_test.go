@@ -0,0 +1,224 @@
+package jsonapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRateLimitStore is a MemoryRateLimitStore which can be slow or broken
+type fakeRateLimitStore struct {
+	*MemoryRateLimitStore
+	mu      sync.Mutex
+	latency time.Duration
+	err     error
+}
+
+func (s *fakeRateLimitStore) wait(ctx context.Context) error {
+	s.mu.Lock()
+	latency, err := s.latency, s.err
+	s.mu.Unlock()
+	select {
+	case <-time.After(latency):
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return err
+}
+
+func (s *fakeRateLimitStore) Increment(ctx context.Context, key string, ttl time.Duration) (int64, time.Duration, error) {
+	if err := s.wait(ctx); err != nil {
+		return 0, 0, err
+	}
+	return s.MemoryRateLimitStore.Increment(ctx, key, ttl)
+}
+
+func (s *fakeRateLimitStore) TakeToken(ctx context.Context, key string, rate float64, burst int64) (bool, int64, time.Duration, error) {
+	if err := s.wait(ctx); err != nil {
+		return false, 0, 0, err
+	}
+	return s.MemoryRateLimitStore.TakeToken(ctx, key, rate, burst)
+}
+
+func withFakeClock() (*FakeClock, func()) {
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	DefaultClock = clock
+	return clock, func() { DefaultClock = RealClock{} }
+}
+
+func rateLimitTest(opts RateLimitOpts) HandlerTest {
+	opts.Key = func(*HTTP) string { return "client" }
+	return HandlerTest(NewRateLimiter(opts).Middleware(APIHandler(okAPI).Handler))
+}
+
+func TestRateLimitWindow(t *testing.T) {
+	clock, restore := withFakeClock()
+	defer restore()
+	store := &fakeRateLimitStore{MemoryRateLimitStore: NewMemoryRateLimitStore(), latency: time.Millisecond}
+	h := rateLimitTest(RateLimitOpts{Limit: 2, Window: time.Minute, Store: store})
+
+	for i, remaining := range []string{"1", "0"} {
+		resp, _ := h.Get("/", "")
+		if resp.Code != http.StatusOK || resp.Header().Get("X-RateLimit-Remaining") != remaining || resp.Header().Get("X-RateLimit-Limit") != "2" {
+			t.Errorf("request %d: %d %v", i, resp.Code, resp.Header())
+		}
+	}
+
+	clock.Advance(20 * time.Second)
+	resp, _ := h.Get("/", "")
+	var body ErrorBody
+	json.Unmarshal(resp.Body.Bytes(), &body)
+	if resp.Code != http.StatusTooManyRequests || body.Error.Kind != KindRateLimited {
+		t.Errorf("expected 429, got %d %s", resp.Code, resp.Body)
+	}
+	if resp.Header().Get("Retry-After") != "40" || resp.Header().Get("X-RateLimit-Reset") != "40" {
+		t.Errorf("unexpected headers %v", resp.Header())
+	}
+
+	clock.Advance(40 * time.Second)
+	if resp, _ := h.Get("/", ""); resp.Code != http.StatusOK {
+		t.Errorf("new window: expected 200, got %d", resp.Code)
+	}
+}
+
+func TestRateLimitTokenBucket(t *testing.T) {
+	clock, restore := withFakeClock()
+	defer restore()
+	// a token per second, up to 2
+	h := rateLimitTest(RateLimitOpts{Limit: 60, Window: time.Minute, Burst: 2})
+
+	for i := 0; i < 2; i++ {
+		if resp, _ := h.Get("/", ""); resp.Code != http.StatusOK {
+			t.Errorf("request %d: expected 200, got %d", i, resp.Code)
+		}
+	}
+	resp, _ := h.Get("/", "")
+	if resp.Code != http.StatusTooManyRequests || resp.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected 429, got %d %v", resp.Code, resp.Header())
+	}
+	clock.Advance(time.Second)
+	if resp, _ := h.Get("/", ""); resp.Code != http.StatusOK {
+		t.Errorf("refilled: expected 200, got %d", resp.Code)
+	}
+}
+
+func TestRateLimitStoreFailure(t *testing.T) {
+	broken := errors.New("connection refused")
+	for _, closed := range []bool{false, true} {
+		store := &fakeRateLimitStore{MemoryRateLimitStore: NewMemoryRateLimitStore(), err: broken}
+		var reported []error
+		h := rateLimitTest(RateLimitOpts{Limit: 1, Store: store, FailClosed: closed, OnStoreError: func(httpData *HTTP, err error) {
+			reported = append(reported, err)
+		}})
+
+		expect := http.StatusOK
+		if closed {
+			expect = http.StatusServiceUnavailable
+		}
+		for i := 0; i < 3; i++ {
+			if resp, _ := h.Get("/", ""); resp.Code != expect || resp.Header().Get("X-RateLimit-Limit") != "" {
+				t.Errorf("FailClosed %v: expected %d, got %d %v", closed, expect, resp.Code, resp.Header())
+			}
+		}
+		if len(reported) != 3 || reported[0] != broken {
+			t.Errorf("FailClosed %v: reported %v", closed, reported)
+		}
+
+		// back to normal when the store recovers
+		store.mu.Lock()
+		store.err = nil
+		store.mu.Unlock()
+		h.Get("/", "")
+		if resp, _ := h.Get("/", ""); resp.Code != http.StatusTooManyRequests {
+			t.Errorf("FailClosed %v: expected 429 after recovery, got %d", closed, resp.Code)
+		}
+	}
+}
+
+func TestRateLimitSlowStore(t *testing.T) {
+	store := &fakeRateLimitStore{MemoryRateLimitStore: NewMemoryRateLimitStore(), latency: time.Minute}
+	h := rateLimitTest(RateLimitOpts{Limit: 1, Store: store, FailClosed: true})
+	resp, _ := h.With(CancelAfter(10*time.Millisecond)).Get("/", "")
+	if resp.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 for timed out store, got %d", resp.Code)
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	clock, restore := withFakeClock()
+	defer restore()
+	m := NewMuxTest([]API{{Pattern: "/api/login", APIHandler: okAPI, Middlewares: []Middleware{
+		RateLimit(RateLimitOpts{Limit: 30, Window: time.Minute, Burst: 2}),
+	}}})
+	from := func(remote string) int {
+		req := newRequest("POST", "/api/login", "")
+		req.RemoteAddr = remote
+		return m.Do(req).Code
+	}
+
+	for i := 0; i < 2; i++ {
+		if code := from("198.51.100.7:1234"); code != http.StatusOK {
+			t.Errorf("request %d: expected 200, got %d", i, code)
+		}
+	}
+	// keyed by client IP, port is ignored
+	if code := from("198.51.100.7:5678"); code != http.StatusTooManyRequests {
+		t.Errorf("expected 429, got %d", code)
+	}
+	if code := from("198.51.100.8:1234"); code != http.StatusOK {
+		t.Errorf("other client: expected 200, got %d", code)
+	}
+
+	clock.Advance(2 * time.Second)
+	if code := from("198.51.100.7:1234"); code != http.StatusOK {
+		t.Errorf("refilled: expected 200, got %d", code)
+	}
+}
+
+func TestMemoryRateLimitStoreSweep(t *testing.T) {
+	clock, restore := withFakeClock()
+	defer restore()
+	s := NewMemoryRateLimitStore()
+	ctx := context.Background()
+	for i := 0; i < 1000; i++ {
+		s.Increment(ctx, "idle"+strconv.Itoa(i), time.Minute)
+	}
+	clock.Advance(time.Minute)
+	for i := 0; i < 100; i++ {
+		s.TakeToken(ctx, "active", 1, 10)
+	}
+	if n := len(s.entries); n != 1 {
+		t.Errorf("expected idle entries to be swept, %d left", n)
+	}
+}
+
+func TestNewRateLimiterRejectsZeroLimit(t *testing.T) {
+	for _, opts := range []RateLimitOpts{{Burst: 5}, {Limit: -1, Window: time.Minute}, {}} {
+		func() {
+			defer func() {
+				if v := recover(); v == nil || !strings.Contains(fmt.Sprint(v), "Limit must be positive") {
+					t.Errorf("%+v: recovered %v", opts, v)
+				}
+			}()
+			NewRateLimiter(opts)
+		}()
+	}
+}
+
+func TestRateLimitRejectsZeroLimit(t *testing.T) {
+	defer func() {
+		if v := recover(); v == nil || !strings.Contains(fmt.Sprint(v), "Limit must be positive") {
+			t.Errorf("recovered %v", v)
+		}
+	}()
+	// panics before any route is registered or served
+	RateLimit(RateLimitOpts{Window: time.Minute, Burst: 2})
+	t.Errorf("RateLimit accepts zero Limit")
+}
@@ -0,0 +1,41 @@
+package jsonapi
+
+import (
+	"io"
+	"log"
+	"net/http"
+)
+
+// Raw is returned by APIHandler to send Body as is with ContentType, skipping JSON
+// encoding, like a CSV export. Body is closed after sending if it is an io.Closer.
+// It is cut if it grows beyond MaxResponseBytes.
+//
+//     return jsonapi.Raw{ContentType: "text/csv; charset=utf-8", Body: bytes.NewReader(csv)}, nil
+//
+// Return json.RawMessage instead for JSON documents encoded already, which is sent
+// byte for byte but still validated and limited by MaxResponseBytes.
+type Raw struct {
+	ContentType string // defaults to application/octet-stream
+	Body        io.Reader
+}
+
+func (r Raw) respond(httpData *HTTP) {
+	if c, ok := r.Body.(io.Closer); ok {
+		defer c.Close()
+	}
+	ct := r.ContentType
+	if ct == "" {
+		ct = "application/octet-stream"
+	}
+	header := httpData.ResponseWriter.Header()
+	header.Set("Content-Type", ct)
+	header.Del("Content-Length")
+	httpData.WriteHeader(http.StatusOK)
+	if r.Body == nil || httpData.Request.Method == "HEAD" {
+		return
+	}
+	lw := httpData.limitStream(httpData.ResponseWriter)
+	if _, err := io.Copy(lw, r.Body); !lw.reportCut(httpData) && err != nil && httpData.Request.Context().Err() == nil {
+		log.Printf("jsonapi: raw response of %s cut: %s", httpData.Request.URL.Path, err)
+	}
+}
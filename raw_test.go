@@ -0,0 +1,82 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestRawMessage(t *testing.T) {
+	doc := "{\"b\": 2,\n  \"a\": [1, 2], \"html\": \"<b>\"}"
+	m := NewMuxTest([]API{
+		{Pattern: "/doc", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return json.RawMessage(doc), nil
+		}},
+		{Pattern: "/broken", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return json.RawMessage(`{"a":`), nil
+		}},
+		{Pattern: "/big", MaxResponseBytes: 10, APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return json.RawMessage(doc), nil
+		}},
+	})
+
+	resp, _ := m.Get("/doc", "")
+	if resp.Code != http.StatusOK || resp.Body.String() != doc {
+		t.Errorf("not sent byte for byte: %d %q", resp.Code, resp.Body)
+	}
+	if ct := resp.Header().Values("Content-Type"); len(ct) != 1 || !strings.HasPrefix(ct[0], "application/json") {
+		t.Errorf("unexpected Content-Type %q", ct)
+	}
+	if resp, _ := m.Get("/broken", ""); resp.Code != http.StatusInternalServerError {
+		t.Errorf("invalid json: expected 500, got %d %s", resp.Code, resp.Body)
+	}
+	resp, _ = m.Get("/big", "")
+	var body ErrorBody
+	json.Unmarshal(resp.Body.Bytes(), &body)
+	if resp.Code != http.StatusInternalServerError || body.Error.Kind != KindResponseTooLarge {
+		t.Errorf("too large: unexpected response %d %s", resp.Code, resp.Body)
+	}
+}
+
+func TestRaw(t *testing.T) {
+	csv := "id,name\n1,john\n"
+	var body *closeRecorder
+	m := NewMuxTest([]API{
+		{Pattern: "/export.csv", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			body = &closeRecorder{Reader: strings.NewReader(csv)}
+			return Raw{ContentType: "text/csv; charset=utf-8", Body: body}, nil
+		}},
+		{Pattern: "/blob", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return Raw{Body: strings.NewReader("\x00\x01")}, nil
+		}},
+	})
+
+	resp, _ := m.Get("/export.csv", "")
+	if resp.Code != http.StatusOK || resp.Body.String() != csv || !body.closed {
+		t.Errorf("unexpected response %d %q, closed %v", resp.Code, resp.Body, body.closed)
+	}
+	if ct := resp.Header().Values("Content-Type"); len(ct) != 1 || ct[0] != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type is not replaced: %q", ct)
+	}
+	resp = m.Do(newRequest("HEAD", "/export.csv", ""))
+	if resp.Code != http.StatusOK || resp.Body.Len() != 0 || !body.closed {
+		t.Errorf("HEAD: unexpected response %d %q", resp.Code, resp.Body)
+	}
+
+	resp, _ = m.Get("/blob", "")
+	if resp.Body.String() != "\x00\x01" || resp.Header().Get("Content-Type") != "application/octet-stream" {
+		t.Errorf("unexpected response %v %q", resp.Header(), resp.Body)
+	}
+}
@@ -0,0 +1,128 @@
+// Package redisstore is a jsonapi.RateLimitStore backed by Redis, so replicas behind
+// a load balancer share the same rate limits.
+//
+// It does not depend on any Redis client. Adapt your client to Evaler, like this
+// for github.com/redis/go-redis:
+//
+//     type evaler struct{ *redis.Client }
+//
+//     func (c evaler) Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error) {
+//         return c.Client.Eval(ctx, script, keys, args...).Result()
+//     }
+//
+//     store := redisstore.New(evaler{client}, "ratelimit:")
+//
+// Both operations are done by Lua scripts, so they are atomic across replicas.
+// Token buckets are refilled according to jsonapi.DefaultClock of the calling server.
+package redisstore
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Patrolavia/jsonapi"
+)
+
+// Evaler runs a Lua script on Redis, and returns the reply
+type Evaler interface {
+	Eval(ctx context.Context, script string, keys []string, args ...interface{}) (interface{}, error)
+}
+
+// Store is a jsonapi.RateLimitStore on Redis
+type Store struct {
+	client Evaler
+	prefix string
+}
+
+var _ jsonapi.RateLimitStore = (*Store)(nil)
+
+// New creates a Store. Keys in Redis are prefixed with prefix.
+func New(client Evaler, prefix string) *Store {
+	return &Store{client: client, prefix: prefix}
+}
+
+const incrementScript = `
+local c = redis.call('INCR', KEYS[1])
+if c == 1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return {c, redis.call('PTTL', KEYS[1])}
+`
+
+// Increment implements jsonapi.RateLimitStore
+func (s *Store) Increment(ctx context.Context, key string, ttl time.Duration) (int64, time.Duration, error) {
+	reply, err := s.client.Eval(ctx, incrementScript, []string{s.prefix + key}, ttl.Milliseconds())
+	if err != nil {
+		return 0, 0, err
+	}
+	v, err := ints(reply, 2)
+	if err != nil {
+		return 0, 0, err
+	}
+	return v[0], time.Duration(v[1]) * time.Millisecond, nil
+}
+
+const takeTokenScript = `
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local b = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(b[1]) or burst
+local updated = tonumber(b[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - updated) * rate)
+local ok = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	ok = 1
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst / rate))
+local wait = 0
+if tokens < 1 then
+	wait = math.ceil((1 - tokens) / rate)
+end
+return {ok, math.floor(tokens), wait}
+`
+
+// TakeToken implements jsonapi.RateLimitStore
+func (s *Store) TakeToken(ctx context.Context, key string, rate float64, burst int64) (bool, int64, time.Duration, error) {
+	perMS := strconv.FormatFloat(rate/1000, 'g', -1, 64)
+	now := jsonapi.DefaultClock.Now().UnixMilli()
+	reply, err := s.client.Eval(ctx, takeTokenScript, []string{s.prefix + key}, perMS, burst, now)
+	if err != nil {
+		return false, 0, 0, err
+	}
+	v, err := ints(reply, 3)
+	if err != nil {
+		return false, 0, 0, err
+	}
+	return v[0] == 1, v[1], time.Duration(v[2]) * time.Millisecond, nil
+}
+
+// ints converts reply of Redis into n integers
+func ints(reply interface{}, n int) ([]int64, error) {
+	arr, ok := reply.([]interface{})
+	if !ok || len(arr) != n {
+		return nil, fmt.Errorf("redisstore: unexpected reply %v", reply)
+	}
+	ret := make([]int64, n)
+	for i, v := range arr {
+		switch x := v.(type) {
+		case int64:
+			ret[i] = x
+		case int:
+			ret[i] = int64(x)
+		case string:
+			y, err := strconv.ParseInt(x, 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("redisstore: unexpected reply %v", reply)
+			}
+			ret[i] = y
+		default:
+			return nil, fmt.Errorf("redisstore: unexpected reply %v", reply)
+		}
+	}
+	return ret, nil
+}
@@ -0,0 +1,490 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Some response features need to change how values are encoded in ways encoding/json
+// does not support. rewriter rebuilds a value into generic JSON values (object,
+// []interface{}, map[string]interface{} and leaves), which encodes to the same
+// document as original value unless a feature adjusts it on the way.
+
+var (
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// member is a key-value pair in object
+type member struct {
+	name  string
+	value interface{}
+}
+
+// object is a rewritten struct. Unlike map, it keeps the order of fields.
+type object []member
+
+func (o object) MarshalJSON() ([]byte, error) {
+	buf := &bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		k, _ := marshal(m.name)
+		buf.Write(k)
+		buf.WriteByte(':')
+		v, err := marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(v)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// field describes how a struct field is encoded
+type field struct {
+	name      string
+	index     []int
+	tagged    bool // name is from json tag
+	omitEmpty bool
+	omitZero  bool
+	quoted    bool // ",string" option
+	tag       reflect.StructTag
+}
+
+var fieldCache sync.Map // map[reflect.Type][]field
+
+// fieldsOf lists fields of struct type t following the rules of encoding/json,
+// including fields promoted from embedded structs.
+func fieldsOf(t reflect.Type) []field {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]field)
+	}
+
+	type candidate struct {
+		field
+		depth int
+	}
+	var all []candidate
+	var walk func(t reflect.Type, index []int, depth int)
+	walk = func(t reflect.Type, index []int, depth int) {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if !sf.IsExported() && !(sf.Anonymous && ft.Kind() == reflect.Struct) {
+				continue
+			}
+
+			tag := sf.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			idx := append(append([]int{}, index...), i)
+			if name == "" && sf.Anonymous && ft.Kind() == reflect.Struct {
+				if depth < 16 {
+					walk(ft, idx, depth+1)
+				}
+				continue
+			}
+			if !sf.IsExported() {
+				continue
+			}
+
+			f := field{
+				name:   name,
+				index:  idx,
+				tagged: name != "",
+				tag:    sf.Tag,
+			}
+			if f.name == "" {
+				f.name = sf.Name
+			}
+			for _, o := range strings.Split(opts, ",") {
+				switch o {
+				case "omitempty":
+					f.omitEmpty = true
+				case "omitzero":
+					f.omitZero = true
+				case "string":
+					switch ft.Kind() {
+					case reflect.Bool, reflect.String, reflect.Float32, reflect.Float64,
+						reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+						reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+						f.quoted = true
+					}
+				}
+			}
+			all = append(all, candidate{f, depth})
+		}
+	}
+	walk(t, nil, 0)
+
+	// shallowest field wins, tagged one breaks the tie, otherwise all are dropped
+	byName := map[string][]candidate{}
+	for _, c := range all {
+		byName[c.name] = append(byName[c.name], c)
+	}
+	var ret []field
+	for _, c := range all {
+		dominant := true
+		tagged := 0
+		for _, o := range byName[c.name] {
+			if o.depth < c.depth {
+				dominant = false
+			}
+			if o.depth == c.depth && o.tagged {
+				tagged++
+			}
+		}
+		siblings := 0
+		for _, o := range byName[c.name] {
+			if o.depth == c.depth {
+				siblings++
+			}
+		}
+		if !dominant || (siblings > 1 && (tagged != 1 || !c.tagged)) {
+			continue
+		}
+		ret = append(ret, c.field)
+	}
+
+	fieldCache.Store(t, ret)
+	return ret
+}
+
+// fieldByIndex is like reflect.Value.FieldByIndex, but reports false when
+// running into a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// isEmptyValue reports whether v is empty in the sense of omitempty
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return v.IsZero() && v.Kind() != reflect.Struct
+}
+
+// isMarshaler reports whether v takes care of its own encoding
+func isMarshaler(v reflect.Value) bool {
+	t := v.Type()
+	if t.Implements(marshalerType) || t.Implements(textMarshalerType) {
+		return true
+	}
+	if v.CanAddr() {
+		pt := reflect.PtrTo(t)
+		return pt.Implements(marshalerType) || pt.Implements(textMarshalerType)
+	}
+	return false
+}
+
+// rewriteTags are struct tags handled by rewriter
+var rewriteTags = []string{"jsstring"}
+
+var tagCache sync.Map // map[reflect.Type]bool
+
+// hasRewriteTags reports whether fields with rewriteTags can be reached from t
+// without going through interface values.
+func hasRewriteTags(t reflect.Type) bool {
+	if ret, ok := tagCache.Load(t); ok {
+		return ret.(bool)
+	}
+
+	seen := map[reflect.Type]bool{}
+	var walk func(t reflect.Type) bool
+	walk = func(t reflect.Type) bool {
+		if seen[t] {
+			return false
+		}
+		seen[t] = true
+
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+			return walk(t.Elem())
+		case reflect.Struct:
+			for _, f := range fieldsOf(t) {
+				for _, tag := range rewriteTags {
+					if f.tag.Get(tag) != "" {
+						return true
+					}
+				}
+				if walk(t.FieldByIndex(f.index).Type) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+	ret := walk(t)
+	tagCache.Store(t, ret)
+	return ret
+}
+
+// rewriter holds enabled response features
+type rewriter struct {
+	variants    bool
+	int64String bool
+	nonFinite   NonFiniteMode
+	omit        OmitPolicy
+
+	visiting *visitSet // set by rewrite, see enter
+}
+
+// visitKey identifies a pointer, map or slice being rewritten, like encoding/json
+// does to detect cycles
+type visitKey struct {
+	ptr uintptr
+	t   reflect.Type
+	len int
+}
+
+type visitSet map[visitKey]bool
+
+// enter marks v as being visited, and reports false if it is visited already,
+// which means v contains itself
+func (s *visitSet) enter(v reflect.Value) (leave func(), ok bool) {
+	if s == nil {
+		return func() {}, true
+	}
+	if *s == nil {
+		*s = visitSet{}
+	}
+	k := visitKey{v.Pointer(), v.Type(), 0}
+	if v.Kind() == reflect.Slice {
+		k.len = v.Len()
+	}
+	if (*s)[k] {
+		return nil, false
+	}
+	(*s)[k] = true
+	return func() { delete(*s, k) }, true
+}
+
+// cycle is the result of rewriting a value containing itself, it cannot be encoded
+type cycle struct {
+	t reflect.Type
+}
+
+func (c cycle) MarshalJSON() ([]byte, error) {
+	return nil, cycleError(c.t)
+}
+
+func cycleError(t reflect.Type) error {
+	return &json.UnsupportedValueError{Str: "encountered a cycle via " + t.String()}
+}
+
+// rewriterFor collects features enabled for the request
+func rewriterFor(httpData *HTTP) rewriter {
+	return rewriter{
+		variants:    hasVariants(),
+		int64String: Int64AsString || (httpData.api != nil && httpData.api.Int64AsString),
+		nonFinite:   NonFinite,
+		omit:        httpData.omitZero(),
+	}
+}
+
+// rewrite rebuilds v, or returns it untouched if not needed
+func (w rewriter) rewrite(v interface{}) interface{} {
+	if v == nil || (w == (rewriter{}) && !hasRewriteTags(reflect.TypeOf(v))) {
+		return v
+	}
+	w.visiting = &visitSet{}
+	return w.value(reflect.ValueOf(v))
+}
+
+func (w rewriter) value(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if isMarshaler(v) {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return nil
+		}
+		if v.CanAddr() && !v.Type().Implements(marshalerType) && !v.Type().Implements(textMarshalerType) {
+			return v.Addr().Interface()
+		}
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Kind() == reflect.Ptr {
+			leave, ok := w.visiting.enter(v)
+			if !ok {
+				return cycle{v.Type()}
+			}
+			defer leave()
+		}
+		return w.value(v.Elem())
+	case reflect.Struct:
+		return w.object(v)
+	case reflect.Map:
+		if v.IsNil() {
+			return nil
+		}
+		leave, ok := w.visiting.enter(v)
+		if !ok {
+			return cycle{v.Type()}
+		}
+		defer leave()
+		return w.mapping(v)
+	case reflect.Int64:
+		if w.int64String {
+			return strconv.FormatInt(v.Int(), 10)
+		}
+	case reflect.Uint64:
+		if w.int64String {
+			return strconv.FormatUint(v.Uint(), 10)
+		}
+	case reflect.Float32, reflect.Float64:
+		if f := v.Float(); w.nonFinite != NonFiniteError && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			if w.nonFinite == NonFiniteNull {
+				return nil
+			}
+			switch {
+			case math.IsNaN(f):
+				return "NaN"
+			case f > 0:
+				return "Inf"
+			}
+			return "-Inf"
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Interface()
+		}
+		leave, ok := w.visiting.enter(v)
+		if !ok {
+			return cycle{v.Type()}
+		}
+		defer leave()
+		fallthrough
+	case reflect.Array:
+		ret := make([]interface{}, v.Len())
+		iface := v.Type().Elem().Kind() == reflect.Interface
+		for i := range ret {
+			e := v.Index(i)
+			if iface && w.variants && !e.IsNil() {
+				ret[i] = w.variant(e.Elem())
+				continue
+			}
+			ret[i] = w.value(e)
+		}
+		return ret
+	}
+
+	return v.Interface()
+}
+
+func (w rewriter) object(v reflect.Value) interface{} {
+	fields := fieldsOf(v.Type())
+	ret := make(object, 0, len(fields))
+	for _, f := range fields {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok || (f.omitEmpty && isEmptyValue(fv)) || (f.omitZero && fv.IsZero()) || w.omits(f, fv) {
+			continue
+		}
+		if f.quoted && fv.Kind() == reflect.Ptr && fv.IsNil() {
+			// like encoding/json, nil is not quoted
+			ret = append(ret, member{f.name, nil})
+			continue
+		}
+		if f.quoted {
+			b, err := marshal(fv.Interface())
+			if err != nil {
+				return v.Interface()
+			}
+			ret = append(ret, member{f.name, string(b)})
+			continue
+		}
+		sub := w
+		if f.tag.Get("jsstring") == "true" {
+			sub.int64String = true
+		}
+		ret = append(ret, member{f.name, sub.value(fv)})
+	}
+	return ret
+}
+
+// omits reports whether field f with value v is dropped by OmitPolicy
+func (w rewriter) omits(f field, v reflect.Value) bool {
+	if w.omit == OmitNone || f.tag.Get("keep") == "true" {
+		return false
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	}
+	return w.omit == OmitAllZero && v.IsZero()
+}
+
+func (w rewriter) mapping(v reflect.Value) interface{} {
+	if v.IsNil() {
+		return nil
+	}
+
+	ret := make(map[string]interface{}, v.Len())
+	iter := v.MapRange()
+	for iter.Next() {
+		key, ok := mapKey(iter.Key())
+		if !ok {
+			return v.Interface()
+		}
+		ret[key] = w.value(iter.Value())
+	}
+	return ret
+}
+
+// mapKey converts key of map into property name like encoding/json
+func mapKey(k reflect.Value) (string, bool) {
+	switch {
+	case k.Kind() == reflect.String:
+		return k.String(), true
+	case k.Type().Implements(textMarshalerType):
+		b, err := k.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return "", false
+		}
+		return string(b), true
+	case k.CanInt():
+		return strconv.FormatInt(k.Int(), 10), true
+	case k.CanUint():
+		return strconv.FormatUint(k.Uint(), 10), true
+	}
+	return "", false
+}
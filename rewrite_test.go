@@ -0,0 +1,98 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type cyclicNode struct {
+	ID       int64                  `json:"id"`
+	Next     *cyclicNode            `json:"next"`
+	Children []*cyclicNode          `json:"children,omitempty"`
+	Props    map[string]interface{} `json:"props,omitempty"`
+}
+
+func TestRewriteCycle(t *testing.T) {
+	self := &cyclicNode{ID: 1}
+	self.Next = self
+	viaMap := &cyclicNode{ID: 2, Props: map[string]interface{}{}}
+	viaMap.Props["self"] = viaMap.Props
+	viaSlice := &cyclicNode{ID: 3}
+	viaSlice.Children = []*cyclicNode{viaSlice}
+
+	for name, v := range map[string]interface{}{"pointer": self, "map": viaMap, "slice": viaSlice} {
+		for _, w := range []rewriter{{int64String: true}, {omit: OmitAllZero}} {
+			_, err := json.Marshal(w.rewrite(v))
+			if err == nil || !strings.Contains(err.Error(), "encountered a cycle") {
+				t.Errorf("%s %+v: err = %v", name, w, err)
+			}
+		}
+		if _, err := encodeLimited(v, rewriter{int64String: true}, 1<<20); err == nil || !strings.Contains(err.Error(), "encountered a cycle") {
+			t.Errorf("%s: encodeLimited err = %v", name, err)
+		}
+	}
+}
+
+func TestRewriteSharedPointer(t *testing.T) {
+	shared := &cyclicNode{ID: 9}
+	v := []*cyclicNode{{ID: 1, Next: shared}, {ID: 2, Next: shared}, shared}
+	b, err := json.Marshal(rewriter{int64String: true}.rewrite(v))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); strings.Count(got, `"id":"9"`) != 3 {
+		t.Errorf("got %s", got)
+	}
+}
+
+func TestRewriteCycleResponse(t *testing.T) {
+	self := &cyclicNode{ID: 1}
+	self.Next = self
+	handler := func(dec *json.Decoder, httpData *HTTP) (interface{}, error) { return self, nil }
+	m := NewMuxTest([]API{
+		{Pattern: "/int64", APIHandler: handler, Int64AsString: true},
+		{Pattern: "/limited", APIHandler: handler, Int64AsString: true, MaxResponseBytes: 1 << 20},
+		{Pattern: "/plain", APIHandler: handler},
+	})
+	for _, uri := range []string{"/int64", "/limited", "/plain"} {
+		resp, err := m.Get(uri, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body ErrorBody
+		if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: %s", uri, resp.Body)
+		}
+		if resp.Code != http.StatusInternalServerError || body.Error.Message != errEncodeResponse.Message {
+			t.Errorf("%s: got %d %s", uri, resp.Code, resp.Body)
+		}
+	}
+}
+
+type quotedPointers struct {
+	ID    *int64   `json:"id,string"`
+	Ratio *float64 `json:"ratio,string"`
+	OK    *bool    `json:"ok,string"`
+	Name  *string  `json:"name,string,omitempty"`
+}
+
+func TestRewriteQuotedNilPointer(t *testing.T) {
+	id, ok := int64(7), true
+	for _, v := range []quotedPointers{{}, {ID: &id, OK: &ok}} {
+		want, _ := json.Marshal(v)
+		for _, w := range []rewriter{{int64String: true}, {omit: OmitAllZero}, {variants: true}} {
+			got, err := json.Marshal(w.rewrite(v))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if w.omit == OmitNone && string(got) != string(want) {
+				t.Errorf("%+v:\ngot  %s\nwant %s", w, got, want)
+			}
+			if strings.Contains(string(got), `"null"`) {
+				t.Errorf("%+v: nil pointer is quoted: %s", w, got)
+			}
+		}
+	}
+}
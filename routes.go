@@ -0,0 +1,145 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RouteInfo describes a route registered by Register or RegisterGone
+type RouteInfo struct {
+	Name       string    `json:"name,omitempty"` // see API.Name
+	Pattern    string    `json:"pattern"`
+	Methods    []string  `json:"methods,omitempty"`    // see API.Methods
+	Deprecated string    `json:"deprecated,omitempty"` // see API.Deprecated
+	Gone       *GoneInfo `json:"gone,omitempty"`       // route is retired
+
+	Description string `json:"description,omitempty"` // see API.Description
+
+	RequiredHeaders []HeaderRule `json:"required_headers,omitempty"` // see API.RequiredHeaders
+}
+
+var (
+	routesMu sync.Mutex
+	routes   = map[string]RouteInfo{}
+	named    = map[string]string{} // pattern of named routes
+)
+
+func addRoute(api *API) {
+	routesMu.Lock()
+	defer routesMu.Unlock()
+	if api.Name != "" {
+		named[api.Name] = api.Pattern
+	}
+	// APIs of same pattern can be registered with different methods
+	key := strings.Join(api.Methods, ",") + " " + api.Pattern
+	routes[key] = routeInfo(api)
+}
+
+func routeInfo(api *API) RouteInfo {
+	return RouteInfo{
+		Name:       api.Name,
+		Pattern:    api.Pattern,
+		Methods:    api.Methods,
+		Deprecated: api.Deprecated,
+		Gone:       api.Gone,
+
+		Description: api.Description,
+
+		RequiredHeaders: api.RequiredHeaders,
+	}
+}
+
+// RegisterWithIndex registers apis like Register, and an index at pattern answering
+// GET requests with RouteInfo of apis in order. Unlike RoutesHandler, which lists
+// every registered route, the index describes only apis.
+//
+//     jsonapi.RegisterWithIndex(apis, mux, "/api/_index")
+func RegisterWithIndex(apis []API, mux *http.ServeMux, pattern string) {
+	index := make([]RouteInfo, 0, len(apis))
+	for i := range apis {
+		index = append(index, routeInfo(&apis[i]))
+	}
+	Register(append(apis[:len(apis):len(apis)], API{
+		Pattern: pattern,
+		Methods: []string{http.MethodGet},
+		APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return index, nil
+		},
+		Description: "Index of APIs",
+	}), mux)
+}
+
+// Routes lists registered routes, sorted by pattern
+func Routes() []RouteInfo {
+	routesMu.Lock()
+	defer routesMu.Unlock()
+	ret := make([]RouteInfo, 0, len(routes))
+	for _, r := range routes {
+		ret = append(ret, r)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Pattern != ret[j].Pattern {
+			return ret[i].Pattern < ret[j].Pattern
+		}
+		return strings.Join(ret[i].Methods, ",") < strings.Join(ret[j].Methods, ",")
+	})
+	return ret
+}
+
+// RoutesHandler is an APIHandler listing registered routes, so tooling can
+// find out what is deprecated or retired.
+//
+//     http.Handle("/admin/routes", jsonapi.HTTPHandler(jsonapi.APIHandler(jsonapi.RoutesHandler).Handler))
+func RoutesHandler(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+	return Routes(), nil
+}
+
+// GoneInfo describes a retired route, see RegisterGone
+type GoneInfo struct {
+	Message   string `json:"message"`
+	Successor string `json:"successor,omitempty"` // url of the replacement
+	Removed   string `json:"removed,omitempty"`   // date of removal, like "2024-06-30"
+}
+
+// pathParam matches wildcards like {id} in patterns
+var pathParam = regexp.MustCompile(`\{[^}]*\}`)
+
+// RegisterGone mounts a handler to http.DefaultServeMux answering requests to
+// retired routes with 410 Gone and info in JSON format, instead of a 404 which
+// is indistinguishable from a typo. A Link header points to the successor, with
+// wildcards replaced by values in request path.
+// Patterns with method retire only that method:
+//
+//     jsonapi.RegisterGone("DELETE /api/user/{id}", jsonapi.GoneInfo{
+//         Message:   "Users are deactivated instead of deleted",
+//         Successor: "/api/user/{id}/deactivate",
+//         Removed:   "2024-06-30",
+//     })
+//
+// Use API.Gone to register on other muxes.
+func RegisterGone(pattern string, info GoneInfo) {
+	Register([]API{{Pattern: pattern, Gone: &info}}, nil)
+}
+
+// respond implements responder
+func (info GoneInfo) respond(httpData *HTTP) {
+	if info.Successor != "" {
+		info.Successor = pathParam.ReplaceAllStringFunc(info.Successor, func(p string) string {
+			if v := httpData.Request.PathValue(strings.Trim(p, "{}.")); v != "" {
+				return url.PathEscape(v)
+			}
+			return p
+		})
+		httpData.ResponseWriter.Header().Add("Link", "<"+info.Successor+`>; rel="successor-version"`)
+	}
+	if info.Message == "" {
+		info.Message = "Resource has been removed"
+	}
+	httpData.WriteHeader(http.StatusGone)
+	httpData.encoder().Encode(info)
+}
@@ -0,0 +1,120 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestGone(t *testing.T) {
+	info := GoneInfo{
+		Message:   "Users are deactivated instead of deleted",
+		Successor: "/api/user/{id}/deactivate",
+		Removed:   "2024-06-30",
+	}
+	m := NewMuxTest([]API{
+		{Pattern: "GET /api/user/{id}", APIHandler: okAPI},
+		{Pattern: "DELETE /api/user/{id}", Gone: &info},
+		{Pattern: "/api/legacy", Gone: &GoneInfo{}},
+	})
+
+	resp, _ := m.Delete("/api/user/a%20b", "", "")
+	if resp.Code != http.StatusGone {
+		t.Fatalf("expected 410, got %d %s", resp.Code, resp.Body)
+	}
+	var body GoneInfo
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	expect := info
+	expect.Successor = "/api/user/a%20b/deactivate"
+	if body != expect {
+		t.Errorf("expected %+v, got %+v", expect, body)
+	}
+	if link := resp.Header().Get("Link"); link != `</api/user/a%20b/deactivate>; rel="successor-version"` {
+		t.Errorf("unexpected Link %q", link)
+	}
+
+	// only DELETE is retired
+	if resp, _ := m.Get("/api/user/1", ""); resp.Code != http.StatusOK {
+		t.Errorf("GET: expected 200, got %d", resp.Code)
+	}
+
+	resp, _ = m.Post("/api/legacy", "", `{}`)
+	body = GoneInfo{}
+	json.Unmarshal(resp.Body.Bytes(), &body)
+	if resp.Code != http.StatusGone || body.Message != "Resource has been removed" || resp.Header().Get("Link") != "" {
+		t.Errorf("got %d %v %s", resp.Code, resp.Header(), resp.Body)
+	}
+}
+
+func TestRegisterGone(t *testing.T) {
+	// a fresh mux, so the pattern can be registered again by later runs
+	defer func(mux *http.ServeMux) { http.DefaultServeMux = mux }(http.DefaultServeMux)
+	mux := http.NewServeMux()
+	http.DefaultServeMux = mux
+
+	info := GoneInfo{Message: "Use v2", Successor: "/api/v2/report"}
+	RegisterGone("GET /api/v1/report", info)
+
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest("GET", "/api/v1/report", nil))
+	if resp.Code != http.StatusGone || resp.Header().Get("Link") != `</api/v2/report>; rel="successor-version"` {
+		t.Errorf("got %d %v %s", resp.Code, resp.Header(), resp.Body)
+	}
+
+	found := false
+	for _, r := range Routes() {
+		if r.Pattern == "GET /api/v1/report" {
+			found = r.Gone != nil && reflect.DeepEqual(*r.Gone, info)
+		}
+	}
+	if !found {
+		t.Errorf("retired route is not listed in Routes()")
+	}
+}
+
+func TestRegisterWithIndex(t *testing.T) {
+	apis := []API{
+		{Pattern: "/api/user", APIHandler: okAPI, Methods: []string{"GET", "POST"}, Description: "List or create users"},
+		{Pattern: "/api/user/{id}", APIHandler: okAPI, Name: "user"},
+		{Pattern: "/api/report", Gone: &GoneInfo{Message: "Use v2"}},
+	}
+	mux := http.NewServeMux()
+	RegisterWithIndex(apis[:2:2], mux, "/api/_index")
+	RegisterWithIndex(apis[2:], mux, "/api/v2/_index")
+	if len(apis) != 3 || apis[2].Pattern != "/api/report" {
+		t.Fatalf("apis is modified: %+v", apis)
+	}
+
+	resp := httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest("GET", "/api/_index", nil))
+	var index []RouteInfo
+	if err := json.Unmarshal(resp.Body.Bytes(), &index); err != nil {
+		t.Fatalf("unexpected index %d %s", resp.Code, resp.Body)
+	}
+	expect := []RouteInfo{
+		{Pattern: "/api/user", Methods: []string{"GET", "POST"}, Description: "List or create users"},
+		{Pattern: "/api/user/{id}", Name: "user"},
+	}
+	if !reflect.DeepEqual(index, expect) {
+		t.Errorf("expected %+v, got %+v", expect, index)
+	}
+
+	// the index describes only APIs registered with it
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest("GET", "/api/v2/_index", nil))
+	index = nil
+	json.Unmarshal(resp.Body.Bytes(), &index)
+	if len(index) != 1 || index[0].Pattern != "/api/report" || index[0].Gone == nil || index[0].Gone.Message != "Use v2" {
+		t.Errorf("unexpected index %s", resp.Body)
+	}
+
+	resp = httptest.NewRecorder()
+	mux.ServeHTTP(resp, httptest.NewRequest("POST", "/api/_index", nil))
+	if resp.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: expected 405, got %d", resp.Code)
+	}
+}
@@ -0,0 +1,226 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+)
+
+// error codes defined by JSON-RPC 2.0
+const (
+	RPCParseError     = -32700
+	RPCInvalidRequest = -32600
+	RPCMethodNotFound = -32601
+	RPCInvalidParams  = -32602
+	RPCInternalError  = -32603
+
+	// RPCServerError is used for other Errors, with status code in data
+	RPCServerError = -32000
+)
+
+// RPC serves APIHandlers as methods of JSON-RPC 2.0 at a single endpoint
+//
+//     rpc := jsonapi.NewRPC()
+//     rpc.Method("hello", helloHandler)
+//     http.Handle("/rpc", rpc.HTTPHandler())
+//
+// params of the call are fed to the handler as request body, and the result or
+// error is sent in the envelope with the id of the call. Batches and notifications
+// are supported, and the response is 204 if there is nothing to send.
+//
+// Error returned by handlers are mapped to error codes of JSON-RPC: 400 and 422
+// become RPCInvalidParams, 500 and errors other than Error become RPCInternalError,
+// and others become RPCServerError. Status code, Kind and Details of the Error are
+// sent in data of the error object:
+//
+//     {"jsonrpc": "2.0", "id": 1, "error": {"code": -32000, "message": "User not found",
+//         "data": {"status": 404}}}
+//
+// Handlers must return their results, as the response is shared by calls of a batch.
+// WriteJSON, Fail and results which write response on their own, like Stream, are
+// not supported.
+type RPC struct {
+	methods map[string]APIHandler
+}
+
+// NewRPC creates an RPC without any method
+func NewRPC() *RPC {
+	return &RPC{methods: map[string]APIHandler{}}
+}
+
+// Method registers handler as method name
+func (rpc *RPC) Method(name string, handler APIHandler) *RPC {
+	rpc.methods[name] = handler
+	return rpc
+}
+
+// rpcRequest is a call of JSON-RPC; ID is nil for notifications
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// rpcErrorData is data of errors converted from Error
+type rpcErrorData struct {
+	Status  int         `json:"status"`
+	Kind    string      `json:"kind,omitempty"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+var rpcNullID = json.RawMessage("null")
+
+func rpcFail(id json.RawMessage, code int, msg string) *rpcResponse {
+	if id == nil {
+		id = rpcNullID
+	}
+	return &rpcResponse{JSONRPC: "2.0", ID: id, Error: &rpcError{Code: code, Message: msg}}
+}
+
+// HTTPHandler creates an HTTPHandler serving calls to methods of rpc
+func (rpc *RPC) HTTPHandler() HTTPHandler {
+	return func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			if e := httpData.bodyErr(); e != nil {
+				writeError(enc, httpData, *e)
+				return
+			}
+			rpcWrite(httpData, rpcFail(nil, RPCParseError, "Parse error"))
+			return
+		}
+
+		if raw = bytes.TrimLeft(raw, " \t\r\n"); raw[0] != '[' {
+			rpcWrite(httpData, rpc.call(httpData, raw))
+			return
+		}
+		var calls []json.RawMessage
+		if err := json.Unmarshal(raw, &calls); err != nil || len(calls) == 0 {
+			rpcWrite(httpData, rpcFail(nil, RPCInvalidRequest, "Invalid Request"))
+			return
+		}
+		ret := make([]*rpcResponse, 0, len(calls))
+		for _, c := range calls {
+			if resp := rpc.call(httpData, c); resp != nil {
+				ret = append(ret, resp)
+			}
+		}
+		if len(ret) == 0 {
+			rpcWrite(httpData, nil)
+			return
+		}
+		rpcWrite(httpData, ret)
+	}
+}
+
+// rpcWrite sends v, or 204 if v is nil
+func rpcWrite(httpData *HTTP, v interface{}) {
+	if resp, ok := v.(*rpcResponse); v == nil || (ok && resp == nil) {
+		httpData.ResponseWriter.Header().Del("Content-Type")
+		httpData.WriteHeader(http.StatusNoContent)
+		return
+	}
+	httpData.encoder().Encode(v)
+}
+
+// call runs a call of JSON-RPC, returning nil for notifications
+func (rpc *RPC) call(httpData *HTTP, raw json.RawMessage) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil || req.JSONRPC != "2.0" || req.Method == "" || !validRPCID(req.ID) {
+		return rpcFail(nil, RPCInvalidRequest, "Invalid Request")
+	}
+	if p := bytes.TrimLeft(req.Params, " \t\r\n"); len(p) > 0 && p[0] != '{' && p[0] != '[' {
+		return rpcFail(req.ID, RPCInvalidParams, "Invalid params")
+	}
+
+	resp := rpc.invoke(httpData, req)
+	if req.ID == nil {
+		return nil
+	}
+	resp.ID = req.ID
+	return resp
+}
+
+func (rpc *RPC) invoke(httpData *HTTP, req rpcRequest) *rpcResponse {
+	h, ok := rpc.methods[req.Method]
+	if !ok {
+		return rpcFail(req.ID, RPCMethodNotFound, "Method not found")
+	}
+
+	res, err := h(httpData.newDecoder(bytes.NewReader(req.Params)), httpData)
+	if err == nil {
+		if s, ok := res.(StatusResult); ok {
+			res = s.Body
+		}
+		res, err = httpData.onResponse(res)
+	}
+	if err != nil {
+		return rpcErrorResponse(httpData, err)
+	}
+	buf, err := marshal(rewriterFor(httpData).rewrite(res))
+	if err != nil {
+		reportError(httpData, http.StatusInternalServerError, err)
+		return rpcFail(nil, RPCInternalError, errEncodeResponse.Message)
+	}
+	return &rpcResponse{JSONRPC: "2.0", Result: buf}
+}
+
+// rpcErrorResponse converts err returned by handler to error object of JSON-RPC
+func rpcErrorResponse(httpData *HTTP, err error) *rpcResponse {
+	e, ok := err.(Error)
+	if !ok {
+		if uf, isUF := unknownField(err); isUF {
+			e, ok = uf, true
+		}
+	}
+	if !ok {
+		reportError(httpData, http.StatusInternalServerError, err)
+		return rpcFail(nil, RPCInternalError, err.Error())
+	}
+
+	code := RPCServerError
+	switch e.Code {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		code = RPCInvalidParams
+	case http.StatusInternalServerError:
+		code = RPCInternalError
+	}
+	reportError(httpData, e.Code, e)
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.Code)
+	}
+	ret := rpcFail(nil, code, msg)
+	data := rpcErrorData{Status: e.Code, Kind: e.Kind, Details: e.Details}
+	if _, mErr := json.Marshal(data); mErr != nil {
+		data.Details = nil
+	}
+	ret.Error.Data = rewriterFor(httpData).rewrite(data)
+	return ret
+}
+
+// validRPCID reports whether id is absent, or a string, number or null
+func validRPCID(id json.RawMessage) bool {
+	if len(id) == 0 {
+		return true
+	}
+	switch id[0] {
+	case '{', '[', 't', 'f':
+		return false
+	}
+	return true
+}
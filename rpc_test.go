@@ -0,0 +1,109 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func rpcTest() HandlerTest {
+	rpc := NewRPC().
+		Method("hello", func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			var args struct{ Name string }
+			if err := dec.Decode(&args); err != nil {
+				return nil, E400.SetData("Invalid name")
+			}
+			return "hello " + args.Name, nil
+		}).
+		Method("user", func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			e := E404.SetData("User not found").WithDetails(map[string]string{"id": "42"})
+			e.Kind = "user_not_found"
+			return nil, e
+		}).
+		Method("crash", func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return nil, errors.New("database is down")
+		})
+	return HandlerTest(rpc.HTTPHandler())
+}
+
+func TestRPC(t *testing.T) {
+	h := rpcTest()
+	cases := []struct {
+		req, resp string
+	}{
+		{
+			`{"jsonrpc":"2.0","method":"hello","params":{"name":"Ruby"},"id":1}`,
+			`{"jsonrpc":"2.0","id":1,"result":"hello Ruby"}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"hello","params":["Ruby"],"id":"a"}`,
+			`{"jsonrpc":"2.0","id":"a","error":{"code":-32602,"message":"Invalid name","data":{"status":400}}}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"hello","params":1,"id":1}`,
+			`{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"Invalid params"}}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"user","id":null}`,
+			`{"jsonrpc":"2.0","id":null,"error":{"code":-32000,"message":"User not found","data":{"status":404,"kind":"user_not_found","details":{"id":"42"}}}}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"crash","id":2}`,
+			`{"jsonrpc":"2.0","id":2,"error":{"code":-32603,"message":"database is down"}}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"bye","id":3}`,
+			`{"jsonrpc":"2.0","id":3,"error":{"code":-32601,"message":"Method not found"}}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"hello"`,
+			`{"jsonrpc":"2.0","id":null,"error":{"code":-32700,"message":"Parse error"}}`,
+		},
+		{
+			`{"jsonrpc":"1.0","method":"hello","id":4}`,
+			`{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"Invalid Request"}}`,
+		},
+		{
+			`{"jsonrpc":"2.0","method":"hello","id":{"a":1}}`,
+			`{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"Invalid Request"}}`,
+		},
+		{
+			`[]`,
+			`{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"Invalid Request"}}`,
+		},
+	}
+	for _, c := range cases {
+		resp, _ := h.Post("/rpc", "", c.req)
+		if resp.Code != http.StatusOK || resp.Body.String() != c.resp+"\n" {
+			t.Errorf("%s: unexpected response %d %s", c.req, resp.Code, resp.Body)
+		}
+	}
+}
+
+func TestRPCBatch(t *testing.T) {
+	h := rpcTest()
+	resp, _ := h.Post("/rpc", "", `[
+		{"jsonrpc":"2.0","method":"hello","params":{"name":"Ruby"},"id":1},
+		{"jsonrpc":"2.0","method":"hello","params":{"name":"nobody"}},
+		{"jsonrpc":"2.0","method":"bye","id":"x"},
+		1
+	]`)
+	expect := `[{"jsonrpc":"2.0","id":1,"result":"hello Ruby"},` +
+		`{"jsonrpc":"2.0","id":"x","error":{"code":-32601,"message":"Method not found"}},` +
+		`{"jsonrpc":"2.0","id":null,"error":{"code":-32600,"message":"Invalid Request"}}]`
+	if resp.Code != http.StatusOK || resp.Body.String() != expect+"\n" {
+		t.Errorf("unexpected response %d %s", resp.Code, resp.Body)
+	}
+
+	// nothing to send for notifications
+	for _, req := range []string{
+		`{"jsonrpc":"2.0","method":"hello","params":{"name":"Ruby"}}`,
+		`[{"jsonrpc":"2.0","method":"user"},{"jsonrpc":"2.0","method":"bye"}]`,
+	} {
+		resp, _ := h.Post("/rpc", "", req)
+		if resp.Code != http.StatusNoContent || resp.Body.Len() != 0 || resp.Header().Get("Content-Type") != "" {
+			t.Errorf("%s: unexpected response %d %v %s", req, resp.Code, resp.Header(), resp.Body)
+		}
+	}
+}
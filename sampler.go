@@ -0,0 +1,262 @@
+package jsonapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Sample is a request captured by Sampler
+type Sample struct {
+	ID        uint64        `json:"id"`
+	Time      time.Time     `json:"time"`
+	Method    string        `json:"method"`
+	Path      string        `json:"path"`
+	Header    http.Header   `json:"header"`
+	Body      string        `json:"body"`
+	Truncated bool          `json:"truncated"` // Body is truncated
+	Status    int           `json:"status"`
+	Duration  time.Duration `json:"duration"`
+	Stack     string        `json:"stack,omitempty"` // stack trace if handler panicked
+}
+
+// SamplerOpts configures a Sampler. Zero values are replaced by defaults.
+type SamplerOpts struct {
+	Size        int                  // number of samples kept, defaults to 100
+	Rate        float64              // fraction of matched requests to capture, defaults to 1
+	MaxBody     int                  // bytes of request body kept, defaults to 4096
+	Match       func(s *Sample) bool // defaults to responses with status >= 500
+	MaskHeaders []string             // headers with secrets, defaults to DefaultMaskHeaders
+}
+
+// DefaultMaskHeaders lists headers which are masked in samples by default
+var DefaultMaskHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization", "X-Api-Key"}
+
+// Sampler keeps last few interesting requests in memory for diagnosis.
+//
+//     sampler := jsonapi.NewSampler(jsonapi.SamplerOpts{Size: 50})
+//     http.Handle("/api/", jsonapi.HTTPHandler(sampler.Middleware(myHandler)))
+//     http.Handle("/admin/samples", jsonapi.HTTPHandler(jsonapi.APIHandler(sampler.Handler).Handler))
+type Sampler struct {
+	opts SamplerOpts
+	mu   sync.Mutex
+	ring []Sample // circular buffer, sample with ID n is at ring[(n-1)%Size]
+	last uint64   // ID of last sample
+}
+
+// NewSampler creates a Sampler
+func NewSampler(opts SamplerOpts) *Sampler {
+	if opts.Size <= 0 {
+		opts.Size = 100
+	}
+	if opts.Rate <= 0 {
+		opts.Rate = 1
+	}
+	if opts.MaxBody <= 0 {
+		opts.MaxBody = 4096
+	}
+	if opts.Match == nil {
+		opts.Match = func(s *Sample) bool { return s.Status >= 500 }
+	}
+	if opts.MaskHeaders == nil {
+		opts.MaskHeaders = DefaultMaskHeaders
+	}
+	return &Sampler{opts: opts, ring: make([]Sample, 0, opts.Size)}
+}
+
+// Middleware captures requests handled by next
+func (s *Sampler) Middleware(next HTTPHandler) HTTPHandler {
+	return func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		start := DefaultClock.Now()
+		w := &statusWriter{ResponseWriter: httpData.ResponseWriter}
+		body := &headBuffer{max: s.opts.MaxBody}
+		if httpData.Request.Body != nil {
+			httpData.Request.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.TeeReader(httpData.Request.Body, body), httpData.Request.Body}
+			dec = httpData.newDecoder(httpData.Request.Body)
+		}
+		httpData.ResponseWriter = w
+
+		defer func() {
+			sample := Sample{
+				Time:      start,
+				Method:    httpData.Request.Method,
+				Path:      httpData.Request.URL.RequestURI(),
+				Header:    s.mask(httpData.Request.Header),
+				Body:      string(body.buf),
+				Truncated: body.truncated,
+				Status:    w.status,
+				Duration:  DefaultClock.Now().Sub(start),
+			}
+			if sample.Status == 0 {
+				sample.Status = http.StatusOK
+			}
+			v := recover()
+			if v != nil {
+				sample.Stack = string(debug.Stack())
+				if w.status == 0 {
+					sample.Status = http.StatusInternalServerError
+				}
+			}
+			s.add(&sample)
+			if v != nil {
+				panic(v)
+			}
+		}()
+		next(httpData.configureEncoder(json.NewEncoder(w)), dec, httpData)
+	}
+}
+
+func (s *Sampler) mask(h http.Header) http.Header {
+	ret := h.Clone()
+	for _, k := range s.opts.MaskHeaders {
+		if vals := ret.Values(k); len(vals) > 0 {
+			for i := range vals {
+				vals[i] = "***"
+			}
+		}
+	}
+	return ret
+}
+
+func (s *Sampler) add(sample *Sample) {
+	if !s.opts.Match(sample) || (s.opts.Rate < 1 && rand.Float64() >= s.opts.Rate) {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last++
+	sample.ID = s.last
+	if len(s.ring) < s.opts.Size {
+		s.ring = append(s.ring, *sample)
+		return
+	}
+	s.ring[int((s.last-1)%uint64(s.opts.Size))] = *sample
+}
+
+// Samples returns captured samples, oldest first
+func (s *Sampler) Samples() []Sample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make([]Sample, 0, len(s.ring))
+	if len(s.ring) < s.opts.Size {
+		return append(ret, s.ring...)
+	}
+	start := int(s.last % uint64(s.opts.Size))
+	ret = append(ret, s.ring[start:]...)
+	return append(ret, s.ring[:start]...)
+}
+
+// Sample finds a captured sample by its ID
+func (s *Sampler) Sample(id uint64) (Sample, bool) {
+	for _, sample := range s.Samples() {
+		if sample.ID == id {
+			return sample, true
+		}
+	}
+	return Sample{}, false
+}
+
+// Handler is an APIHandler listing captured samples, or the one specified by the
+// "id" query parameter.
+func (s *Sampler) Handler(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+	str := httpData.Request.URL.Query().Get("id")
+	if str == "" {
+		return s.Samples(), nil
+	}
+
+	id, err := strconv.ParseUint(str, 10, 64)
+	if err != nil {
+		return nil, E400.SetData("Invalid sample id")
+	}
+	sample, ok := s.Sample(id)
+	if !ok {
+		return nil, E404.SetData("Sample not found")
+	}
+	return sample, nil
+}
+
+// headBuffer keeps only first max bytes written to it
+type headBuffer struct {
+	buf       []byte
+	max       int
+	truncated bool
+}
+
+func (b *headBuffer) Write(p []byte) (int, error) {
+	if room := b.max - len(b.buf); room < len(p) {
+		b.truncated = true
+		if room > 0 {
+			b.buf = append(b.buf, p[:room]...)
+		}
+		return len(p), nil
+	}
+	b.buf = append(b.buf, p...)
+	return len(p), nil
+}
+
+// statusWriter remembers status code and bytes sent to client
+type statusWriter struct {
+	http.ResponseWriter
+	status  int
+	written int64
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.written += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher if underlying ResponseWriter supports it
+func (w *statusWriter) Flush() {
+	w.FlushError()
+}
+
+// FlushError is used by http.ResponseController
+func (w *statusWriter) FlushError() error {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack implements http.Hijacker if underlying ResponseWriter supports it. The
+// status is recorded as 101 Switching Protocols.
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return conn, rw, err
+}
+
+// Push implements http.Pusher if underlying ResponseWriter supports it
+func (w *statusWriter) Push(target string, opts *http.PushOptions) error {
+	return push(w.ResponseWriter, target, opts)
+}
+
+// Unwrap returns underlying ResponseWriter, see http.ResponseController
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,102 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func samplerTest(s *Sampler) HandlerTest {
+	return HandlerTest(s.Middleware(APIHandler(func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		if strings.HasPrefix(httpData.Request.URL.Path, "/panic") {
+			panic("boom")
+		}
+		if strings.HasPrefix(httpData.Request.URL.Path, "/ok") {
+			return "ok", nil
+		}
+		var body interface{}
+		dec.Decode(&body)
+		return nil, E500
+	}).Handler))
+}
+
+func TestSamplerEviction(t *testing.T) {
+	s := NewSampler(SamplerOpts{Size: 3})
+	h := samplerTest(s)
+	h.Get("/ok", "")
+	for i := 1; i <= 5; i++ {
+		h.Post(fmt.Sprintf("/fail/%d", i), "", `{}`)
+	}
+
+	samples := s.Samples()
+	if len(samples) != 3 {
+		t.Fatalf("expected 3 samples, got %d", len(samples))
+	}
+	for i, sample := range samples {
+		if path := fmt.Sprintf("/fail/%d", i+3); sample.Path != path || sample.ID != uint64(i+3) {
+			t.Errorf("sample %d: expected #%d %s, got #%d %s", i, i+3, path, sample.ID, sample.Path)
+		}
+		if sample.Status != http.StatusInternalServerError || sample.Method != "POST" || sample.Body != "{}" {
+			t.Errorf("sample %d: %+v", i, sample)
+		}
+	}
+	if _, ok := s.Sample(2); ok {
+		t.Errorf("evicted sample is still found")
+	}
+	if sample, ok := s.Sample(4); !ok || sample.Path != "/fail/4" {
+		t.Errorf("cannot find sample 4: %+v", sample)
+	}
+}
+
+func TestSamplerMask(t *testing.T) {
+	s := NewSampler(SamplerOpts{MaxBody: 4})
+	h := samplerTest(s)
+	h.With(Headers{"Authorization": "Bearer secret", "X-Trace": "abc"}).Post("/fail", "session=secret", `{"long":"body"}`)
+
+	samples := s.Samples()
+	if len(samples) != 1 {
+		t.Fatalf("expected 1 sample, got %d", len(samples))
+	}
+	sample := samples[0]
+	if sample.Header.Get("Authorization") != "***" || sample.Header.Get("Cookie") != "***" || sample.Header.Get("X-Trace") != "abc" {
+		t.Errorf("headers not masked: %v", sample.Header)
+	}
+	if sample.Body != `{"lo` || !sample.Truncated {
+		t.Errorf("body not truncated: %q", sample.Body)
+	}
+}
+
+func TestSamplerPanic(t *testing.T) {
+	s := NewSampler(SamplerOpts{})
+	func() {
+		defer func() { recover() }()
+		samplerTest(s).Get("/panic", "")
+	}()
+
+	samples := s.Samples()
+	if len(samples) != 1 || samples[0].Status != http.StatusInternalServerError || !strings.Contains(samples[0].Stack, "panic") {
+		t.Errorf("panic not captured: %+v", samples)
+	}
+}
+
+func TestSamplerHandler(t *testing.T) {
+	s := NewSampler(SamplerOpts{})
+	samplerTest(s).Get("/fail", "")
+	admin := HandlerTest(APIHandler(s.Handler).Handler)
+
+	var list []Sample
+	if _, err := admin.GetInto("/samples", "", &list); err != nil || len(list) != 1 || list[0].Path != "/fail" {
+		t.Errorf("unexpected list %+v: %v", list, err)
+	}
+	var one Sample
+	if _, err := admin.GetInto("/samples?id=1", "", &one); err != nil || one.Path != "/fail" {
+		t.Errorf("unexpected sample %+v: %v", one, err)
+	}
+	for uri, code := range map[string]int{"/samples?id=2": http.StatusNotFound, "/samples?id=x": http.StatusBadRequest} {
+		if resp, _ := admin.Get(uri, ""); resp.Code != code {
+			t.Errorf("%s: expected %d, got %d", uri, code, resp.Code)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package jsonapi
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// serverConfig is built from ServerOptions
+type serverConfig struct {
+	ctx      context.Context
+	drain    time.Duration
+	certFile string
+	keyFile  string
+	tls      *tls.Config
+	setup    []func(srv *http.Server)
+}
+
+// ServerOption configures the server of Serve
+type ServerOption func(c *serverConfig)
+
+// WithShutdownContext shuts down the server when ctx is done, in addition to
+// SIGINT and SIGTERM
+func WithShutdownContext(ctx context.Context) ServerOption {
+	return func(c *serverConfig) { c.ctx = ctx }
+}
+
+// WithDrainTimeout is how long to wait for requests in flight when shutting down,
+// 30 seconds by default
+func WithDrainTimeout(d time.Duration) ServerOption {
+	return func(c *serverConfig) { c.drain = d }
+}
+
+// WithTLSFiles serves https with certificate and key in files
+func WithTLSFiles(certFile, keyFile string) ServerOption {
+	return func(c *serverConfig) { c.certFile, c.keyFile = certFile, keyFile }
+}
+
+// WithTLSConfig serves https with cfg, which should have certificates unless
+// WithTLSFiles is also given
+func WithTLSConfig(cfg *tls.Config) ServerOption {
+	return func(c *serverConfig) { c.tls = cfg }
+}
+
+// WithServer calls fn to change the server before it starts, like its timeouts
+// or ErrorLog
+func WithServer(fn func(srv *http.Server)) ServerOption {
+	return func(c *serverConfig) { c.setup = append(c.setup, fn) }
+}
+
+// Serve registers apis to a new ServeMux and serves it at addr, until SIGINT or
+// SIGTERM is received. Then it stops accepting connections, and waits for
+// requests in flight to finish before returning.
+//
+//     func main() {
+//         if err := jsonapi.Serve(":8080", apis); err != nil {
+//             log.Fatal(err)
+//         }
+//     }
+//
+// The server times out reading requests in 30 seconds, writing responses in 60
+// seconds, and closes idle connections after 2 minutes. Change them with
+// WithServer if needed, for example when DefaultTimeout is longer.
+//
+// It returns nil after shutting down gracefully, or the error failing to listen or
+// serve, or context.DeadlineExceeded if requests are still running after the drain
+// timeout.
+func Serve(addr string, apis []API, opts ...ServerOption) error {
+	cfg := serverConfig{ctx: context.Background(), drain: 30 * time.Second}
+	for _, o := range opts {
+		o(&cfg)
+	}
+
+	mux := http.NewServeMux()
+	Register(apis, mux)
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      60 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+		TLSConfig:         cfg.tls,
+	}
+	for _, fn := range cfg.setup {
+		fn(srv)
+	}
+
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(cfg.ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	done := make(chan error, 1)
+	go func() {
+		if cfg.tls != nil || cfg.certFile != "" {
+			done <- srv.ServeTLS(ln, cfg.certFile, cfg.keyFile)
+			return
+		}
+		done <- srv.Serve(ln)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), cfg.drain)
+	defer cancel()
+	err = srv.Shutdown(drainCtx)
+	if serveErr := <-done; !errors.Is(serveErr, http.ErrServerClosed) && err == nil {
+		err = serveErr
+	}
+	return err
+}
@@ -0,0 +1,140 @@
+package jsonapi
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// serveTest runs Serve in background at a free port, with a route blocking until
+// release is closed. It returns the base url and the result of Serve.
+func serveTest(t *testing.T, entered, release chan struct{}, opts ...ServerOption) (string, chan error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(addr, []API{
+			{Pattern: "/ping", APIHandler: okAPI},
+			{Pattern: "/slow", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+				close(entered)
+				<-release
+				return "done", nil
+			}},
+		}, opts...)
+	}()
+	for i := 0; ; i++ {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			break
+		}
+		if i > 100 {
+			t.Fatalf("server is not started: %s", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return addr, done
+}
+
+func TestServeGracefulShutdown(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	entered, release := make(chan struct{}), make(chan struct{})
+	addr, done := serveTest(t, entered, release, WithShutdownContext(ctx), WithDrainTimeout(5*time.Second))
+
+	slow := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + addr + "/slow")
+		if err != nil {
+			slow <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := ioutil.ReadAll(resp.Body)
+		slow <- string(body)
+	}()
+	<-entered
+	cancel()
+
+	select {
+	case err := <-done:
+		t.Fatalf("returned before requests in flight finish: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	if _, err := http.Get("http://" + addr + "/ping"); err == nil {
+		t.Errorf("new connections are accepted while shutting down")
+	}
+
+	close(release)
+	if body := <-slow; body != `"done"`+"\n" {
+		t.Errorf("request in flight is broken: %s", body)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestServeDrainTimeout(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	entered, release := make(chan struct{}), make(chan struct{})
+	defer close(release)
+	addr, done := serveTest(t, entered, release, WithShutdownContext(ctx), WithDrainTimeout(50*time.Millisecond))
+
+	go http.Get("http://" + addr + "/slow")
+	<-entered
+	cancel()
+	if err := <-done; err != context.DeadlineExceeded {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+func TestServeTLS(t *testing.T) {
+	// borrow the certificate and trusting client of httptest
+	ts := httptest.NewTLSServer(http.NotFoundHandler())
+	ts.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	var timeout time.Duration
+	addr, done := serveTest(t, make(chan struct{}), make(chan struct{}),
+		WithShutdownContext(ctx),
+		WithTLSConfig(&tls.Config{Certificates: ts.TLS.Certificates}),
+		WithServer(func(srv *http.Server) {
+			timeout = srv.ReadTimeout
+			srv.WriteTimeout = 10 * time.Second
+			// probes of serveTest close connections without handshakes
+			srv.ErrorLog = log.New(ioutil.Discard, "", 0)
+		}),
+	)
+
+	resp, err := ts.Client().Get("https://" + addr + "/ping")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.TLS == nil {
+		t.Errorf("unexpected response %d %v", resp.StatusCode, resp.TLS)
+	}
+	if timeout != 30*time.Second {
+		t.Errorf("unexpected default ReadTimeout %s", timeout)
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestServeListenError(t *testing.T) {
+	if err := Serve("256.0.0.1:80", nil); err == nil {
+		t.Errorf("expected error")
+	}
+}
@@ -0,0 +1,173 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+)
+
+// sessionBase is used to resolve relative urls in Session
+var sessionBase = &url.URL{Scheme: "http", Host: "example.com"}
+
+// Session helps you to test flows of many requests, like login → act → logout.
+// Cookies set by responses are kept in a cookie jar and sent with later requests.
+//
+//     s := jsonapi.NewSession(mux)
+//     s.PostJSON("/api/login", map[string]string{"user": "admin", "pass": "admin"})
+//     s.PostJSON("/api/order", order)
+//     s.Get("/api/logout")
+type Session struct {
+	Handler http.Handler
+	Jar     http.CookieJar
+
+	csrfHeader string // see UseCSRF
+	csrfField  string
+	csrfToken  string
+}
+
+// NewSession creates a Session sending requests to h
+func NewSession(h http.Handler) *Session {
+	jar, _ := cookiejar.New(nil)
+	return &Session{Handler: h, Jar: jar}
+}
+
+// resolve converts uri into absolute url
+func resolve(uri string) (*url.URL, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	return sessionBase.ResolveReference(u), nil
+}
+
+// Do sends req with cookies in the jar, and stores cookies set by the response
+func (s *Session) Do(req *http.Request) *httptest.ResponseRecorder {
+	if !req.URL.IsAbs() {
+		req.URL = sessionBase.ResolveReference(req.URL)
+		req.Host = req.URL.Host
+	}
+	for _, c := range s.Jar.Cookies(req.URL) {
+		req.AddCookie(c)
+	}
+	if s.csrfToken != "" && req.Header.Get(s.csrfHeader) == "" {
+		req.Header.Set(s.csrfHeader, s.csrfToken)
+	}
+
+	ret := httptest.NewRecorder()
+	s.Handler.ServeHTTP(ret, req)
+	if cookies := ret.Result().Cookies(); len(cookies) > 0 {
+		s.Jar.SetCookies(req.URL, cookies)
+	}
+	s.captureCSRF(ret)
+	return ret
+}
+
+// Get sends a GET request to uri
+func (s *Session) Get(uri string) (*httptest.ResponseRecorder, error) {
+	req, err := http.NewRequest("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.Do(req), nil
+}
+
+// Post sends a POST request to uri with data
+func (s *Session) Post(uri, data string) (*httptest.ResponseRecorder, error) {
+	req, err := http.NewRequest("POST", uri, strings.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return s.Do(req), nil
+}
+
+// PostJSON sends a POST request to uri with json encoded data
+func (s *Session) PostJSON(uri string, data interface{}) (*httptest.ResponseRecorder, error) {
+	buf, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest("POST", uri, bytes.NewReader(buf))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return s.Do(req), nil
+}
+
+// Cookies returns cookies which would be sent to uri
+func (s *Session) Cookies(uri string) []*http.Cookie {
+	u, err := resolve(uri)
+	if err != nil {
+		return nil
+	}
+	return s.Jar.Cookies(u)
+}
+
+// Cookie finds cookie named name which would be sent to uri
+func (s *Session) Cookie(uri, name string) *http.Cookie {
+	for _, c := range s.Cookies(uri) {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// SetCookie stores c as if it is set by response of uri, so you can tamper with
+// cookies between steps.
+func (s *Session) SetCookie(uri string, c *http.Cookie) {
+	if u, err := resolve(uri); err == nil {
+		s.Jar.SetCookies(u, []*http.Cookie{c})
+	}
+}
+
+// DeleteCookie removes cookie named name for uri
+func (s *Session) DeleteCookie(uri, name string) {
+	s.SetCookie(uri, &http.Cookie{Name: name, Path: "/", MaxAge: -1})
+}
+
+// ClearCookies removes all cookies, and forgets the CSRF token
+func (s *Session) ClearCookies() {
+	s.Jar, _ = cookiejar.New(nil)
+	s.csrfToken = ""
+}
+
+// UseCSRF extracts CSRF token from response header named header, or the top-level
+// field of JSON response body, and sends it in request header named header of
+// following requests. An empty field only checks the response header.
+//
+//     s.UseCSRF("X-CSRF-Token", "csrf_token")
+func (s *Session) UseCSRF(header, field string) {
+	s.csrfHeader, s.csrfField = header, field
+}
+
+// CSRFToken returns the CSRF token extracted from last response carrying one
+func (s *Session) CSRFToken() string {
+	return s.csrfToken
+}
+
+func (s *Session) captureCSRF(resp *httptest.ResponseRecorder) {
+	if s.csrfHeader == "" {
+		return
+	}
+	if v := resp.Header().Get(s.csrfHeader); v != "" {
+		s.csrfToken = v
+		return
+	}
+	if s.csrfField == "" {
+		return
+	}
+
+	var body map[string]json.RawMessage
+	if json.Unmarshal(resp.Body.Bytes(), &body) != nil {
+		return
+	}
+	var token string
+	if raw, ok := body[s.csrfField]; ok && json.Unmarshal(raw, &token) == nil && token != "" {
+		s.csrfToken = token
+	}
+}
@@ -0,0 +1,86 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// shopAPIs is a flow of login, order and logout, guarded by cookie and CSRF token
+var shopAPIs = []API{
+	{Pattern: "POST /login", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		http.SetCookie(httpData.ResponseWriter, &http.Cookie{Name: "sid", Value: "s1", Path: "/"})
+		return map[string]string{"csrf_token": "t1"}, nil
+	}},
+	{Pattern: "POST /order", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		if c, err := httpData.Request.Cookie("sid"); err != nil || c.Value != "s1" {
+			return nil, E401
+		}
+		if httpData.Request.Header.Get("X-CSRF-Token") != "t1" {
+			return nil, E403
+		}
+		httpData.ResponseWriter.Header().Set("X-CSRF-Token", "t2")
+		return "ordered", nil
+	}},
+	{Pattern: "POST /logout", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		if httpData.Request.Header.Get("X-CSRF-Token") != "t2" {
+			return nil, E403
+		}
+		http.SetCookie(httpData.ResponseWriter, &http.Cookie{Name: "sid", Path: "/", MaxAge: -1})
+		return nil, nil
+	}},
+}
+
+func sessionPost(t *testing.T, s *Session, uri string, status int) {
+	t.Helper()
+	resp, err := s.PostJSON(uri, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != status {
+		t.Errorf("%s: expected %d, got %d %s", uri, status, resp.Code, resp.Body)
+	}
+}
+
+func TestSessionFlow(t *testing.T) {
+	s := NewSession(NewMuxTest(shopAPIs).Mux)
+	s.UseCSRF("X-CSRF-Token", "csrf_token")
+
+	sessionPost(t, s, "/login", http.StatusOK)
+	if c := s.Cookie("/", "sid"); c == nil || c.Value != "s1" || s.CSRFToken() != "t1" {
+		t.Fatalf("login not captured: %v %q", c, s.CSRFToken())
+	}
+	sessionPost(t, s, "/order", http.StatusOK)
+	if s.CSRFToken() != "t2" {
+		t.Errorf("CSRF token not updated from header: %q", s.CSRFToken())
+	}
+	sessionPost(t, s, "/logout", http.StatusOK)
+	if c := s.Cookie("/", "sid"); c != nil {
+		t.Errorf("cookie not removed by logout: %v", c)
+	}
+	sessionPost(t, s, "/order", http.StatusUnauthorized)
+}
+
+func TestSessionTamper(t *testing.T) {
+	s := NewSession(NewMuxTest(shopAPIs).Mux)
+	s.UseCSRF("X-CSRF-Token", "csrf_token")
+	sessionPost(t, s, "/login", http.StatusOK)
+
+	s.SetCookie("/", &http.Cookie{Name: "sid", Value: "forged", Path: "/"})
+	sessionPost(t, s, "/order", http.StatusUnauthorized)
+
+	s.SetCookie("/", &http.Cookie{Name: "sid", Value: "s1", Path: "/"})
+	s.DeleteCookie("/", "sid")
+	sessionPost(t, s, "/order", http.StatusUnauthorized)
+
+	sessionPost(t, s, "/login", http.StatusOK)
+	s.ClearCookies()
+	if len(s.Cookies("/")) != 0 || s.CSRFToken() != "" {
+		t.Errorf("ClearCookies left %v %q", s.Cookies("/"), s.CSRFToken())
+	}
+
+	// without UseCSRF, the token is not sent
+	s = NewSession(NewMuxTest(shopAPIs).Mux)
+	sessionPost(t, s, "/login", http.StatusOK)
+	sessionPost(t, s, "/order", http.StatusForbidden)
+}
@@ -0,0 +1,85 @@
+package jsonapi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// SSEWriter sends server-sent events, see HTTP.SSE
+type SSEWriter struct {
+	h  *HTTP
+	lw *streamLimiter
+}
+
+// SSE starts a text/event-stream response, so the handler can push events before
+// it returns. It fails if the ResponseWriter cannot be flushed, or with ErrReplied
+// if the response has been sent. Like WriteJSON, the result returned by APIHandler
+// is ignored after calling it.
+//
+//     func progress(dec *json.Decoder, httpData *jsonapi.HTTP) (interface{}, error) {
+//         w, err := httpData.SSE()
+//         if err != nil {
+//             return nil, err
+//         }
+//         for p := range job.Progress(w.Context()) {
+//             if err := w.SendJSON("progress", p); err != nil {
+//                 break
+//             }
+//         }
+//         w.SendJSON("done", job.Result())
+//         return nil, nil
+//     }
+//
+// Long streams may need SetWriteDeadline to outlive WriteTimeout of the server.
+// Events beyond MaxResponseBytes are not sent, and SendJSON fails with an Error of
+// KindResponseTooLarge.
+// Client.Stream reads the events.
+func (h *HTTP) SSE() (*SSEWriter, error) {
+	if h.replied {
+		return nil, ErrReplied
+	}
+	header := h.ResponseWriter.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Del("Content-Length")
+	if err := h.Flush(); err != nil {
+		header.Set("Content-Type", "application/json")
+		header.Del("Cache-Control")
+		return nil, err
+	}
+	h.replied = true
+	return &SSEWriter{h: h, lw: h.limitStream(h.ResponseWriter)}, nil
+}
+
+// Context is the context of the request, which is done when client disconnects
+func (w *SSEWriter) Context() context.Context {
+	return w.h.Request.Context()
+}
+
+// SendJSON sends an event with data encoded in JSON format, and flushes it to the
+// client. Empty event sends an unnamed event, which is "message" for browsers.
+func (w *SSEWriter) SendJSON(event string, data interface{}) error {
+	if strings.ContainsAny(event, "\r\n") {
+		return fmt.Errorf("jsonapi: invalid event name %q", event)
+	}
+	if err := w.Context().Err(); err != nil {
+		return err
+	}
+	buf, err := marshal(rewriterFor(w.h).rewrite(data))
+	if err != nil {
+		return err
+	}
+	frame := fmt.Sprintf("data: %s\n\n", buf)
+	if event != "" {
+		frame = "event: " + event + "\n" + frame
+	}
+	if err := w.lw.reserve(len(frame)); err != nil {
+		w.lw.reportCut(w.h)
+		return err
+	}
+	if _, err := w.lw.Write([]byte(frame)); err != nil {
+		return err
+	}
+	return w.h.Flush()
+}
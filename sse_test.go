@@ -0,0 +1,117 @@
+package jsonapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// plainWriter is a ResponseWriter which cannot be flushed
+type plainWriter struct {
+	header http.Header
+	code   int
+}
+
+func (w *plainWriter) Header() http.Header         { return w.header }
+func (w *plainWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *plainWriter) WriteHeader(code int)        { w.code = code }
+
+func progressAPI(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+	w, err := httpData.SSE()
+	if err != nil {
+		return nil, err
+	}
+	for i := 1; i <= 3; i++ {
+		if err := w.SendJSON("progress", map[string]int{"percent": i * 33}); err != nil {
+			return nil, err
+		}
+	}
+	if err := w.SendJSON("bad\nname", nil); err == nil {
+		return nil, errors.New("invalid event name is sent")
+	}
+	w.SendJSON("", "done")
+	return "ignored", nil
+}
+
+func TestSSE(t *testing.T) {
+	rec := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	req, _ := http.NewRequest("GET", "/progress", nil)
+	HTTPHandler(APIHandler(progressAPI).Handler).ServeHTTP(rec, req)
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/event-stream" || rec.Header().Get("Cache-Control") != "no-cache" {
+		t.Errorf("unexpected headers %v", rec.Header())
+	}
+	expect := []string{
+		"",
+		"event: progress\ndata: {\"percent\":33}\n\n",
+		"event: progress\ndata: {\"percent\":66}\n\n",
+		"event: progress\ndata: {\"percent\":99}\n\n",
+		"data: \"done\"\n\n",
+	}
+	if strings.Join(rec.flushed, "|") != strings.Join(expect, "|") || rec.pending.Len() != 0 {
+		t.Errorf("events are not flushed one by one: %q", rec.flushed)
+	}
+}
+
+func TestSSEUnsupported(t *testing.T) {
+	w := &plainWriter{header: http.Header{}}
+	req, _ := http.NewRequest("GET", "/progress", nil)
+	HTTPHandler(APIHandler(progressAPI).Handler).ServeHTTP(w, req)
+	if w.code != http.StatusInternalServerError || w.header.Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected response %d %v", w.code, w.header)
+	}
+
+	var second error
+	HandlerTest(func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		httpData.WriteJSON(http.StatusOK, "sent")
+		_, second = httpData.SSE()
+	}).Get("/", "")
+	if second != ErrReplied {
+		t.Errorf("expected ErrReplied, got %v", second)
+	}
+}
+
+func TestSSEDisconnect(t *testing.T) {
+	stopped := make(chan error, 1)
+	srv := NewMuxTest([]API{{Pattern: "/events", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		w, err := httpData.SSE()
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; ; i++ {
+			if err := w.SendJSON("tick", i); err != nil {
+				stopped <- err
+				return nil, nil
+			}
+			select {
+			case <-w.Context().Done():
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}}}).StartServer()
+	defer srv.Close()
+
+	var got []int
+	stop := errors.New("enough")
+	err := NewClient(srv.URL).Stream(context.Background(), "GET", "/events", nil, func(item json.RawMessage) error {
+		var i int
+		json.Unmarshal(item, &i)
+		if got = append(got, i); len(got) == 5 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || len(got) != 5 || got[4] != 4 {
+		t.Errorf("unexpected events %v: %v", got, err)
+	}
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("handler does not stop after client disconnected")
+	}
+}
@@ -0,0 +1,159 @@
+package jsonapi
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+)
+
+// OnError, if not nil, is called with every error answered with 5xx status code
+// (except StatusClientClosedRequest). err carries a stack trace, which can be
+// retrieved by StackTrace, and wraps the error returned by handler. Panics are
+// reported too if Recover is used. RegisterOpts.OnError overrides it for some APIs.
+//
+//     jsonapi.OnError = func(httpData *jsonapi.HTTP, err error) {
+//         log.Printf("%s %s: %s\n%s", httpData.Request.Method, httpData.Request.URL, err,
+//             strings.Join(jsonapi.StackTrace(err), "\n"))
+//     }
+var OnError func(httpData *HTTP, err error)
+
+// DevMode includes stack trace in the body of 5xx responses, and Cause of Error in
+// the body of all error responses. Never enable it in production, it leaks details
+// of your code.
+var DevMode bool
+
+// CaptureStack makes Errorf capture stack trace where the error is created.
+// Other errors get stack trace where they are sent to client.
+var CaptureStack = true
+
+// maxStackDepth is the max number of frames captured
+const maxStackDepth = 32
+
+// pkgPath is used to trim frames of this package from stack traces
+var pkgPath = reflect.TypeOf(Error{}).PkgPath()
+
+// tracedError is an error with stack trace
+type tracedError struct {
+	err   error
+	stack []uintptr
+}
+
+func (e *tracedError) Error() string { return e.err.Error() }
+func (e *tracedError) Unwrap() error { return e.err }
+
+// withStack captures stack trace of the caller, skipping frames of this package
+func withStack(err error) error {
+	pc := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(2, pc)
+	return &tracedError{err: err, stack: pc[:n]}
+}
+
+// Errorf is fmt.Errorf which also captures stack trace if CaptureStack is true,
+// so OnError knows where an internal error is created.
+//
+//     return nil, jsonapi.Errorf("cannot load user %d: %w", id, err)
+func Errorf(format string, args ...interface{}) error {
+	err := fmt.Errorf(format, args...)
+	if !CaptureStack {
+		return err
+	}
+	return withStack(err)
+}
+
+// StackTrace returns the stack trace carried by err in the form of "function
+// (file:line)", top frame first, or nil if there's none. Frames of this package
+// (except its tests) and runtime are trimmed, so the code of your application is on top.
+func StackTrace(err error) []string {
+	var t *tracedError
+	if !errors.As(err, &t) {
+		return nil
+	}
+
+	ret := []string{}
+	frames := runtime.CallersFrames(t.stack)
+	for {
+		f, more := frames.Next()
+		if !isFrameworkFrame(f.Function) || strings.HasSuffix(f.File, "_test.go") {
+			ret = append(ret, fmt.Sprintf("%s (%s:%d)", f.Function, f.File, f.Line))
+		}
+		if !more {
+			break
+		}
+	}
+	return ret
+}
+
+// isFrameworkFrame reports whether fn is a function of this package or runtime
+func isFrameworkFrame(fn string) bool {
+	if strings.HasPrefix(fn, "runtime.") {
+		return true
+	}
+	rest := strings.TrimPrefix(fn, pkgPath)
+	// subpackages like jsonapi/redisstore are not trimmed
+	return rest != fn && strings.HasPrefix(rest, ".")
+}
+
+// reportError captures stack trace of err and calls OnError if it is an internal
+// error. It returns the traced error, or nil if it is not reported.
+func reportError(httpData *HTTP, code int, err error) error {
+	onError := OnError
+	if httpData.api != nil && httpData.api.onError != nil {
+		onError = httpData.api.onError
+	}
+	if code < 500 || code == StatusClientClosedRequest || (onError == nil && !DevMode) {
+		return nil
+	}
+	var t *tracedError
+	if !errors.As(err, &t) {
+		err = withStack(err)
+	}
+	if onError != nil {
+		onError(httpData, err)
+	}
+	return err
+}
+
+// devErrorBody is body of error responses in DevMode
+type devErrorBody struct {
+	Error   interface{}            `json:"error"`
+	Details map[string]interface{} `json:"details"`
+}
+
+// devBody adds stack trace and Cause of err to body in DevMode. Details of Error are
+// kept, dev info is merged into them only if they are a map[string]interface{}.
+func devBody(body interface{}, err, traced error) interface{} {
+	if !DevMode {
+		return body
+	}
+	details := map[string]interface{}{}
+	if traced != nil {
+		details["stack"] = StackTrace(traced)
+	}
+	var e Error
+	if errors.As(err, &e) && e.Cause != nil {
+		details["cause"] = e.Cause.Error()
+	}
+	if len(details) == 0 {
+		return body
+	}
+	if b, ok := body.(ErrorBody); ok {
+		switch d := b.Error.Details.(type) {
+		case nil:
+			b.Error.Details = details
+		case map[string]interface{}:
+			// merged into a copy, Details of the Error might be shared
+			merged := make(map[string]interface{}, len(d)+len(details))
+			for k, v := range details {
+				merged[k] = v
+			}
+			for k, v := range d {
+				merged[k] = v
+			}
+			b.Error.Details = merged
+		}
+		return b
+	}
+	return devErrorBody{Error: body, Details: details}
+}
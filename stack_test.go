@@ -0,0 +1,143 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func loadUser() error {
+	return Errorf("cannot load user %d: %w", 42, errors.New("connection refused"))
+}
+
+var stackAPIs = []API{
+	{Pattern: "/errorf", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return nil, loadUser()
+	}},
+	{Pattern: "/plain", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return nil, errors.New("plain failure")
+	}},
+	{Pattern: "/404", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return nil, E404
+	}},
+}
+
+func TestStackTraceReported(t *testing.T) {
+	reported := map[string]error{}
+	OnError = func(httpData *HTTP, err error) { reported[httpData.Request.URL.Path] = err }
+	defer func() { OnError = nil }()
+
+	m := NewMuxTest(stackAPIs)
+	for _, uri := range []string{"/errorf", "/plain", "/404"} {
+		resp, _ := m.Get(uri, "")
+		var body struct {
+			Error map[string]interface{} `json:"error"`
+		}
+		json.Unmarshal(resp.Body.Bytes(), &body)
+		if _, ok := body.Error["details"]; ok || strings.Contains(resp.Body.String(), ".go:") {
+			t.Errorf("%s: stack trace leaked in production: %s", uri, resp.Body)
+		}
+	}
+
+	if _, ok := reported["/404"]; ok {
+		t.Errorf("4xx reported to OnError")
+	}
+	stack := StackTrace(reported["/errorf"])
+	if len(stack) == 0 || !strings.Contains(stack[0], ".loadUser (") || !strings.Contains(stack[0], "stack_test.go:") {
+		t.Errorf("Errorf: stack does not start at loadUser: %q", stack)
+	}
+	if !strings.Contains(reported["/errorf"].Error(), "cannot load user 42") {
+		t.Errorf("reported error %v", reported["/errorf"])
+	}
+	for uri, err := range reported {
+		stack := StackTrace(err)
+		if len(stack) == 0 {
+			t.Errorf("%s: no stack trace", uri)
+		}
+		for _, f := range stack {
+			if strings.Contains(f, "jsonapi.writeError") || strings.HasPrefix(f, "runtime.") {
+				t.Errorf("%s: framework frame not trimmed: %s", uri, f)
+			}
+		}
+	}
+}
+
+func TestStackTraceDevMode(t *testing.T) {
+	DevMode = true
+	defer func() { DevMode = false }()
+
+	resp, _ := NewMuxTest(stackAPIs).Get("/errorf", "")
+	var body struct {
+		Error struct {
+			Details struct {
+				Stack []string `json:"stack"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != http.StatusInternalServerError || len(body.Error.Details.Stack) == 0 || !strings.Contains(body.Error.Details.Stack[0], "loadUser") {
+		t.Errorf("expected stack trace in body: %s", resp.Body)
+	}
+}
+
+func TestCaptureStackDisabled(t *testing.T) {
+	CaptureStack = false
+	defer func() { CaptureStack = true }()
+	if err := loadUser(); StackTrace(err) != nil {
+		t.Errorf("stack trace captured: %q", StackTrace(err))
+	}
+	if StackTrace(errors.New("x")) != nil {
+		t.Errorf("stack trace of plain error")
+	}
+}
+
+func TestRegisterOnError(t *testing.T) {
+	var global, own []string
+	OnError = func(httpData *HTTP, err error) { global = append(global, httpData.Request.URL.Path) }
+	defer func() { OnError = nil }()
+
+	dbErr := errors.New("connection refused")
+	mux := http.NewServeMux()
+	Register([]API{{Pattern: "/global", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return nil, dbErr
+	}}}, mux)
+	ownAPIs := []API{
+		{Pattern: "/wrapped", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return nil, E503.Wrap(dbErr)
+		}},
+		{Pattern: "/unencodable", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return make(chan int), nil
+		}},
+		{Pattern: "/panic", Middlewares: []Middleware{Recover(func(string, ...interface{}) {})}, APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			panic(dbErr)
+		}},
+		{Pattern: "/404", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return nil, E404
+		}},
+	}
+	var reported []error
+	RegisterWith(ownAPIs, mux, RegisterOpts{OnError: func(httpData *HTTP, err error) {
+		own = append(own, httpData.Request.URL.Path)
+		reported = append(reported, err)
+	}})
+
+	m := &MuxTest{TestRequest: &TestRequest{h: mux}, Mux: mux}
+	for _, uri := range []string{"/global", "/wrapped", "/unencodable", "/panic", "/404"} {
+		if resp, _ := m.Get(uri, ""); (uri == "/404") != (resp.Code < 500) {
+			t.Errorf("%s: unexpected status %d", uri, resp.Code)
+		}
+	}
+	if strings.Join(global, " ") != "/global" || strings.Join(own, " ") != "/wrapped /unencodable /panic" {
+		t.Fatalf("reported to package-level %q, to RegisterWith %q", global, own)
+	}
+	if !errors.Is(reported[0], dbErr) || !errors.Is(reported[2], dbErr) {
+		t.Errorf("original error is not reported: %v", reported)
+	}
+	if reported[1] == nil || !strings.Contains(reported[1].Error(), "chan") {
+		t.Errorf("/unencodable: reported %v", reported[1])
+	}
+}
@@ -0,0 +1,152 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// staticFile is a JSON document loaded by Static
+type staticFile struct {
+	data    []byte
+	gz      []byte // pre-compressed data from name.gz, optional
+	etag    string
+	modTime time.Time
+}
+
+// loadStatic reads and validates name in fsys
+func loadStatic(fsys fs.FS, name string) (*staticFile, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("jsonapi: %s is not valid JSON", name)
+	}
+	f := &staticFile{
+		data: data,
+		etag: entityTag(data),
+	}
+	if info, err := fs.Stat(fsys, name); err == nil {
+		// files in embed.FS have zero ModTime
+		f.modTime = info.ModTime().UTC().Truncate(time.Second)
+	}
+	if gz, err := fs.ReadFile(fsys, name+".gz"); err == nil {
+		f.gz = gz
+	}
+	return f, nil
+}
+
+// Static creates an APIHandler sending JSON document name in fsys, like embed.FS,
+// with ETag and Last-Modified headers. If name.gz exists, it is sent instead to
+// clients accepting gzip encoding.
+//
+//     //go:embed countries.json countries.json.gz
+//     var files embed.FS
+//
+//     jsonapi.Register([]jsonapi.API{
+//         {Pattern: "/api/countries", APIHandler: jsonapi.Static(files, "countries.json")},
+//     }, nil)
+//
+// The file is read and validated at once, Static panics if it is missing or not
+// valid JSON.
+func Static(fsys fs.FS, name string) APIHandler {
+	f, err := loadStatic(fsys, name)
+	if err != nil {
+		panic(err)
+	}
+	return func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return f, nil
+	}
+}
+
+// StaticDir creates an APIHandler sending JSON documents (files with .json extension)
+// in fsys, mapping request path without prefix to file name. Other files are
+// not sent, except .json.gz files used for pre-compression like Static.
+//
+//     jsonapi.Register([]jsonapi.API{
+//         {Pattern: "/api/data/", APIHandler: jsonapi.StaticDir(files, "/api/data/")},
+//     }, nil)
+//
+// All files are read and validated at once, StaticDir panics if any is not valid JSON.
+func StaticDir(fsys fs.FS, prefix string) APIHandler {
+	files := map[string]*staticFile{}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || path.Ext(name) != ".json" {
+			return err
+		}
+		f, err := loadStatic(fsys, name)
+		if err == nil {
+			files[name] = f
+		}
+		return err
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	return func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		name := strings.TrimPrefix(httpData.Request.URL.Path, prefix)
+		name = strings.TrimPrefix(path.Clean("/"+name), "/")
+		f, ok := files[name]
+		if !ok {
+			return nil, E404
+		}
+		return f, nil
+	}
+}
+
+// acceptsGzip reports whether client accepts gzip encoding
+func acceptsGzip(r *http.Request) bool {
+	accept := acceptEncoding(r)
+	if q, ok := accept["gzip"]; ok {
+		return q > 0
+	}
+	return accept["*"] > 0
+}
+
+// notModified checks conditional headers of the request
+func (f *staticFile) notModified(r *http.Request, etag string) bool {
+	if match, ok := etagMatches(r, etag); ok {
+		return match
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" && !f.modTime.IsZero() {
+		t, err := http.ParseTime(ims)
+		return err == nil && !f.modTime.After(t)
+	}
+	return false
+}
+
+func (f *staticFile) respond(httpData *HTTP) {
+	data, etag := f.data, f.etag
+	header := httpData.ResponseWriter.Header()
+	if f.gz != nil {
+		httpData.Vary("Accept-Encoding")
+		if acceptsGzip(httpData.Request) {
+			// each representation has its own entity tag
+			data, etag = f.gz, strings.TrimSuffix(etag, `"`)+`-gzip"`
+			header.Set("Content-Encoding", "gzip")
+		}
+	}
+	header.Set("ETag", etag)
+	if !f.modTime.IsZero() {
+		header.Set("Last-Modified", f.modTime.Format(http.TimeFormat))
+	}
+	if f.notModified(httpData.Request, etag) {
+		header.Del("Content-Type")
+		header.Del("Content-Encoding")
+		httpData.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	header.Set("Content-Length", strconv.Itoa(len(data)))
+	httpData.WriteHeader(http.StatusOK)
+	if httpData.Request.Method != "HEAD" {
+		httpData.ResponseWriter.Write(data)
+	}
+}
@@ -0,0 +1,134 @@
+package jsonapi
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func gzipped(s string) []byte {
+	buf := &bytes.Buffer{}
+	w := gzip.NewWriter(buf)
+	w.Write([]byte(s))
+	w.Close()
+	return buf.Bytes()
+}
+
+var (
+	staticTime = time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	staticFS   = fstest.MapFS{
+		"countries.json":         {Data: []byte(`["TW","JP"]`), ModTime: staticTime},
+		"countries.json.gz":      {Data: gzipped(`["TW","JP"]`)},
+		"config/public.json":     {Data: []byte(`{"debug":false}`)},
+		"config/notes.txt":       {Data: []byte("not json")},
+		"config/broken.json.bak": {Data: []byte("{")},
+	}
+)
+
+func staticTest() *MuxTest {
+	return NewMuxTest([]API{
+		{Pattern: "/api/countries", APIHandler: Static(staticFS, "countries.json")},
+		{Pattern: "/api/data/", APIHandler: StaticDir(staticFS, "/api/data/")},
+	})
+}
+
+func TestStatic(t *testing.T) {
+	m := staticTest()
+	resp, _ := m.Get("/api/countries", "")
+	if resp.Code != http.StatusOK || resp.Body.String() != `["TW","JP"]` {
+		t.Fatalf("unexpected response %d: %s", resp.Code, resp.Body)
+	}
+	etag := resp.Header().Get("ETag")
+	if ct := resp.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("unexpected content type %s", ct)
+	}
+	if etag == "" || resp.Header().Get("Last-Modified") != staticTime.Format(http.TimeFormat) {
+		t.Errorf("unexpected validators: %v", resp.Header())
+	}
+	if resp.Header().Get("Content-Encoding") != "" || resp.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("unexpected encoding headers: %v", resp.Header())
+	}
+
+	for _, h := range []Headers{
+		{"If-None-Match": etag},
+		{"If-Modified-Since": staticTime.Add(time.Hour).Format(http.TimeFormat)},
+	} {
+		resp, _ := m.With(h).Get("/api/countries", "")
+		if resp.Code != http.StatusNotModified || resp.Body.Len() != 0 {
+			t.Errorf("%v: expected 304, got %d: %s", h, resp.Code, resp.Body)
+		}
+	}
+	resp, _ = m.With(Headers{"If-None-Match": `"stale"`}).Get("/api/countries", "")
+	if resp.Code != http.StatusOK {
+		t.Errorf("expected 200 for stale etag, got %d", resp.Code)
+	}
+}
+
+func TestStaticGzip(t *testing.T) {
+	m := staticTest()
+	resp, _ := m.With(Headers{"Accept-Encoding": "gzip"}).Get("/api/countries", "")
+	if resp.Code != http.StatusOK || resp.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("pre-compressed file is not sent: %d %v", resp.Code, resp.Header())
+	}
+	r, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadAll(r); string(data) != `["TW","JP"]` {
+		t.Errorf("unexpected decompressed body: %s", data)
+	}
+
+	etag := resp.Header().Get("ETag")
+	plain, _ := m.Get("/api/countries", "")
+	if etag == plain.Header().Get("ETag") {
+		t.Errorf("representations share entity tag %s", etag)
+	}
+	resp, _ = m.With(Headers{"Accept-Encoding": "gzip", "If-None-Match": etag}).Get("/api/countries", "")
+	if resp.Code != http.StatusNotModified || resp.Header().Get("Content-Encoding") != "" {
+		t.Errorf("expected 304 without encoding, got %d %v", resp.Code, resp.Header())
+	}
+	resp, _ = m.With(Headers{"Accept-Encoding": "gzip;q=0"}).Get("/api/countries", "")
+	if resp.Header().Get("Content-Encoding") != "" || resp.Body.String() != `["TW","JP"]` {
+		t.Errorf("gzip sent to client refusing it: %v", resp.Header())
+	}
+}
+
+func TestStaticDir(t *testing.T) {
+	m := staticTest()
+	resp, _ := m.Get("/api/data/config/public.json", "")
+	if resp.Code != http.StatusOK || resp.Body.String() != `{"debug":false}` || resp.Header().Get("ETag") == "" {
+		t.Errorf("unexpected response %d: %s", resp.Code, resp.Body)
+	}
+	for _, uri := range []string{
+		"/api/data/missing.json",
+		"/api/data/config/notes.txt",
+		"/api/data/countries.json.gz",
+	} {
+		resp, _ := m.Get(uri, "")
+		if resp.Code != http.StatusNotFound || !strings.Contains(resp.Body.String(), `"code":404`) {
+			t.Errorf("%s: expected 404, got %d: %s", uri, resp.Code, resp.Body)
+		}
+	}
+}
+
+func TestStaticInvalid(t *testing.T) {
+	for name, f := range map[string]func(){
+		"missing": func() { Static(staticFS, "missing.json") },
+		"invalid": func() { Static(fstest.MapFS{"a.json": {Data: []byte("{")}}, "a.json") },
+		"dir":     func() { StaticDir(fstest.MapFS{"a/b.json": {Data: []byte("[")}}, "/") },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%s: expected panic", name)
+				}
+			}()
+			f()
+		}()
+	}
+}
@@ -0,0 +1,35 @@
+package jsonapi
+
+import "net/http"
+
+// StatusResult is returned by APIHandler to send Body with status code other than
+// 200. Body is encoded like other results, 204 and 304 responses have no body.
+type StatusResult struct {
+	Code     int
+	Body     interface{}
+	Location string // sets Location header if not empty, relative to request path
+}
+
+// Status creates a StatusResult sending body with status code
+//
+//     return jsonapi.Status(http.StatusAccepted, job), nil
+func Status(code int, body interface{}) StatusResult {
+	return StatusResult{Code: code, Body: body}
+}
+
+// Created creates a 201 StatusResult with Location header
+//
+//     return jsonapi.Created("/api/user/"+id, user), nil
+func Created(location string, body interface{}) StatusResult {
+	return StatusResult{Code: http.StatusCreated, Body: body, Location: location}
+}
+
+// NoContent creates a 204 StatusResult, which sends no body
+func NoContent() StatusResult {
+	return StatusResult{Code: http.StatusNoContent}
+}
+
+// bodyless reports whether responses of status code have no body
+func bodyless(code int) bool {
+	return code == http.StatusNoContent || code == http.StatusNotModified
+}
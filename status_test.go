@@ -0,0 +1,41 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestStatusResult(t *testing.T) {
+	user := map[string]int{"id": 42}
+	cases := []struct {
+		name     string
+		result   interface{}
+		code     int
+		body     string
+		location string
+	}{
+		{"created", Created("42", user), http.StatusCreated, `{"id":42}`, "/api/users/42"},
+		{"created absolute", Created("/api/user/42", user), http.StatusCreated, `{"id":42}`, "/api/user/42"},
+		{"accepted", Status(http.StatusAccepted, "queued"), http.StatusAccepted, `"queued"`, ""},
+		{"no content", NoContent(), http.StatusNoContent, ``, ""},
+		{"zero code", StatusResult{Body: user}, http.StatusOK, `{"id":42}`, ""},
+	}
+	for _, c := range cases {
+		c := c
+		resp, _ := HandlerTest(APIHandler(func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return c.result, nil
+		}).Handler).Post("/api/users/", "", "{}")
+		if resp.Code != c.code || strings.TrimSpace(resp.Body.String()) != c.body {
+			t.Errorf("%s: unexpected response %d: %s", c.name, resp.Code, resp.Body)
+		}
+		if loc := resp.Header().Get("Location"); loc != c.location {
+			t.Errorf("%s: unexpected Location %q", c.name, loc)
+		}
+		ct := resp.Header().Get("Content-Type")
+		if (c.code == http.StatusNoContent) != (ct == "") {
+			t.Errorf("%s: unexpected Content-Type %q", c.name, ct)
+		}
+	}
+}
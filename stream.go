@@ -0,0 +1,142 @@
+package jsonapi
+
+import (
+	"log"
+	"net/http"
+)
+
+// Stream is returned by APIHandler to send large results element by element,
+// without building the whole list in memory. Produce calls emit for each element,
+// which is encoded and written at once. emit fails if client has gone, and the
+// error should be returned as is.
+//
+//     func export(dec *json.Decoder, httpData *jsonapi.HTTP) (interface{}, error) {
+//         rows, err := db.QueryContext(httpData.Request.Context(), "SELECT * FROM logs")
+//         if err != nil {
+//             return nil, err
+//         }
+//         return jsonapi.Stream{Produce: func(emit func(interface{}) error) error {
+//             defer rows.Close()
+//             for rows.Next() {
+//                 var l Log
+//                 if err := rows.Scan(&l.ID, &l.Message); err != nil {
+//                     return err
+//                 }
+//                 if err := emit(l); err != nil {
+//                     return err
+//                 }
+//             }
+//             return rows.Err()
+//         }}, nil
+//     }
+//
+// If Produce fails before emitting anything, the error is sent like errors returned
+// by APIHandler. Otherwise headers are already sent: the stream is cut, and the
+// error is logged and reported to OnError unless client has gone. A cut JSON array
+// is left unterminated, so clients cannot mistake it for the complete result.
+//
+// The stream is cut the same way once it grows beyond MaxResponseBytes, and emit
+// fails with an Error of KindResponseTooLarge.
+type Stream struct {
+	Produce func(emit func(v interface{}) error) error
+
+	// ContentType defaults to "application/x-ndjson", which writes an element per
+	// line. "application/json" writes a JSON array incrementally instead.
+	ContentType string
+
+	// FlushEvery is the number of elements written between flushes, defaults to 1
+	FlushEvery int
+}
+
+// StreamChan creates a Stream sending elements received from ch until it is
+// closed. The sender should stop when context of the request is done, as nothing
+// is received from ch after client has gone.
+func StreamChan[T any](ch <-chan T) Stream {
+	return Stream{Produce: func(emit func(v interface{}) error) error {
+		for v := range ch {
+			if err := emit(v); err != nil {
+				return err
+			}
+		}
+		return nil
+	}}
+}
+
+func (s Stream) respond(httpData *HTTP) {
+	ct := s.ContentType
+	if ct == "" {
+		ct = "application/x-ndjson"
+	}
+	array := ct == "application/json"
+	every := s.FlushEvery
+	if every <= 0 {
+		every = 1
+	}
+	ctx := httpData.Request.Context()
+	rw := rewriterFor(httpData)
+	w := httpData.ResponseWriter
+	lw := httpData.limitStream(w)
+
+	n := 0
+	write := func(p []byte) error {
+		if err := lw.reserve(len(p)); err != nil {
+			return err
+		}
+		if n == 0 {
+			w.Header().Set("Content-Type", ct)
+			w.Header().Del("Content-Length")
+		}
+		_, err := lw.Write(p)
+		return err
+	}
+	emit := func(v interface{}) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		buf, err := marshal(rw.rewrite(v))
+		if err != nil {
+			return err
+		}
+		switch {
+		case !array:
+		case n == 0:
+			buf = append([]byte{'['}, buf...)
+		default:
+			buf = append([]byte{','}, buf...)
+		}
+		if !array {
+			buf = append(buf, '\n')
+		}
+		if err := write(buf); err != nil {
+			return err
+		}
+		if n++; n%every == 0 {
+			httpData.Flush()
+		}
+		return nil
+	}
+
+	err := s.Produce(emit)
+	if err == nil && array {
+		end := []byte("]\n")
+		if n == 0 {
+			end = []byte("[]\n")
+		}
+		if err = write(end); lw.err == nil {
+			err = nil
+		}
+	}
+	if err == nil {
+		return
+	}
+	if n == 0 {
+		writeError(httpData.encoder(), httpData, err)
+		return
+	}
+	if ctx.Err() != nil {
+		// client has gone
+		return
+	}
+	log.Printf("jsonapi: stream of %s cut after %d elements: %s", httpData.Request.URL.Path, n, err)
+	reportError(httpData, http.StatusInternalServerError, err)
+}
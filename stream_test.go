@@ -0,0 +1,120 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+// flushRecorder records data written between flushes
+type flushRecorder struct {
+	*httptest.ResponseRecorder
+	pending bytes.Buffer
+	flushed []string
+}
+
+func (r *flushRecorder) Write(p []byte) (int, error) {
+	r.pending.Write(p)
+	return r.ResponseRecorder.Write(p)
+}
+
+func (r *flushRecorder) Flush() {
+	r.flushed = append(r.flushed, r.pending.String())
+	r.pending.Reset()
+	r.ResponseRecorder.Flush()
+}
+
+func serveStream(s Stream) *flushRecorder {
+	rec := &flushRecorder{ResponseRecorder: httptest.NewRecorder()}
+	req, _ := http.NewRequest("GET", "/export", nil)
+	HTTPHandler(APIHandler(func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return s, nil
+	}).Handler).ServeHTTP(rec, req)
+	return rec
+}
+
+func produce(n int, err error) func(emit func(v interface{}) error) error {
+	return func(emit func(v interface{}) error) error {
+		for i := 0; i < n; i++ {
+			if e := emit(map[string]int{"id": i}); e != nil {
+				return e
+			}
+		}
+		return err
+	}
+}
+
+func TestStreamNDJSON(t *testing.T) {
+	rec := serveStream(Stream{Produce: produce(3, nil)})
+	if ct := rec.Header().Get("Content-Type"); ct != "application/x-ndjson" || rec.Code != http.StatusOK {
+		t.Errorf("unexpected response %d %s", rec.Code, ct)
+	}
+	expect := []string{"{\"id\":0}\n", "{\"id\":1}\n", "{\"id\":2}\n"}
+	if strings.Join(rec.flushed, "|") != strings.Join(expect, "|") || rec.pending.Len() != 0 {
+		t.Errorf("elements are not flushed one by one: %q, pending %q", rec.flushed, rec.pending.String())
+	}
+}
+
+func TestStreamArray(t *testing.T) {
+	rec := serveStream(Stream{Produce: produce(5, nil), ContentType: "application/json", FlushEvery: 2})
+	var ids []map[string]int
+	if err := json.Unmarshal(rec.Body.Bytes(), &ids); err != nil || len(ids) != 5 || ids[4]["id"] != 4 {
+		t.Errorf("invalid array %s: %v", rec.Body, err)
+	}
+	if strings.Join(rec.flushed, "|") != `[{"id":0},{"id":1}|,{"id":2},{"id":3}` {
+		t.Errorf("unexpected flushes %q", rec.flushed)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("unexpected content type %s", ct)
+	}
+
+	rec = serveStream(Stream{Produce: produce(0, nil), ContentType: "application/json"})
+	if rec.Body.String() != "[]\n" {
+		t.Errorf("unexpected empty array %q", rec.Body)
+	}
+}
+
+func TestStreamFailure(t *testing.T) {
+	var reported []error
+	OnError = func(httpData *HTTP, err error) { reported = append(reported, err) }
+	defer func() { OnError = nil }()
+	logs := &bytes.Buffer{}
+	log.SetOutput(logs)
+	defer log.SetOutput(os.Stderr)
+
+	broken := errors.New("connection lost")
+	rec := serveStream(Stream{Produce: produce(0, E404.SetData("No logs"))})
+	var body ErrorBody
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if rec.Code != http.StatusNotFound || body.Error.Message != "No logs" {
+		t.Errorf("error before first element is not sent: %d %s", rec.Code, rec.Body)
+	}
+
+	rec = serveStream(Stream{Produce: produce(2, broken), ContentType: "application/json"})
+	if rec.Code != http.StatusOK || rec.Body.String() != `[{"id":0},{"id":1}` {
+		t.Errorf("stream is not cut: %q", rec.Body)
+	}
+	if !strings.Contains(logs.String(), "stream of /export cut after 2 elements: connection lost") {
+		t.Errorf("unexpected logs %q", logs)
+	}
+	if len(reported) != 1 || !errors.Is(reported[0], broken) {
+		t.Errorf("unexpected reported errors %v", reported)
+	}
+}
+
+func TestStreamChan(t *testing.T) {
+	ch := make(chan int, 3)
+	ch <- 1
+	ch <- 2
+	ch <- 3
+	close(ch)
+	if rec := serveStream(StreamChan(ch)); rec.Body.String() != "1\n2\n3\n" {
+		t.Errorf("unexpected body %q", rec.Body)
+	}
+}
@@ -0,0 +1,55 @@
+package jsonapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+)
+
+// KindTrailingData is the Kind of Error sent when StrictBody rejects a request
+const KindTrailingData = "trailing_data"
+
+// StrictBody rejects requests with anything but whitespace after the JSON document
+// in request body, like `{"a":1}{"b":2}`, with a 400 Error of KindTrailingData.
+// API.StrictBody enables it per route, and API.AllowTrailingData skips the check for
+// routes decoding a stream of documents.
+//
+// The check runs before the handler is called, so the request body is buffered in
+// memory, see HTTP.RawBody. Bodies not in JSON format, like application/x-ndjson,
+// are not checked.
+var StrictBody bool
+
+func (h *HTTP) strictBody() bool {
+	if h.api != nil && h.api.AllowTrailingData {
+		return false
+	}
+	return StrictBody || (h.api != nil && h.api.StrictBody)
+}
+
+var errTrailingData = Error{
+	Code:    http.StatusBadRequest,
+	Message: "Trailing data after JSON document",
+	Kind:    KindTrailingData,
+}
+
+// hasTrailingData reports whether non-whitespace data remains after the first JSON
+// document in raw. Syntax errors are left to the decoder used by the handler.
+func hasTrailingData(raw []byte) bool {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	var doc json.RawMessage
+	if err := dec.Decode(&doc); err != nil {
+		return false
+	}
+	return !isSpace(raw[dec.InputOffset():])
+}
+
+func isSpace(buf []byte) bool {
+	for _, b := range buf {
+		switch b {
+		case ' ', '\t', '\r', '\n':
+		default:
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,94 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestStrictBody(t *testing.T) {
+	called := 0
+	handler := func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		called++
+		var v map[string]interface{}
+		if err := dec.Decode(&v); err != nil {
+			return nil, E400.SetData(err.Error())
+		}
+		return v, nil
+	}
+	stream := func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		called++
+		n := 0
+		for dec.More() {
+			var v interface{}
+			if err := dec.Decode(&v); err != nil {
+				return nil, E400.SetData(err.Error())
+			}
+			n++
+		}
+		return n, nil
+	}
+	m := NewMuxTest([]API{
+		{Pattern: "/strict", APIHandler: handler, StrictBody: true},
+		{Pattern: "/loose", APIHandler: handler},
+		{Pattern: "/stream", APIHandler: stream, StrictBody: true, AllowTrailingData: true},
+	})
+	cases := []struct {
+		uri, body string
+		code      int
+		called    bool
+	}{
+		{"/strict", `{"a":1}{"b":2}`, http.StatusBadRequest, false},
+		{"/strict", `{"a":1} DROP TABLE`, http.StatusBadRequest, false},
+		{"/strict", "{\"a\":1} \r\n\t", http.StatusOK, true},
+		{"/strict", `{"a":`, http.StatusBadRequest, true},
+		{"/loose", `{"a":1}{"b":2}`, http.StatusOK, true},
+		{"/stream", `{"a":1}{"b":2}`, http.StatusOK, true},
+	}
+	for _, c := range cases {
+		called = 0
+		resp, err := m.Post(c.uri, "", c.body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Code != c.code {
+			t.Errorf("%s %q: status = %d, want %d: %s", c.uri, c.body, resp.Code, c.code, resp.Body)
+		}
+		if (called > 0) != c.called {
+			t.Errorf("%s %q: handler called = %v, want %v", c.uri, c.body, called > 0, c.called)
+		}
+		if c.code == http.StatusBadRequest && !c.called {
+			var body ErrorBody
+			if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if body.Error.Kind != KindTrailingData {
+				t.Errorf("%s %q: kind = %q, want %q", c.uri, c.body, body.Error.Kind, KindTrailingData)
+			}
+		}
+	}
+}
+
+func TestStrictBodySkipsNDJSON(t *testing.T) {
+	StrictBody = true
+	defer func() { StrictBody = false }()
+
+	m := NewMuxTest([]API{{Pattern: "/", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		n := 0
+		for dec.More() {
+			var v interface{}
+			if err := dec.Decode(&v); err != nil {
+				return nil, err
+			}
+			n++
+		}
+		return n, nil
+	}}})
+	req, _ := http.NewRequest("POST", "/", strings.NewReader("{\"a\":1}\n{\"a\":2}\n"))
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	resp := m.Do(req)
+	if resp.Code != http.StatusOK || strings.TrimSpace(resp.Body.String()) != "2" {
+		t.Errorf("got %d %s", resp.Code, resp.Body)
+	}
+}
@@ -0,0 +1,221 @@
+package jsonapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// MaxRequestTimeout enables clients to limit how long the server may spend on their
+// requests, by sending X-Request-Timeout (or Request-Timeout) header with a duration
+// like "2s", or an integer in milliseconds:
+//
+//     X-Request-Timeout: 2000
+//
+// The value is clamped between MinRequestTimeout and MaxRequestTimeout, and set as the
+// deadline of request context, so handlers can stop working by watching
+// httpData.Request.Context(). If the context already has an earlier deadline, that one
+// wins, like a shorter API.Timeout or DefaultTimeout. The effective timeout is sent back
+// in X-Effective-Timeout header in milliseconds, and handlers returning the error of
+// expired context, like context.DeadlineExceeded, get a 504 Error of KindTimeout.
+// Invalid values are ignored.
+//
+// Zero disables the feature.
+var MaxRequestTimeout time.Duration
+
+// MinRequestTimeout is the lower bound of timeouts requested by clients, see MaxRequestTimeout
+var MinRequestTimeout time.Duration
+
+// requestTimeout parses timeout requested by client
+func requestTimeout(r *http.Request) (time.Duration, bool) {
+	v := r.Header.Get("X-Request-Timeout")
+	if v == "" {
+		v = r.Header.Get("Request-Timeout")
+	}
+	if v == "" {
+		return 0, false
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		ms, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return 0, false
+		}
+		d = time.Duration(ms) * time.Millisecond
+	}
+	if d <= 0 {
+		return 0, false
+	}
+	return d, true
+}
+
+// withClientTimeout applies timeout requested by client to the context of r
+func withClientTimeout(w http.ResponseWriter, r *http.Request) (*http.Request, context.CancelFunc) {
+	if MaxRequestTimeout <= 0 {
+		return r, func() {}
+	}
+	d, ok := requestTimeout(r)
+	if !ok {
+		return r, func() {}
+	}
+
+	if d > MaxRequestTimeout {
+		d = MaxRequestTimeout
+	}
+	if d < MinRequestTimeout {
+		d = MinRequestTimeout
+	}
+
+	ctx, cancel := withTimeout(r.Context(), d)
+	setEffectiveTimeout(w.Header(), ctx)
+	return r.WithContext(ctx), cancel
+}
+
+// setEffectiveTimeout sets X-Effective-Timeout header to time left before deadline
+// of ctx
+func setEffectiveTimeout(header http.Header, ctx context.Context) {
+	if deadline, ok := ctx.Deadline(); ok {
+		d := deadline.Sub(DefaultClock.Now()).Round(time.Millisecond)
+		header.Set("X-Effective-Timeout", strconv.FormatInt(d.Milliseconds(), 10))
+	}
+}
+
+// KindTimeout is the Kind of Error sent when handler does not return in time
+const KindTimeout = "timeout"
+
+// DefaultTimeout limits how long handlers of registered APIs may run, zero means
+// no limit. API.Timeout overrides it per route, a negative value disables it.
+//
+// Context of the request is canceled at the deadline, and a 504 Error of KindTimeout
+// is sent if the handler has not returned. The response written by handler is
+// buffered until it returns, so only one of them is sent, and handlers writing after
+// the timeout get http.ErrHandlerTimeout. Streaming responses like SSE cannot be
+// flushed early under a timeout.
+//
+// The request is finished after the handler returns, so handlers should stop once
+// the context is done.
+var DefaultTimeout time.Duration
+
+var errTimeout = Error{
+	Code:    http.StatusGatewayTimeout,
+	Message: "Request timed out",
+	Kind:    KindTimeout,
+}
+
+func (h *HTTP) timeout() time.Duration {
+	if h.api != nil && h.api.Timeout != 0 {
+		return h.api.Timeout
+	}
+	return DefaultTimeout
+}
+
+// runWithTimeout runs next with a copy of h, which writes to a buffer and has a
+// request context canceled after d. The copy shares bodyState with h, so the
+// handler can still read request body after the deadline.
+func (h *HTTP) runWithTimeout(d time.Duration, next HTTPHandler, dec *json.Decoder) {
+	ctx, cancel := withTimeout(h.Request.Context(), d)
+	defer cancel()
+	if header := h.ResponseWriter.Header(); header.Get("X-Effective-Timeout") != "" {
+		// timeout asked by client is cut by d
+		setEffectiveTimeout(header, ctx)
+	}
+
+	tw := &timeoutWriter{header: h.ResponseWriter.Header().Clone()}
+	inner := *h
+	inner.ResponseWriter = tw
+	inner.Request = h.Request.WithContext(ctx)
+
+	done := make(chan struct{})
+	var panicked interface{}
+	go func() {
+		defer func() {
+			panicked = recover()
+			close(done)
+		}()
+		next(inner.encoder(), dec, &inner)
+	}()
+
+	select {
+	case <-done:
+		w, r := h.ResponseWriter, h.Request
+		*h = inner
+		h.ResponseWriter, h.Request = w, r
+		if panicked != nil {
+			panic(panicked)
+		}
+		tw.copyTo(w)
+	case <-ctx.Done():
+		tw.expire()
+		if ctx.Err() == context.DeadlineExceeded {
+			h.replied = true
+			writeError(h.encoder(), h, errTimeout)
+			// the client should not wait for the handler to return
+			http.NewResponseController(h.ResponseWriter).Flush()
+		}
+		// the handler must not outlive the request
+		<-done
+	}
+}
+
+// timeoutWriter buffers the response until handler returns. It does not implement
+// Unwrap, so the handler cannot reach underlying ResponseWriter after the timeout.
+type timeoutWriter struct {
+	mu      sync.Mutex
+	header  http.Header
+	buf     bytes.Buffer
+	code    int
+	expired bool
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expired || w.code != 0 {
+		return
+	}
+	w.code = code
+}
+
+func (w *timeoutWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expired {
+		return 0, http.ErrHandlerTimeout
+	}
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	return w.buf.Write(p)
+}
+
+// expire rejects further writes
+func (w *timeoutWriter) expire() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.expired = true
+}
+
+// copyTo sends buffered response to dst
+func (w *timeoutWriter) copyTo(dst http.ResponseWriter) {
+	header := dst.Header()
+	for k := range header {
+		delete(header, k)
+	}
+	for k, v := range w.header {
+		header[k] = v
+	}
+	if w.code == 0 {
+		return
+	}
+	dst.WriteHeader(w.code)
+	dst.Write(w.buf.Bytes())
+}
@@ -0,0 +1,241 @@
+package jsonapi
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowAPI ignores context of the request, and returns after release is closed
+func slowAPI(release chan struct{}) APIHandler {
+	return func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		<-release
+		return "late", nil
+	}
+}
+
+func TestTimeoutSendsGatewayTimeoutAtDeadline(t *testing.T) {
+	release := make(chan struct{})
+	m := NewMuxTest([]API{{Pattern: "/slow", APIHandler: slowAPI(release), Timeout: 50 * time.Millisecond}})
+	srv := m.StartServer()
+	defer srv.Close()
+	// the handler returns eventually even if the 504 is held back
+	time.AfterFunc(time.Second, func() { close(release) })
+
+	start := time.Now()
+	resp, err := http.Get(srv.URL + "/slow")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("504 arrived after %s, want right after the deadline", elapsed)
+	}
+	if resp.StatusCode != http.StatusGatewayTimeout {
+		t.Fatalf("status = %d, want 504", resp.StatusCode)
+	}
+	var body ErrorBody
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Error.Kind != KindTimeout {
+		t.Errorf("kind = %q, want %q", body.Error.Kind, KindTimeout)
+	}
+}
+
+func TestTimeoutFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	DefaultClock = clock
+	defer func() { DefaultClock = RealClock{} }()
+
+	started := make(chan struct{})
+	m := NewMuxTest([]API{{
+		Pattern: "/wait",
+		Timeout: time.Minute,
+		APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			close(started)
+			<-httpData.Request.Context().Done()
+			return nil, httpData.Request.Context().Err()
+		},
+	}})
+	go func() {
+		<-started
+		clock.Advance(time.Minute)
+	}()
+
+	resp, err := m.Get("/wait", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != http.StatusGatewayTimeout {
+		t.Errorf("status = %d, want 504: %s", resp.Code, resp.Body)
+	}
+}
+
+func TestTimeoutHandlerInTime(t *testing.T) {
+	m := NewMuxTest([]API{{
+		Pattern: "/fast",
+		Timeout: time.Second,
+		APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			httpData.ResponseWriter.Header().Set("X-Handler", "1")
+			return StatusResult{Code: http.StatusCreated, Body: "ok"}, nil
+		},
+	}})
+	resp, err := m.Get("/fast", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != http.StatusCreated || strings.TrimSpace(resp.Body.String()) != `"ok"` {
+		t.Errorf("got %d %s", resp.Code, resp.Body)
+	}
+	if resp.Header().Get("X-Handler") != "1" {
+		t.Error("headers set by handler are lost")
+	}
+}
+
+func TestTimeoutDefaultAndDisabled(t *testing.T) {
+	DefaultTimeout = 20 * time.Millisecond
+	defer func() { DefaultTimeout = 0 }()
+
+	deadline := func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		_, ok := httpData.Request.Context().Deadline()
+		return ok, nil
+	}
+	m := NewMuxTest([]API{
+		{Pattern: "/default", APIHandler: deadline},
+		{Pattern: "/unlimited", APIHandler: deadline, Timeout: -1},
+	})
+	for uri, want := range map[string]string{"/default": "true", "/unlimited": "false"} {
+		resp, err := m.Get(uri, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSpace(resp.Body.String()); got != want {
+			t.Errorf("%s: has deadline = %s, want %s", uri, got, want)
+		}
+	}
+}
+
+func TestWithTimeoutFakeClock(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	DefaultClock = clock
+	defer func() { DefaultClock = RealClock{} }()
+
+	ctx, cancel := withTimeout(context.Background(), time.Second)
+	defer cancel()
+	if d, ok := ctx.Deadline(); !ok || !d.Equal(clock.Now().Add(time.Second)) {
+		t.Errorf("deadline = %v, %v", d, ok)
+	}
+	clock.Advance(time.Second)
+	<-ctx.Done()
+	if ctx.Err() != context.DeadlineExceeded {
+		t.Errorf("err = %v, want DeadlineExceeded", ctx.Err())
+	}
+
+	ctx, cancel = withTimeout(context.Background(), time.Second)
+	cancel()
+	if ctx.Err() != context.Canceled {
+		t.Errorf("err = %v, want Canceled", ctx.Err())
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	DefaultClock = clock
+	MaxRequestTimeout, MinRequestTimeout = 5*time.Second, 100*time.Millisecond
+	defer func() {
+		DefaultClock = RealClock{}
+		MaxRequestTimeout, MinRequestTimeout = 0, 0
+	}()
+
+	ok := func(dec *json.Decoder, httpData *HTTP) (interface{}, error) { return "ok", nil }
+	m := NewMuxTest([]API{
+		{Pattern: "/plain", APIHandler: ok},
+		{Pattern: "/short", APIHandler: ok, Timeout: time.Second},
+	})
+	cases := []struct {
+		uri, asked, want string
+	}{
+		{"/plain", "2000", "2000"},
+		{"/plain", "1m", "5000"},
+		{"/plain", "1ms", "100"},
+		{"/short", "2s", "1000"},
+		{"/short", "500", "500"},
+		{"/plain", "soon", ""},
+	}
+	for _, c := range cases {
+		resp, err := m.With(Headers{"X-Request-Timeout": c.asked}).Get(c.uri, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := resp.Header().Get("X-Effective-Timeout"); got != c.want {
+			t.Errorf("%s %s: X-Effective-Timeout = %q, want %q", c.uri, c.asked, got, c.want)
+		}
+	}
+}
+
+func TestClientTimeoutExpired(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	DefaultClock = clock
+	MaxRequestTimeout = time.Minute
+	defer func() {
+		DefaultClock = RealClock{}
+		MaxRequestTimeout = 0
+	}()
+
+	started := make(chan struct{})
+	m := NewMuxTest([]API{{Pattern: "/wait", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		close(started)
+		<-httpData.Request.Context().Done()
+		return nil, httpData.Request.Context().Err()
+	}}})
+	go func() {
+		<-started
+		clock.Advance(time.Second)
+	}()
+
+	resp, err := m.With(Headers{"X-Request-Timeout": "1s"}).Get("/wait", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body ErrorBody
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != http.StatusGatewayTimeout || body.Error.Kind != KindTimeout {
+		t.Errorf("got %d %s", resp.Code, resp.Body)
+	}
+}
+
+func TestTimeoutHandlerReadsBodyLate(t *testing.T) {
+	done := make(chan error, 1)
+	m := NewMuxTest([]API{{Pattern: "/slow", Timeout: 20 * time.Millisecond, MaxBodyBytes: 16, APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		<-httpData.Request.Context().Done()
+		var v interface{}
+		err := Bind(dec, httpData, &v)
+		done <- err
+		return v, err
+	}}})
+	srv := m.StartServer()
+	defer srv.Close()
+
+	// without Content-Length, so it is not rejected early
+	body := io.MultiReader(strings.NewReader(`["` + strings.Repeat("x", 1000) + `"]`))
+	resp, err := http.Post(srv.URL+"/slow", "application/json", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var e ErrorBody
+	json.NewDecoder(resp.Body).Decode(&e)
+	if resp.StatusCode != http.StatusGatewayTimeout || e.Error.Kind != KindTimeout {
+		t.Errorf("got %d %+v", resp.StatusCode, e)
+	}
+	if err := <-done; err == nil {
+		t.Errorf("oversized body is accepted after the deadline")
+	}
+}
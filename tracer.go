@@ -0,0 +1,76 @@
+package jsonapi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// Tracer starts a span for each request to registered APIs. route is API.Pattern.
+// The returned context is used as context of the request, so spans created by the
+// handler and downstream calls join it. finish is called after the response is
+// sent, with status code and the error sent to client, which is nil on success.
+//
+// It can be bridged to a tracing library, like OpenTelemetry:
+//
+//     type otelTracer struct {
+//         tracer trace.Tracer
+//     }
+//
+//     func (t otelTracer) StartSpan(ctx context.Context, route string) (context.Context, func(int, error)) {
+//         ctx, span := t.tracer.Start(ctx, route, trace.WithSpanKind(trace.SpanKindServer))
+//         return ctx, func(status int, err error) {
+//             span.SetAttributes(semconv.HTTPRoute(route), semconv.HTTPResponseStatusCode(status))
+//             if status >= 500 {
+//                 span.SetStatus(codes.Error, http.StatusText(status))
+//             }
+//             if err != nil {
+//                 span.RecordError(err)
+//             }
+//             span.End()
+//         }
+//     }
+//
+//     jsonapi.DefaultTracer = otelTracer{otel.Tracer("api")}
+type Tracer interface {
+	StartSpan(ctx context.Context, route string) (context.Context, func(status int, err error))
+}
+
+// NopTracer is a Tracer doing nothing
+type NopTracer struct{}
+
+// StartSpan implements Tracer
+func (NopTracer) StartSpan(ctx context.Context, route string) (context.Context, func(status int, err error)) {
+	return ctx, func(int, error) {}
+}
+
+// DefaultTracer is used by APIs not registered with RegisterOpts.Tracer
+var DefaultTracer Tracer = NopTracer{}
+
+// trace runs next in a span started by tracer
+func trace(tracer Tracer, route string, next HTTPHandler) HTTPHandler {
+	return func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		ctx, finish := tracer.StartSpan(httpData.Request.Context(), route)
+		httpData.Request = httpData.Request.WithContext(ctx)
+		w := &statusWriter{ResponseWriter: httpData.ResponseWriter}
+		httpData.ResponseWriter = w
+		defer func() {
+			httpData.ResponseWriter = w.ResponseWriter
+			status, err := w.status, httpData.failure
+			v := recover()
+			switch {
+			case v != nil && status == 0:
+				status = http.StatusInternalServerError
+				err = fmt.Errorf("panic: %v", v)
+			case status == 0:
+				status = http.StatusOK
+			}
+			finish(status, err)
+			if v != nil {
+				panic(v)
+			}
+		}()
+		next(httpData.configureEncoder(json.NewEncoder(w)), dec, httpData)
+	}
+}
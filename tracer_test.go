@@ -0,0 +1,120 @@
+package jsonapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+type spanKey struct{}
+
+type fakeSpan struct {
+	route  string
+	status int
+	err    error
+	ended  bool
+}
+
+// fakeTracer records spans, and puts the span in context of requests
+type fakeTracer struct {
+	mu    sync.Mutex
+	spans []*fakeSpan
+}
+
+func (t *fakeTracer) StartSpan(ctx context.Context, route string) (context.Context, func(int, error)) {
+	span := &fakeSpan{route: route}
+	t.mu.Lock()
+	t.spans = append(t.spans, span)
+	t.mu.Unlock()
+	return context.WithValue(ctx, spanKey{}, span), func(status int, err error) {
+		span.status, span.err, span.ended = status, err, true
+	}
+}
+
+func TestTracer(t *testing.T) {
+	tracer := &fakeTracer{}
+	dbErr := errors.New("connection refused")
+	var joined bool
+	mux := http.NewServeMux()
+	RegisterWith([]API{
+		{Pattern: "GET /api/user/{id}", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			span, _ := httpData.Request.Context().Value(spanKey{}).(*fakeSpan)
+			joined = span != nil && !span.ended
+			switch httpData.Request.PathValue("id") {
+			case "0":
+				return nil, E404.SetData("User not found")
+			case "db":
+				return nil, E500.Wrap(dbErr)
+			}
+			return "john", nil
+		}},
+		{Pattern: "/api/panic", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			panic("boom")
+		}},
+	}, mux, RegisterOpts{Tracer: tracer})
+	m := &MuxTest{TestRequest: &TestRequest{h: mux}, Mux: mux}
+
+	m.Get("/api/user/1", "")
+	if !joined {
+		t.Errorf("span is not in context of the request")
+	}
+	m.Get("/api/user/0", "")
+	m.Get("/api/user/db", "")
+	func() {
+		defer func() {
+			if v := recover(); v != "boom" {
+				t.Errorf("panic is not passed through: %v", v)
+			}
+		}()
+		m.Get("/api/panic", "")
+	}()
+
+	if len(tracer.spans) != 4 {
+		t.Fatalf("expected 4 spans, got %d", len(tracer.spans))
+	}
+	for i, expect := range []struct {
+		route  string
+		status int
+		err    string
+	}{
+		{"GET /api/user/{id}", 200, ""},
+		{"GET /api/user/{id}", 404, "User not found"},
+		{"GET /api/user/{id}", 500, "Internal server error"},
+		{"/api/panic", 500, "panic: boom"},
+	} {
+		span := tracer.spans[i]
+		msg := ""
+		if span.err != nil {
+			msg = span.err.Error()
+		}
+		if !span.ended || span.route != expect.route || span.status != expect.status || !strings.Contains(msg, expect.err) || (expect.err == "") != (span.err == nil) {
+			t.Errorf("span %d: expected %+v, got %+v", i, expect, span)
+		}
+	}
+	if !errors.Is(tracer.spans[2].err, dbErr) {
+		t.Errorf("original error is not recorded: %v", tracer.spans[2].err)
+	}
+}
+
+func TestDefaultTracer(t *testing.T) {
+	tracer := &fakeTracer{}
+	DefaultTracer = tracer
+	defer func() { DefaultTracer = NopTracer{} }()
+	m := NewMuxTest([]API{{Pattern: "/api/ping", APIHandler: okAPI}})
+	m.Get("/api/ping", "")
+	if len(tracer.spans) != 1 || tracer.spans[0].route != "/api/ping" || tracer.spans[0].status != 200 {
+		t.Errorf("unexpected spans %+v", tracer.spans)
+	}
+
+	// NopTracer keeps the context
+	ctx := context.WithValue(context.Background(), spanKey{}, 1)
+	if got, finish := (NopTracer{}).StartSpan(ctx, "/"); got != ctx {
+		t.Errorf("context is changed")
+	} else {
+		finish(200, nil)
+	}
+}
@@ -0,0 +1,101 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+)
+
+// Typed creates an APIHandler from fn, which receives request body decoded into
+// Req by Bind, so malformed JSON becomes a 400 Error and validators of Req run
+// before calling fn. Zero-sized Req like struct{} skips decoding, for handlers of
+// GET requests.
+//
+//     func hello(args HelloArgs, httpData *jsonapi.HTTP) (HelloReply, error) {
+//         return HelloReply{"Hello, " + args.Name}, nil
+//     }
+//
+//     jsonapi.Register([]jsonapi.API{
+//         {Pattern: "/api/hello", APIHandler: jsonapi.Typed(hello)},
+//     }, nil)
+func Typed[Req any, Resp any](fn func(req Req, httpData *HTTP) (Resp, error)) APIHandler {
+	skip := reflect.TypeOf((*Req)(nil)).Elem().Size() == 0
+	return func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		var req Req
+		if !skip {
+			if err := Bind(dec, httpData, &req); err != nil {
+				return nil, err
+			}
+		}
+		resp, err := fn(req, httpData)
+		if err != nil {
+			return nil, err
+		}
+		return resp, nil
+	}
+}
+
+var (
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	httpPtrType = reflect.TypeOf((*HTTP)(nil))
+)
+
+// Wrap creates an APIHandler from fn, which is one of
+//
+//     func(args Args) (Reply, error)
+//     func(httpData *jsonapi.HTTP, args Args) (Reply, error)
+//     func() (Reply, error)
+//
+// Like Typed, args are decoded and validated by Bind, and Args can be a pointer.
+// It panics if fn is not one of them, so mistakes are found when registering.
+//
+//     jsonapi.Register([]jsonapi.API{
+//         {Pattern: "/api/hello", APIHandler: jsonapi.Wrap(hello)},
+//     }, nil)
+func Wrap(fn interface{}) APIHandler {
+	fv := reflect.ValueOf(fn)
+	if fv.Kind() != reflect.Func || fv.IsNil() {
+		panic(fmt.Sprintf("jsonapi: Wrap needs a function, got %T", fn))
+	}
+	ft := fv.Type()
+	if ft.IsVariadic() || ft.NumOut() != 2 || ft.Out(1) != errorType {
+		panic(fmt.Sprintf("jsonapi: function passed to Wrap must return (Reply, error), got %s", ft))
+	}
+	withHTTP := ft.NumIn() == 2 && ft.In(0) == httpPtrType
+	var args reflect.Type
+	switch {
+	case ft.NumIn() == 0:
+	case ft.NumIn() == 1 && ft.In(0) != httpPtrType:
+		args = ft.In(0)
+	case withHTTP && ft.In(1) != httpPtrType:
+		args = ft.In(1)
+	default:
+		panic(fmt.Sprintf("jsonapi: function passed to Wrap must receive (Args) or (*HTTP, Args), got %s", ft))
+	}
+
+	return func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		var in []reflect.Value
+		if withHTTP {
+			in = append(in, reflect.ValueOf(httpData))
+		}
+		if args != nil {
+			t := args
+			if t.Kind() == reflect.Ptr {
+				t = t.Elem()
+			}
+			v := reflect.New(t)
+			if err := Bind(dec, httpData, v.Interface()); err != nil {
+				return nil, err
+			}
+			if args.Kind() != reflect.Ptr {
+				v = v.Elem()
+			}
+			in = append(in, v)
+		}
+		out := fv.Call(in)
+		if err, _ := out[1].Interface().(error); err != nil {
+			return nil, err
+		}
+		return out[0].Interface(), nil
+	}
+}
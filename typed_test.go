@@ -0,0 +1,141 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type typedArgs struct {
+	Name  string `json:"name"`
+	Title string `json:"title"`
+}
+
+func (a typedArgs) Validate() error {
+	if a.Title == "Dr." && a.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+type typedReply struct {
+	Message string `json:"message"`
+}
+
+func typedHello(args typedArgs, httpData *HTTP) (typedReply, error) {
+	if args.Name == "nobody" {
+		return typedReply{}, E404.SetData("No such user")
+	}
+	return typedReply{"Hello, " + strings.TrimSpace(args.Title+" "+args.Name)}, nil
+}
+
+func TestTyped(t *testing.T) {
+	h := HandlerTest(Typed(typedHello).Handler)
+	cases := []struct {
+		name   string
+		body   string
+		code   int
+		expect string
+	}{
+		{"happy path", `{"name":"John","title":"Mr."}`, 200, "Hello, Mr. John"},
+		{"empty body", ``, 200, "Hello,"},
+		{"malformed", `{"name":`, 400, "Cannot decode request body: "},
+		{"wrong type", `{"name":42}`, 400, "Cannot decode request body: "},
+		{"validation", `{"title":"Dr."}`, 422, "name is required"},
+		{"handler error", `{"name":"nobody"}`, 404, "No such user"},
+	}
+	for _, c := range cases {
+		resp, _ := h.Post("/api/hello", "", c.body)
+		if resp.Code != c.code {
+			t.Errorf("%s: expected %d, got %d: %s", c.name, c.code, resp.Code, resp.Body)
+			continue
+		}
+		var msg string
+		if c.code == 200 {
+			var reply typedReply
+			json.Unmarshal(resp.Body.Bytes(), &reply)
+			msg = reply.Message
+		} else {
+			var body ErrorBody
+			json.Unmarshal(resp.Body.Bytes(), &body)
+			msg = body.Error.Message
+		}
+		if !strings.HasPrefix(msg, c.expect) {
+			t.Errorf("%s: unexpected message %q", c.name, msg)
+		}
+	}
+}
+
+func TestTypedSkipsDecoding(t *testing.T) {
+	h := HandlerTest(Typed(func(req struct{}, httpData *HTTP) ([]string, error) {
+		return []string{httpData.Request.Method}, nil
+	}).Handler)
+	for _, body := range []string{"", "not json at all"} {
+		resp, _ := h.Post("/api/list", "", body)
+		if resp.Code != http.StatusOK || strings.TrimSpace(resp.Body.String()) != `["POST"]` {
+			t.Errorf("%q: unexpected response %d: %s", body, resp.Code, resp.Body)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	cases := []struct {
+		name string
+		fn   interface{}
+	}{
+		{"args", func(args typedArgs) (typedReply, error) {
+			return typedHello(args, nil)
+		}},
+		{"http and args", func(httpData *HTTP, args typedArgs) (typedReply, error) {
+			return typedHello(args, httpData)
+		}},
+		{"pointer args", func(httpData *HTTP, args *typedArgs) (*typedReply, error) {
+			reply, err := typedHello(*args, httpData)
+			return &reply, err
+		}},
+	}
+	for _, c := range cases {
+		h := HandlerTest(Wrap(c.fn).Handler)
+		for body, expect := range map[string]string{
+			`{"name":"John","title":"Mr."}`: `{"message":"Hello, Mr. John"}`,
+			`{"name":"nobody"}`:             `{"error":{"code":404,"message":"No such user"}}`,
+			`{"title":"Dr."}`:               `{"error":{"code":422,"message":"name is required","kind":"validation_failed"}}`,
+			`{"name":`:                      `{"error":{"code":400,"message":"Cannot decode request body: unexpected EOF"}}`,
+		} {
+			resp, _ := h.Post("/api/hello", "", body)
+			if s := strings.TrimSpace(resp.Body.String()); s != expect {
+				t.Errorf("%s %s: unexpected response %d %s", c.name, body, resp.Code, s)
+			}
+		}
+	}
+
+	h := HandlerTest(Wrap(func() ([]string, error) { return []string{"a"}, nil }).Handler)
+	if resp, _ := h.Post("/api/list", "", "not json at all"); strings.TrimSpace(resp.Body.String()) != `["a"]` {
+		t.Errorf("no args: unexpected response %d %s", resp.Code, resp.Body)
+	}
+}
+
+func TestWrapRejects(t *testing.T) {
+	for _, fn := range []interface{}{
+		nil,
+		"hello",
+		(func() (string, error))(nil),
+		func(args typedArgs) typedReply { return typedReply{} },
+		func(args typedArgs) (typedReply, string) { return typedReply{}, "" },
+		func(args typedArgs, httpData *HTTP) (typedReply, error) { return typedReply{}, nil },
+		func(httpData *HTTP) (typedReply, error) { return typedReply{}, nil },
+		func(args ...typedArgs) (typedReply, error) { return typedReply{}, nil },
+	} {
+		func() {
+			defer func() {
+				v := recover()
+				if msg, _ := v.(string); !strings.HasPrefix(msg, "jsonapi: ") {
+					t.Errorf("%T: expected panic, got %v", fn, v)
+				}
+			}()
+			Wrap(fn)
+		}()
+	}
+}
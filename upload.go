@@ -0,0 +1,109 @@
+package jsonapi
+
+import (
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+// UploadFile is a file sent by Client.Upload
+type UploadFile struct {
+	Field       string // name of form field
+	Filename    string
+	ContentType string // defaults to application/octet-stream
+	Reader      io.Reader
+
+	// Progress, if not nil, is called with bytes of this file written so far
+	Progress func(written int64)
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// progressReader reports bytes read from it
+type progressReader struct {
+	io.Reader
+	n  int64
+	fn func(int64)
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	if n > 0 {
+		r.n += int64(n)
+		r.fn(r.n)
+	}
+	return n, err
+}
+
+// Upload POSTs fields and files to uri as multipart/form-data, and decodes the
+// response into out if it is not nil. Files are streamed instead of being loaded
+// into memory, so their size is not limited.
+//
+//     f, _ := os.Open("avatar.png")
+//     defer f.Close()
+//     var ret UploadResult
+//     err := c.Upload(ctx, "avatar", map[string]string{"user": "42"}, []jsonapi.UploadFile{
+//         {Field: "avatar", Filename: "avatar.png", ContentType: "image/png", Reader: f},
+//     }, &ret)
+func (c *Client) Upload(ctx context.Context, uri string, fields map[string]string, files []UploadFile, out interface{}) error {
+	u, err := c.base.Parse(uri)
+	if err != nil {
+		return err
+	}
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	r, err := http.NewRequestWithContext(ctx, "POST", u.String(), pr)
+	if err != nil {
+		return err
+	}
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+
+	go func() {
+		pw.CloseWithError(writeMultipart(mw, fields, files))
+	}()
+	err = c.send(r, out)
+	// stops the writer if request failed before whole body is sent
+	pr.Close()
+	return err
+}
+
+// writeMultipart writes fields (sorted by name) and files into mw
+func writeMultipart(mw *multipart.Writer, fields map[string]string, files []UploadFile) error {
+	names := make([]string, 0, len(fields))
+	for k := range fields {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		if err := mw.WriteField(k, fields[k]); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range files {
+		typ := f.ContentType
+		if typ == "" {
+			typ = "application/octet-stream"
+		}
+		h := textproto.MIMEHeader{}
+		h.Set("Content-Disposition", `form-data; name="`+quoteEscaper.Replace(f.Field)+
+			`"; filename="`+quoteEscaper.Replace(f.Filename)+`"`)
+		h.Set("Content-Type", typ)
+		w, err := mw.CreatePart(h)
+		if err != nil {
+			return err
+		}
+		var src io.Reader = f.Reader
+		if f.Progress != nil {
+			src = &progressReader{Reader: src, fn: f.Progress}
+		}
+		if _, err := io.Copy(w, src); err != nil {
+			return err
+		}
+	}
+	return mw.Close()
+}
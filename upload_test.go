@@ -0,0 +1,112 @@
+package jsonapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type uploadedFile struct {
+	Filename    string
+	ContentType string
+	Size        int64
+	Sum         string
+}
+
+func uploadServer() *MuxTest {
+	return NewMuxTest([]API{{Pattern: "/api/upload", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		var meta struct{ Album string }
+		if err := httpData.DecodeFormJSON("meta", &meta); err != nil {
+			return nil, err
+		}
+		ret := map[string]interface{}{"album": meta.Album, "user": httpData.Request.FormValue("user")}
+		for _, field := range []string{"photo", "thumbnail"} {
+			f, hdr, err := httpData.MultipartFile(field, 1<<10)
+			if err != nil {
+				return nil, err
+			}
+			h := sha256.New()
+			n, _ := io.Copy(h, f)
+			f.Close()
+			ret[field] = uploadedFile{hdr.Filename, hdr.Header.Get("Content-Type"), n, hex.EncodeToString(h.Sum(nil))}
+		}
+		return ret, nil
+	}}})
+}
+
+func TestClientUpload(t *testing.T) {
+	srv := uploadServer().StartServer()
+	defer srv.Close()
+
+	photo := make([]byte, 3<<20)
+	rand.New(rand.NewSource(1)).Read(photo)
+	thumbnail := []byte("tiny \"thumbnail\"")
+	var progress []int64
+	var ret struct {
+		Album, User      string
+		Photo, Thumbnail uploadedFile
+	}
+	err := NewClient(srv.URL+"/api/").Upload(context.Background(), "upload", map[string]string{
+		"user": "42",
+		"meta": `{"album":"holiday"}`,
+	}, []UploadFile{
+		{Field: "photo", Filename: "beach.png", ContentType: "image/png", Reader: bytes.NewReader(photo),
+			Progress: func(n int64) { progress = append(progress, n) }},
+		{Field: "thumbnail", Filename: `a "quoted" name.bin`, Reader: bytes.NewReader(thumbnail)},
+	}, &ret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ret.Album != "holiday" || ret.User != "42" {
+		t.Errorf("unexpected fields: %+v", ret)
+	}
+	sum := func(b []byte) string {
+		s := sha256.Sum256(b)
+		return hex.EncodeToString(s[:])
+	}
+	if expect := (uploadedFile{"beach.png", "image/png", int64(len(photo)), sum(photo)}); ret.Photo != expect {
+		t.Errorf("photo is corrupted: %+v", ret.Photo)
+	}
+	if expect := (uploadedFile{`a "quoted" name.bin`, "application/octet-stream", int64(len(thumbnail)), sum(thumbnail)}); ret.Thumbnail != expect {
+		t.Errorf("thumbnail is corrupted: %+v", ret.Thumbnail)
+	}
+	if len(progress) < 2 || progress[len(progress)-1] != int64(len(photo)) {
+		t.Errorf("unexpected progress: %v", progress)
+	}
+	for i := 1; i < len(progress); i++ {
+		if progress[i] <= progress[i-1] {
+			t.Errorf("progress is not increasing: %v", progress)
+			break
+		}
+	}
+}
+
+func TestClientUploadErrors(t *testing.T) {
+	srv := uploadServer().StartServer()
+	defer srv.Close()
+	c := NewClient(srv.URL + "/api/")
+
+	err := c.Upload(context.Background(), "upload", map[string]string{"meta": "{}"}, []UploadFile{
+		{Field: "photo", Filename: "a.png", Reader: strings.NewReader("png")},
+	}, nil)
+	if e, ok := err.(Error); !ok || e.Code != http.StatusBadRequest || e.Message != `Missing file "thumbnail"` {
+		t.Errorf("expected Error of server, got %#v", err)
+	}
+
+	broken := errors.New("disk failure")
+	err = c.Upload(context.Background(), "upload", nil, []UploadFile{
+		{Field: "photo", Filename: "a.png", Reader: io.MultiReader(strings.NewReader("png"), &failingReader{ReadCloser: http.NoBody, err: broken})},
+	}, nil)
+	if !errors.Is(err, broken) {
+		t.Errorf("expected error of reader, got %v", err)
+	}
+}
@@ -0,0 +1,166 @@
+package jsonapi
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/token"
+	"net/url"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// URLFor builds URL of route registered with API.Name, substituting wildcards in
+// its pattern with escaped params, and appending query if not empty. Missing or
+// extra params are errors.
+//
+//     // {Name: "user-log", Pattern: "GET /api/user/{id}/log/{date}", ...}
+//     u, err := jsonapi.URLFor("user-log", map[string]string{"id": "42", "date": "2024-06-30"}, nil)
+//     // /api/user/42/log/2024-06-30
+//
+// Values of {name...} wildcards may contain slashes.
+func URLFor(name string, params map[string]string, query url.Values) (string, error) {
+	routesMu.Lock()
+	pattern, ok := named[name]
+	routesMu.Unlock()
+	if !ok {
+		return "", fmt.Errorf("jsonapi: no route named %q", name)
+	}
+
+	used := map[string]bool{}
+	var missing []string
+	path := pathParam.ReplaceAllStringFunc(patternPath(pattern), func(w string) string {
+		w = strings.Trim(w, "{}")
+		if w == "$" {
+			return ""
+		}
+		rest := strings.HasSuffix(w, "...")
+		w = strings.TrimSuffix(w, "...")
+		v, ok := params[w]
+		if !ok {
+			missing = append(missing, w)
+			return ""
+		}
+		used[w] = true
+		if !rest {
+			return url.PathEscape(v)
+		}
+		segs := strings.Split(v, "/")
+		for i := range segs {
+			segs[i] = url.PathEscape(segs[i])
+		}
+		return strings.Join(segs, "/")
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("jsonapi: missing params %s of route %q", strings.Join(missing, ", "), name)
+	}
+	var extra []string
+	for k := range params {
+		if !used[k] {
+			extra = append(extra, k)
+		}
+	}
+	if len(extra) > 0 {
+		sort.Strings(extra)
+		return "", fmt.Errorf("jsonapi: unknown params %s of route %q", strings.Join(extra, ", "), name)
+	}
+
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+	return path, nil
+}
+
+// patternPath strips method and host from pattern
+func patternPath(pattern string) string {
+	if i := strings.IndexAny(pattern, " \t"); i >= 0 {
+		pattern = strings.TrimSpace(pattern[i:])
+	}
+	if i := strings.Index(pattern, "/"); i > 0 {
+		pattern = pattern[i:]
+	}
+	return pattern
+}
+
+// GenerateRouteFile generates Go source of package pkg, with a constant and a URL
+// builder for each named route in apis, so code linking to other routes breaks at
+// compile time instead of rotting when patterns change.
+//
+//     // {Name: "user-log", Pattern: "GET /api/user/{id}/log/{date}"} becomes
+//     const RouteUserLog = "user-log"
+//
+//     func UserLogURL(id, date string, query url.Values) (string, error)
+func GenerateRouteFile(pkg string, apis []API) ([]byte, error) {
+	sorted := make([]API, 0, len(apis))
+	for _, api := range apis {
+		if api.Name != "" {
+			sorted = append(sorted, api)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "// Code generated by jsonapi.GenerateRouteFile. DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	if len(sorted) > 0 {
+		// only URL builders use them, unused imports do not compile
+		fmt.Fprintf(buf, "import (\n\t\"net/url\"\n\n\t\"github.com/Patrolavia/jsonapi\"\n)\n")
+	}
+	for _, api := range sorted {
+		id := camel(api.Name)
+		var params []string
+		for _, w := range pathParam.FindAllString(patternPath(api.Pattern), -1) {
+			if w = strings.TrimSuffix(strings.Trim(w, "{}"), "..."); w != "$" {
+				params = append(params, w)
+			}
+		}
+
+		fmt.Fprintf(buf, "\n// Route%s is name of route %s\nconst Route%s = %q\n", id, api.Pattern, id, api.Name)
+		args := make([]string, len(params))
+		values := make([]string, len(params))
+		for i, p := range params {
+			args[i] = lowerCamel(p)
+			values[i] = fmt.Sprintf("%q: %s", p, args[i])
+		}
+		decl := "query url.Values"
+		if len(args) > 0 {
+			decl = strings.Join(args, ", ") + " string, " + decl
+		}
+		fmt.Fprintf(buf, "\n// %sURL builds URL of route %s\nfunc %sURL(%s) (string, error) {\n", id, api.Pattern, id, decl)
+		fmt.Fprintf(buf, "\treturn jsonapi.URLFor(Route%s, map[string]string{%s}, query)\n}\n", id, strings.Join(values, ", "))
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+// camel converts names like "user-log" or "user_log" into "UserLog"
+func camel(s string) string {
+	buf := &bytes.Buffer{}
+	upper := true
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+// lowerCamel is like camel, but first letter is lowercased, and names used in
+// generated code are avoided
+func lowerCamel(s string) string {
+	s = camel(s)
+	if s == "" {
+		return "p"
+	}
+	s = strings.ToLower(s[:1]) + s[1:]
+	if token.IsKeyword(s) || s == "query" || s == "url" || s == "jsonapi" {
+		s += "Param"
+	}
+	return s
+}
@@ -0,0 +1,96 @@
+package jsonapi
+
+import (
+	"bytes"
+	"flag"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// stubPackages are sources of packages imported by generated code, so it can be
+// type checked without export data
+var stubPackages = map[string]string{
+	"net/url":                       `package url; type Values map[string][]string`,
+	"github.com/Patrolavia/jsonapi": `package jsonapi; import "net/url"; func URLFor(name string, params map[string]string, query url.Values) (string, error) { return "", nil }`,
+}
+
+type stubImporter map[string]*types.Package
+
+func (m stubImporter) Import(path string) (*types.Package, error) {
+	if pkg, ok := m[path]; ok {
+		return pkg, nil
+	}
+	src, ok := stubPackages[path]
+	if !ok {
+		return importer.Default().Import(path)
+	}
+	pkg, err := typeCheck(path, []byte(src), m)
+	m[path] = pkg
+	return pkg, err
+}
+
+func typeCheck(path string, src []byte, imp types.Importer) (*types.Package, error) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, path+".go", src, 0)
+	if err != nil {
+		return nil, err
+	}
+	conf := types.Config{Importer: imp}
+	return conf.Check(path, fset, []*ast.File{f}, nil)
+}
+
+// checkGolden compares got with testdata/name, or updates it with -update flag
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	file := filepath.Join("testdata", name)
+	if *update {
+		if err := ioutil.WriteFile(file, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s, run with -update if intended:\n%s", file, got)
+	}
+}
+
+func TestGenerateRouteFile(t *testing.T) {
+	apis := []API{
+		{Name: "user-log", Pattern: "GET /api/user/{id}/log/{date}"},
+		{Name: "health", Pattern: "/health"},
+		{Name: "files", Pattern: "GET example.com/files/{path...}"},
+		{Name: "url_keyword", Pattern: "/k/{type}/{url}/{$}"},
+		{Pattern: "/unnamed/{id}"},
+	}
+	src, err := GenerateRouteFile("routes", apis)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "routes.golden", src)
+	if _, err := typeCheck("routes", src, stubImporter{}); err != nil {
+		t.Errorf("generated code does not compile: %v\n%s", err, src)
+	}
+}
+
+func TestGenerateRouteFileWithoutNamedRoutes(t *testing.T) {
+	src, err := GenerateRouteFile("routes", []API{{Pattern: "/unnamed"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkGolden(t, "routes_empty.golden", src)
+	if _, err := typeCheck("routes", src, stubImporter{}); err != nil {
+		t.Errorf("generated code does not compile: %v\n%s", err, src)
+	}
+}
@@ -0,0 +1,157 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// VariantTypeField and VariantDataField are property names used to encode registered variants.
+var (
+	VariantTypeField = "type"
+	VariantDataField = "data"
+)
+
+// VariantInline makes registered variants being encoded with discriminator injected
+// into the object itself, instead of wrapping in another object.
+//
+//     {"type": "login", "data": {"user": "john"}} // default
+//     {"type": "login", "user": "john"}           // VariantInline = true
+//
+// Variants not encoded as JSON object are always wrapped.
+var VariantInline bool
+
+var variants = struct {
+	sync.RWMutex
+	names map[reflect.Type]string
+	types map[string]reflect.Type
+}{
+	names: map[reflect.Type]string{},
+	types: map[string]reflect.Type{},
+}
+
+// RegisterVariant registers type of prototype with discriminator.
+//
+// Elements of interface-typed slices in APIHandler results holding a registered type
+// are encoded with discriminator, so clients can tell what they are.
+//
+//     jsonapi.RegisterVariant("login", LoginEvent{})
+//     jsonapi.RegisterVariant("logout", LogoutEvent{})
+//
+//     func events(dec *json.Decoder, httpData *jsonapi.HTTP) (interface{}, error) {
+//         return []interface{}{LoginEvent{"john"}, LogoutEvent{"john"}}, nil
+//     }
+//
+// Pointer to registered type is also recognized. DecodeVariant creates values of
+// the same type as prototype.
+func RegisterVariant(discriminator string, prototype interface{}) {
+	t := reflect.TypeOf(prototype)
+	if t == nil {
+		panic("jsonapi: RegisterVariant with nil prototype")
+	}
+
+	variants.Lock()
+	defer variants.Unlock()
+	variants.names[t] = discriminator
+	if t.Kind() == reflect.Ptr {
+		variants.names[t.Elem()] = discriminator
+	} else {
+		variants.names[reflect.PtrTo(t)] = discriminator
+	}
+	variants.types[discriminator] = t
+}
+
+func hasVariants() bool {
+	variants.RLock()
+	defer variants.RUnlock()
+	return len(variants.types) > 0
+}
+
+func variantName(t reflect.Type) (name string, ok bool) {
+	variants.RLock()
+	defer variants.RUnlock()
+	name, ok = variants.names[t]
+	return
+}
+
+// Variant converts v to the form with discriminator if it is of registered type.
+// It is applied automatically to elements of interface-typed slices in responses,
+// use this to encode variants at other places.
+func Variant(v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return rewriter{variants: true, visiting: &visitSet{}}.variant(reflect.ValueOf(v))
+}
+
+func (w rewriter) variant(v reflect.Value) interface{} {
+	data := w.value(v)
+	name, ok := variantName(v.Type())
+	if !ok {
+		return data
+	}
+
+	if obj, isObj := data.(object); isObj && VariantInline {
+		return append(object{{VariantTypeField, name}}, obj...)
+	}
+	return object{{VariantTypeField, name}, {VariantDataField, data}}
+}
+
+// DecodeVariant decodes a JSON document with discriminator into value of registered type.
+// It returns E400 if the discriminator is missing or not registered.
+func DecodeVariant(data []byte) (interface{}, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, E400.SetData("Variant must be a JSON object")
+	}
+
+	var name string
+	if err := json.Unmarshal(doc[VariantTypeField], &name); err != nil || name == "" {
+		return nil, E400.SetData(fmt.Sprintf("Missing %q property", VariantTypeField))
+	}
+
+	variants.RLock()
+	t, ok := variants.types[name]
+	variants.RUnlock()
+	if !ok {
+		return nil, E400.SetData(fmt.Sprintf("Unknown %s %q", VariantTypeField, name))
+	}
+
+	payload, wrapped := doc[VariantDataField]
+	if !wrapped {
+		payload = data
+	}
+
+	elem := t
+	if t.Kind() == reflect.Ptr {
+		elem = t.Elem()
+	}
+	ptr := reflect.New(elem)
+	if err := json.Unmarshal(payload, ptr.Interface()); err != nil {
+		return nil, E400.SetData(fmt.Sprintf("Cannot decode %q: %s", name, err))
+	}
+
+	if t.Kind() == reflect.Ptr {
+		return ptr.Interface(), nil
+	}
+	return ptr.Elem().Interface(), nil
+}
+
+// DecodeVariants decodes a JSON array of variants from dec, see DecodeVariant.
+func DecodeVariants(dec *json.Decoder) ([]interface{}, error) {
+	var raw []json.RawMessage
+	if err := dec.Decode(&raw); err != nil {
+		return nil, E400.SetData("Variants must be a JSON array")
+	}
+
+	ret := make([]interface{}, len(raw))
+	for i, r := range raw {
+		v, err := DecodeVariant(r)
+		if err != nil {
+			return nil, err
+		}
+		ret[i] = v
+	}
+	return ret, nil
+}
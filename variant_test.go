@@ -0,0 +1,94 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+type loginEvent struct {
+	User string `json:"user"`
+}
+
+type logoutEvent struct {
+	User   string `json:"user"`
+	Reason string `json:"reason"`
+}
+
+// withVariants registers test variants and returns a function undoing it
+func withVariants() func() {
+	RegisterVariant("login", loginEvent{})
+	RegisterVariant("logout", &logoutEvent{})
+	return func() {
+		variants.Lock()
+		defer variants.Unlock()
+		variants.names = map[reflect.Type]string{}
+		variants.types = map[string]reflect.Type{}
+	}
+}
+
+func TestVariantRoundTrip(t *testing.T) {
+	defer withVariants()()
+	events := []interface{}{loginEvent{"john"}, &logoutEvent{"john", "idle"}, "plain"}
+
+	for _, inline := range []bool{false, true} {
+		VariantInline = inline
+		m := NewMuxTest([]API{
+			{Pattern: "/events", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+				return events, nil
+			}},
+			{Pattern: "/echo", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+				vs, err := DecodeVariants(dec)
+				if err != nil {
+					return nil, err
+				}
+				if !reflect.DeepEqual(vs, events[:2]) {
+					t.Errorf("inline %v: decoded %#v", inline, vs)
+				}
+				return vs, nil
+			}},
+		})
+
+		resp, _ := m.Get("/events", "")
+		expect := `[{"type":"login","data":{"user":"john"}},{"type":"logout","data":{"user":"john","reason":"idle"}},"plain"]`
+		if inline {
+			expect = `[{"type":"login","user":"john"},{"type":"logout","user":"john","reason":"idle"},"plain"]`
+		}
+		if actual := resp.Body.String(); actual != expect+"\n" {
+			t.Errorf("inline %v: expected %s, got %s", inline, expect, actual)
+		}
+
+		resp, _ = m.Post("/echo", "", expect[:len(expect)-len(`,"plain"]`)]+"]")
+		if resp.Code != http.StatusOK {
+			t.Errorf("inline %v: echo got %d %s", inline, resp.Code, resp.Body)
+		}
+	}
+	VariantInline = false
+}
+
+func TestDecodeVariantErrors(t *testing.T) {
+	defer withVariants()()
+	m := NewMuxTest([]API{{Pattern: "/", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+		return DecodeVariants(dec)
+	}}})
+
+	for _, body := range []string{
+		`[{"type":"unknown","data":{}}]`,
+		`[{"data":{"user":"john"}}]`,
+		`[{"type":"login","data":{"user":1}}]`,
+		`{"type":"login"}`,
+	} {
+		resp, _ := m.Post("/", "", body)
+		if resp.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected 400, got %d %s", body, resp.Code, resp.Body)
+		}
+	}
+
+	resp, _ := m.Post("/", "", `[{"type":"unknown","data":{}}]`)
+	var body ErrorBody
+	json.Unmarshal(resp.Body.Bytes(), &body)
+	if body.Error.Message != `Unknown type "unknown"` {
+		t.Errorf("unexpected message %q", body.Error.Message)
+	}
+}
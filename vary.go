@@ -0,0 +1,51 @@
+package jsonapi
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Vary records request headers the response varies on, like Accept-Encoding or
+// Authorization. Features of this package register what they depend on, and
+// handlers can add their own. Before the response is written, they are merged
+// with Vary header already set into one de-duplicated, canonicalized header.
+//
+//     httpData.Vary("X-Role")
+func (h *HTTP) Vary(headers ...string) {
+	h.vary = append(h.vary, headers...)
+}
+
+// mergeVary merges values of Vary header in dst with extra
+func mergeVary(dst http.Header, extra []string) {
+	var ret []string
+	seen := map[string]bool{}
+	add := func(v string) {
+		for _, name := range strings.Split(v, ",") {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			if name != "*" {
+				name = http.CanonicalHeaderKey(name)
+			}
+			if !seen[name] {
+				seen[name] = true
+				ret = append(ret, name)
+			}
+		}
+	}
+	for _, v := range dst.Values("Vary") {
+		add(v)
+	}
+	for _, v := range extra {
+		add(v)
+	}
+
+	switch {
+	case len(ret) == 0:
+		return
+	case seen["*"]:
+		dst.Set("Vary", "*")
+	default:
+		dst.Set("Vary", strings.Join(ret, ", "))
+	}
+}
@@ -0,0 +1,43 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestVary(t *testing.T) {
+	defer withCodecs(testCodec{})()
+	auth := Auth(func(token string, h *HTTP) error { return nil })
+	cors := CORS(CORSOptions{Origins: []string{"https://app.example.com"}})
+	m := NewMuxTest([]API{
+		{Pattern: "/all", Encodings: []string{"gzip"}, Middlewares: []Middleware{cors, auth}, APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			httpData.ResponseWriter.Header().Set("Vary", "accept, x-custom")
+			httpData.Vary("x-role", "Authorization", "X-ROLE")
+			return "ok", nil
+		}},
+		{Pattern: "/star", APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			httpData.Vary("*")
+			return "ok", nil
+		}},
+		{Pattern: "/error", Middlewares: []Middleware{auth}, APIHandler: func(dec *json.Decoder, httpData *HTTP) (interface{}, error) {
+			return nil, E404
+		}},
+	})
+
+	cases := map[string]string{
+		"/all":   "Accept, X-Custom, Origin, Authorization, X-Role, Accept-Encoding",
+		"/star":  "*",
+		"/error": "Accept, Authorization",
+	}
+	for uri, expect := range cases {
+		req, _ := http.NewRequest("GET", uri, nil)
+		req.Header.Set("Origin", "https://app.example.com")
+		req.Header.Set("Authorization", "Bearer t")
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp := m.Do(req)
+		if vary := resp.Header().Values("Vary"); len(vary) != 1 || vary[0] != expect {
+			t.Errorf("%s: expected Vary %q, got %q", uri, expect, vary)
+		}
+	}
+}
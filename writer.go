@@ -0,0 +1,142 @@
+package jsonapi
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+)
+
+// responseWriter wraps http.ResponseWriter to finalize headers before they are sent
+type responseWriter struct {
+	http.ResponseWriter
+	h           *HTTP
+	wroteHeader bool
+	hijacked    bool           // connection is hijacked, nothing can be written
+	cw          io.WriteCloser // compressor, see Compression
+	codec       Codec          // negotiated if not JSON, see RegisterCodec
+	transcoded  *bytes.Buffer  // JSON body buffered to be converted by codec
+}
+
+// prepare finalizes headers once, before they are sent with status code. Zero
+// code means no response body is sent.
+func (w *responseWriter) prepare(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	header := w.ResponseWriter.Header()
+	if w.codec != nil && code != 0 && !bodyless(code) && header.Get("Content-Type") == w.codec.ContentType() {
+		w.transcoded = &bytes.Buffer{}
+		header.Del("Content-Length")
+	}
+	w.cw = w.h.compressor(w.ResponseWriter, code)
+	mergeVary(header, w.h.vary)
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.hijacked {
+		return
+	}
+	if code == http.StatusSwitchingProtocols {
+		// protocol upgrade like WebSocket, the response is not JSON
+		w.ResponseWriter.Header().Del("Content-Type")
+	}
+	w.prepare(code)
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.hijacked {
+		return 0, http.ErrHijacked
+	}
+	w.prepare(http.StatusOK)
+	if w.transcoded != nil {
+		return w.transcoded.Write(p)
+	}
+	return w.write(p)
+}
+
+func (w *responseWriter) write(p []byte) (int, error) {
+	if w.cw != nil {
+		return w.cw.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush implements http.Flusher if underlying ResponseWriter supports it
+func (w *responseWriter) Flush() {
+	w.FlushError()
+}
+
+// FlushError is used by http.ResponseController, it returns http.ErrNotSupported
+// if underlying ResponseWriter cannot be flushed.
+func (w *responseWriter) FlushError() error {
+	if w.hijacked {
+		return http.ErrHijacked
+	}
+	w.prepare(http.StatusOK)
+	if w.transcoded != nil {
+		// converted when handler returns
+		return nil
+	}
+	if f, ok := w.cw.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack implements http.Hijacker, it returns http.ErrNotSupported if underlying
+// ResponseWriter cannot be hijacked. The request is marked as Hijacked if it
+// succeeds.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, rw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err == nil {
+		w.hijacked = true
+		w.h.Hijacked()
+	}
+	return conn, rw, err
+}
+
+// Push implements http.Pusher, it returns http.ErrNotSupported if underlying
+// ResponseWriter does not support server push.
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	return push(w.ResponseWriter, target, opts)
+}
+
+// Unwrap returns underlying ResponseWriter, see http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// finish is called after handler returns
+func (w *responseWriter) finish() {
+	if w.hijacked {
+		return
+	}
+	w.prepare(0)
+	if w.transcoded != nil && w.transcoded.Len() > 0 {
+		w.write(transcode(w.codec, w.transcoded.Bytes()))
+	}
+	if w.cw != nil {
+		w.cw.Close()
+	}
+}
+
+// push unwraps w until it finds an http.Pusher, as http.ResponseController has no
+// method to push
+func push(w http.ResponseWriter, target string, opts *http.PushOptions) error {
+	for {
+		if p, ok := w.(http.Pusher); ok {
+			return p.Push(target, opts)
+		}
+		u, ok := w.(unwrapper)
+		if !ok {
+			return http.ErrNotSupported
+		}
+		w = u.Unwrap()
+	}
+}
@@ -0,0 +1,91 @@
+package jsonapi
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// hijackRecorder is a ResponseRecorder supporting Hijacker and Pusher, like the
+// ResponseWriter of http.Server
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	conn   net.Conn // server side of the hijacked connection
+	client net.Conn
+	pushed []string
+}
+
+func newHijackRecorder() *hijackRecorder {
+	conn, client := net.Pipe()
+	return &hijackRecorder{ResponseRecorder: httptest.NewRecorder(), conn: conn, client: client}
+}
+
+func (w *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.conn, bufio.NewReadWriter(bufio.NewReader(w.conn), bufio.NewWriter(w.conn)), nil
+}
+
+func (w *hijackRecorder) Push(target string, opts *http.PushOptions) error {
+	w.pushed = append(w.pushed, target)
+	return nil
+}
+
+func TestResponseWriterInterfaces(t *testing.T) {
+	for _, logged := range []bool{false, true} {
+		if logged {
+			// wraps the writer once more, see SetLogger
+			SetLogger(func(LogEntry) {})
+		}
+		w := newHijackRecorder()
+		var flushErr, pushErr, hijackErr error
+		HTTPHandler(func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+			rw := httpData.ResponseWriter
+			if _, ok := rw.(http.Flusher); !ok {
+				t.Errorf("logged %v: not a Flusher", logged)
+			}
+			if p, ok := rw.(http.Pusher); !ok {
+				t.Errorf("logged %v: not a Pusher", logged)
+			} else {
+				pushErr = p.Push("/app.js", nil)
+			}
+			if _, ok := rw.(http.Hijacker); !ok {
+				t.Errorf("logged %v: not a Hijacker", logged)
+			}
+			rc := http.NewResponseController(rw)
+			enc.Encode("partial")
+			flushErr = rc.Flush()
+			var conn net.Conn
+			conn, _, hijackErr = rc.Hijack()
+			if conn != w.conn {
+				t.Errorf("logged %v: hijacked %v", logged, conn)
+			}
+		}).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		SetLogger(nil)
+
+		if flushErr != nil || !w.Flushed || w.Body.String() != `"partial"`+"\n" {
+			t.Errorf("logged %v: not flushed: %v %q", logged, flushErr, w.Body)
+		}
+		if pushErr != nil || len(w.pushed) != 1 || w.pushed[0] != "/app.js" {
+			t.Errorf("logged %v: not pushed: %v %q", logged, pushErr, w.pushed)
+		}
+		if hijackErr != nil {
+			t.Errorf("logged %v: cannot hijack: %v", logged, hijackErr)
+		}
+		w.conn.Close()
+	}
+}
+
+func TestResponseWriterNotSupported(t *testing.T) {
+	var pushErr, hijackErr error
+	HTTPHandler(func(enc *json.Encoder, dec *json.Decoder, httpData *HTTP) {
+		pushErr = httpData.ResponseWriter.(http.Pusher).Push("/app.js", nil)
+		_, _, hijackErr = http.NewResponseController(httpData.ResponseWriter).Hijack()
+		enc.Encode("ok")
+	}).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if !errors.Is(pushErr, http.ErrNotSupported) || !errors.Is(hijackErr, http.ErrNotSupported) {
+		t.Errorf("expected http.ErrNotSupported, got %v and %v", pushErr, hijackErr)
+	}
+}